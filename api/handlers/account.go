@@ -4,55 +4,62 @@ import (
     "github.com/gofiber/fiber/v2"
     "github.com/google/uuid"
     "gorm.io/gorm"
+    "weriKana/middleware"
     "weriKana/models"
 )
 
-func GetAccount(db *gorm.DB) fiber.Handler {
+// ListAccounts returns every account the caller owns, across all asset types
+func ListAccounts(db *gorm.DB) fiber.Handler {
     return func(c *fiber.Ctx) error {
-        customerIDStr := c.Locals("customer_id").(string) // From JWT middleware
-        sharpIDStr := c.Locals("sharp_id").(string)
-        accountType := c.Locals("account_type").(string)
-        customerID, err := uuid.Parse(customerIDStr)
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
         if err != nil {
             return c.Status(400).JSON(fiber.Map{"error": "Invalid customer_id"})
         }
-        sharpID, err := uuid.Parse(sharpIDStr)
+        refs, err := models.ListAccountRefs(db, customerID)
         if err != nil {
-            return c.Status(400).JSON(fiber.Map{"error": "Invalid sharp_id"})
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to load accounts"})
         }
+        return c.JSON(fiber.Map{"accounts": refs})
+    }
+}
+
+// GetAccount returns the account selected by the :id path parameter
+func GetAccount(db *gorm.DB) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        ref := middleware.Account(c)
         response := fiber.Map{
-            "customer_id":   customerID,
-            "sharp_id":      sharpID,
-            "account_type":  accountType,
+            "customer_id":  ref.CustomerID,
+            "account_id":   ref.ID,
+            "account_type": ref.Type,
         }
-        switch accountType {
+        switch ref.Type {
         case "sharp":
             var acc models.SharpAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).Preload("Sharp").First(&acc).Error; err != nil {
+            if err := db.Preload("Sharp").First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             response["account"] = acc
         case "sports":
             var acc models.SportsAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).Preload("Sharp").First(&acc).Error; err != nil {
+            if err := db.First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             response["account"] = acc
         case "stock":
             var acc models.StockAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).Preload("Sharp").First(&acc).Error; err != nil {
+            if err := db.First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             response["account"] = acc
         case "forex":
             var acc models.ForexAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).Preload("Sharp").First(&acc).Error; err != nil {
+            if err := db.First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             response["account"] = acc
         case "crypto":
             var acc models.CryptoAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).Preload("Sharp").First(&acc).Error; err != nil {
+            if err := db.First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             response["account"] = acc
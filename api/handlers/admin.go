@@ -0,0 +1,153 @@
+// api/handlers/admin.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "weriKana/models"
+    "weriKana/service/backoffice"
+    "weriKana/service/session"
+)
+
+// actorOf builds the back-office actor from the token locals
+func actorOf(c *fiber.Ctx) (backoffice.Actor, error) {
+    id, err := uuid.Parse(c.Locals("customer_id").(string))
+    if err != nil {
+        return backoffice.Actor{}, err
+    }
+    role, _ := c.Locals("role").(string)
+    return backoffice.Actor{ID: id, Role: models.Role(role)}, nil
+}
+
+// pageOf reads ?limit= (1-100, default 50) and ?offset=
+func pageOf(c *fiber.Ctx) (limit, offset int) {
+    limit = c.QueryInt("limit", 50)
+    if limit < 1 || limit > 100 {
+        limit = 50
+    }
+    offset = c.QueryInt("offset", 0)
+    if offset < 0 {
+        offset = 0
+    }
+    return limit, offset
+}
+
+// AdminGetCustomer looks up a customer by id
+func AdminGetCustomer(bo *backoffice.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid customer id"})
+        }
+        customer, err := bo.Customer(id)
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return c.Status(404).JSON(fiber.Map{"error": "Customer not found"})
+        }
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to load customer"})
+        }
+        return c.JSON(customer)
+    }
+}
+
+// AdminListTransactions pages through a customer's transactions
+func AdminListTransactions(bo *backoffice.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid customer id"})
+        }
+        limit, offset := pageOf(c)
+        txs, total, err := bo.Transactions(id, limit, offset)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to load transactions"})
+        }
+        return c.JSON(fiber.Map{"transactions": txs, "total": total, "limit": limit, "offset": offset})
+    }
+}
+
+// AdminReverseTransaction books a reversal for a successful transaction
+func AdminReverseTransaction(bo *backoffice.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        txID, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid transaction id"})
+        }
+        var req struct {
+            Reason string `json:"reason"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+
+        reversal, err := bo.Reverse(actor, txID, req.Reason)
+        switch {
+        case errors.Is(err, gorm.ErrRecordNotFound):
+            return c.Status(404).JSON(fiber.Map{"error": "Transaction not found"})
+        case errors.Is(err, backoffice.ErrReasonRequired):
+            return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, backoffice.ErrNotReversible):
+            return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to reverse transaction"})
+        }
+        return c.JSON(fiber.Map{
+            "status":      "reversed",
+            "reversal_id": reversal.ID,
+            "reference":   reversal.Reference,
+        })
+    }
+}
+
+// AdminSetRole changes a customer's role and logs out their sessions
+func AdminSetRole(bo *backoffice.Service, sessionSvc *session.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid customer id"})
+        }
+        var req struct {
+            Role models.Role `json:"role"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+
+        err = bo.SetRole(actor, id, req.Role)
+        switch {
+        case errors.Is(err, gorm.ErrRecordNotFound):
+            return c.Status(404).JSON(fiber.Map{"error": "Customer not found"})
+        case errors.Is(err, backoffice.ErrInvalidRole), errors.Is(err, backoffice.ErrSelfRoleChange):
+            return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to set role"})
+        }
+        if err := sessionSvc.RevokeAll(id); err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Role changed, but sessions could not be revoked"})
+        }
+        return c.JSON(fiber.Map{"customer_id": id, "role": req.Role})
+    }
+}
+
+// AdminAuditLog pages through back-office actions
+func AdminAuditLog(bo *backoffice.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        limit, offset := pageOf(c)
+        entries, err := bo.AuditLog(limit, offset)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to load audit log"})
+        }
+        return c.JSON(fiber.Map{"entries": entries, "limit": limit, "offset": offset})
+    }
+}
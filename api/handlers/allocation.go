@@ -2,32 +2,47 @@
 package handlers
 
 import (
-    "context"
-    "fmt"
     "math"
-    "time"
+    "weriKana/models"
+    "weriKana/service/fees"
 )
 
-// Bookie struct simplified for allocation
-type Bookie struct {
-    Name           string
-    MpesaNumber    string
-    MinDeposit     int64 // KES in cents (or smallest unit you use)
-    MaxDeposit     int64
-    RecentLogRet   float64 // EWMA of log returns
-    RecentVol      float64 // EWMA volatility
-    CurrentBalance int64
-}
-
-// AllocationResult for each bookie
-type AllocationResult struct {
-    Bookie      Bookie
+// BookieAllocation is the planned transfer to one bookie
+type BookieAllocation struct {
+    Bookie       models.Bookie
     AmountToSend int64
     Reason       string
+    Fee          models.FeeQuote // set by QuoteAllocations for previews
+}
+
+// QuoteAllocations prices each planned real-money deposit with the bookie's
+// deposit fee, so a preview shows what will be charged and credited
+func QuoteAllocations(feeSvc *fees.Service, allocs []BookieAllocation) error {
+    for i := range allocs {
+        if allocs[i].AmountToSend <= 0 {
+            allocs[i].Fee = models.NoFee(models.FeeDeposit, 0)
+            continue
+        }
+        q, err := feeSvc.Quote(models.FeeDeposit, allocs[i].Bookie.ID, allocs[i].AmountToSend)
+        if err != nil {
+            return err
+        }
+        allocs[i].Fee = q
+    }
+    return nil
+}
+
+// maxDeposit is the bookie's deposit ceiling; the catalog uses 0 for none
+func maxDeposit(b models.Bookie) int64 {
+    if b.MaxDepositCents == 0 {
+        return math.MaxInt64
+    }
+    return b.MaxDepositCents
 }
 
-// AllocateFunds computes the planned transfers
-func AllocateFunds(totalBalance int64, reservePct float64, bookies []Bookie, beta float64, minSend int64) []AllocationResult {
+// AllocateFunds computes the planned transfers across catalog bookies
+// (see bookies.Service.ForAllocation), within each one's deposit bounds
+func AllocateFunds(totalBalance int64, reservePct float64, bookies []models.Bookie, beta float64, minSend int64) []BookieAllocation {
     // 1. Reserve buffer
     reserve := int64(float64(totalBalance) * reservePct)
     allocatable := totalBalance - reserve
@@ -39,9 +54,9 @@ func AllocateFunds(totalBalance int64, reservePct float64, bookies []Bookie, bet
     risk := make([]float64, n)
     for i, b := range bookies {
         // Perf weight: positive part of EWMA log return
-        perf[i] = math.Max(0, b.RecentLogRet)
+        perf[i] = math.Max(0, b.RecentLogReturn)
         // Risk weight: inverse vol (avoid division by zero)
-        vol := math.Max(b.RecentVol, 1e-6)
+        vol := math.Max(b.RecentVolatility, 1e-6)
         risk[i] = 1.0 / vol
     }
 
@@ -70,23 +85,23 @@ func AllocateFunds(totalBalance int64, reservePct float64, bookies []Bookie, bet
     scores = norm(scores)
 
     // 5. Map scores -> amounts (apply min constraints)
-    results := make([]AllocationResult, 0, n)
+    results := make([]BookieAllocation, 0, n)
     remaining := allocatable
     for i, b := range bookies {
         amt := int64(math.Floor(float64(allocatable) * scores[i]))
         // enforce min deposit
-        if amt > 0 && amt < b.MinDeposit { 
+        if amt > 0 && amt < b.MinDepositCents {
             // if below min, round up to min if funds permit, otherwise zero
-            if remaining >= b.MinDeposit {
-                amt = b.MinDeposit
+            if remaining >= b.MinDepositCents {
+                amt = b.MinDepositCents
             } else {
                 amt = 0
             }
         }
-        if amt > b.MaxDeposit { amt = b.MaxDeposit }
+        if amt > maxDeposit(b) { amt = maxDeposit(b) }
         if amt < minSend { amt = 0 } // skip tiny transfers
         remaining -= amt
-        results = append(results, AllocationResult{Bookie: b, AmountToSend: amt})
+        results = append(results, BookieAllocation{Bookie: b, AmountToSend: amt})
     }
 
     // If rounding left some remainder, distribute it to highest scores
@@ -98,7 +113,7 @@ func AllocateFunds(totalBalance int64, reservePct float64, bookies []Bookie, bet
             for j := 1; j < n; j++ {
                 if scores[j] > scores[topIdx] { topIdx = j }
             }
-            inc := int64(math.Min(float64(remaining), float64(bookies[topIdx].MaxDeposit - results[topIdx].AmountToSend)))
+            inc := int64(math.Min(float64(remaining), float64(maxDeposit(bookies[topIdx]) - results[topIdx].AmountToSend)))
             if inc <= 0 { break }
             results[topIdx].AmountToSend += inc
             remaining -= inc
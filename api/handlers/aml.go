@@ -0,0 +1,149 @@
+// api/handlers/aml.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+    "weriKana/service/aml"
+)
+
+// amlError maps AML case errors to responses
+func amlError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, aml.ErrCaseNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, aml.ErrCaseClosed), errors.Is(err, aml.ErrInvalidTransition):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, aml.ErrNoteRequired), errors.Is(err, aml.ErrInvalidAssignee):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "AML case request failed"})
+    }
+}
+
+// AdminListAMLCases lists alert cases (?status=, ?assignee=, ?customer= filter)
+func AdminListAMLCases(svc *aml.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        limit, offset := pageOf(c)
+        assignee, err := optionalUUID(c.Query("assignee"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid assignee id"})
+        }
+        customer, err := optionalUUID(c.Query("customer"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid customer id"})
+        }
+        filter := aml.CaseFilter{Status: models.AMLCaseStatus(c.Query("status")), AssigneeID: assignee, CustomerID: customer}
+        cases, total, err := svc.Cases(filter, limit, offset)
+        if err != nil {
+            return amlError(c, err)
+        }
+        return c.JSON(fiber.Map{"cases": cases, "total": total, "limit": limit, "offset": offset})
+    }
+}
+
+// AdminGetAMLCase returns a case with its notes
+func AdminGetAMLCase(svc *aml.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid case id"})
+        }
+        amlCase, notes, err := svc.Case(id)
+        if err != nil {
+            return amlError(c, err)
+        }
+        return c.JSON(fiber.Map{"case": amlCase, "notes": notes})
+    }
+}
+
+// AdminAssignAMLCase sets or clears a case's assignee
+func AdminAssignAMLCase(svc *aml.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid case id"})
+        }
+        var req struct {
+            AssigneeID *uuid.UUID `json:"assignee_id"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        amlCase, err := svc.Assign(actor, id, req.AssigneeID)
+        if err != nil {
+            return amlError(c, err)
+        }
+        return c.JSON(amlCase)
+    }
+}
+
+// AdminSetAMLCaseStatus moves a case through review with an explanatory note
+func AdminSetAMLCaseStatus(svc *aml.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid case id"})
+        }
+        var req struct {
+            Status models.AMLCaseStatus `json:"status"`
+            Note   string               `json:"note"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        amlCase, err := svc.SetStatus(actor, id, req.Status, req.Note)
+        if err != nil {
+            return amlError(c, err)
+        }
+        return c.JSON(amlCase)
+    }
+}
+
+// AdminAddAMLCaseNote appends a note to an open case
+func AdminAddAMLCaseNote(svc *aml.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid case id"})
+        }
+        var req struct {
+            Body string `json:"body"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        note, err := svc.AddNote(actor, id, req.Body)
+        if err != nil {
+            return amlError(c, err)
+        }
+        return c.Status(201).JSON(note)
+    }
+}
+
+// optionalUUID parses s, treating an empty string as absent
+func optionalUUID(s string) (*uuid.UUID, error) {
+    if s == "" {
+        return nil, nil
+    }
+    id, err := uuid.Parse(s)
+    if err != nil {
+        return nil, err
+    }
+    return &id, nil
+}
@@ -0,0 +1,86 @@
+// api/handlers/api_keys.go
+package handlers
+
+import (
+    "errors"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/service/apikeys"
+)
+
+// CreateAPIKey issues a scoped key for a bot or partner; the raw key is returned once
+func CreateAPIKey(apiKeySvc *apikeys.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req struct {
+            Name       string     `json:"name"`
+            Scopes     []string   `json:"scopes"`
+            AllowedIPs []string   `json:"allowed_ips"`
+            ExpiresAt  *time.Time `json:"expires_at"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        if len(req.Name) > 100 {
+            return c.Status(400).JSON(fiber.Map{"error": "name too long"})
+        }
+
+        key, raw, err := apiKeySvc.Create(customerID, apikeys.CreateParams{
+            Name:       req.Name,
+            Scopes:     req.Scopes,
+            AllowedIPs: req.AllowedIPs,
+            ExpiresAt:  req.ExpiresAt,
+        })
+        switch {
+        case errors.Is(err, apikeys.ErrInvalidScopes), errors.Is(err, apikeys.ErrInvalidAllowlist), errors.Is(err, apikeys.ErrInvalidExpiry):
+            return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, apikeys.ErrTooManyKeys):
+            return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to create API key"})
+        }
+        return c.Status(201).JSON(fiber.Map{"api_key": raw, "key": key})
+    }
+}
+
+// ListAPIKeys returns the caller's unrevoked keys (never the secrets)
+func ListAPIKeys(apiKeySvc *apikeys.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        keys, err := apiKeySvc.List(customerID)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to load API keys"})
+        }
+        return c.JSON(fiber.Map{"api_keys": keys})
+    }
+}
+
+// RevokeAPIKey disables one of the caller's keys immediately
+func RevokeAPIKey(apiKeySvc *apikeys.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        keyID, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid key id"})
+        }
+        err = apiKeySvc.Revoke(customerID, keyID)
+        switch {
+        case errors.Is(err, apikeys.ErrKeyNotFound):
+            return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke API key"})
+        }
+        return c.JSON(fiber.Map{"status": "revoked"})
+    }
+}
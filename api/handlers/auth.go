@@ -0,0 +1,133 @@
+// api/handlers/auth.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/service/auth"
+    "weriKana/service/otp"
+    "weriKana/service/session"
+)
+
+// Register creates a customer with a password
+func Register(authSvc *auth.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        var req struct {
+            Name     string `json:"name"`
+            Email    string `json:"email"`
+            Phone    string `json:"phone"`
+            Password string `json:"password"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        if req.Name == "" {
+            return c.Status(400).JSON(fiber.Map{"error": "name is required"})
+        }
+
+        customer, err := authSvc.Register(req.Name, req.Email, req.Phone, req.Password)
+        switch {
+        case errors.Is(err, auth.ErrAlreadyRegistered):
+            return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+        }
+        return c.Status(201).JSON(fiber.Map{
+            "customer_id": customer.ID,
+            "email":       customer.Email,
+            "phone":       customer.Phone,
+        })
+    }
+}
+
+// ChangePassword replaces the logged-in customer's password and logs out
+// every other session
+func ChangePassword(authSvc *auth.Service, sessionSvc *session.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        sessionID, err := uuid.Parse(c.Locals("session_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req struct {
+            CurrentPassword string `json:"current_password"`
+            NewPassword     string `json:"new_password"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+
+        err = authSvc.ChangePassword(customerID, req.CurrentPassword, req.NewPassword)
+        switch {
+        case errors.Is(err, auth.ErrWeakPassword):
+            return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, auth.ErrAccountLocked):
+            return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, auth.ErrInvalidCredentials):
+            return c.Status(401).JSON(fiber.Map{"error": "Current password is incorrect"})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to change password"})
+        }
+        if err := sessionSvc.RevokeOthers(customerID, sessionID); err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Password changed, but other sessions could not be revoked"})
+        }
+        return c.JSON(fiber.Map{"status": "password_changed"})
+    }
+}
+
+// RequestPasswordReset texts a reset OTP; the response is the same whether or not the customer exists
+func RequestPasswordReset(authSvc *auth.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        var req struct {
+            Identifier string `json:"identifier"` // phone or email
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+
+        if err := authSvc.RequestPasswordReset(req.Identifier); err != nil {
+            switch {
+            case errors.Is(err, otp.ErrCooldown), errors.Is(err, otp.ErrLocked):
+                return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+            default:
+                return c.Status(500).JSON(fiber.Map{"error": "Failed to send OTP"})
+            }
+        }
+        return c.JSON(fiber.Map{"status": "otp_sent_if_registered"})
+    }
+}
+
+// ResetPassword sets a new password with a reset OTP and logs out every session
+func ResetPassword(authSvc *auth.Service, sessionSvc *session.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        var req struct {
+            Identifier  string `json:"identifier"`
+            OTP         string `json:"otp"`
+            NewPassword string `json:"new_password"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+
+        customerID, err := authSvc.ResetPassword(req.Identifier, req.OTP, req.NewPassword)
+        switch {
+        case errors.Is(err, auth.ErrWeakPassword):
+            return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, otp.ErrLocked):
+            return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, otp.ErrInvalidCode):
+            return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to reset password"})
+        }
+        if err := sessionSvc.RevokeAll(customerID); err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Password reset, but sessions could not be revoked"})
+        }
+        return c.JSON(fiber.Map{"status": "password_reset"})
+    }
+}
@@ -0,0 +1,114 @@
+// api/handlers/bookie_accounts.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+    "weriKana/service/bookieaccounts"
+)
+
+// bookieAccountError maps bookie account linking errors to responses
+func bookieAccountError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, bookieaccounts.ErrAccountNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, bookieaccounts.ErrAlreadyLinked), errors.Is(err, bookieaccounts.ErrNoNexus):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, bookieaccounts.ErrInvalidLink), errors.Is(err, bookieaccounts.ErrBookieUnavailable):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "Bookie account request failed"})
+    }
+}
+
+// linkResponse returns the link, or a 502 carrying it when the test deposit
+// could not be started
+func linkResponse(c *fiber.Ctx, status int, a *models.SportsAccount, err error) error {
+    if errors.Is(err, bookieaccounts.ErrTestDepositFailed) && a != nil {
+        return c.Status(502).JSON(fiber.Map{"error": err.Error(), "account": bookieaccounts.NewView(a)})
+    }
+    if err != nil {
+        return bookieAccountError(c, err)
+    }
+    return c.Status(status).JSON(bookieaccounts.NewView(a))
+}
+
+// LinkBookieAccount links one of the caller's bookie accounts and prompts
+// its M-Pesa number for a test deposit
+func LinkBookieAccount(svc *bookieaccounts.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req bookieaccounts.LinkRequest
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        a, err := svc.Link(c.UserContext(), customerID, req)
+        return linkResponse(c, 201, a, err)
+    }
+}
+
+// ListBookieAccounts returns the caller's bookie accounts without credentials
+func ListBookieAccounts(svc *bookieaccounts.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        list, err := svc.List(customerID)
+        if err != nil {
+            return bookieAccountError(c, err)
+        }
+        views := make([]bookieaccounts.View, 0, len(list))
+        for i := range list {
+            views = append(views, bookieaccounts.NewView(&list[i]))
+        }
+        return c.JSON(fiber.Map{"accounts": views})
+    }
+}
+
+// DeactivateBookieAccount stops deposits to and withdrawals from a link
+func DeactivateBookieAccount(svc *bookieaccounts.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, accountID, err := bookieAccountParams(c)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid account id"})
+        }
+        a, err := svc.Deactivate(customerID, accountID)
+        if err != nil {
+            return bookieAccountError(c, err)
+        }
+        return c.JSON(bookieaccounts.NewView(a))
+    }
+}
+
+// RelinkBookieAccount replaces a link's number or credentials and verifies
+// it again
+func RelinkBookieAccount(svc *bookieaccounts.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, accountID, err := bookieAccountParams(c)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid account id"})
+        }
+        var req bookieaccounts.RelinkRequest
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        a, err := svc.Relink(c.UserContext(), customerID, accountID, req)
+        return linkResponse(c, 200, a, err)
+    }
+}
+
+// bookieAccountParams reads the caller and the :id account from the request
+func bookieAccountParams(c *fiber.Ctx) (customerID, accountID uuid.UUID, err error) {
+    if customerID, err = uuid.Parse(c.Locals("customer_id").(string)); err != nil {
+        return
+    }
+    accountID, err = uuid.Parse(c.Params("id"))
+    return
+}
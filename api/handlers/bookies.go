@@ -0,0 +1,111 @@
+// api/handlers/bookies.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+    "weriKana/service/bookies"
+)
+
+// bookieError maps bookie catalog errors to responses
+func bookieError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, bookies.ErrBookieNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, bookies.ErrBookieInUse):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, models.ErrInvalidBookie):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "Bookie request failed"})
+    }
+}
+
+// AdminListBookies returns the catalog (?include_inactive=true for all)
+func AdminListBookies(svc *bookies.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        list, err := svc.List(c.QueryBool("include_inactive"))
+        if err != nil {
+            return bookieError(c, err)
+        }
+        return c.JSON(fiber.Map{"bookies": list})
+    }
+}
+
+// AdminGetBookie returns one catalog entry
+func AdminGetBookie(svc *bookies.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid bookie id"})
+        }
+        b, err := svc.Get(id)
+        if err != nil {
+            return bookieError(c, err)
+        }
+        return c.JSON(b)
+    }
+}
+
+// AdminCreateBookie adds a bookie; it is active unless is_active is false
+func AdminCreateBookie(svc *bookies.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        req := models.Bookie{IsActive: true}
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        b, err := svc.Create(actor, req)
+        if err != nil {
+            return bookieError(c, err)
+        }
+        return c.Status(201).JSON(b)
+    }
+}
+
+// AdminUpdateBookie replaces a bookie's catalog settings
+func AdminUpdateBookie(svc *bookies.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid bookie id"})
+        }
+        req := models.Bookie{IsActive: true}
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        b, err := svc.Update(actor, id, req)
+        if err != nil {
+            return bookieError(c, err)
+        }
+        return c.JSON(b)
+    }
+}
+
+// AdminDeleteBookie removes a bookie no active account uses
+func AdminDeleteBookie(svc *bookies.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid bookie id"})
+        }
+        if err := svc.Delete(actor, id); err != nil {
+            return bookieError(c, err)
+        }
+        return c.SendStatus(204)
+    }
+}
@@ -1,42 +1,35 @@
+// api/handlers/customers.go
 package handlers
 
 import (
-	"net/http"
+    "errors"
 
-	"github.com/goremit/money-transfer/db"
+    "github.com/gofiber/fiber/v2"
+    "weriKana/service/auth"
+    "weriKana/service/onboarding"
 )
-type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-}
-
-type Account struct {
-	UserID     int     `json:"user_id"`
-	BookieName string  `json:"bookie_name"`
-	RealBal    float64 `json:"real_balance"` // Encrypted in DB
-	FakeBal    float64 `json:"fake_balance"`
-}
-
-type Customers struct {
-	transactionsRepo db.TransactionsRepo
-}
-
-func NewCustomers(t db.TransactionsRepo) *Customers {
-	return &Customers{transactionsRepo: t}
-}
-
-func (c *Customers) HandleShowTransaction(w http.ResponseWriter, r *http.Request) {
-	//
-}
-
-func (c *Customers) HandleCreateTransaction(w http.ResponseWriter, r *http.Request) {
-	//
-}
-
-func (c *Customers) HandleFundTransaction(w http.ResponseWriter, r *http.Request) {
-	//
-}
 
-func (c *Customers) HandleCancelTransaction(w http.ResponseWriter, r *http.Request) {
-	//
+// CreateCustomer onboards a customer with their asset nexus, sharp profiles and
+// requested accounts, all in one transaction, and returns the created graph
+func CreateCustomer(onboardSvc *onboarding.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        var req onboarding.Request
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+
+        graph, err := onboardSvc.Onboard(req)
+        switch {
+        case errors.Is(err, auth.ErrAlreadyRegistered):
+            return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, onboarding.ErrInvalidCustomer), errors.Is(err, onboarding.ErrInvalidAccount),
+            errors.Is(err, onboarding.ErrDuplicateAccount), errors.Is(err, auth.ErrWeakPassword):
+            return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, onboarding.ErrUnknownProvider):
+            return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to create customer"})
+        }
+        return c.Status(201).JSON(graph)
+    }
 }
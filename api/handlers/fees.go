@@ -0,0 +1,121 @@
+// api/handlers/fees.go
+package handlers
+
+import (
+    "errors"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+    "weriKana/service/fees"
+)
+
+// feeError maps fee schedule and quote errors to responses
+func feeError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, fees.ErrScheduleNotFound), errors.Is(err, fees.ErrAccountNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, fees.ErrDuplicateSchedule):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, models.ErrInvalidFeeSchedule), errors.Is(err, models.ErrOutsideTariff),
+        errors.Is(err, models.ErrFeeExceedsAmount):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "Fee request failed"})
+    }
+}
+
+// AdminListFeeSchedules returns every fee schedule, enabled or not
+func AdminListFeeSchedules(svc *fees.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        list, err := svc.Schedules()
+        if err != nil {
+            return feeError(c, err)
+        }
+        return c.JSON(fiber.Map{"schedules": list})
+    }
+}
+
+// AdminCreateFeeSchedule adds a fee schedule
+func AdminCreateFeeSchedule(svc *fees.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req models.FeeSchedule
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        f, err := svc.CreateSchedule(actor, req)
+        if err != nil {
+            return feeError(c, err)
+        }
+        return c.Status(201).JSON(f)
+    }
+}
+
+// AdminUpdateFeeSchedule replaces a fee schedule's settings
+func AdminUpdateFeeSchedule(svc *fees.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid schedule id"})
+        }
+        var req models.FeeSchedule
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        f, err := svc.UpdateSchedule(actor, id, req)
+        if err != nil {
+            return feeError(c, err)
+        }
+        return c.JSON(f)
+    }
+}
+
+// AdminDeleteFeeSchedule removes a fee schedule
+func AdminDeleteFeeSchedule(svc *fees.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid schedule id"})
+        }
+        if err := svc.DeleteSchedule(actor, id); err != nil {
+            return feeError(c, err)
+        }
+        return c.SendStatus(204)
+    }
+}
+
+// AdminFeeRevenue totals fee revenue between ?from and ?to (RFC 3339),
+// the last 30 days by default
+func AdminFeeRevenue(svc *fees.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        to := time.Now()
+        from := to.AddDate(0, 0, -30)
+        for param, t := range map[string]*time.Time{"from": &from, "to": &to} {
+            if v := c.Query(param); v != "" {
+                parsed, err := time.Parse(time.RFC3339, v)
+                if err != nil {
+                    return c.Status(400).JSON(fiber.Map{"error": "Invalid " + param + " time"})
+                }
+                *t = parsed
+            }
+        }
+        totals, err := svc.Revenue(from, to)
+        if err != nil {
+            return feeError(c, err)
+        }
+        return c.JSON(fiber.Map{"from": from, "to": to, "revenue": totals})
+    }
+}
@@ -0,0 +1,105 @@
+// api/handlers/fx.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "weriKana/middleware"
+    "weriKana/models"
+    "weriKana/service/fx"
+)
+
+// fxError maps currency and conversion errors to responses
+func fxError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, fx.ErrQuoteNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, fx.ErrQuoteUsed):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, fx.ErrQuoteExpired), errors.Is(err, fx.ErrSameCurrency), errors.Is(err, fx.ErrAmountTooSmall),
+        errors.Is(err, models.ErrUnknownCurrency), errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInsufficientFunds):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, fx.ErrNoRate), errors.Is(err, fx.ErrStaleRate):
+        return c.Status(503).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "Conversion failed"})
+    }
+}
+
+// GetAccountBalances lists the account's balances in every currency it holds
+func GetAccountBalances(db *gorm.DB) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        ref := middleware.Account(c) // resolved from /accounts/:id
+        list, err := models.CurrencyBalances(db, ref.Type, ref.ID)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to load balances"})
+        }
+        balances := make([]fiber.Map, 0, len(list))
+        for _, b := range list {
+            amount, _ := models.FormatAmount(b.Currency, b.AmountCents)
+            balances = append(balances, fiber.Map{
+                "currency":     b.Currency,
+                "is_real":      b.IsReal,
+                "amount_cents": b.AmountCents, // minor units of the currency
+                "amount":       amount,
+            })
+        }
+        return c.JSON(fiber.Map{"account_id": ref.ID, "balances": balances})
+    }
+}
+
+// QuoteConversion prices selling an amount of one currency the account holds
+// for another. The amount is a decimal string in the currency sold, e.g.
+// "1250.50"; the quote is held for a short time.
+func QuoteConversion(fxSvc *fx.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        var req struct {
+            From   string `json:"from"`
+            To     string `json:"to"`
+            Amount string `json:"amount"`
+            IsReal bool   `json:"is_real"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        amountCents, err := models.ParseAmount(req.From, req.Amount)
+        if err != nil {
+            return fxError(c, err)
+        }
+        q, err := fxSvc.Quote(middleware.Account(c), req.IsReal, req.From, req.To, amountCents)
+        if err != nil {
+            return fxError(c, err)
+        }
+        return c.Status(201).JSON(q)
+    }
+}
+
+// ExecuteConversion converts at a rate quoted on this account
+func ExecuteConversion(fxSvc *fx.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        var req struct {
+            QuoteID uuid.UUID `json:"quote_id"`
+            IsReal  bool      `json:"is_real"` // must match the quote
+        }
+        if err := c.BodyParser(&req); err != nil || req.QuoteID == uuid.Nil {
+            return c.Status(400).JSON(fiber.Map{"error": "quote_id is required"})
+        }
+        t, err := fxSvc.Convert(middleware.Account(c), req.IsReal, req.QuoteID)
+        if err != nil {
+            return fxError(c, err)
+        }
+        return c.JSON(fiber.Map{
+            "transaction_id":   t.ID,
+            "reference":        t.Reference,
+            "currency":         t.Currency,
+            "amount_cents":     t.AmountCents,
+            "counter_currency": t.CounterCurrency,
+            "counter_cents":    t.CounterCents,
+            "rate":             t.FXRate,
+            "spread_cents":     t.FXSpreadCents,
+        })
+    }
+}
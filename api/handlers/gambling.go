@@ -0,0 +1,112 @@
+// api/handlers/gambling.go
+package handlers
+
+import (
+    "errors"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+    "weriKana/service/gambling"
+)
+
+// gamblingError maps player-protection errors to responses
+func gamblingError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, gambling.ErrLimitNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, gambling.ErrAlreadyExcluded):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, gambling.ErrInvalidLimit), errors.Is(err, gambling.ErrInvalidExclusion),
+        errors.Is(err, gambling.ErrInvalidRealityCheck):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "Player protection request failed"})
+    }
+}
+
+// GetPlayerProtection returns the caller's limits, any cool-off or
+// self-exclusion in force and their reality-check interval
+func GetPlayerProtection(svc *gambling.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        status, err := svc.Status(customerID)
+        if err != nil {
+            return gamblingError(c, err)
+        }
+        return c.JSON(status)
+    }
+}
+
+// SetGamblingLimit sets a deposit or loss limit; raising or removing one
+// (amount_cents 0) takes effect after a delay
+func SetGamblingLimit(svc *gambling.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req struct {
+            Kind        models.GamblingLimitKind `json:"kind"`
+            Period      models.GamblingPeriod    `json:"period"`
+            AmountCents int64                    `json:"amount_cents"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        limit, err := svc.SetLimit(customerID, req.Kind, req.Period, req.AmountCents)
+        if err != nil {
+            return gamblingError(c, err)
+        }
+        return c.JSON(limit)
+    }
+}
+
+// StartExclusion starts a cool-off or self-exclusion; neither can be undone
+func StartExclusion(svc *gambling.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req struct {
+            Kind   models.GamblingExclusionKind `json:"kind"`
+            Days   int                          `json:"days"`
+            Reason string                       `json:"reason"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        exclusion, err := svc.Exclude(customerID, req.Kind, time.Duration(req.Days)*24*time.Hour, req.Reason)
+        if err != nil {
+            return gamblingError(c, err)
+        }
+        return c.Status(201).JSON(exclusion)
+    }
+}
+
+// SetRealityCheck sets how often the caller is reminded of their real-money
+// play (0 turns reminders off)
+func SetRealityCheck(svc *gambling.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req struct {
+            IntervalMinutes int `json:"interval_minutes"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        rc, err := svc.SetRealityCheck(customerID, req.IntervalMinutes)
+        if err != nil {
+            return gamblingError(c, err)
+        }
+        return c.JSON(rc)
+    }
+}
@@ -0,0 +1,15 @@
+// api/handlers/jwks.go
+package handlers
+
+import (
+    "github.com/gofiber/fiber/v2"
+    "weriKana/service/jwtkeys"
+)
+
+// JWKS publishes the public token-verification keys for other services
+func JWKS(keys *jwtkeys.KeySet) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+        return c.JSON(keys.JWKS())
+    }
+}
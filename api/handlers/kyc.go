@@ -0,0 +1,180 @@
+// api/handlers/kyc.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+    "weriKana/service/kyc"
+)
+
+// kycError maps KYC service errors to responses
+func kycError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, kyc.ErrSubjectNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, kyc.ErrLocked), errors.Is(err, kyc.ErrInvalidTransition):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, kyc.ErrNoDocuments), errors.Is(err, kyc.ErrInvalidDocument), errors.Is(err, kyc.ErrNameRequired),
+        errors.Is(err, models.ErrUnsupportedIDType), errors.Is(err, models.ErrInvalidIDNumber),
+        errors.Is(err, models.ErrIDExpired), errors.Is(err, models.ErrIDExpiryRequired),
+        errors.Is(err, models.ErrBirthDateRequired), errors.Is(err, models.ErrUnderage):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "KYC request failed"})
+    }
+}
+
+// GetKYC returns the caller's KYC status and sender documents
+func GetKYC(kycSvc *kyc.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        status, err := kycSvc.Status(customerID)
+        if err != nil {
+            return kycError(c, err)
+        }
+        docs, err := kycSvc.Documents(customerID, kyc.Subject{Type: models.KYCSubjectSender})
+        if err != nil && !errors.Is(err, kyc.ErrSubjectNotFound) {
+            return kycError(c, err)
+        }
+        return c.JSON(fiber.Map{"kyc_status": status, "documents": docs})
+    }
+}
+
+// SaveSenderKYC records the caller's own identity details
+func SaveSenderKYC(kycSvc *kyc.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req kyc.SenderDetails
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        sender, err := kycSvc.SaveSender(customerID, req)
+        if err != nil {
+            return kycError(c, err)
+        }
+        return c.JSON(fiber.Map{"sender_id": sender.ID, "kyc_status": sender.KYCStatus})
+    }
+}
+
+// SaveRecipientKYC records identity details for one of the caller's recipients
+func SaveRecipientKYC(kycSvc *kyc.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        recipientID, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid recipient id"})
+        }
+        var req models.Identity
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        if err := kycSvc.SaveRecipientIdentity(customerID, recipientID, req); err != nil {
+            return kycError(c, err)
+        }
+        return c.JSON(fiber.Map{"status": "saved"})
+    }
+}
+
+// AddKYCDocument records metadata for a document already uploaded to storage
+func AddKYCDocument(kycSvc *kyc.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req struct {
+            kyc.Subject
+            Kind        string `json:"kind"`
+            StorageKey  string `json:"storage_key"`
+            SHA256      string `json:"sha256"`
+            ContentType string `json:"content_type"`
+            SizeBytes   int64  `json:"size_bytes"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        doc, err := kycSvc.AddDocument(customerID, req.Subject, models.KYCDocument{
+            Kind:        req.Kind,
+            StorageKey:  req.StorageKey,
+            SHA256:      req.SHA256,
+            ContentType: req.ContentType,
+            SizeBytes:   req.SizeBytes,
+        })
+        if err != nil {
+            return kycError(c, err)
+        }
+        return c.Status(201).JSON(doc)
+    }
+}
+
+// SubmitKYC sends a sender or recipient for review
+func SubmitKYC(kycSvc *kyc.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req kyc.Subject
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        if err := kycSvc.Submit(customerID, req); err != nil {
+            return kycError(c, err)
+        }
+        return c.JSON(fiber.Map{"kyc_status": models.KYCPending})
+    }
+}
+
+// AdminPendingKYC lists submissions awaiting review (?type=sender|recipient)
+func AdminPendingKYC(kycSvc *kyc.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        limit, offset := pageOf(c)
+        subjectType := models.KYCSubject(c.Query("type", string(models.KYCSubjectSender)))
+        records, err := kycSvc.Pending(subjectType, limit, offset)
+        if err != nil {
+            return kycError(c, err)
+        }
+        return c.JSON(fiber.Map{"pending": records, "limit": limit, "offset": offset})
+    }
+}
+
+// AdminReviewKYC approves or rejects a pending submission
+func AdminReviewKYC(kycSvc *kyc.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        subjectID, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid subject id"})
+        }
+        var req struct {
+            Approve bool   `json:"approve"`
+            Reason  string `json:"reason"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        if !req.Approve && req.Reason == "" {
+            return c.Status(400).JSON(fiber.Map{"error": "reason is required when rejecting"})
+        }
+        status, err := kycSvc.Review(actor, kyc.Subject{Type: models.KYCSubject(c.Params("type")), ID: subjectID}, req.Approve, req.Reason)
+        if err != nil {
+            return kycError(c, err)
+        }
+        return c.JSON(fiber.Map{"kyc_status": status})
+    }
+}
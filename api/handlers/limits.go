@@ -0,0 +1,94 @@
+// api/handlers/limits.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+    "weriKana/service/limits"
+)
+
+// limitError maps limit rule errors to responses
+func limitError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, limits.ErrRuleNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, models.ErrInvalidLimitRule):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "Limit rule request failed"})
+    }
+}
+
+// AdminListLimits returns every limit rule, enabled or not
+func AdminListLimits(svc *limits.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        rules, err := svc.Rules()
+        if err != nil {
+            return limitError(c, err)
+        }
+        return c.JSON(fiber.Map{"rules": rules})
+    }
+}
+
+// AdminCreateLimit adds a limit rule
+func AdminCreateLimit(svc *limits.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req models.LimitRule
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        rule, err := svc.CreateRule(actor, req)
+        if err != nil {
+            return limitError(c, err)
+        }
+        return c.Status(201).JSON(rule)
+    }
+}
+
+// AdminUpdateLimit replaces a limit rule's settings
+func AdminUpdateLimit(svc *limits.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid rule id"})
+        }
+        var req models.LimitRule
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        rule, err := svc.UpdateRule(actor, id, req)
+        if err != nil {
+            return limitError(c, err)
+        }
+        return c.JSON(rule)
+    }
+}
+
+// AdminDeleteLimit removes a limit rule
+func AdminDeleteLimit(svc *limits.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid rule id"})
+        }
+        if err := svc.DeleteRule(actor, id); err != nil {
+            return limitError(c, err)
+        }
+        return c.SendStatus(204)
+    }
+}
@@ -2,86 +2,47 @@
 package handlers
 
 import (
-    "time"
+    "errors"
     "github.com/gofiber/fiber/v2"
-    "github.com/golang-jwt/jwt/v5"
-    "github.com/google/uuid"
-    "gorm.io/gorm"
     "weriKana/middleware"
-    "weriKana/models"
+    "weriKana/service/auth"
+    "weriKana/service/session"
 )
 
-// Login authenticates users and issues a JWT
-func Login(db *gorm.DB, secretKey string) fiber.Handler {
+// Login authenticates a customer by phone or email and password and issues a
+// customer-scoped token pair. Clients may request a narrower set of scopes.
+func Login(authSvc *auth.Service, sessionSvc *session.Service) fiber.Handler {
     return func(c *fiber.Ctx) error {
         var creds struct {
-            CustomerID  string `json:"customer_id"`
-            SharpID     string `json:"sharp_id"`
-            AccountType string `json:"account_type"`
-            Password    string `json:"password"`
+            Identifier string   `json:"identifier"` // phone or email
+            Password   string   `json:"password"`
+            Scopes     []string `json:"scopes"`
         }
         if err := c.BodyParser(&creds); err != nil {
             return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
         }
-        if creds.CustomerID == "" || creds.SharpID == "" || creds.AccountType == "" || creds.Password != "secret" {
+        if creds.Identifier == "" || creds.Password == "" {
             return c.Status(401).JSON(fiber.Map{"error": "Invalid credentials"})
         }
-        validTypes := map[string]bool{"sharp": true, "sports": true, "stock": true, "forex": true, "crypto": true}
-        if !validTypes[creds.AccountType] {
-            return c.Status(400).JSON(fiber.Map{"error": "Invalid account type"})
+        if len(creds.Scopes) == 0 {
+            creds.Scopes = middleware.AllScopes
         }
-        customerID, err := uuid.Parse(creds.CustomerID)
-        if err != nil {
-            return c.Status(400).JSON(fiber.Map{"error": "Invalid customer_id"})
-        }
-        sharpID, err := uuid.Parse(creds.SharpID)
-        if err != nil {
-            return c.Status(400).JSON(fiber.Map{"error": "Invalid sharp_id"})
+        if !middleware.ValidScopes(creds.Scopes) {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid scopes"})
         }
-        switch creds.AccountType {
-        case "sharp":
-            var acc models.SharpAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                return c.Status(401).JSON(fiber.Map{"error": "Account not found"})
-            }
-        case "sports":
-            var acc models.SportsAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                return c.Status(401).JSON(fiber.Map{"error": "Account not found"})
-            }
-        case "stock":
-            var acc models.StockAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                return c.Status(401).JSON(fiber.Map{"error": "Account not found"})
-            }
-        case "forex":
-            var acc models.ForexAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                return c.Status(401).JSON(fiber.Map{"error": "Account not found"})
-            }
-        case "crypto":
-            var acc models.CryptoAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                return c.Status(401).JSON(fiber.Map{"error": "Account not found"})
-            }
-        }
-        expirationTime := time.Now().Add(1 * time.Hour)
-        claims := &middleware.Claims{
-            CustomerID:  creds.CustomerID,
-            SharpID:     creds.SharpID,
-            AccountType: creds.AccountType,
-            RegisteredClaims: jwt.RegisteredClaims{
-                ExpiresAt: jwt.NewNumericDate(expirationTime),
-            },
+        customer, err := authSvc.Authenticate(creds.Identifier, creds.Password)
+        switch {
+        case errors.Is(err, auth.ErrAccountLocked):
+            return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, auth.ErrInvalidCredentials):
+            return c.Status(401).JSON(fiber.Map{"error": "Invalid credentials"})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Could not authenticate"})
         }
-        token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-        tokenString, err := token.SignedString([]byte(secretKey))
+        tokens, err := sessionSvc.Start(customer.ID, customer.Role, creds.Scopes, deviceOf(c))
         if err != nil {
             return c.Status(500).JSON(fiber.Map{"error": "Could not create token"})
         }
-        return c.JSON(fiber.Map{
-            "access_token": tokenString,
-            "token_type":   "bearer",
-        })
+        return c.JSON(tokens)
     }
 }
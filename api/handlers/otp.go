@@ -1,24 +1,42 @@
+// api/handlers/otp.go
 package handlers
 
-// api/handlers/otp.go
-func RequestWithdrawOTP(db *gorm.DB, otpSvc *otp.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var req struct {
-			CustomerID uuid.UUID `json:"customer_id"`
-		}
-		json.NewDecoder(r.Body).Decode(&req)
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "weriKana/models"
+    "weriKana/service/otp"
+)
+
+// RequestWithdrawOTP texts a withdrawal OTP to the customer's registered phone
+func RequestWithdrawOTP(db *gorm.DB, otpSvc *otp.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        var req struct {
+            CustomerID uuid.UUID `json:"customer_id"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
 
-		var customer models.Customer
-		if err := db.First(&customer, "id = ?", req.CustomerID).Error; err != nil {
-			http.Error(w, "customer not found", http.StatusNotFound)
-			return
-		}
+        var customer models.Customer
+        if err := db.First(&customer, "id = ?", req.CustomerID).Error; err != nil {
+            return c.Status(404).JSON(fiber.Map{"error": "Customer not found"})
+        }
 
-		code := otpSvc.Send(customer.ID, customer.Phone)
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "otp_sent",
-			"hint":   "Check SMS",
-		})
-	}
+        if err := otpSvc.Send(customer.ID, customer.Phone, otp.PurposeWithdraw); err != nil {
+            switch {
+            case errors.Is(err, otp.ErrCooldown), errors.Is(err, otp.ErrLocked):
+                return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+            default:
+                return c.Status(500).JSON(fiber.Map{"error": "Failed to send OTP"})
+            }
+        }
+        return c.JSON(fiber.Map{
+            "status": "otp_sent",
+            "hint":   "Check SMS",
+        })
+    }
 }
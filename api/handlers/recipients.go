@@ -0,0 +1,129 @@
+// api/handlers/recipients.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/service/recipients"
+)
+
+// recipientError maps address book errors to responses
+func recipientError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, recipients.ErrRecipientNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, recipients.ErrDuplicateRecipient), errors.Is(err, recipients.ErrRecipientInUse),
+        errors.Is(err, recipients.ErrTooManyRecipients):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, recipients.ErrInvalidRecipient), errors.Is(err, recipients.ErrUnknownBank),
+        errors.Is(err, recipients.ErrInvalidBankAccount):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "Recipient request failed"})
+    }
+}
+
+// ListPayoutBanks returns the banks recipients can be paid at
+func ListPayoutBanks() fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        return c.JSON(fiber.Map{"banks": recipients.Banks})
+    }
+}
+
+// CreateRecipient adds a recipient to the caller's address book
+func CreateRecipient(svc *recipients.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req recipients.Details
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        r, err := svc.Create(customerID, req)
+        if err != nil {
+            return recipientError(c, err)
+        }
+        return c.Status(201).JSON(recipients.NewView(r))
+    }
+}
+
+// ListRecipients returns the caller's recipients with contact details masked
+func ListRecipients(svc *recipients.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        limit, offset := pageOf(c)
+        list, err := svc.List(customerID, limit, offset)
+        if err != nil {
+            return recipientError(c, err)
+        }
+        views := make([]recipients.View, 0, len(list))
+        for i := range list {
+            views = append(views, recipients.NewView(&list[i]))
+        }
+        return c.JSON(fiber.Map{"recipients": views, "limit": limit, "offset": offset})
+    }
+}
+
+// GetRecipient returns one recipient with contact details masked
+func GetRecipient(svc *recipients.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, recipientID, err := recipientParams(c)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid recipient id"})
+        }
+        r, err := svc.Get(customerID, recipientID)
+        if err != nil {
+            return recipientError(c, err)
+        }
+        return c.JSON(recipients.NewView(r))
+    }
+}
+
+// UpdateRecipient replaces a recipient's contact and bank details
+func UpdateRecipient(svc *recipients.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, recipientID, err := recipientParams(c)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid recipient id"})
+        }
+        var req recipients.Details
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        r, err := svc.Update(customerID, recipientID, req)
+        if err != nil {
+            return recipientError(c, err)
+        }
+        return c.JSON(recipients.NewView(r))
+    }
+}
+
+// DeleteRecipient removes a recipient from the address book
+func DeleteRecipient(svc *recipients.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, recipientID, err := recipientParams(c)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid recipient id"})
+        }
+        if err := svc.Delete(customerID, recipientID); err != nil {
+            return recipientError(c, err)
+        }
+        return c.SendStatus(204)
+    }
+}
+
+// recipientParams reads the caller and the :id recipient from the request
+func recipientParams(c *fiber.Ctx) (customerID, recipientID uuid.UUID, err error) {
+    if customerID, err = uuid.Parse(c.Locals("customer_id").(string)); err != nil {
+        return
+    }
+    recipientID, err = uuid.Parse(c.Params("id"))
+    return
+}
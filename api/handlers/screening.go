@@ -0,0 +1,73 @@
+// api/handlers/screening.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/service/screening"
+)
+
+// screeningError maps sanctions screening errors to responses
+func screeningError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, screening.ErrResultNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, screening.ErrNotPending):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, screening.ErrNoteRequired), errors.Is(err, screening.ErrNoLists):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "Screening request failed"})
+    }
+}
+
+// AdminPendingScreening lists possible list matches awaiting review
+func AdminPendingScreening(svc *screening.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        limit, offset := pageOf(c)
+        results, err := svc.Pending(limit, offset)
+        if err != nil {
+            return screeningError(c, err)
+        }
+        return c.JSON(fiber.Map{"results": results, "limit": limit, "offset": offset})
+    }
+}
+
+// AdminReviewScreening confirms or clears a possible match
+func AdminReviewScreening(svc *screening.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        actor, err := actorOf(c)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        id, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid screening result id"})
+        }
+        var req struct {
+            Confirm bool   `json:"confirm"`
+            Note    string `json:"note"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        result, err := svc.Review(actor, id, req.Confirm, req.Note)
+        if err != nil {
+            return screeningError(c, err)
+        }
+        return c.JSON(result)
+    }
+}
+
+// AdminRefreshScreening reloads the list files and rescreens everyone
+func AdminRefreshScreening(svc *screening.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        flagged, err := svc.Refresh()
+        if err != nil {
+            return screeningError(c, err)
+        }
+        return c.JSON(fiber.Map{"pending_review": flagged})
+    }
+}
@@ -0,0 +1,104 @@
+// api/handlers/sessions.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/service/session"
+)
+
+// deviceOf records the client metadata kept on a session
+func deviceOf(c *fiber.Ctx) session.Device {
+    return session.Device{UserAgent: c.Get(fiber.HeaderUserAgent), IPAddress: c.IP()}
+}
+
+// RefreshToken rotates a refresh token and returns a new token pair
+func RefreshToken(sessionSvc *session.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        var req struct {
+            RefreshToken string `json:"refresh_token"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+
+        tokens, err := sessionSvc.Refresh(req.RefreshToken, deviceOf(c))
+        switch {
+        case errors.Is(err, session.ErrInvalidRefreshToken), errors.Is(err, session.ErrRefreshTokenReused):
+            return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Could not refresh token"})
+        }
+        return c.JSON(tokens)
+    }
+}
+
+// Logout revokes the session behind the presented access token
+func Logout(sessionSvc *session.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        sessionID, err := uuid.Parse(c.Locals("session_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        if err := sessionSvc.Revoke(customerID, sessionID); err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+            return c.Status(500).JSON(fiber.Map{"error": "Could not log out"})
+        }
+        return c.JSON(fiber.Map{"status": "logged_out"})
+    }
+}
+
+// ListSessions returns the caller's active sessions with device metadata
+func ListSessions(sessionSvc *session.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        sessions, err := sessionSvc.List(customerID)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Could not load sessions"})
+        }
+        current := c.Locals("session_id").(string)
+        out := make([]fiber.Map, len(sessions))
+        for i, s := range sessions {
+            out[i] = fiber.Map{
+                "id":           s.ID,
+                "user_agent":   s.UserAgent,
+                "ip_address":   s.IPAddress,
+                "created_at":   s.CreatedAt,
+                "last_used_at": s.LastUsedAt,
+                "expires_at":   s.ExpiresAt,
+                "current":      s.ID.String() == current,
+            }
+        }
+        return c.JSON(fiber.Map{"sessions": out})
+    }
+}
+
+// RevokeSession ends one of the caller's sessions by id
+func RevokeSession(sessionSvc *session.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        sessionID, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid session id"})
+        }
+        err = sessionSvc.Revoke(customerID, sessionID)
+        switch {
+        case errors.Is(err, session.ErrSessionNotFound):
+            return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Could not revoke session"})
+        }
+        return c.JSON(fiber.Map{"status": "revoked"})
+    }
+}
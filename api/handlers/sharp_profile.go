@@ -3,20 +3,16 @@ package handlers
 
 import (
     "github.com/gofiber/fiber/v2"
-    "github.com/google/uuid"
     "gorm.io/gorm"
+    "weriKana/middleware"
     "weriKana/models"
 )
 
-// GetSharpProfile retrieves the customer's SharpProfile metrics
+// GetSharpProfile retrieves the customer's SharpProfile metrics for the account's asset class
 func GetSharpProfile(db *gorm.DB) fiber.Handler {
     return func(c *fiber.Ctx) error {
-        customerIDStr := c.Locals("customer_id").(string)
-        accountType := c.Locals("account_type").(string)
-        customerID, err := uuid.Parse(customerIDStr)
-        if err != nil {
-            return c.Status(400).JSON(fiber.Map{"error": "Invalid customer_id"})
-        }
+        ref := middleware.Account(c)
+        customerID, accountType := ref.CustomerID, ref.Type
         var profile models.SharpProfile
         if err := db.Where("customer_id = ? AND asset_class = ?", customerID, accountType).First(&profile).Error; err != nil {
             return c.Status(404).JSON(fiber.Map{"error": "SharpProfile not found"})
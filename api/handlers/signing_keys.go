@@ -0,0 +1,117 @@
+// api/handlers/signing_keys.go
+package handlers
+
+import (
+    "encoding/base64"
+    "errors"
+    "time"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+    "weriKana/service/keystore"
+)
+
+// signingKeyResponse returns the secret once, at creation or rotation
+func signingKeyResponse(key *models.SigningKey, secret []byte) fiber.Map {
+    return fiber.Map{
+        "key_id":     key.KeyID,
+        "label":      key.Label,
+        "secret":     base64.StdEncoding.EncodeToString(secret),
+        "created_at": key.CreatedAt,
+        "algorithm":  "HMAC-SHA256",
+    }
+}
+
+// EnrollSigningKey creates a request-signing key for the caller
+func EnrollSigningKey(ks *keystore.KeyStore) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req struct {
+            Label string `json:"label"`
+        }
+        if len(c.Body()) > 0 {
+            if err := c.BodyParser(&req); err != nil {
+                return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+            }
+        }
+        if len(req.Label) > 100 {
+            return c.Status(400).JSON(fiber.Map{"error": "label too long"})
+        }
+
+        key, secret, err := ks.Enroll(customerID, req.Label)
+        if errors.Is(err, keystore.ErrTooManyKeys) {
+            return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+        }
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to create signing key"})
+        }
+        return c.Status(201).JSON(signingKeyResponse(key, secret))
+    }
+}
+
+// ListSigningKeys returns the caller's usable keys (never the secrets)
+func ListSigningKeys(ks *keystore.KeyStore) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        keys, err := ks.List(customerID)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to load signing keys"})
+        }
+        return c.JSON(fiber.Map{"signing_keys": keys})
+    }
+}
+
+// RotateSigningKey issues a replacement; the old key keeps working for grace_hours (default 24)
+func RotateSigningKey(ks *keystore.KeyStore) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        grace := keystore.DefaultGrace
+        if h := c.QueryInt("grace_hours", -1); h >= 0 {
+            if h > 24*7 {
+                return c.Status(400).JSON(fiber.Map{"error": "grace_hours must be at most 168"})
+            }
+            grace = time.Duration(h) * time.Hour
+        }
+
+        key, secret, err := ks.Rotate(customerID, c.Params("kid"), grace)
+        switch {
+        case errors.Is(err, keystore.ErrUnknownKey):
+            return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, keystore.ErrTooManyKeys):
+            return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to rotate signing key"})
+        }
+        resp := signingKeyResponse(key, secret)
+        resp["previous_key_expires_in_hours"] = int(grace.Hours())
+        return c.Status(201).JSON(resp)
+    }
+}
+
+// RevokeSigningKey disables a key immediately
+func RevokeSigningKey(ks *keystore.KeyStore) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        err = ks.Revoke(customerID, c.Params("kid"))
+        switch {
+        case errors.Is(err, keystore.ErrUnknownKey):
+            return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke signing key"})
+        }
+        return c.JSON(fiber.Map{"status": "revoked"})
+    }
+}
@@ -10,7 +10,9 @@ import (
     "github.com/google/uuid"
     "github.com/nats-io/nats.go"
     "gorm.io/gorm"
+    "weriKana/middleware"
     "weriKana/models"
+    "weriKana/service/fees"
 )
 
 // JSONMap is a map for JSON data
@@ -21,7 +23,9 @@ type AllocationResult struct {
     BookieID       uuid.UUID
     BookieName     string
     MpesaNumber    string
-    AmountToSend   int64 // cents
+    AmountToSend   int64 // cents, gross
+    FeeCents       int64
+    NetCents       int64 // credited to the account
     Proportion     float64
     IsReal         bool
     IdempotencyKey string
@@ -39,13 +43,14 @@ type DepositRequest struct {
     BookieID    uuid.UUID `json:"bookie_id,omitempty"` // optional, for bookie account deposits
 }
 
-// BaseDeposit updates an account's balance and creates a Transaction
+// BaseDeposit credits the quote's net amount to an account, creates a
+// Transaction for the gross amount and books the fee
 func BaseDeposit(
     db *gorm.DB,
     customerID uuid.UUID,
     accountType string,
     accountID uuid.UUID,
-    amountCents int64,
+    quote models.FeeQuote,
     isReal bool,
     metadata JSONMap,
     reference string,
@@ -55,14 +60,14 @@ func BaseDeposit(
         ID:              uuid.New(),
         CustomerID:      customerID,
         Type:            models.TransactionTypeDeposit,
-        AmountCents:     amountCents,
         IsReal:          isReal,
-        Currency:        "KES",
+        Currency:        models.DefaultCurrency, // M-Pesa only moves shillings
         Status:          models.StatusSuccess,
         Metadata:        metadata,
         Reference:       reference,
         BookieAccountID: bookieAccountID,
     }
+    quote.Apply(&tx)
 
     switch accountType {
     case "sharp":
@@ -71,9 +76,9 @@ func BaseDeposit(
             return nil, fmt.Errorf("account not found")
         }
         if isReal {
-            acc.RealBalanceCents += amountCents
+            acc.RealBalanceCents += quote.NetCents
         } else {
-            acc.FakeBalanceCents += amountCents
+            acc.FakeBalanceCents += quote.NetCents
         }
         if err := db.Save(&acc).Error; err != nil {
             return nil, fmt.Errorf("failed to update account")
@@ -85,9 +90,9 @@ func BaseDeposit(
             return nil, fmt.Errorf("account not found")
         }
         if isReal {
-            acc.RealBalanceCents += amountCents
+            acc.RealBalanceCents += quote.NetCents
         } else {
-            acc.FakeBalanceCents += amountCents
+            acc.FakeBalanceCents += quote.NetCents
         }
         acc.TradeHistory = metadata
         if err := db.Save(&acc).Error; err != nil {
@@ -100,9 +105,9 @@ func BaseDeposit(
             return nil, fmt.Errorf("account not found")
         }
         if isReal {
-            acc.RealBalanceCents += amountCents
+            acc.RealBalanceCents += quote.NetCents
         } else {
-            acc.FakeBalanceCents += amountCents
+            acc.FakeBalanceCents += quote.NetCents
         }
         acc.Portfolio = metadata
         if err := db.Save(&acc).Error; err != nil {
@@ -115,9 +120,9 @@ func BaseDeposit(
             return nil, fmt.Errorf("account not found")
         }
         if isReal {
-            acc.RealBalanceCents += amountCents
+            acc.RealBalanceCents += quote.NetCents
         } else {
-            acc.FakeBalanceCents += amountCents
+            acc.FakeBalanceCents += quote.NetCents
         }
         acc.OpenPositions = metadata
         if err := db.Save(&acc).Error; err != nil {
@@ -130,9 +135,9 @@ func BaseDeposit(
             return nil, fmt.Errorf("account not found")
         }
         if isReal {
-            acc.RealBalanceCents += amountCents
+            acc.RealBalanceCents += quote.NetCents
         } else {
-            acc.FakeBalanceCents += amountCents
+            acc.FakeBalanceCents += quote.NetCents
         }
         acc.Addresses = metadata
         if err := db.Save(&acc).Error; err != nil {
@@ -145,9 +150,9 @@ func BaseDeposit(
             return nil, fmt.Errorf("bookie account not found")
         }
         if isReal {
-            acc.RealBalanceCents += amountCents
+            acc.RealBalanceCents += quote.NetCents
         } else {
-            acc.FakeBalanceCents += amountCents
+            acc.FakeBalanceCents += quote.NetCents
         }
         if err := db.Save(&acc).Error; err != nil {
             return nil, fmt.Errorf("failed to update bookie account")
@@ -160,11 +165,14 @@ func BaseDeposit(
     if err := db.Create(&tx).Error; err != nil {
         return nil, fmt.Errorf("failed to create transaction")
     }
+    if err := fees.Post(db, &tx, quote); err != nil {
+        return nil, fmt.Errorf("failed to record fee")
+    }
     return &tx, nil
 }
 
-// AccountDeposit handles deposits for a specific account or bookie account
-func AccountDeposit(db *gorm.DB) fiber.Handler {
+// AccountDeposit deposits into the account in the path, or into a bookie account when bookie_id is set
+func AccountDeposit(db *gorm.DB, feeSvc *fees.Service) fiber.Handler {
     return func(c *fiber.Ctx) error {
         var req DepositRequest
         if err := c.BodyParser(&req); err != nil {
@@ -173,65 +181,40 @@ func AccountDeposit(db *gorm.DB) fiber.Handler {
         if req.AmountCents <= 0 {
             return c.Status(400).JSON(fiber.Map{"error": "Amount must be positive"})
         }
-        customerIDStr := c.Locals("customer_id").(string)
-        sharpIDStr := c.Locals("sharp_id").(string)
-        accountType := c.Locals("account_type").(string)
-        customerID, err := uuid.Parse(customerIDStr)
-        if err != nil {
-            return c.Status(400).JSON(fiber.Map{"error": "Invalid customer_id"})
-        }
+        ref := middleware.Account(c) // resolved from /accounts/:id
+        customerID := ref.CustomerID
         if req.CustomerID != uuid.Nil && req.CustomerID != customerID {
             return c.Status(400).JSON(fiber.Map{"error": "CustomerID mismatch"})
         }
         req.CustomerID = customerID
 
-        var accountID uuid.UUID
+        accountType, accountID := ref.Type, ref.ID
+        currency, err := models.AccountCurrency(db, ref.Type, ref.ID)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to load account"})
+        }
+        if currency != models.DefaultCurrency {
+            return c.Status(422).JSON(fiber.Map{"error": "Deposits are in " + models.DefaultCurrency + "; deposit to a " + models.DefaultCurrency + " account and convert"})
+        }
         reference := fmt.Sprintf("DEP-%s", uuid.New().String()[:8])
+        var quote models.FeeQuote
         if req.BookieID != uuid.Nil {
-            // Bookie account deposit
+            // Bookie account deposit, priced by the bookie it is credited to
+            var acct models.SportsAccount
+            if err := db.Where("id = ? AND customer_id = ?", req.BookieID, customerID).First(&acct).Error; err != nil {
+                return c.Status(404).JSON(fiber.Map{"error": "Bookie account not found"})
+            }
             accountType = "bookie"
             accountID = req.BookieID
-        } else {
-            // Account type deposit
-            sharpID, err := uuid.Parse(sharpIDStr)
-            if err != nil {
-                return c.Status(400).JSON(fiber.Map{"error": "Invalid sharp_id"})
-            }
-            // Lookup account ID by sharp_id
-            switch accountType {
-            case "sharp":
-                var acc models.SharpAccount
-                if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                    return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
-                }
-                accountID = acc.ID
-            case "sports":
-                var acc models.SportsAccount
-                if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                    return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
-                }
-                accountID = acc.ID
-            case "stock":
-                var acc models.StockAccount
-                if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                    return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
-                }
-                accountID = acc.ID
-            case "forex":
-                var acc models.ForexAccount
-                if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                    return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
-                }
-                accountID = acc.ID
-            case "crypto":
-                var acc models.CryptoAccount
-                if err := db.Where("customer_id = ? AND sharp_id = ?", customerID, sharpID).First(&acc).Error; err != nil {
-                    return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
-                }
-                accountID = acc.ID
-            default:
-                return c.Status(400).JSON(fiber.Map{"error": "Invalid account type"})
+            quote = models.NoFee(models.FeeDeposit, req.AmountCents)
+            if req.IsReal {
+                quote, err = feeSvc.Quote(models.FeeDeposit, acct.BookieID, req.AmountCents)
             }
+        } else {
+            quote, err = feeSvc.QuoteAccount(models.FeeDeposit, ref.Type, ref.ID, req.IsReal, req.AmountCents)
+        }
+        if err != nil {
+            return feeError(c, err)
         }
 
         tx, err := BaseDeposit(
@@ -239,7 +222,7 @@ func AccountDeposit(db *gorm.DB) fiber.Handler {
             customerID,
             accountType,
             accountID,
-            req.AmountCents,
+            quote,
             req.IsReal,
             req.AssetData,
             reference,
@@ -252,12 +235,14 @@ func AccountDeposit(db *gorm.DB) fiber.Handler {
         return c.JSON(fiber.Map{
             "message":        "Deposit successful",
             "transaction_id": tx.ID,
+            "fee":            quote,
         })
     }
 }
 
-// SmartDeposit allocates deposits across bookie accounts
-func SmartDeposit(db *gorm.DB, nc *nats.Conn) fiber.Handler {
+// SmartDeposit allocates deposits across bookie accounts. Each leg is
+// charged its bookie's deposit fee; a dry run returns the legs as a preview.
+func SmartDeposit(db *gorm.DB, nc *nats.Conn, feeSvc *fees.Service) fiber.Handler {
     return func(c *fiber.Ctx) error {
         var req DepositRequest
         if err := c.BodyParser(&req); err != nil {
@@ -300,6 +285,7 @@ func SmartDeposit(db *gorm.DB, nc *nats.Conn) fiber.Handler {
         }
 
         var allocs []AllocationResult
+        var totalFee int64
         parentRef := uuid.New().String()
         for _, acct := range accounts {
             var balance int64
@@ -316,6 +302,12 @@ func SmartDeposit(db *gorm.DB, nc *nats.Conn) fiber.Handler {
             if amountToSend <= 0 {
                 continue
             }
+            quote := models.NoFee(models.FeeDeposit, amountToSend)
+            if req.IsReal {
+                if quote, err = feeSvc.Quote(models.FeeDeposit, acct.Bookie.ID, amountToSend); err != nil {
+                    return feeError(c, err)
+                }
+            }
             idempotency := fmt.Sprintf("%s-%d-%d", acct.Bookie.Name, time.Now().UnixNano(), amountToSend)
             metadata := models.JSONMap{
                 "proportion":  proportion,
@@ -330,7 +322,7 @@ func SmartDeposit(db *gorm.DB, nc *nats.Conn) fiber.Handler {
                 req.CustomerID,
                 "bookie",
                 acct.ID,
-                amountToSend,
+                quote,
                 req.IsReal,
                 metadata,
                 parentRef,
@@ -344,11 +336,14 @@ func SmartDeposit(db *gorm.DB, nc *nats.Conn) fiber.Handler {
                 BookieName:     acct.Bookie.Name,
                 MpesaNumber:    acct.Bookie.MpesaNumber,
                 AmountToSend:   amountToSend,
+                FeeCents:       quote.FeeCents,
+                NetCents:       quote.NetCents,
                 Proportion:     proportion,
                 IsReal:         req.IsReal,
                 IdempotencyKey: idempotency,
                 TransactionID:  tx.ID,
             })
+            totalFee += quote.FeeCents
         }
 
         if req.IsReal && (req.DryRun == nil || !*req.DryRun) && len(allocs) > 0 {
@@ -388,14 +383,19 @@ func SmartDeposit(db *gorm.DB, nc *nats.Conn) fiber.Handler {
             }
         }
 
-        return c.JSON(fiber.Map{
+        resp := fiber.Map{
             "status":          "smart_deposit_initiated",
             "parent_ref":      parentRef[:8],
             "is_real":         req.IsReal,
             "dry_run":         req.DryRun,
             "total_allocated": req.AmountCents,
+            "total_fee":       totalFee,
             "allocations":     len(allocs),
             "pot_balance":     totalPot,
-        })
+        }
+        if req.DryRun != nil && *req.DryRun {
+            resp["preview"] = allocs
+        }
+        return c.JSON(resp)
     }
 }
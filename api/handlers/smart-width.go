@@ -1,37 +1,58 @@
 package handlers
 
 import (
-    "fmt"
+    "errors"
     "github.com/gofiber/fiber/v2"
     "github.com/google/uuid"
+    "github.com/nats-io/nats.go"
     "weriKana/models"
     "weriKana/service/dd_rr"
+    "weriKana/service/fees"
+    "weriKana/service/otp"
+    "weriKana/service/totp"
     "gorm.io/gorm"
     "log"
 )
 
-func SmartWithdraw(db *gorm.DB, keyStore *KeyStore, crypto *securewithdrawal.CryptoEngine, nc *nats.Conn) fiber.Handler {
+// SmartWithdrawRequest carries an SMS OTP and/or authenticator code, per the customer's second-factor policy.
+// The request itself must be signed (see middleware.RequireSignedRequest).
+type SmartWithdrawRequest struct {
+    CustomerID uuid.UUID `json:"customer_id"`
+    OTP        string    `json:"otp"`
+    TOTPCode   string    `json:"totp_code"`
+    Amount     int64     `json:"amount"`
+    IsReal     bool      `json:"is_real"`
+}
+
+// SmartWithdraw draws the amount from the customer's sports accounts in
+// proportion to their balances. Each leg is debited in full and pays out the
+// net of its bookie's withdrawal fee.
+func SmartWithdraw(db *gorm.DB, otpSvc *otp.Service, totpSvc *totp.Service, feeSvc *fees.Service, crypto *securewithdrawal.CryptoEngine, nc *nats.Conn) fiber.Handler {
     return func(c *fiber.Ctx) error {
         var req SmartWithdrawRequest
         if err := c.BodyParser(&req); err != nil {
             return c.Status(400).JSON(fiber.Map{"error": "invalid json"})
         }
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil || (req.CustomerID != uuid.Nil && req.CustomerID != customerID) {
+            return c.Status(403).JSON(fiber.Map{"error": "customer_id does not match token"})
+        }
+        req.CustomerID = customerID
 
         // Step 1: Validate the withdrawal amount
         if req.Amount <= 0 {
             return c.Status(400).JSON(fiber.Map{"error": "amount must be > 0"})
         }
 
-        // Step 2: Verify OTP
-        if !otp.Verify(req.CustomerID, req.OTP) {
-            return c.Status(401).JSON(fiber.Map{"error": "invalid or expired OTP"})
+        // Step 2: Verify second factor (SMS OTP or authenticator, per policy)
+        if err := verifyWithdrawFactor(db, otpSvc, totpSvc, req.CustomerID, req.OTP, req.TOTPCode); err != nil {
+            if errors.Is(err, otp.ErrLocked) || errors.Is(err, totp.ErrLocked) {
+                return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+            }
+            return c.Status(401).JSON(fiber.Map{"error": errSecondFactor.Error()})
         }
 
-        // Step 3: Verify signature using keyStore
-        payload := fmt.Sprintf("%s:%s:%d", req.CustomerID, req.OTP, req.Amount)
-        if !keyStore.Verify(req.CustomerID, payload, req.Signature) {
-            return c.Status(401).JSON(fiber.Map{"error": "invalid signature"})
-        }
+        // Step 3: the request signature was checked by middleware before the body was trusted
 
         // Step 4: Delegate allocation logic to `securewithdrawal` service
         allocs, totalPot, err := securewithdrawal.CalculateAllocations(db, req.CustomerID, req.Amount, req.IsReal)
@@ -43,17 +64,32 @@ func SmartWithdraw(db *gorm.DB, keyStore *KeyStore, crypto *securewithdrawal.Cry
             return c.Status(400).JSON(fiber.Map{"error": "no active bookie accounts or insufficient balance"})
         }
 
-        // Step 5: Prepare withdrawal items and transaction
+        // Step 5: Charge each leg its bookie's withdrawal fee, then debit the legs and book the fees together
+        if err := securewithdrawal.PriceLegs(feeSvc, req.IsReal, allocs); err != nil {
+            return feeError(c, err)
+        }
+        transaction := &securewithdrawal.Transaction{ID: uuid.New()}
+        err = db.Transaction(func(tx *gorm.DB) error {
+            return securewithdrawal.BookLegs(tx, req.CustomerID, req.IsReal, transaction.ID.String(), allocs)
+        })
+        if errors.Is(err, models.ErrInsufficientFunds) {
+            return c.Status(409).JSON(fiber.Map{"error": "balance changed, please retry"})
+        }
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "failed to book withdrawal"})
+        }
+
+        // Step 6: Send secure withdrawal via NATS; each bookie pays out the net of its fee
         items := make([]securewithdrawal.WithdrawalItem, len(allocs))
+        var totalFee int64
         for i, a := range allocs {
             items[i] = securewithdrawal.WithdrawalItem{
-                BookieID:    a.BookieID,
-                AmountCents: a.AmountToSend,
+                BookieAccountID: a.AccountID,
+                AmountCents:     a.Fee.NetCents,
+                EncryptedKey:    []byte(a.EncryptedKey),
             }
+            totalFee += a.Fee.FeeCents
         }
-
-        // Step 6: Send secure withdrawal via NATS
-        transaction := &securewithdrawal.Transaction{ID: uuid.New()}
         if err := transaction.SendSecureWithdrawalViaNATS(db, nc, "withdraw.secure", crypto, items); err != nil {
             log.Printf("Failed to send secure withdrawal to NATS: %v", err)
             return c.Status(500).JSON(fiber.Map{"error": "failed to send secure withdrawal"})
@@ -64,41 +100,10 @@ func SmartWithdraw(db *gorm.DB, keyStore *KeyStore, crypto *securewithdrawal.Cry
             "status":       "smart_withdraw_initiated",
             "parent_ref":   transaction.ID.String()[:8], // Shortened for readability
             "total_cents":  req.Amount,
+            "total_fee":    totalFee,
             "is_real":      req.IsReal,
             "bookies":      len(allocs),
             "pot_balance":  totalPot,
         })
     }
 }
-
-
-func ProcessWithdrawal(db *gorm.DB, nc *nats.Conn, request map[string]interface{}) error {
-    // Extract necessary fields from the NATS message (request)
-    customerID := request["customer_id"].(string)
-    amount := int64(request["total_cents"].(float64))
-    isReal := request["is_real"].(bool)
-    otp := request["otp"].(string)
-    signature := []byte(request["signature"].(string))
-
-    // Create SmartWithdrawRequest to pass to business logic in securewithdrawal
-    smartWithdrawRequest := dd_rr.SmartWithdrawRequest{
-        CustomerID: customerID,
-        Amount:     amount,
-        IsReal:     isReal,
-        OTP:        otp,
-        Signature:  signature,
-    }
-
-    // Call SmartWithdraw logic from dd_rr/foreman.go to verify and process withdrawal
-    if err := dd_rr.SmartWithdraw(db, smartWithdrawRequest); err != nil {
-        return fmt.Errorf("failed to process smart withdrawal: %w", err)
-    }
-
-    // Send withdrawal information to NATS via natsAnish
-    err := natsAnish.PublishWithdrawalToNATS(db, nc, smartWithdrawRequest)
-    if err != nil {
-        return fmt.Errorf("failed to send withdrawal to NATS: %w", err)
-    }
-
-    return nil
-}
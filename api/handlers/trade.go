@@ -6,11 +6,14 @@ import (
     "github.com/gofiber/fiber/v2"
     "github.com/google/uuid"
     "gorm.io/gorm"
+    "weriKana/middleware"
     "weriKana/models"
+    "weriKana/service/fees"
 )
 
-// PlaceTrade handles placing a trade, updating account and profile
-func PlaceTrade(db *gorm.DB) fiber.Handler {
+// PlaceTrade places a trade on the account in the path, updating account and
+// profile. The stake is debited in full; any trade fee comes out of it.
+func PlaceTrade(db *gorm.DB, feeSvc *fees.Service) fiber.Handler {
     return func(c *fiber.Ctx) error {
         var input struct {
             AmountCents int64   `json:"amount_cents"`
@@ -24,16 +27,11 @@ func PlaceTrade(db *gorm.DB) fiber.Handler {
         if input.AmountCents <= 0 {
             return c.Status(400).JSON(fiber.Map{"error": "Amount must be positive"})
         }
-        customerIDStr := c.Locals("customer_id").(string)
-        sharpIDStr := c.Locals("sharp_id").(string)
-        accountType := c.Locals("account_type").(string)
-        customerID, err := uuid.Parse(customerIDStr)
+        ref := middleware.Account(c) // resolved from /accounts/:id
+        customerID, accountType := ref.CustomerID, ref.Type
+        quote, err := feeSvc.QuoteAccount(models.FeeTrade, ref.Type, ref.ID, input.IsReal, input.AmountCents)
         if err != nil {
-            return c.Status(400).JSON(fiber.Map{"error": "Invalid customer_id"})
-        }
-        sharpID, err := uuid.Parse(sharpIDStr)
-        if err != nil {
-            return c.Status(400).JSON(fiber.Map{"error": "Invalid sharp_id"})
+            return feeError(c, err)
         }
         var profile models.SharpProfile
         if err := db.Where("customer_id = ? AND asset_class = ?", customerID, accountType).First(&profile).Error; err != nil {
@@ -43,7 +41,7 @@ func PlaceTrade(db *gorm.DB) fiber.Handler {
         switch accountType {
         case "sharp":
             var acc models.SharpAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ? AND sharp_profile_id = ?", customerID, sharpID, profile.ID).First(&acc).Error; err != nil {
+            if err := db.First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             if input.IsReal && acc.RealBalanceCents < input.AmountCents {
@@ -73,14 +71,14 @@ func PlaceTrade(db *gorm.DB) fiber.Handler {
                 Type:           models.TransactionTypeTrade,
                 AmountCents:    input.AmountCents,
                 IsReal:         input.IsReal,
-                Currency:       "KES",
+                Currency:       acc.Currency,
                 Status:         models.StatusSuccess,
                 Metadata:       JSONMap{"ev": input.EV, "metadata": input.Metadata},
                 Reference:      fmt.Sprintf("TRD-%s", uuid.New().String()[:8]),
             }
         case "sports":
             var acc models.SportsAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ? AND sharp_profile_id = ?", customerID, sharpID, profile.ID).First(&acc).Error; err != nil {
+            if err := db.First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             if input.IsReal && acc.RealBalanceCents < input.AmountCents {
@@ -111,14 +109,14 @@ func PlaceTrade(db *gorm.DB) fiber.Handler {
                 Type:            models.TransactionTypeTrade,
                 AmountCents:     input.AmountCents,
                 IsReal:          input.IsReal,
-                Currency:        "KES",
+                Currency:        acc.Currency,
                 Status:          models.StatusSuccess,
                 Metadata:        JSONMap{"ev": input.EV, "metadata": input.Metadata},
                 Reference:       fmt.Sprintf("TRD-%s", uuid.New().String()[:8]),
             }
         case "stock":
             var acc models.StockAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ? AND sharp_profile_id = ?", customerID, sharpID, profile.ID).First(&acc).Error; err != nil {
+            if err := db.First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             if input.IsReal && acc.RealBalanceCents < input.AmountCents {
@@ -149,14 +147,14 @@ func PlaceTrade(db *gorm.DB) fiber.Handler {
                 Type:           models.TransactionTypeTrade,
                 AmountCents:    input.AmountCents,
                 IsReal:         input.IsReal,
-                Currency:       "KES",
+                Currency:       acc.Currency,
                 Status:         models.StatusSuccess,
                 Metadata:       JSONMap{"ev": input.EV, "metadata": input.Metadata},
                 Reference:      fmt.Sprintf("TRD-%s", uuid.New().String()[:8]),
             }
         case "forex":
             var acc models.ForexAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ? AND sharp_profile_id = ?", customerID, sharpID, profile.ID).First(&acc).Error; err != nil {
+            if err := db.First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             if input.IsReal && acc.RealBalanceCents < input.AmountCents {
@@ -187,14 +185,14 @@ func PlaceTrade(db *gorm.DB) fiber.Handler {
                 Type:           models.TransactionTypeTrade,
                 AmountCents:    input.AmountCents,
                 IsReal:         input.IsReal,
-                Currency:       "KES",
+                Currency:       acc.Currency,
                 Status:         models.StatusSuccess,
                 Metadata:       JSONMap{"ev": input.EV, "metadata": input.Metadata},
                 Reference:      fmt.Sprintf("TRD-%s", uuid.New().String()[:8]),
             }
         case "crypto":
             var acc models.CryptoAccount
-            if err := db.Where("customer_id = ? AND sharp_id = ? AND sharp_profile_id = ?", customerID, sharpID, profile.ID).First(&acc).Error; err != nil {
+            if err := db.First(&acc, "id = ?", ref.ID).Error; err != nil {
                 return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
             }
             if input.IsReal && acc.RealBalanceCents < input.AmountCents {
@@ -225,7 +223,7 @@ func PlaceTrade(db *gorm.DB) fiber.Handler {
                 Type:            models.TransactionTypeTrade,
                 AmountCents:     input.AmountCents,
                 IsReal:          input.IsReal,
-                Currency:        "KES",
+                Currency:        acc.Currency,
                 Status:          models.StatusSuccess,
                 Metadata:        JSONMap{"ev": input.EV, "metadata": input.Metadata},
                 Reference:       fmt.Sprintf("TRD-%s", uuid.New().String()[:8]),
@@ -234,13 +232,18 @@ func PlaceTrade(db *gorm.DB) fiber.Handler {
             return c.Status(400).JSON(fiber.Map{"error": "Invalid account type"})
         }
         transaction.ID = uuid.New()
+        quote.Apply(&transaction)
         if err := db.Create(&transaction).Error; err != nil {
             return c.Status(500).JSON(fiber.Map{"error": "Failed to create transaction"})
         }
+        if err := fees.Post(db, &transaction, quote); err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to record fee"})
+        }
         return c.JSON(fiber.Map{
             "message":        "Trade placed successfully",
             "transaction_id": transaction.ID,
             "reference":      transaction.Reference,
+            "fee":            quote,
         })
     }
 }
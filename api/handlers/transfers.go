@@ -0,0 +1,144 @@
+// api/handlers/transfers.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+    "weriKana/service/kyc"
+    "weriKana/service/remittance"
+    "weriKana/service/screening"
+)
+
+// transferError maps remittance service errors to responses
+func transferError(c *fiber.Ctx, err error) error {
+    switch {
+    case errors.Is(err, remittance.ErrTransferNotFound), errors.Is(err, remittance.ErrRecipientNotFound),
+        errors.Is(err, remittance.ErrAccountNotFound):
+        return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, remittance.ErrInvalidState), errors.Is(err, remittance.ErrQuoteExpired):
+        return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, kyc.ErrNotVerified), errors.Is(err, screening.ErrScreeningHold):
+        return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, models.ErrInsufficientFunds):
+        return c.Status(402).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, remittance.ErrInvalidAmount), errors.Is(err, remittance.ErrUnsupportedPayout),
+        errors.Is(err, remittance.ErrNoSender), errors.Is(err, remittance.ErrPayoutDetails),
+        errors.Is(err, remittance.ErrInvalidFunding):
+        return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+    case errors.Is(err, remittance.ErrCollectionFailed), errors.Is(err, remittance.ErrRefundUnavailable):
+        return c.Status(502).JSON(fiber.Map{"error": err.Error()})
+    default:
+        return c.Status(500).JSON(fiber.Map{"error": "Transfer request failed"})
+    }
+}
+
+// transferParams reads the caller and the :id transfer from the request
+func transferParams(c *fiber.Ctx) (customerID, transferID uuid.UUID, err error) {
+    if customerID, err = uuid.Parse(c.Locals("customer_id").(string)); err != nil {
+        return
+    }
+    transferID, err = uuid.Parse(c.Params("id"))
+    return
+}
+
+// CreateTransfer quotes a transfer to one of the caller's recipients
+func CreateTransfer(svc *remittance.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req remittance.QuoteRequest
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        t, err := svc.Quote(customerID, req)
+        if err != nil {
+            return transferError(c, err)
+        }
+        return c.Status(201).JSON(t)
+    }
+}
+
+// ListTransfers returns the caller's transfers, newest first
+func ListTransfers(svc *remittance.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        limit, offset := pageOf(c)
+        transfers, err := svc.List(customerID, limit, offset)
+        if err != nil {
+            return transferError(c, err)
+        }
+        return c.JSON(fiber.Map{"transfers": transfers, "limit": limit, "offset": offset})
+    }
+}
+
+// GetTransfer returns one transfer and its status history
+func GetTransfer(svc *remittance.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, transferID, err := transferParams(c)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid transfer id"})
+        }
+        t, events, err := svc.Get(customerID, transferID)
+        if err != nil {
+            return transferError(c, err)
+        }
+        return c.JSON(fiber.Map{"transfer": t, "events": events})
+    }
+}
+
+// FundTransfer pays for a quoted transfer from an account or M-Pesa
+func FundTransfer(svc *remittance.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, transferID, err := transferParams(c)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid transfer id"})
+        }
+        var req remittance.FundRequest
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+        t, err := svc.Fund(c.UserContext(), customerID, transferID, req)
+        if err != nil {
+            return transferError(c, err)
+        }
+        return c.JSON(t)
+    }
+}
+
+// SendTransfer pays a funded transfer out, or retries a failed payout
+func SendTransfer(svc *remittance.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, transferID, err := transferParams(c)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid transfer id"})
+        }
+        t, err := svc.Send(c.UserContext(), customerID, transferID)
+        if err != nil {
+            return transferError(c, err)
+        }
+        return c.JSON(t)
+    }
+}
+
+// CancelTransfer cancels a transfer before payout, refunding it if funded
+func CancelTransfer(svc *remittance.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, transferID, err := transferParams(c)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid transfer id"})
+        }
+        t, err := svc.Cancel(c.UserContext(), customerID, transferID)
+        if err != nil {
+            return transferError(c, err)
+        }
+        return c.JSON(t)
+    }
+}
@@ -0,0 +1,141 @@
+// api/handlers/two_factor.go
+package handlers
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "weriKana/models"
+    "weriKana/service/otp"
+    "weriKana/service/totp"
+)
+
+// EnrollTOTP starts authenticator-app enrollment and returns the secret and otpauth URI
+func EnrollTOTP(db *gorm.DB, totpSvc *totp.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+
+        var customer models.Customer
+        if err := db.First(&customer, "id = ?", customerID).Error; err != nil {
+            return c.Status(404).JSON(fiber.Map{"error": "Customer not found"})
+        }
+
+        secret, uri, err := totpSvc.Enroll(customer.ID, customer.Phone)
+        if errors.Is(err, totp.ErrAlreadyEnrolled) {
+            return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+        }
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to start enrollment"})
+        }
+        return c.JSON(fiber.Map{
+            "secret":           secret,
+            "provisioning_uri": uri, // render as a QR code client-side
+        })
+    }
+}
+
+// ConfirmTOTP activates enrollment with a first code and returns one-time recovery codes
+func ConfirmTOTP(totpSvc *totp.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req struct {
+            Code string `json:"code"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+
+        recovery, err := totpSvc.Confirm(customerID, req.Code)
+        switch {
+        case errors.Is(err, totp.ErrNotEnrolled):
+            return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, totp.ErrAlreadyEnrolled):
+            return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+        case errors.Is(err, totp.ErrInvalidCode):
+            return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+        case err != nil:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to confirm enrollment"})
+        }
+        return c.JSON(fiber.Map{
+            "status":         "totp_enabled",
+            "recovery_codes": recovery, // shown once; only hashes are stored
+        })
+    }
+}
+
+// UpdateSecondFactorPolicy sets which factor SmartWithdraw accepts (sms, totp or any)
+func UpdateSecondFactorPolicy(db *gorm.DB, totpSvc *totp.Service) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        var req struct {
+            SecondFactor models.SecondFactor `json:"second_factor"`
+        }
+        if err := c.BodyParser(&req); err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+        }
+
+        switch req.SecondFactor {
+        case models.SecondFactorSMS:
+        case models.SecondFactorTOTP, models.SecondFactorAny:
+            enrolled, err := totpSvc.Enrolled(customerID)
+            if err != nil {
+                return c.Status(500).JSON(fiber.Map{"error": "Failed to load enrollment"})
+            }
+            if !enrolled {
+                return c.Status(409).JSON(fiber.Map{"error": "Confirm an authenticator before enabling it"})
+            }
+        default:
+            return c.Status(400).JSON(fiber.Map{"error": "second_factor must be sms, totp or any"})
+        }
+
+        if err := db.Model(&models.Customer{}).Where("id = ?", customerID).
+            Update("second_factor", req.SecondFactor).Error; err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to update policy"})
+        }
+        return c.JSON(fiber.Map{"second_factor": req.SecondFactor})
+    }
+}
+
+// errSecondFactor is returned when neither accepted factor verified
+var errSecondFactor = errors.New("invalid or expired second factor")
+
+// verifyWithdrawFactor checks the SMS OTP and/or authenticator code the
+// customer's policy allows. Under "any", a supplied TOTP code is tried first.
+func verifyWithdrawFactor(db *gorm.DB, otpSvc *otp.Service, totpSvc *totp.Service, customerID uuid.UUID, smsCode, totpCode string) error {
+    var customer models.Customer
+    if err := db.Select("id", "second_factor").First(&customer, "id = ?", customerID).Error; err != nil {
+        return err
+    }
+
+    allowSMS := customer.SecondFactor != models.SecondFactorTOTP
+    allowTOTP := customer.SecondFactor == models.SecondFactorTOTP || customer.SecondFactor == models.SecondFactorAny
+
+    if allowTOTP && totpCode != "" {
+        err := totpSvc.Verify(customerID, totpCode)
+        if err == nil || !allowSMS || smsCode == "" {
+            if errors.Is(err, totp.ErrInvalidCode) || errors.Is(err, totp.ErrNotEnrolled) {
+                return errSecondFactor
+            }
+            return err
+        }
+    }
+    if allowSMS && smsCode != "" {
+        err := otpSvc.Verify(customerID, otp.PurposeWithdraw, smsCode)
+        if errors.Is(err, otp.ErrInvalidCode) {
+            return errSecondFactor
+        }
+        return err
+    }
+    return errSecondFactor
+}
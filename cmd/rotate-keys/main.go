@@ -0,0 +1,40 @@
+// cmd/rotate-keys/main.go
+package main
+
+import (
+    "flag"
+    "log"
+
+    "weriKana/db"
+    "weriKana/service/keymgmt"
+)
+
+// rotate-keys mints a new key version (unless -reencrypt-only) and
+// re-encrypts Sender/Recipient PII and account secrets under it.
+func main() {
+    reencryptOnly := flag.Bool("reencrypt-only", false, "skip minting a new key; re-encrypt stale values under the current key")
+    flag.Parse()
+
+    db.InitDB()
+
+    if !*reencryptOnly {
+        rotator, ok := db.FieldKeys.Provider().(keymgmt.Rotator)
+        if !ok {
+            log.Fatal("KEY_PROVIDER does not support rotation; add the new key to its source and run with -reencrypt-only")
+        }
+        keyID, err := rotator.Rotate()
+        if err != nil {
+            log.Fatal("Failed to mint new key:", err)
+        }
+        log.Printf("New current key: %s; running servers switch to it within a minute", keyID)
+    }
+
+    report, err := db.RotateEncryptedFields(db.DB)
+    for table, n := range report {
+        log.Printf("%s: %d rows re-encrypted", table, n)
+    }
+    if err != nil {
+        log.Fatal("Rotation failed:", err)
+    }
+    log.Println("Key rotation complete")
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+    "fmt"
+
+    "weriKana/models"
+
+    "gorm.io/gorm"
+)
+
+// blindIndexTables hold email_enc/phone_enc with email_bidx/phone_bidx beside them
+var blindIndexTables = []string{"senders", "recipients"}
+
+// blindIndexRow decrypts a row's contact fields through the models' column type
+type blindIndexRow struct {
+    ID         string
+    Email      models.EncryptedString `gorm:"column:email_enc"`
+    Phone      models.EncryptedString `gorm:"column:phone_enc"`
+    EmailIndex *string                `gorm:"column:email_bidx"`
+    PhoneIndex *string                `gorm:"column:phone_bidx"`
+}
+
+// BackfillReport counts rows given blind indexes per table
+type BackfillReport map[string]int
+
+// BackfillBlindIndexes fills in email_bidx and phone_bidx for rows written
+// before the indexes existed, which lookups such as FindSenderByPhone would
+// otherwise miss. Only NULL indexes are computed, so it is cheap to run on
+// every start once done. Field encryption and the blind index key must be set.
+func BackfillBlindIndexes(db *gorm.DB) (BackfillReport, error) {
+    report := BackfillReport{}
+    for _, table := range blindIndexTables {
+        n, err := backfillTable(db, table)
+        report[table] = n
+        if err != nil {
+            return report, fmt.Errorf("%s: %w", table, err)
+        }
+    }
+    return report, nil
+}
+
+func backfillTable(db *gorm.DB, table string) (int, error) {
+    filled := 0
+    lastID := ""
+    for {
+        var rows []blindIndexRow
+        q := db.Table(table).Select("id, email_enc, phone_enc, email_bidx, phone_bidx").
+            Where("(email_bidx IS NULL AND email_enc <> '') OR (phone_bidx IS NULL AND phone_enc <> '')").
+            Order("id").Limit(rotationBatchSize)
+        if lastID != "" {
+            q = q.Where("id > ?", lastID)
+        }
+        if err := q.Find(&rows).Error; err != nil {
+            return filled, err
+        }
+        if len(rows) == 0 {
+            return filled, nil
+        }
+        for _, row := range rows {
+            lastID = row.ID
+            updates := map[string]any{}
+            if row.EmailIndex == nil && row.Email != "" {
+                idx, err := models.EmailBlindIndex(row.Email.String())
+                if err != nil {
+                    return filled, fmt.Errorf("row %s column email_enc: %w", row.ID, err)
+                }
+                updates["email_bidx"] = idx
+            }
+            if row.PhoneIndex == nil && row.Phone != "" {
+                idx, err := models.PhoneBlindIndex(row.Phone.String())
+                if err != nil {
+                    return filled, fmt.Errorf("row %s column phone_enc: %w", row.ID, err)
+                }
+                updates["phone_bidx"] = idx
+            }
+            if err := db.Table(table).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+                return filled, err
+            }
+            filled++
+        }
+    }
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/keymgmt"
+)
+
+func TestBackfillBlindIndexes(t *testing.T) {
+	kms := keymgmt.NewLocalKMS()
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	models.SetFieldEncryption(keymgmt.NewEnvelope(kms))
+	models.SetBlindIndexKey([]byte(strings.Repeat("b", keymgmt.KeySize)))
+	t.Cleanup(func() {
+		models.SetFieldEncryption(nil)
+		models.SetBlindIndexKey(nil)
+	})
+	db := testdb.Open(t, &models.Sender{}, &models.Recipient{})
+
+	// Rows from before the indexes existed: ciphertext only, as entered.
+	legacy := models.Sender{ID: uuid.New(), CustomerID: uuid.New(), FirstName: "Achieng", LastName: "Otieno",
+		Email: "Achieng@Example.com", PhoneNumber: "0712345678"}
+	if err := db.Omit("Customer", "ExternalID").Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	recipient := models.Recipient{ID: uuid.New(), CustomerID: legacy.CustomerID, FirstName: "Baraka", LastName: "Mwangi", PhoneNumber: "+254722000111"}
+	if err := db.Omit("Customer", "ExternalID").Create(&recipient).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("UPDATE senders SET email_bidx = NULL, phone_bidx = NULL")
+	db.Exec("UPDATE recipients SET email_bidx = NULL, phone_bidx = NULL")
+	// A sender written since is left alone.
+	current := models.Sender{ID: uuid.New(), CustomerID: uuid.New(), FirstName: "Wanjiru", LastName: "Kamau"}
+	if err := current.SetPhone("0733000222"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Omit("Customer", "ExternalID").Create(&current).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := models.FindSenderByPhone(db, "+254712345678"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("legacy sender before the backfill: %v", err)
+	}
+	report, err := BackfillBlindIndexes(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report["senders"] != 1 || report["recipients"] != 1 {
+		t.Fatalf("report = %v", report)
+	}
+
+	for _, find := range []func() (*models.Sender, error){
+		func() (*models.Sender, error) { return models.FindSenderByPhone(db, "+254712345678") },
+		func() (*models.Sender, error) { return models.FindSenderByEmail(db, "achieng@example.com") },
+	} {
+		if s, err := find(); err != nil || s.ID != legacy.ID {
+			t.Fatalf("legacy sender after the backfill = %v, %v", s, err)
+		}
+	}
+	if s, err := models.FindSenderByPhone(db, "0733000222"); err != nil || s.ID != current.ID {
+		t.Fatalf("current sender = %v, %v", s, err)
+	}
+	var phoneIdx string
+	var emailIdx *string
+	db.Raw("SELECT phone_bidx, email_bidx FROM recipients WHERE id = ?", recipient.ID).Row().Scan(&phoneIdx, &emailIdx)
+	if want, _ := models.PhoneBlindIndex("0722000111"); phoneIdx != want || emailIdx != nil {
+		t.Fatalf("recipient indexes = %q, %v", phoneIdx, emailIdx)
+	}
+
+	if report, err := BackfillBlindIndexes(db); err != nil || report["senders"]+report["recipients"] != 0 {
+		t.Fatalf("second run = %v, %v", report, err)
+	}
+}
@@ -1,12 +1,13 @@
 package db
 
 import (
-    "crypto/rand"
+    "errors"
     "log"
     "os"
     "time"
 
     "weriKana/models"
+    "weriKana/service/keymgmt"
 
     "gorm.io/driver/postgres"
     "gorm.io/driver/sqlite" // Import SQLite driver
@@ -16,6 +17,9 @@ import (
 
 var DB *gorm.DB
 
+// FieldKeys encrypts PII columns and account secrets (see models.SetFieldEncryption)
+var FieldKeys *keymgmt.Envelope
+
 // InitDB initializes the database with GORM + all constraints
 func InitDB() {
     dsn := os.Getenv("DATABASE_URL")
@@ -36,7 +40,8 @@ func InitDB() {
 
     var err error
     DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-        Logger: newLogger,
+        Logger:         newLogger,
+        TranslateError: true, // unique violations surface as gorm.ErrDuplicatedKey
     })
     if err != nil {
         log.Fatal("Failed to connect to the database:", err)
@@ -44,6 +49,7 @@ func InitDB() {
 
     // === 1. AutoMigrate All Models ===
     err = DB.AutoMigrate(
+        &models.Bookie{},
         &models.SportsAccount{}, 
         &models.StockAccount{}, 
         &models.ForexAccount{}, 
@@ -52,6 +58,7 @@ func InitDB() {
         &models.SharpAccount{}, 
         &models.Customer{}, 
         &models.Sender{},
+        &models.Recipient{},
         &models.AssetNexus{}, 
         &models.SportsManager{}, 
         &models.StockManager{},
@@ -59,6 +66,27 @@ func InitDB() {
         &models.CryptoManager{},
         &models.SharpProfile{}, 
         &models.Transaction{},
+        &models.OTPCode{},
+        &models.CustomerTOTP{},
+        &models.TOTPRecoveryCode{},
+        &models.Session{},
+        &models.AuditLog{},
+        &models.SigningKey{},
+        &models.APIKey{},
+        &models.KYCDocument{},
+        &models.Transfer{},
+        &models.TransferEvent{},
+        &models.LimitRule{},
+        &models.AMLCase{},
+        &models.AMLCaseNote{},
+        &models.ScreeningResult{},
+        &models.GamblingLimit{},
+        &models.GamblingExclusion{},
+        &models.RealityCheck{},
+        &models.FeeSchedule{},
+        &models.RevenueEntry{},
+        &models.AccountBalance{},
+        &models.FXQuote{},
     )
     if err != nil {
         log.Fatal("Migration failed:", err)
@@ -113,33 +141,73 @@ func InitDB() {
     // Add IsReal to transactions
     DB.Exec("ALTER TABLE transactions ADD COLUMN IF NOT EXISTS is_real BOOLEAN DEFAULT FALSE")
 
+    // Rows written before fees carry no fee, so net equals gross
+    DB.Exec("UPDATE transactions SET net_amount_cents = amount_cents - fee_cents WHERE net_amount_cents = 0 AND amount_cents <> 0")
+
     // Index for fast balance queries
     DB.Exec("CREATE INDEX IF NOT EXISTS idx_bookie_real_balance ON sports_accounts (customer_id, bookie_id) WHERE real_balance_cents > 0")
     DB.Exec("CREATE INDEX IF NOT EXISTS idx_bookie_fake_balance ON sports_accounts (customer_id, bookie_id) WHERE fake_balance_cents > 0")
-    DB.Exec("ALTER TABLE bookies ADD COLUMN IF NOT EXISTS mpesa_number TEXT")
     DB.Exec("ALTER TABLE sports_accounts ADD COLUMN IF NOT EXISTS encrypted_key TEXT")
 
-    // === 5. Seed Master Encryption Key (if not exists) ===
-    seedMasterKey()
+    // Shillings held by crypto accounts move into per-currency balances:
+    // the account's own balance when it is kept in KES, a KES balance otherwise
+    err = DB.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Exec(`
+            INSERT INTO account_balances (id, account_type, account_id, currency, is_real, amount_cents, updated_at)
+            SELECT gen_random_uuid(), 'crypto', id, 'KES', TRUE, fiat_cents_ke, NOW()
+            FROM crypto_accounts WHERE fiat_cents_ke <> 0 AND COALESCE(currency, 'KES') <> 'KES'
+            ON CONFLICT (account_type, account_id, currency, is_real)
+            DO UPDATE SET amount_cents = account_balances.amount_cents + EXCLUDED.amount_cents, updated_at = NOW()
+        `).Error; err != nil {
+            return err
+        }
+        if err := tx.Exec("UPDATE crypto_accounts SET real_balance_cents = real_balance_cents + fiat_cents_ke WHERE fiat_cents_ke <> 0 AND COALESCE(currency, 'KES') = 'KES'").Error; err != nil {
+            return err
+        }
+        return tx.Exec("UPDATE crypto_accounts SET fiat_cents_ke = 0 WHERE fiat_cents_ke <> 0").Error
+    })
+    if err != nil {
+        log.Fatal("Failed to move crypto fiat balances:", err)
+    }
+
+    // === 5. Field Encryption Keys ===
+    if err := InitFieldEncryption(); err != nil {
+        log.Fatal("Failed to initialize encryption keys:", err)
+    }
+    report, err := BackfillBlindIndexes(DB)
+    if err != nil {
+        log.Fatal("Failed to backfill blind indexes:", err)
+    }
+    for table, n := range report {
+        if n > 0 {
+            log.Printf("%s: %d rows given blind indexes", table, n)
+        }
+    }
 
     log.Println("Database initialized successfully with all constraints")
 }
 
-// seedMasterKey generates a 32-byte AES key and stores it in .env or KMS
-func seedMasterKey() {
-    keyPath := ".master_key"
-    if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-        key := make([]byte, 32)
-        if _, err := rand.Read(key); err != nil {
-            log.Fatal("Failed to generate master key:", err)
-        }
-        if err := os.WriteFile(keyPath, key, 0600); err != nil {
-            log.Fatal("Failed to save master key:", err)
+// InitFieldEncryption loads the key provider selected by KEY_PROVIDER and the
+// blind index key, and installs both for model field encryption
+func InitFieldEncryption() error {
+    provider, err := keymgmt.NewProviderFromEnv()
+    if err != nil {
+        return err
+    }
+    if _, err := provider.CurrentKeyID(); errors.Is(err, keymgmt.ErrUnknownKey) {
+        if err := mintFirstKey(DB, provider); err != nil {
+            return err
         }
-        log.Println("Generated new master encryption key")
-    } else {
-        log.Println("Master key already exists")
     }
+    FieldKeys = keymgmt.NewEnvelope(provider)
+    models.SetFieldEncryption(FieldKeys)
+
+    bidxKey, err := keymgmt.LoadBlindIndexKey()
+    if err != nil {
+        return err
+    }
+    models.SetBlindIndexKey(bidxKey)
+    return nil
 }
 
 // SetupDatabase connects to SQLite DB (outside of InitDB function)
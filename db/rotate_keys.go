@@ -0,0 +1,127 @@
+package db
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+
+    "weriKana/models"
+    "weriKana/service/keymgmt"
+
+    "gorm.io/gorm"
+)
+
+// encryptedColumns lists every column holding a models field ciphertext
+var encryptedColumns = map[string][]string{
+    "senders":         {"email_enc", "phone_enc", "ip_address_enc"},
+    "recipients":      {"email_enc", "phone_enc"},
+    "sharp_accounts":  {"encrypted_key"},
+    "sports_accounts": {"encrypted_key"},
+    "stock_accounts":  {"encrypted_key"},
+    "forex_accounts":  {"encrypted_key"},
+    "crypto_accounts": {"encrypted_key", "encrypted_seed"},
+    "customer_totps":  {"secret_enc"},
+    "signing_keys":    {"secret_enc"},
+}
+
+const rotationBatchSize = 500
+
+// RotationReport counts re-encrypted values per table
+type RotationReport map[string]int
+
+// RotateEncryptedFields re-encrypts PII and account secrets under the current
+// key version. Values already on the current key are left untouched, so it is
+// safe to re-run after a partial failure.
+func RotateEncryptedFields(db *gorm.DB) (RotationReport, error) {
+    report := RotationReport{}
+    for table, cols := range encryptedColumns {
+        n, err := rotateTable(db, table, cols)
+        report[table] = n
+        if err != nil {
+            return report, fmt.Errorf("%s: %w", table, err)
+        }
+    }
+    return report, nil
+}
+
+func rotateTable(db *gorm.DB, table string, cols []string) (int, error) {
+    rotated := 0
+    lastID := ""
+    for {
+        var rows []map[string]any
+        q := db.Table(table).Select(append([]string{"id"}, cols...)).Order("id").Limit(rotationBatchSize)
+        if lastID != "" {
+            q = q.Where("id > ?", lastID)
+        }
+        if err := q.Find(&rows).Error; err != nil {
+            return rotated, err
+        }
+        if len(rows) == 0 {
+            return rotated, nil
+        }
+        for _, row := range rows {
+            lastID = fmt.Sprint(row["id"])
+            updates := map[string]any{}
+            for _, col := range cols {
+                value, _ := row[col].(string)
+                next, changed, err := models.RotateFieldCiphertext(value)
+                if err != nil {
+                    return rotated, fmt.Errorf("row %s column %s: %w", lastID, col, err)
+                }
+                if changed {
+                    updates[col] = next
+                }
+            }
+            if len(updates) == 0 {
+                continue
+            }
+            if err := db.Table(table).Where("id = ?", lastID).Updates(updates).Error; err != nil {
+                return rotated, err
+            }
+            rotated++
+        }
+    }
+}
+
+// mintFirstKey creates the first key version for a provider that has none.
+// It refuses when rows already hold ciphertext: a new key cannot open them,
+// so the key files or KMS that sealed them must be restored instead.
+func mintFirstKey(db *gorm.DB, provider keymgmt.KeyProvider) error {
+    rotator, ok := provider.(keymgmt.Rotator)
+    if !ok {
+        return errors.New("KEY_PROVIDER has no current key")
+    }
+    table, err := firstEncryptedTable(db)
+    if err != nil {
+        return err
+    }
+    if table != "" {
+        return fmt.Errorf("no current encryption key but %s holds encrypted values; restore the keys they were sealed with", table)
+    }
+    _, err = rotator.Rotate()
+    return err
+}
+
+// firstEncryptedTable returns the first table with a stored ciphertext, or ""
+func firstEncryptedTable(db *gorm.DB) (string, error) {
+    tables := make([]string, 0, len(encryptedColumns))
+    for table := range encryptedColumns {
+        tables = append(tables, table)
+    }
+    sort.Strings(tables)
+    for _, table := range tables {
+        var conds []string
+        for _, col := range encryptedColumns[table] {
+            conds = append(conds, col+" <> ''")
+        }
+        var n int64
+        if err := db.Table(table).Where(strings.Join(conds, " OR ")).Limit(1).Count(&n).Error; err != nil {
+            return "", err
+        }
+        if n > 0 {
+            return table, nil
+        }
+    }
+    return "", nil
+}
@@ -0,0 +1,66 @@
+// Package testdb opens sqlite databases for service tests
+package testdb
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// sqliteUUID stands in for postgres' gen_random_uuid() column default
+const sqliteUUID = "(lower(hex(randomblob(16))))"
+
+// jsonTypes are the column types whose values the models scan from []byte
+var jsonTypes = map[schema.DataType]bool{"json": true, "jsonb": true}
+
+// Open returns an in-memory database with tables for models, and any models
+// they belong to, migrated from the models' own tags. Errors are translated,
+// so unique violations surface as gorm.ErrDuplicatedKey.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		TranslateError:                           true,
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[*schema.Schema]bool{}
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			t.Fatal(err)
+		}
+		sqliteDefaults(stmt.Schema, seen)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// sqliteDefaults rewrites the defaults of s and its relations for sqlite:
+// uuids get a stand-in for gen_random_uuid(), and JSON literals are stored as
+// blobs, since sqlite hands text back as a string rather than the []byte the
+// postgres driver returns. gorm caches schemas per database, so the models'
+// tags are untouched for everyone else.
+func sqliteDefaults(s *schema.Schema, seen map[*schema.Schema]bool) {
+	if seen[s] {
+		return
+	}
+	seen[s] = true
+	for _, f := range s.Fields {
+		switch {
+		case strings.HasPrefix(f.DefaultValue, "gen_random_uuid"):
+			f.DefaultValue = sqliteUUID
+		case jsonTypes[f.DataType] && strings.HasPrefix(f.DefaultValue, "'"):
+			f.DefaultValue = "(CAST(" + f.DefaultValue + " AS BLOB))"
+		}
+	}
+	for _, rel := range s.Relationships.Relations {
+		sqliteDefaults(rel.FieldSchema, seen)
+	}
+}
@@ -0,0 +1,82 @@
+// middleware/account.go
+package middleware
+
+import (
+    "errors"
+    "slices"
+    "strings"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "weriKana/models"
+)
+
+// Token scopes. A token grants actions; which account they apply to comes from the path.
+const (
+    ScopeAccountsRead     = "accounts:read"
+    ScopeAccountsTrade    = "accounts:trade"
+    ScopeAccountsDeposit  = "accounts:deposit"
+    ScopeAccountsWithdraw = "accounts:withdraw"
+)
+
+// AllScopes is what a login grants when no narrower set is requested
+var AllScopes = []string{ScopeAccountsRead, ScopeAccountsTrade, ScopeAccountsDeposit, ScopeAccountsWithdraw}
+
+// ValidScopes reports whether every entry is a known scope
+func ValidScopes(scopes []string) bool {
+    for _, s := range scopes {
+        if !slices.Contains(AllScopes, s) {
+            return false
+        }
+    }
+    return true
+}
+
+// JoinScopes formats scopes for the "scope" claim
+func JoinScopes(scopes []string) string {
+    return strings.Join(scopes, " ")
+}
+
+// RequireScope rejects tokens that were not granted scope
+func RequireScope(scope string) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        scopes, _ := c.Locals("scopes").([]string)
+        if !slices.Contains(scopes, scope) {
+            return c.Status(403).JSON(fiber.Map{"error": "Token lacks scope " + scope})
+        }
+        return c.Next()
+    }
+}
+
+// AccountAccess resolves the :id path parameter to an account of any type and
+// authorizes the caller against its CustomerID. Accounts owned by someone else
+// answer 404 so IDs cannot be probed. The result is stored in Locals("account").
+func AccountAccess(db *gorm.DB) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        accountID, err := uuid.Parse(c.Params("id"))
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{"error": "Invalid account id"})
+        }
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Invalid token claims"})
+        }
+
+        ref, err := models.ResolveAccount(db, accountID)
+        if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && ref.CustomerID != customerID) {
+            return c.Status(404).JSON(fiber.Map{"error": "Account not found"})
+        }
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to load account"})
+        }
+        c.Locals("account", ref)
+        return c.Next()
+    }
+}
+
+// Account returns the account resolved by AccountAccess
+func Account(c *fiber.Ctx) *models.AccountRef {
+    ref, _ := c.Locals("account").(*models.AccountRef)
+    return ref
+}
@@ -2,40 +2,104 @@
 package middleware
 
 import (
+    "crypto"
+    "strings"
+
     "github.com/gofiber/fiber/v2"
     "github.com/golang-jwt/jwt/v5"
+    "weriKana/models"
 )
 
+// HeaderAPIKey carries a "<prefix>.<secret>" API key instead of a bearer token
+const HeaderAPIKey = "X-API-Key"
+
+// Claims are customer-scoped; the account is chosen per request (see AccountAccess)
 type Claims struct {
-    CustomerID  string `json:"customer_id"`
-    SharpID     string `json:"sharp_id"`
-    AccountType string `json:"account_type"`
+    CustomerID string `json:"customer_id"`
+    SessionID  string `json:"sid"`
+    Scope      string `json:"scope"` // space-separated, e.g. "accounts:read accounts:trade"
+    Role       string `json:"role"`  // customer, support, finance or admin
     jwt.RegisteredClaims
 }
 
-func AuthMiddleware(secretKey string) fiber.Handler {
+// SessionChecker reports whether a session is still live (not logged out or revoked)
+type SessionChecker interface {
+    IsActive(sessionID string) bool
+}
+
+// KeyResolver returns the verification key and algorithm for a token's kid
+type KeyResolver interface {
+    VerificationKey(kid string) (alg string, key crypto.PublicKey, ok bool)
+}
+
+// APIKeyVerifier resolves a raw API key presented from ip (apikeys.Service implements it)
+type APIKeyVerifier interface {
+    Verify(raw, ip string) (*models.APIKey, error)
+}
+
+// AuthMiddleware validates the bearer token against the key named by its kid
+// header and rejects tokens whose session was revoked. When apiKeys is non-nil
+// an X-API-Key header is accepted instead; such callers act as the key's
+// customer with the key's scopes and have no session.
+func AuthMiddleware(keys KeyResolver, sessions SessionChecker, apiKeys APIKeyVerifier) fiber.Handler {
     return func(c *fiber.Ctx) error {
+        if raw := c.Get(HeaderAPIKey); raw != "" && apiKeys != nil {
+            key, err := apiKeys.Verify(raw, c.IP())
+            if err != nil {
+                return c.Status(401).JSON(fiber.Map{"error": "Invalid API key"})
+            }
+            c.Locals("customer_id", key.CustomerID.String())
+            c.Locals("scopes", strings.Fields(key.Scopes))
+            c.Locals("role", string(models.RoleCustomer))
+            c.Locals("session_id", "")
+            c.Locals("jti", "")
+            c.Locals("api_key_id", key.ID.String())
+            return c.Next()
+        }
+
         authHeader := c.Get("Authorization")
         if authHeader == "" || len(authHeader) < 7 || authHeader[:7] != "Bearer " {
             return c.Status(401).JSON(fiber.Map{"error": "Missing or invalid token"})
         }
         tokenString := authHeader[7:]
         token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-            if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+            kid, _ := token.Header["kid"].(string)
+            alg, key, ok := keys.VerificationKey(kid)
+            if !ok {
+                return nil, fiber.NewError(fiber.StatusUnauthorized, "Unknown signing key")
+            }
+            // The key fixes the algorithm, so a token cannot pick a weaker one.
+            if token.Method.Alg() != alg {
                 return nil, fiber.NewError(fiber.StatusUnauthorized, "Unexpected signing method")
             }
-            return []byte(secretKey), nil
-        })
+            return key, nil
+        }, jwt.WithValidMethods([]string{"EdDSA", "RS256", "HS256"}), jwt.WithExpirationRequired())
         if err != nil || !token.Valid {
             return c.Status(401).JSON(fiber.Map{"error": "Invalid token"})
         }
         claims, ok := token.Claims.(*Claims)
-        if !ok {
+        if !ok || claims.SessionID == "" || claims.ID == "" {
             return c.Status(401).JSON(fiber.Map{"error": "Invalid token claims"})
         }
+        if !sessions.IsActive(claims.SessionID) {
+            return c.Status(401).JSON(fiber.Map{"error": "Session revoked"})
+        }
         c.Locals("customer_id", claims.CustomerID)
-        c.Locals("sharp_id", claims.SharpID)
-        c.Locals("account_type", claims.AccountType)
+        c.Locals("scopes", strings.Fields(claims.Scope))
+        c.Locals("role", claims.Role)
+        c.Locals("session_id", claims.SessionID)
+        c.Locals("jti", claims.ID)
+        return c.Next()
+    }
+}
+
+// RequireSession rejects API-key callers. Credentials (passwords, sessions,
+// keys, second factors) are only managed from an interactive login.
+func RequireSession() fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        if sid, _ := c.Locals("session_id").(string); sid == "" {
+            return c.Status(403).JSON(fiber.Map{"error": "This endpoint requires a logged-in session"})
+        }
         return c.Next()
     }
 }
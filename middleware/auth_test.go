@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"weriKana/models"
+)
+
+type staticKeys map[string]struct {
+	alg string
+	key crypto.PublicKey
+}
+
+func (s staticKeys) VerificationKey(kid string) (string, crypto.PublicKey, bool) {
+	k, ok := s[kid]
+	return k.alg, k.key, ok
+}
+
+type sessionSet map[string]bool
+
+func (s sessionSet) IsActive(id string) bool { return s[id] }
+
+type apiKeySet map[string]*models.APIKey
+
+func (s apiKeySet) Verify(raw, ip string) (*models.APIKey, error) {
+	if k, ok := s[raw]; ok {
+		return k, nil
+	}
+	return nil, errors.New("invalid")
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := staticKeys{"ed1": {alg: "EdDSA", key: pub}}
+	sessions := sessionSet{"live": true}
+
+	app := fiber.New()
+	apiKeys := apiKeySet{"wk_1.secret": {CustomerID: uuid.New(), Scopes: ScopeAccountsRead}}
+
+	app.Get("/", AuthMiddleware(keys, sessions, apiKeys), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("customer_id").(string))
+	})
+
+	token := func(method jwt.SigningMethod, kid, sid string, key interface{}) string {
+		claims := &Claims{
+			CustomerID: "c1",
+			SessionID:  sid,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti-1",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		}
+		tok := jwt.NewWithClaims(method, claims)
+		tok.Header["kid"] = kid
+		signed, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	cases := map[string]struct {
+		token string
+		want  int
+	}{
+		"valid":           {token(jwt.SigningMethodEdDSA, "ed1", "live", priv), 200},
+		"unknown kid":     {token(jwt.SigningMethodEdDSA, "ed2", "live", priv), 401},
+		"revoked session": {token(jwt.SigningMethodEdDSA, "ed1", "gone", priv), 401},
+		// HMAC keyed with the public key must not pass as the EdDSA key
+		"alg confusion": {token(jwt.SigningMethodHS256, "ed1", "live", []byte(pub)), 401},
+		"missing":       {"", 401},
+		"api key":       {"key:wk_1.secret", 200},
+		"bad api key":   {"key:wk_1.other", 401},
+	}
+	for name, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		if raw, ok := strings.CutPrefix(tc.token, "key:"); ok {
+			req.Header.Set(HeaderAPIKey, raw)
+		} else if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", name, resp.StatusCode, tc.want)
+		}
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Locals("session_id", c.Get("X-Session"))
+		return c.Next()
+	}, RequireSession(), func(c *fiber.Ctx) error { return c.SendStatus(204) })
+
+	for sid, want := range map[string]int{"": 403, "s1": 204} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Session", sid)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("session %q: status %d, want %d", sid, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// middleware/gambling.go
+package middleware
+
+import (
+    "github.com/gofiber/fiber/v2"
+    "weriKana/models"
+)
+
+// GamblingChecker applies a customer's own player-protection settings
+// (gambling.Service implements it)
+type GamblingChecker interface {
+    Check(req models.LimitRequest) (*models.GamblingBlock, error)
+}
+
+// EnforcePlayerProtection rejects deposits and real-money trades refused by
+// the customer's cool-off, self-exclusion or deposit and loss limits.
+// Refusals are 403 with the reason under "block".
+func EnforcePlayerProtection(checker GamblingChecker, action models.LimitAction) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        req, err := moneyRequest(c, action)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        if req == nil {
+            return c.Next()
+        }
+        block, err := checker.Check(*req)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to check player protection"})
+        }
+        if block != nil {
+            return c.Status(403).JSON(fiber.Map{"error": "Blocked by player protection", "block": block})
+        }
+        return c.Next()
+    }
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"weriKana/models"
+)
+
+// coolingOff blocks real-money requests, as a cool-off would for trades
+type coolingOff struct{ until time.Time }
+
+func (c coolingOff) Check(req models.LimitRequest) (*models.GamblingBlock, error) {
+	if !req.IsReal {
+		return nil, nil
+	}
+	return &models.GamblingBlock{Reason: "cool-off", Exclusion: models.GamblingCoolOff, Until: &c.until}, nil
+}
+
+func TestEnforcePlayerProtection(t *testing.T) {
+	until := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		c.Locals("customer_id", uuid.NewString())
+		return c.Next()
+	}, EnforcePlayerProtection(coolingOff{until}, models.LimitTrade), func(c *fiber.Ctx) error { return c.SendStatus(204) })
+
+	cases := []struct {
+		body string
+		want int
+	}{
+		{`{"amount_cents":500,"is_real":true}`, 403},
+		{`{"amount_cents":500}`, 204},
+		{`not json`, 204},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.body, resp.StatusCode, tc.want)
+		}
+		if resp.StatusCode == 403 {
+			var out struct {
+				Block models.GamblingBlock `json:"block"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.Block.Exclusion != models.GamblingCoolOff || !out.Block.Until.Equal(until) {
+				t.Errorf("%s: rejection body %+v, %v", tc.body, out, err)
+			}
+		}
+	}
+}
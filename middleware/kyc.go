@@ -0,0 +1,44 @@
+// middleware/kyc.go
+package middleware
+
+import (
+    "encoding/json"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+)
+
+// KYCStatusReader reports a customer's own KYC status (kyc.Service implements it)
+type KYCStatusReader interface {
+    Status(customerID uuid.UUID) (models.KYCStatus, error)
+}
+
+// RequireKYCForRealMoney rejects requests whose JSON body sets "is_real": true
+// until the caller's KYC is verified. Fake-money requests pass through.
+func RequireKYCForRealMoney(kyc KYCStatusReader) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        var body struct {
+            IsReal bool `json:"is_real"`
+        }
+        // Malformed bodies are left for the handler to reject.
+        if err := json.Unmarshal(c.Body(), &body); err != nil || !body.IsReal {
+            return c.Next()
+        }
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        status, err := kyc.Status(customerID)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to check KYC status"})
+        }
+        if status != models.KYCVerified {
+            return c.Status(403).JSON(fiber.Map{
+                "error":      "KYC verification required for real-money transfers",
+                "kyc_status": status,
+            })
+        }
+        return c.Next()
+    }
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"weriKana/models"
+)
+
+type kycStatuses map[uuid.UUID]models.KYCStatus
+
+func (k kycStatuses) Status(id uuid.UUID) (models.KYCStatus, error) {
+	if s, ok := k[id]; ok {
+		return s, nil
+	}
+	return models.KYCUnverified, nil
+}
+
+func TestRequireKYCForRealMoney(t *testing.T) {
+	verified, pending := uuid.New(), uuid.New()
+	statuses := kycStatuses{verified: models.KYCVerified, pending: models.KYCPending}
+
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		c.Locals("customer_id", c.Get("X-Customer"))
+		return c.Next()
+	}, RequireKYCForRealMoney(statuses), func(c *fiber.Ctx) error { return c.SendStatus(204) })
+
+	cases := []struct {
+		customer uuid.UUID
+		body     string
+		want     int
+	}{
+		{pending, `{"is_real":true,"amount_cents":100}`, 403},
+		{pending, `{"is_real":false}`, 204},
+		{pending, `{}`, 204},
+		{verified, `{"is_real":true}`, 204},
+		{uuid.New(), `{"is_real":true}`, 403},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Customer", tc.customer.String())
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s %s: status %d, want %d", statuses[tc.customer], tc.body, resp.StatusCode, tc.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// middleware/limits.go
+package middleware
+
+import (
+    "encoding/json"
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/models"
+)
+
+// LimitChecker evaluates transaction limits (limits.Service implements it)
+type LimitChecker interface {
+    Check(req models.LimitRequest) (*models.LimitViolation, error)
+}
+
+var errNoCustomer = errors.New("no customer on request")
+
+// moneyRequest reads "amount_cents" (or "amount") and "is_real" from the
+// JSON body; per-account routes also supply the asset class from
+// AccountAccess. It returns nil for malformed bodies and missing amounts,
+// which are left for the handler to reject.
+func moneyRequest(c *fiber.Ctx, action models.LimitAction) (*models.LimitRequest, error) {
+    var body struct {
+        AmountCents int64 `json:"amount_cents"`
+        Amount      int64 `json:"amount"`
+        IsReal      bool  `json:"is_real"`
+    }
+    if err := json.Unmarshal(c.Body(), &body); err != nil {
+        return nil, nil
+    }
+    amount := body.AmountCents
+    if amount == 0 {
+        amount = body.Amount
+    }
+    if amount <= 0 {
+        return nil, nil
+    }
+    customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+    if err != nil {
+        return nil, errNoCustomer
+    }
+    req := &models.LimitRequest{
+        CustomerID:  customerID,
+        Action:      action,
+        IsReal:      body.IsReal,
+        AmountCents: amount,
+    }
+    if ref := Account(c); ref != nil {
+        req.AccountID, req.AssetClass = ref.ID, ref.Type
+    }
+    return req, nil
+}
+
+// EnforceLimits rejects requests that would break a limit rule for action.
+// Refusals are 403 with the violated rule under "limit".
+func EnforceLimits(limits LimitChecker, action models.LimitAction) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        req, err := moneyRequest(c, action)
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+        }
+        if req == nil {
+            return c.Next()
+        }
+        violation, err := limits.Check(*req)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to check limits"})
+        }
+        if violation != nil {
+            return c.Status(403).JSON(fiber.Map{"error": "Transaction limit exceeded", "limit": violation})
+        }
+        return c.Next()
+    }
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"weriKana/models"
+)
+
+// capAt refuses any request above its value and records what it saw
+type capAt struct {
+	max  int64
+	seen []models.LimitRequest
+}
+
+func (l *capAt) Check(req models.LimitRequest) (*models.LimitViolation, error) {
+	l.seen = append(l.seen, req)
+	if req.AmountCents > l.max {
+		return &models.LimitViolation{Rule: "test cap", Kind: models.LimitPerTransaction, LimitCents: l.max, AttemptedCents: req.AmountCents}, nil
+	}
+	return nil, nil
+}
+
+func TestEnforceLimits(t *testing.T) {
+	limits := &capAt{max: 1000}
+	account := &models.AccountRef{ID: uuid.New(), Type: "forex"}
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		c.Locals("customer_id", uuid.NewString())
+		c.Locals("account", account)
+		return c.Next()
+	}, EnforceLimits(limits, models.LimitTrade), func(c *fiber.Ctx) error { return c.SendStatus(204) })
+
+	cases := []struct {
+		body string
+		want int
+	}{
+		{`{"amount_cents":500,"is_real":true}`, 204},
+		{`{"amount_cents":1500,"is_real":true}`, 403},
+		{`{"amount":2000}`, 403},
+		{`{"amount_cents":0}`, 204},
+		{`not json`, 204},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.body, resp.StatusCode, tc.want)
+		}
+		if resp.StatusCode == 403 {
+			var out struct {
+				Limit models.LimitViolation `json:"limit"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.Limit.Rule != "test cap" {
+				t.Errorf("%s: rejection body %+v, %v", tc.body, out, err)
+			}
+		}
+	}
+	if len(limits.seen) != 3 || limits.seen[0].AssetClass != "forex" || !limits.seen[0].IsReal || limits.seen[0].Action != models.LimitTrade {
+		t.Fatalf("requests = %+v", limits.seen)
+	}
+}
@@ -0,0 +1,63 @@
+// middleware/rbac.go
+package middleware
+
+import (
+    "slices"
+
+    "github.com/gofiber/fiber/v2"
+    "weriKana/models"
+)
+
+// Back-office permissions
+const (
+    PermCustomersRead       = "customers:read"
+    PermTransactionsRead    = "transactions:read"
+    PermTransactionsReverse = "transactions:reverse"
+    PermRolesManage         = "roles:manage"
+    PermAuditRead           = "audit:read"
+    PermKYCReview           = "kyc:review"
+    PermLimitsManage        = "limits:manage"
+    PermAMLReview           = "aml:review"
+    PermScreeningReview     = "screening:review"
+    PermBookiesManage       = "bookies:manage"
+    PermFeesManage          = "fees:manage"
+)
+
+// rolePermissions is the single source of truth for what each role may do
+var rolePermissions = map[models.Role][]string{
+    models.RoleCustomer: {},
+    models.RoleSupport:  {PermCustomersRead, PermTransactionsRead, PermKYCReview, PermScreeningReview},
+    models.RoleFinance:  {PermCustomersRead, PermTransactionsRead, PermTransactionsReverse, PermLimitsManage, PermAMLReview, PermBookiesManage, PermFeesManage},
+    models.RoleAdmin:    {PermCustomersRead, PermTransactionsRead, PermTransactionsReverse, PermRolesManage, PermAuditRead, PermKYCReview, PermLimitsManage, PermAMLReview, PermScreeningReview, PermBookiesManage, PermFeesManage},
+}
+
+// HasPermission reports whether role grants perm
+func HasPermission(role models.Role, perm string) bool {
+    return slices.Contains(rolePermissions[role], perm)
+}
+
+// RequireStaff admits any non-customer role; layer it after AuthMiddleware
+func RequireStaff() fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        role := models.Role(roleOf(c))
+        if !role.Valid() || role == models.RoleCustomer {
+            return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+        }
+        return c.Next()
+    }
+}
+
+// RequirePermission rejects callers whose role lacks perm
+func RequirePermission(perm string) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        if !HasPermission(models.Role(roleOf(c)), perm) {
+            return c.Status(403).JSON(fiber.Map{"error": "Missing permission " + perm})
+        }
+        return c.Next()
+    }
+}
+
+func roleOf(c *fiber.Ctx) string {
+    role, _ := c.Locals("role").(string)
+    return role
+}
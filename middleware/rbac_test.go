@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"weriKana/models"
+)
+
+func TestRolePermissions(t *testing.T) {
+	cases := []struct {
+		role models.Role
+		perm string
+		want bool
+	}{
+		{models.RoleCustomer, PermCustomersRead, false},
+		{models.RoleSupport, PermCustomersRead, true},
+		{models.RoleSupport, PermTransactionsReverse, false},
+		{models.RoleFinance, PermTransactionsReverse, true},
+		{models.RoleFinance, PermRolesManage, false},
+		{models.RoleAdmin, PermRolesManage, true},
+		{models.Role("root"), PermCustomersRead, false},
+	}
+	for _, tc := range cases {
+		if got := HasPermission(tc.role, tc.perm); got != tc.want {
+			t.Errorf("HasPermission(%s, %s) = %v, want %v", tc.role, tc.perm, got, tc.want)
+		}
+	}
+}
+
+func TestRequireStaffAndPermission(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("role", c.Get("X-Role"))
+		return c.Next()
+	})
+	app.Post("/reverse", RequireStaff(), RequirePermission(PermTransactionsReverse), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	for role, want := range map[string]int{"": 403, "customer": 403, "support": 403, "finance": 200, "admin": 200} {
+		req := httptest.NewRequest("POST", "/reverse", nil)
+		req.Header.Set("X-Role", role)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("role %q: status %d, want %d", role, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// middleware/signed_request.go
+package middleware
+
+import (
+    "errors"
+
+    "github.com/gofiber/fiber/v2"
+    "github.com/google/uuid"
+    "weriKana/service/keystore"
+)
+
+// Request-signing headers; see keystore.CanonicalRequest for what is signed
+const (
+    HeaderKeyID     = "X-Key-Id"
+    HeaderTimestamp = "X-Timestamp"
+    HeaderNonce     = "X-Nonce"
+    HeaderSignature = "X-Signature"
+)
+
+// RequireSignedRequest verifies the caller's HMAC request signature; layer it after AuthMiddleware
+func RequireSignedRequest(ks *keystore.KeyStore) fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        customerID, err := uuid.Parse(c.Locals("customer_id").(string))
+        if err != nil {
+            return c.Status(401).JSON(fiber.Map{"error": "Invalid token claims"})
+        }
+        err = ks.VerifyRequest(customerID, keystore.SignedRequest{
+            KeyID:     c.Get(HeaderKeyID),
+            Method:    c.Method(),
+            Path:      c.OriginalURL(),
+            Timestamp: c.Get(HeaderTimestamp),
+            Nonce:     c.Get(HeaderNonce),
+            Body:      c.Body(),
+            Signature: c.Get(HeaderSignature),
+        })
+        switch {
+        case err == nil:
+            return c.Next()
+        case errors.Is(err, keystore.ErrUnknownKey), errors.Is(err, keystore.ErrBadSignature),
+            errors.Is(err, keystore.ErrStaleRequest), errors.Is(err, keystore.ErrReplayedRequest):
+            return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+        default:
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to verify signature"})
+        }
+    }
+}
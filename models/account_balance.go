@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AccountBalance holds an account's money in a currency other than its own.
+// The account's own currency stays in its real and fake balance columns, so
+// code that only deals in that currency is unaffected.
+type AccountBalance struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"-"`
+	AccountType string    `gorm:"size:10;not null;uniqueIndex:idx_account_balance" json:"-"`
+	AccountID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_account_balance" json:"-"`
+	Currency    string    `gorm:"size:3;not null;uniqueIndex:idx_account_balance" json:"currency"`
+	IsReal      bool      `gorm:"not null;uniqueIndex:idx_account_balance" json:"is_real"`
+	AmountCents int64     `gorm:"type:bigint;not null" json:"amount_cents"` // minor units of Currency
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (AccountBalance) TableName() string {
+	return "account_balances"
+}
+
+// AccountCurrency returns the currency an account keeps its own balances in
+func AccountCurrency(db *gorm.DB, accountType string, id uuid.UUID) (string, error) {
+	table, ok := accountTables[accountType]
+	if !ok {
+		return "", errors.New("unknown account type " + accountType)
+	}
+	var codes []sql.NullString
+	if err := db.Table(table).Where("id = ?", id).Limit(1).Pluck("currency", &codes).Error; err != nil {
+		return "", err
+	}
+	if len(codes) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+	if codes[0].String == "" {
+		return DefaultCurrency, nil
+	}
+	return codes[0].String, nil
+}
+
+// AdjustCurrencyBalance adds delta minor units of currency to the real or
+// fake balance of an account, whether or not it is the account's own currency
+func AdjustCurrencyBalance(db *gorm.DB, accountType string, id uuid.UUID, currency string, isReal bool, delta int64) error {
+	own, err := AccountCurrency(db, accountType, id)
+	if err != nil {
+		return err
+	}
+	if currency == own {
+		return AdjustBalance(db, accountType, id, isReal, delta)
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_type"}, {Name: "account_id"}, {Name: "currency"}, {Name: "is_real"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"amount_cents": gorm.Expr("account_balances.amount_cents + ?", delta), "updated_at": time.Now()}),
+	}).Create(&AccountBalance{
+		ID:          uuid.New(),
+		AccountType: accountType,
+		AccountID:   id,
+		Currency:    currency,
+		IsReal:      isReal,
+		AmountCents: delta,
+	}).Error
+}
+
+// DebitCurrencyBalance subtracts amount minor units of currency from an
+// account, refusing to take it below zero
+func DebitCurrencyBalance(db *gorm.DB, accountType string, id uuid.UUID, currency string, isReal bool, amount int64) error {
+	own, err := AccountCurrency(db, accountType, id)
+	if err != nil {
+		return err
+	}
+	if currency == own {
+		return DebitBalance(db, accountType, id, isReal, amount)
+	}
+	res := db.Model(&AccountBalance{}).
+		Where("account_type = ? AND account_id = ? AND currency = ? AND is_real = ? AND amount_cents >= ?", accountType, id, currency, isReal, amount).
+		Updates(map[string]interface{}{"amount_cents": gorm.Expr("amount_cents - ?", amount), "updated_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// CurrencyBalances lists an account's balances in every currency it holds,
+// its own currency first
+func CurrencyBalances(db *gorm.DB, accountType string, id uuid.UUID) ([]AccountBalance, error) {
+	table, ok := accountTables[accountType]
+	if !ok {
+		return nil, errors.New("unknown account type " + accountType)
+	}
+	var own struct {
+		Currency         sql.NullString
+		RealBalanceCents int64
+		FakeBalanceCents int64
+	}
+	if err := db.Table(table).Select("currency", "real_balance_cents", "fake_balance_cents").Where("id = ?", id).Take(&own).Error; err != nil {
+		return nil, err
+	}
+	currency := own.Currency.String
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	out := []AccountBalance{
+		{AccountType: accountType, AccountID: id, Currency: currency, IsReal: true, AmountCents: own.RealBalanceCents},
+		{AccountType: accountType, AccountID: id, Currency: currency, IsReal: false, AmountCents: own.FakeBalanceCents},
+	}
+	var others []AccountBalance
+	err := db.Where("account_type = ? AND account_id = ? AND currency <> ?", accountType, id, currency).
+		Order("currency, is_real DESC").Find(&others).Error
+	return append(out, others...), err
+}
@@ -0,0 +1,120 @@
+// models/account_ref.go
+package models
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AccountTypes lists the per-asset account kinds in lookup order
+var AccountTypes = []string{"sharp", "sports", "stock", "forex", "crypto"}
+
+var accountTables = map[string]string{
+	"sharp":  "sharp_accounts",
+	"sports": "sports_accounts",
+	"stock":  "stock_accounts",
+	"forex":  "forex_accounts",
+	"crypto": "crypto_accounts",
+}
+
+// AccountRef identifies an account of any type and its owner
+type AccountRef struct {
+	ID         uuid.UUID `json:"id"`
+	CustomerID uuid.UUID `json:"-"`
+	Type       string    `json:"account_type" gorm:"-"`
+}
+
+// ResolveAccount finds which account table holds id
+func ResolveAccount(db *gorm.DB, id uuid.UUID) (*AccountRef, error) {
+	for _, t := range AccountTypes {
+		var ref AccountRef
+		err := db.Table(accountTables[t]).
+			Select("id", "customer_id").
+			Where("id = ? AND deleted_at IS NULL", id).
+			Take(&ref).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ref.Type = t
+		return &ref, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// ListAccountRefs returns every live account the customer owns across all types
+func ListAccountRefs(db *gorm.DB, customerID uuid.UUID) ([]AccountRef, error) {
+	var refs []AccountRef
+	for _, t := range AccountTypes {
+		var found []AccountRef
+		err := db.Table(accountTables[t]).
+			Select("id", "customer_id").
+			Where("customer_id = ? AND deleted_at IS NULL", customerID).
+			Find(&found).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range found {
+			found[i].Type = t
+		}
+		refs = append(refs, found...)
+	}
+	return refs, nil
+}
+
+// AdjustBalance adds delta cents to the real or fake balance of an account
+func AdjustBalance(db *gorm.DB, accountType string, id uuid.UUID, isReal bool, delta int64) error {
+	table, ok := accountTables[accountType]
+	if !ok {
+		return errors.New("unknown account type " + accountType)
+	}
+	column := "fake_balance_cents"
+	if isReal {
+		column = "real_balance_cents"
+	}
+	res := db.Table(table).Where("id = ?", id).Update(column, gorm.Expr(column+" + ?", delta))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ErrInsufficientFunds is returned by DebitBalance when the balance is too low
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// DebitBalance subtracts amount cents from the real or fake balance of an
+// account, refusing to take it below zero
+func DebitBalance(db *gorm.DB, accountType string, id uuid.UUID, isReal bool, amount int64) error {
+	table, ok := accountTables[accountType]
+	if !ok {
+		return errors.New("unknown account type " + accountType)
+	}
+	column := "fake_balance_cents"
+	if isReal {
+		column = "real_balance_cents"
+	}
+	res := db.Table(table).
+		Where("id = ? AND "+column+" >= ?", id, amount).
+		Update(column, gorm.Expr(column+" - ?", amount))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		var n int64
+		if err := db.Table(table).Where("id = ?", id).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return ErrInsufficientFunds
+	}
+	return nil
+}
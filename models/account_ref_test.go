@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+)
+
+func newAccountRefDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return testdb.Open(t, &SharpAccount{}, &SportsAccount{}, &StockAccount{}, &ForexAccount{}, &CryptoAccount{})
+}
+
+func TestResolveAccount(t *testing.T) {
+	db := newAccountRefDB(t)
+	owner := uuid.New()
+	sports, crypto, deleted := uuid.New(), uuid.New(), uuid.New()
+	db.Exec("INSERT INTO sports_accounts (id, customer_id, bookie_id, manager_id) VALUES (?, ?, ?, ?)", sports, owner, uuid.New(), uuid.New())
+	db.Exec("INSERT INTO crypto_accounts (id, customer_id, bookie_id, manager_id) VALUES (?, ?, ?, ?)", crypto, owner, uuid.New(), uuid.New())
+	db.Exec("INSERT INTO stock_accounts (id, customer_id, bookie_id, manager_id, deleted_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)", deleted, owner, uuid.New(), uuid.New())
+	db.Exec("INSERT INTO forex_accounts (id, customer_id, bookie_id, manager_id) VALUES (?, ?, ?, ?)", uuid.New(), uuid.New(), uuid.New(), uuid.New())
+
+	ref, err := ResolveAccount(db, crypto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Type != "crypto" || ref.CustomerID != owner || ref.ID != crypto {
+		t.Fatalf("ref = %+v", ref)
+	}
+	for _, id := range []uuid.UUID{deleted, uuid.New()} {
+		if _, err := ResolveAccount(db, id); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("%s: want ErrRecordNotFound, got %v", id, err)
+		}
+	}
+
+	refs, err := ListAccountRefs(db, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Type != "sports" || refs[1].Type != "crypto" {
+		t.Fatalf("refs = %+v", refs)
+	}
+}
+
+func TestAdjustBalance(t *testing.T) {
+	db := newAccountRefDB(t)
+	id := uuid.New()
+	db.Exec("INSERT INTO forex_accounts (id, customer_id, bookie_id, manager_id, real_balance_cents, fake_balance_cents) VALUES (?, ?, ?, ?, 1000, 50)", id, uuid.New(), uuid.New(), uuid.New())
+
+	if err := AdjustBalance(db, "forex", id, true, -300); err != nil {
+		t.Fatal(err)
+	}
+	if err := AdjustBalance(db, "forex", id, false, 25); err != nil {
+		t.Fatal(err)
+	}
+	var real, fake int64
+	db.Raw("SELECT real_balance_cents, fake_balance_cents FROM forex_accounts WHERE id = ?", id).Row().Scan(&real, &fake)
+	if real != 700 || fake != 75 {
+		t.Fatalf("balances = %d/%d, want 700/75", real, fake)
+	}
+
+	if err := AdjustBalance(db, "forex", uuid.New(), true, 1); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("missing account: %v", err)
+	}
+	if err := AdjustBalance(db, "bogus", id, true, 1); err == nil {
+		t.Fatal("unknown account type accepted")
+	}
+}
+
+func TestDebitBalance(t *testing.T) {
+	db := newAccountRefDB(t)
+	id := uuid.New()
+	db.Exec("INSERT INTO stock_accounts (id, customer_id, bookie_id, manager_id, real_balance_cents) VALUES (?, ?, ?, ?, 1000)", id, uuid.New(), uuid.New(), uuid.New())
+
+	if err := DebitBalance(db, "stock", id, true, 1001); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("overdraft: %v", err)
+	}
+	if err := DebitBalance(db, "stock", id, true, 1000); err != nil {
+		t.Fatal(err)
+	}
+	var real int64
+	db.Raw("SELECT real_balance_cents FROM stock_accounts WHERE id = ?", id).Row().Scan(&real)
+	if real != 0 {
+		t.Fatalf("balance = %d, want 0", real)
+	}
+	if err := DebitBalance(db, "stock", uuid.New(), true, 1); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("missing account: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AMLCaseStatus is where an alert is in review
+type AMLCaseStatus string
+
+const (
+	AMLCaseOpen          AMLCaseStatus = "open"
+	AMLCaseInvestigating AMLCaseStatus = "investigating"
+	AMLCaseEscalated     AMLCaseStatus = "escalated"
+	AMLCaseDismissed     AMLCaseStatus = "dismissed" // false positive
+	AMLCaseReported      AMLCaseStatus = "reported"  // suspicious transaction report filed
+)
+
+// amlCaseTransitions lists the allowed moves; dismissed and reported are final
+var amlCaseTransitions = map[AMLCaseStatus][]AMLCaseStatus{
+	AMLCaseOpen:          {AMLCaseInvestigating, AMLCaseDismissed},
+	AMLCaseInvestigating: {AMLCaseEscalated, AMLCaseDismissed, AMLCaseReported},
+	AMLCaseEscalated:     {AMLCaseInvestigating, AMLCaseDismissed, AMLCaseReported},
+}
+
+// CanTransition reports whether s may move to next
+func (s AMLCaseStatus) CanTransition(next AMLCaseStatus) bool {
+	return slices.Contains(amlCaseTransitions[s], next)
+}
+
+// Final reports whether the case is closed
+func (s AMLCaseStatus) Final() bool {
+	return len(amlCaseTransitions[s]) == 0
+}
+
+// AMLSeverity ranks how urgently a case needs review
+type AMLSeverity string
+
+const (
+	AMLSeverityLow    AMLSeverity = "low"
+	AMLSeverityMedium AMLSeverity = "medium"
+	AMLSeverityHigh   AMLSeverity = "high"
+)
+
+// Rank orders severities, low first
+func (s AMLSeverity) Rank() int {
+	return slices.Index([]AMLSeverity{AMLSeverityLow, AMLSeverityMedium, AMLSeverityHigh}, s)
+}
+
+// AMLCase is an alert raised by a monitoring rule. While a case is open,
+// further hits of the same rule for the customer are added to it rather
+// than opening another.
+type AMLCase struct {
+	ID         uuid.UUID     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CustomerID uuid.UUID     `gorm:"type:uuid;index;not null" json:"customer_id"`
+	Rule       string        `gorm:"size:50;not null;index" json:"rule"`
+	Severity   AMLSeverity   `gorm:"size:10;not null" json:"severity"`
+	Status     AMLCaseStatus `gorm:"size:20;not null;index" json:"status"`
+	Summary    string        `gorm:"type:text" json:"summary"`
+	Evidence   JSONMap       `gorm:"type:jsonb" json:"evidence"` // latest hit, plus "transactions" across all hits
+	Hits       int           `gorm:"not null;default:1" json:"hits"`
+	AssigneeID *uuid.UUID    `gorm:"type:uuid;index" json:"assignee_id,omitempty"`
+	ClosedAt   *time.Time    `json:"closed_at,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+func (AMLCase) TableName() string {
+	return "aml_cases"
+}
+
+// AMLCaseNote is a reviewer's remark on a case; notes are append-only
+type AMLCaseNote struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CaseID    uuid.UUID `gorm:"type:uuid;index;not null" json:"case_id"`
+	AuthorID  uuid.UUID `gorm:"type:uuid;not null" json:"author_id"`
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AMLCaseNote) TableName() string {
+	return "aml_case_notes"
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey lets a bot or partner act for a customer without a password login.
+// Only the SHA-256 of the secret is stored; Prefix identifies the key in
+// listings and is the lookup index.
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CustomerID uuid.UUID  `gorm:"type:uuid;index;not null" json:"-"`
+	Name       string     `gorm:"size:100" json:"name"`
+	Prefix     string     `gorm:"size:20;uniqueIndex;not null" json:"prefix"`
+	SecretHash string     `gorm:"size:64;not null" json:"-"`
+	Scopes     string     `gorm:"size:200;not null" json:"scopes"`        // space-separated token scopes
+	AllowedIPs string     `gorm:"size:1000" json:"allowed_ips,omitempty"` // space-separated IPs/CIDRs; empty allows any
+	ExpiresAt  *time.Time `gorm:"type:timestamp" json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `gorm:"type:timestamp" json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `gorm:"type:timestamp" json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"size:45" json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"-"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Active reports whether the key is neither revoked nor expired
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// AllowsIP checks ip against the allowlist
+func (k *APIKey) AllowsIP(ip string) bool {
+	if k.AllowedIPs == "" {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, entry := range strings.Fields(k.AllowedIPs) {
+		if prefix, err := netip.ParsePrefix(entry); err == nil && prefix.Contains(addr) {
+			return true
+		}
+		if single, err := netip.ParseAddr(entry); err == nil && single.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -20,6 +20,10 @@ type SportsAccount struct {
 	IsActive         bool           `gorm:"default:true"`
 	EncryptedKey     string         `gorm:"type:text"` // AES-GCM encrypted session key
 	BetHistory       JSONMap        `gorm:"type:jsonb"` // e.g., {"bets": [{"match": "EPL", "amount": 1000}]}
+	// Link verification (see service/bookieaccounts)
+	LinkStatus       BookieLinkStatus `gorm:"size:24;not null;default:'verified'"`
+	VerificationRef  string         `gorm:"size:100;index"` // M-Pesa checkout ID of the latest test deposit
+	VerifiedAt       *time.Time     `gorm:"type:timestamp"`
 	// Relationships
 	Bookie           Bookie         `gorm:"foreignKey:BookieID"`
 	Customer         Customer       `gorm:"foreignKey:CustomerID"`
@@ -29,6 +33,17 @@ type SportsAccount struct {
 	DeletedAt        gorm.DeletedAt `gorm:"index"`
 }
 
+// BookieLinkStatus tracks whether a customer-linked bookie account has been
+// proven by a test deposit. Accounts created before linking existed default
+// to verified.
+type BookieLinkStatus string
+
+const (
+	BookieLinkPending  BookieLinkStatus = "pending_verification"
+	BookieLinkVerified BookieLinkStatus = "verified"
+	BookieLinkFailed   BookieLinkStatus = "verification_failed"
+)
+
 func (SportsAccount) TableName() string {
 	return "sports_accounts"
 }
@@ -43,8 +58,8 @@ func CreateSportsAccountConstraints(db *gorm.DB) error {
 
 type StockAccount struct {
 	ID               uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	CustomerID       uuid.UUID      `gorm:"type:uuid;index;uniqueIndex:idx_customer_bookie;not null"`
-	BookieID         uuid.UUID      `gorm:"type:uuid;index;uniqueIndex:idx_customer_bookie;not null"`
+	CustomerID       uuid.UUID      `gorm:"type:uuid;index;uniqueIndex:idx_stock_customer_bookie;not null"`
+	BookieID         uuid.UUID      `gorm:"type:uuid;index;uniqueIndex:idx_stock_customer_bookie;not null"`
 	ManagerID        uuid.UUID      `gorm:"type:uuid;index;not null"` // Links to StockManager
 	MpesaNumber      string         `gorm:"size:20"`
 	RealBalanceCents int64          `gorm:"default:0"`
@@ -69,7 +84,7 @@ func (StockAccount) TableName() string {
 
 func CreateStockAccountConstraints(db *gorm.DB) error {
 	return db.Exec(`
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_customer_bookie
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_customer_bookie
 		ON stock_accounts (customer_id, bookie_id)
 		WHERE deleted_at IS NULL;
 	`).Error
@@ -77,8 +92,8 @@ func CreateStockAccountConstraints(db *gorm.DB) error {
 
 type ForexAccount struct {
 	ID               uuid.UUID       `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	CustomerID       uuid.UUID       `gorm:"type:uuid;index;uniqueIndex:idx_customer_bookie;not null"`
-	BookieID         uuid.UUID       `gorm:"type:uuid;index;uniqueIndex:idx_customer_bookie;not null"`
+	CustomerID       uuid.UUID       `gorm:"type:uuid;index;uniqueIndex:idx_forex_customer_bookie;not null"`
+	BookieID         uuid.UUID       `gorm:"type:uuid;index;uniqueIndex:idx_forex_customer_bookie;not null"`
 	ManagerID        uuid.UUID       `gorm:"type:uuid;index;not null"` // Links to ForexManager
 	MpesaNumber      string          `gorm:"size:20"`
 	RealBalanceCents int64           `gorm:"default:0"`
@@ -103,7 +118,7 @@ func (ForexAccount) TableName() string {
 
 func CreateForexAccountConstraints(db *gorm.DB) error {
 	return db.Exec(`
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_customer_bookie
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_forex_customer_bookie
 		ON forex_accounts (customer_id, bookie_id)
 		WHERE deleted_at IS NULL;
 	`).Error
@@ -111,13 +126,13 @@ func CreateForexAccountConstraints(db *gorm.DB) error {
 
 type CryptoAccount struct {
 	ID               uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	CustomerID       uuid.UUID      `gorm:"type:uuid;index;uniqueIndex:idx_customer_bookie;not null"`
-	BookieID         uuid.UUID      `gorm:"type:uuid;index;uniqueIndex:idx_customer_bookie;not null"`
+	CustomerID       uuid.UUID      `gorm:"type:uuid;index;uniqueIndex:idx_crypto_customer_bookie;not null"`
+	BookieID         uuid.UUID      `gorm:"type:uuid;index;uniqueIndex:idx_crypto_customer_bookie;not null"`
 	ManagerID        uuid.UUID      `gorm:"type:uuid;index;not null"` // Links to CryptoManager
 	MpesaNumber      string         `gorm:"size:20"`
 	RealBalanceCents int64          `gorm:"default:0"`
 	FakeBalanceCents int64          `gorm:"default:0"`
-	FiatCentsKE      int64          `gorm:"default:0"`
+	FiatCentsKE      int64          `gorm:"default:0"` // Deprecated: moved into AccountBalance by InitDB; always zero
 	Currency         string         `gorm:"size:3;default:'KES'"`
 	IsActive         bool           `gorm:"default:true"`
 	EncryptedSeed    string         `gorm:"type:text"` // AES-GCM encrypted wallet seed
@@ -138,7 +153,7 @@ func (CryptoAccount) TableName() string {
 
 func CreateCryptoAccountConstraints(db *gorm.DB) error {
 	return db.Exec(`
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_customer_bookie
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_crypto_customer_bookie
 		ON crypto_accounts (customer_id, bookie_id)
 		WHERE deleted_at IS NULL;
 	`).Error
@@ -0,0 +1,24 @@
+// models/audit_log.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditLog records a back-office action; rows are append-only
+type AuditLog struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ActorID    uuid.UUID `gorm:"type:uuid;index;not null" json:"actor_id"`
+	ActorRole  Role      `gorm:"size:20;not null" json:"actor_role"`
+	Action     string    `gorm:"size:50;not null;index" json:"action"` // e.g. "transaction.reverse"
+	TargetType string    `gorm:"size:30;not null" json:"target_type"`
+	TargetID   uuid.UUID `gorm:"type:uuid;index" json:"target_id"`
+	Details    JSONMap   `gorm:"type:jsonb" json:"details"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
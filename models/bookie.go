@@ -1,19 +1,166 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
 	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+var ErrInvalidBookie = errors.New("invalid bookie")
+
+// BookieAssetClasses are the account types a bookie can hold; sharp
+// accounts belong to a Sharp instead
+var BookieAssetClasses = []string{"sports", "stock", "forex", "crypto"}
+
+var shortcodeRegex = regexp.MustCompile(`^\d{5,7}$`)
+
+// BookieFees is what a bookie charges on money moving in and out.
+// Basis points are hundredths of a percent.
+type BookieFees struct {
+	DepositFlatCents  int64 `json:"deposit_flat_cents,omitempty"`
+	DepositBps        int64 `json:"deposit_bps,omitempty"`
+	WithdrawFlatCents int64 `json:"withdraw_flat_cents,omitempty"`
+	WithdrawBps       int64 `json:"withdraw_bps,omitempty"`
+}
+
+// Deposit is the fee on depositing amountCents
+func (f BookieFees) Deposit(amountCents int64) int64 {
+	return f.DepositFlatCents + amountCents*f.DepositBps/10000
+}
+
+// Withdraw is the fee on withdrawing amountCents
+func (f BookieFees) Withdraw(amountCents int64) int64 {
+	return f.WithdrawFlatCents + amountCents*f.WithdrawBps/10000
+}
+
+// Scan implements sql.Scanner
+func (f *BookieFees) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*f = BookieFees{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, f)
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	}
+	return fmt.Errorf("cannot scan %T into BookieFees", value)
+}
+
+// Value implements driver.Valuer
+func (f BookieFees) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+// StringList is a JSON column of strings
+type StringList []string
+
+// Scan implements sql.Scanner
+func (l *StringList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return fmt.Errorf("cannot scan %T into StringList", value)
+}
+
+// Value implements driver.Valuer
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	return json.Marshal(l)
+}
+
+// Bookie is a catalog entry for a betting or trading house customers hold
+// accounts with. A zero maximum means no maximum.
 type Bookie struct {
-	ID        uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	Name      string         `gorm:"size:255;not null"` // e.g., "Bet365", "SportPesa"
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID               uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name             string         `gorm:"size:255;not null" json:"name"` // e.g., "Bet365", "SportPesa"
+	PaybillNumber    string         `gorm:"size:10" json:"paybill_number,omitempty"`
+	TillNumber       string         `gorm:"size:10" json:"till_number,omitempty"`
+	MpesaNumber      string         `gorm:"size:20" json:"mpesa_number,omitempty"` // phone number for STK deposits
+	MinDepositCents  int64          `gorm:"type:bigint;not null;default:0" json:"min_deposit_cents"`
+	MaxDepositCents  int64          `gorm:"type:bigint;not null;default:0" json:"max_deposit_cents"`
+	MinWithdrawCents int64          `gorm:"type:bigint;not null;default:0" json:"min_withdraw_cents"`
+	MaxWithdrawCents int64          `gorm:"type:bigint;not null;default:0" json:"max_withdraw_cents"`
+	Fees             BookieFees     `gorm:"type:jsonb" json:"fees"`
+	AssetClasses     StringList     `gorm:"type:jsonb" json:"asset_classes"`
+	IsActive         bool           `gorm:"not null;default:true" json:"is_active"`
+	RecentLogReturn  float64        `json:"recent_log_return"` // EWMA of log returns, read by the allocator
+	RecentVolatility float64        `json:"recent_volatility"` // EWMA volatility, read by the allocator
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (Bookie) TableName() string {
 	return "bookies"
 }
+
+// Validate checks that the entry can take payments and its bounds make sense
+func (b *Bookie) Validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidBookie)
+	}
+	if b.PaybillNumber == "" && b.TillNumber == "" && b.MpesaNumber == "" {
+		return fmt.Errorf("%w: a paybill, till or M-Pesa number is required", ErrInvalidBookie)
+	}
+	for _, code := range []string{b.PaybillNumber, b.TillNumber} {
+		if code != "" && !shortcodeRegex.MatchString(code) {
+			return fmt.Errorf("%w: %q is not a paybill or till number", ErrInvalidBookie, code)
+		}
+	}
+	if b.MpesaNumber != "" {
+		phone, err := NormalizePhone(b.MpesaNumber)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBookie, err)
+		}
+		b.MpesaNumber = phone
+	}
+	for _, bounds := range [][2]int64{{b.MinDepositCents, b.MaxDepositCents}, {b.MinWithdrawCents, b.MaxWithdrawCents}} {
+		if bounds[0] < 0 || bounds[1] < 0 || (bounds[1] > 0 && bounds[0] > bounds[1]) {
+			return fmt.Errorf("%w: minimum and maximum amounts are inconsistent", ErrInvalidBookie)
+		}
+	}
+	f := b.Fees
+	if f.DepositFlatCents < 0 || f.WithdrawFlatCents < 0 || f.DepositBps < 0 || f.DepositBps > 10000 || f.WithdrawBps < 0 || f.WithdrawBps > 10000 {
+		return fmt.Errorf("%w: fees must be non-negative and at most 100%%", ErrInvalidBookie)
+	}
+	if len(b.AssetClasses) == 0 {
+		return fmt.Errorf("%w: at least one asset class is required", ErrInvalidBookie)
+	}
+	for _, c := range b.AssetClasses {
+		if !slices.Contains(BookieAssetClasses, c) {
+			return fmt.Errorf("%w: unknown asset class %q", ErrInvalidBookie, c)
+		}
+	}
+	return nil
+}
+
+// Supports reports whether customers may hold assetClass accounts here
+func (b *Bookie) Supports(assetClass string) bool {
+	return slices.Contains(b.AssetClasses, assetClass)
+}
+
+// DepositAllowed reports whether amountCents is within the deposit bounds
+func (b *Bookie) DepositAllowed(amountCents int64) bool {
+	return amountCents >= b.MinDepositCents && (b.MaxDepositCents == 0 || amountCents <= b.MaxDepositCents)
+}
+
+// WithdrawAllowed reports whether amountCents is within the withdrawal bounds
+func (b *Bookie) WithdrawAllowed(amountCents int64) bool {
+	return amountCents >= b.MinWithdrawCents && (b.MaxWithdrawCents == 0 || amountCents <= b.MaxWithdrawCents)
+}
@@ -4,10 +4,11 @@ package models
 import (
     "crypto/aes"
     "crypto/cipher"
-    "crypto/rand"
     "encoding/base64"
     "errors"
     "regexp"
+
+    "weriKana/service/keymgmt"
 )
 
 // --- Regex ---
@@ -35,38 +36,87 @@ const (
     BankAccountTypeSavings BankAccountType = "savings"
 )
 
-// --- Encryption Key (same for sender/recipient) ---
-var encryptionKey = []byte("32-byte-key-for-aes-256-gcm!!!!!") // 32 bytes = AES-256
+// --- Field Encryption ---
+// PII columns and account secrets (EncryptedKey, EncryptedSeed) are envelope
+// ciphertexts from keymgmt, carrying the id of the key that sealed them.
+var fieldEnvelope *keymgmt.Envelope
 
-// --- Encrypt Helper ---
-func encrypt(plaintext string) (string, error) {
-    block, err := aes.NewCipher(encryptionKey)
+// SetFieldEncryption installs the envelope used for all encrypted columns
+func SetFieldEncryption(e *keymgmt.Envelope) {
+    fieldEnvelope = e
+}
+
+// legacyEncryptionKey sealed values written before keymgmt existed.
+// It is only used to read them so RotateFieldCiphertext can re-encrypt.
+var legacyEncryptionKey = []byte("32-byte-key-for-aes-256-gcm!!!!!")
+
+// EncryptSecret seals an account secret for EncryptedKey / EncryptedSeed columns
+func EncryptSecret(plaintext string) (string, error) {
+    return encrypt(plaintext)
+}
+
+// DecryptSecret opens a value produced by EncryptSecret
+func DecryptSecret(ciphertext string) (string, error) {
+    return decrypt(ciphertext)
+}
+
+// RotateFieldCiphertext re-encrypts ciphertext under the current key.
+// changed is false when it was already current (or empty).
+func RotateFieldCiphertext(ciphertext string) (rotated string, changed bool, err error) {
+    if ciphertext == "" {
+        return ciphertext, false, nil
+    }
+    if fieldEnvelope == nil {
+        return "", false, errors.New("field encryption not configured")
+    }
+    if keymgmt.IsEnvelope(ciphertext) {
+        stale, err := fieldEnvelope.NeedsRotation(ciphertext)
+        if err != nil || !stale {
+            return ciphertext, false, err
+        }
+    }
+    plain, err := decrypt(ciphertext)
     if err != nil {
-        return "", err
+        return "", false, err
     }
-
-    aesGCM, err := cipher.NewGCM(block)
+    rotated, err = encrypt(plain)
     if err != nil {
-        return "", err
+        return "", false, err
     }
+    return rotated, true, nil
+}
 
-    nonce := make([]byte, aesGCM.NonceSize())
-    if _, err = rand.Read(nonce); err != nil {
-        return "", err
+// --- Encrypt Helper ---
+func encrypt(plaintext string) (string, error) {
+    if fieldEnvelope == nil {
+        return "", errors.New("field encryption not configured")
     }
-
-    ciphertext := aesGCM.Seal(nonce, nonce, []byte(plaintext), nil)
-    return base64.StdEncoding.EncodeToString(ciphertext), nil
+    return fieldEnvelope.Encrypt([]byte(plaintext), nil)
 }
 
 // --- Decrypt Helper ---
-func decrypt(ciphertextB64 string) (string, error) {
+func decrypt(ciphertext string) (string, error) {
+    if !keymgmt.IsEnvelope(ciphertext) {
+        return decryptLegacy(ciphertext)
+    }
+    if fieldEnvelope == nil {
+        return "", errors.New("field encryption not configured")
+    }
+    plaintext, err := fieldEnvelope.Decrypt(ciphertext, nil)
+    if err != nil {
+        return "", err
+    }
+    return string(plaintext), nil
+}
+
+// decryptLegacy opens base64(nonce || AES-GCM) under legacyEncryptionKey
+func decryptLegacy(ciphertextB64 string) (string, error) {
     data, err := base64.StdEncoding.DecodeString(ciphertextB64)
     if err != nil {
         return "", err
     }
 
-    block, err := aes.NewCipher(encryptionKey)
+    block, err := aes.NewCipher(legacyEncryptionKey)
     if err != nil {
         return "", err
     }
@@ -89,4 +139,3 @@ func decrypt(ciphertextB64 string) (string, error) {
 
     return string(plaintext), nil
 }
-
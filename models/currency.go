@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of accounts and M-Pesa payments
+const DefaultCurrency = "KES"
+
+var (
+	ErrUnknownCurrency = errors.New("unknown or unsupported currency")
+	ErrInvalidAmount   = errors.New("invalid amount")
+)
+
+// minorUnits is the number of decimal places of each supported ISO 4217
+// currency. Amount columns named ...Cents hold minor units of the row's
+// currency, which are not always hundredths: UGX and RWF have none, BHD has
+// thousandths.
+var minorUnits = map[string]int{
+	"AED": 2, "AUD": 2, "BHD": 3, "BIF": 0, "CAD": 2, "CHF": 2, "CNY": 2,
+	"DJF": 0, "ETB": 2, "EUR": 2, "GBP": 2, "INR": 2, "JOD": 3, "JPY": 0,
+	"KES": 2, "KRW": 0, "KWD": 3, "NGN": 2, "OMR": 3, "RWF": 0, "SAR": 2,
+	"SOS": 2, "SSP": 2, "TND": 3, "TZS": 2, "UGX": 0, "USD": 2, "XAF": 0,
+	"XOF": 0, "ZAR": 2, "ZMW": 2,
+}
+
+// NormalizeCurrency upper-cases code and checks that it is supported
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := minorUnits[code]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return code, nil
+}
+
+// MinorUnits is the number of decimal places of currency code
+func MinorUnits(code string) (int, error) {
+	code, err := NormalizeCurrency(code)
+	if err != nil {
+		return 0, err
+	}
+	return minorUnits[code], nil
+}
+
+// MinorScale is 10 to the power of the currency's minor units, the number of
+// minor units in one major unit
+func MinorScale(code string) (int64, error) {
+	units, err := MinorUnits(code)
+	if err != nil {
+		return 0, err
+	}
+	scale := int64(1)
+	for range units {
+		scale *= 10
+	}
+	return scale, nil
+}
+
+// ParseAmount reads a decimal amount such as "1250.5" into minor units of
+// code, refusing more decimal places than the currency has
+func ParseAmount(code, s string) (int64, error) {
+	units, err := MinorUnits(code)
+	if err != nil {
+		return 0, err
+	}
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() < 0 || strings.ContainsAny(s, "eE/") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	scale, _ := MinorScale(code)
+	r.Mul(r, new(big.Rat).SetInt64(scale))
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places for %s or is too large", ErrInvalidAmount, s, units, code)
+	}
+	return r.Num().Int64(), nil
+}
+
+// FormatAmount writes minor units of code as a decimal, e.g. 125050 KES as
+// "1250.50" and 1250 UGX as "1250"
+func FormatAmount(code string, minor int64) (string, error) {
+	units, err := MinorUnits(code)
+	if err != nil {
+		return "", err
+	}
+	sign, abs := "", minor
+	if minor < 0 {
+		sign, abs = "-", -minor
+	}
+	digits := strconv.FormatInt(abs, 10)
+	if units == 0 {
+		return sign + digits, nil
+	}
+	if len(digits) <= units {
+		digits = strings.Repeat("0", units-len(digits)+1) + digits
+	}
+	cut := len(digits) - units
+	return sign + digits[:cut] + "." + digits[cut:], nil
+}
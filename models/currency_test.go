@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAndFormatAmount(t *testing.T) {
+	for _, c := range []struct {
+		code, in string
+		minor    int64
+		out      string
+	}{
+		{"KES", "1250.5", 125050, "1250.50"},
+		{"kes", "0.07", 7, "0.07"},
+		{"UGX", "35000", 35000, "35000"},
+		{"BHD", "1.005", 1005, "1.005"},
+		{"USD", "12", 1200, "12.00"},
+	} {
+		minor, err := ParseAmount(c.code, c.in)
+		if err != nil || minor != c.minor {
+			t.Errorf("ParseAmount(%s, %s) = %d, %v; want %d", c.code, c.in, minor, err, c.minor)
+			continue
+		}
+		if out, _ := FormatAmount(c.code, minor); out != c.out {
+			t.Errorf("FormatAmount(%s, %d) = %s; want %s", c.code, minor, out, c.out)
+		}
+	}
+	if out, _ := FormatAmount("KES", -5); out != "-0.05" {
+		t.Errorf("negative = %s", out)
+	}
+
+	for code, in := range map[string]string{"UGX": "10.5", "KES": "1.001", "USD": "-1", "EUR": "1e3"} {
+		if _, err := ParseAmount(code, in); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("ParseAmount(%s, %s): %v", code, in, err)
+		}
+	}
+	if _, err := ParseAmount("XYZ", "1"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("unknown currency: %v", err)
+	}
+}
@@ -14,6 +14,13 @@ type Customer struct {
 	Email          string           `gorm:"size:255;uniqueIndex;not null" json:"email"`
 	Phone          string           `gorm:"size:20;uniqueIndex;not null" json:"phone"` // e.g. +254712345678
 	PreferredMpesa string           `gorm:"size:20" json:"preferred_mpesa"` // fallback payout number
+	SecondFactor   SecondFactor     `gorm:"size:10;default:'sms'" json:"second_factor"`
+	Role           Role             `gorm:"size:20;default:'customer'" json:"role"`
+	// Credentials (see service/auth)
+	PasswordHash      string     `gorm:"size:255" json:"-"` // Argon2id PHC string (bcrypt accepted for imports)
+	FailedLogins      int        `gorm:"default:0" json:"-"`
+	LockedUntil       *time.Time `gorm:"type:timestamp" json:"-"`
+	PasswordChangedAt *time.Time `gorm:"type:timestamp" json:"-"`
 	// Relationships
 	SportsAccounts []SportsAccount  `gorm:"foreignKey:CustomerID" json:"-"` // Replaced BookieAccounts
 	StockAccounts  []StockAccount   `gorm:"foreignKey:CustomerID" json:"-"` // Optional
@@ -0,0 +1,49 @@
+// models/customer_totp.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SecondFactor is the customer's policy for high-value actions
+type SecondFactor string
+
+const (
+	SecondFactorSMS  SecondFactor = "sms"  // SMS OTP only
+	SecondFactorTOTP SecondFactor = "totp" // authenticator app only
+	SecondFactorAny  SecondFactor = "any"  // either
+)
+
+// CustomerTOTP is a customer's authenticator-app enrollment.
+// It is unusable until ConfirmedAt is set by a first valid code.
+type CustomerTOTP struct {
+	ID           uuid.UUID       `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	CustomerID   uuid.UUID       `gorm:"type:uuid;uniqueIndex;not null"`
+	Secret       EncryptedString `gorm:"column:secret_enc;size:500;not null"`
+	ConfirmedAt  *time.Time      `gorm:"type:timestamp"`
+	LastUsedStep int64           `gorm:"default:0"` // replay guard: codes must be for a later step
+	Attempts     int             `gorm:"default:0"` // failed verifications since the last success or lockout
+	LockedUntil  *time.Time      `gorm:"type:timestamp"`
+	Customer     Customer        `gorm:"foreignKey:CustomerID"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (CustomerTOTP) TableName() string {
+	return "customer_totps"
+}
+
+// TOTPRecoveryCode is one single-use fallback code (SHA-256 hash only)
+type TOTPRecoveryCode struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	CustomerID uuid.UUID  `gorm:"type:uuid;index;not null"`
+	CodeHash   string     `gorm:"size:64;not null"`
+	UsedAt     *time.Time `gorm:"type:timestamp"`
+	CreatedAt  time.Time
+}
+
+func (TOTPRecoveryCode) TableName() string {
+	return "totp_recovery_codes"
+}
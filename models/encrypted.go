@@ -0,0 +1,145 @@
+// models/encrypted.go
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EncryptedString is a string column stored as a field-encryption envelope.
+// It encrypts in Value and decrypts in Scan, so struct fields hold plaintext.
+type EncryptedString string
+
+// Scan implements sql.Scanner
+func (e *EncryptedString) Scan(value interface{}) error {
+	var ciphertext string
+	switch v := value.(type) {
+	case nil:
+		*e = ""
+		return nil
+	case string:
+		ciphertext = v
+	case []byte:
+		ciphertext = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into EncryptedString", value)
+	}
+	if ciphertext == "" {
+		*e = ""
+		return nil
+	}
+	plaintext, err := decrypt(ciphertext)
+	if err != nil {
+		return err
+	}
+	*e = EncryptedString(plaintext)
+	return nil
+}
+
+// Value implements driver.Valuer
+func (e EncryptedString) Value() (driver.Value, error) {
+	if e == "" {
+		return "", nil
+	}
+	return encrypt(string(e))
+}
+
+// String returns the plaintext
+func (e EncryptedString) String() string {
+	return string(e)
+}
+
+// --- Blind Index ---
+// A blind index is a keyed HMAC of a normalized value, stored next to the
+// ciphertext so equality lookups work without decrypting the table.
+var blindIndexKey []byte
+
+// SetBlindIndexKey installs the HMAC key for blind indexes
+func SetBlindIndexKey(key []byte) {
+	blindIndexKey = key
+}
+
+// blindIndex computes HMAC-SHA256(key, field || 0x00 || value) as hex.
+// The field name keeps equal values in different columns from matching.
+func blindIndex(field, value string) (string, error) {
+	if len(blindIndexKey) == 0 {
+		return "", errors.New("blind index key not configured")
+	}
+	mac := hmac.New(sha256.New, blindIndexKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// NormalizePhone returns the +254 form of a valid Kenyan number
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	if !kenyanPhoneRegex.MatchString(phone) {
+		return "", errors.New("invalid Kenyan phone")
+	}
+	if phone[0] == '0' {
+		phone = "+254" + phone[1:]
+	}
+	return phone, nil
+}
+
+// NormalizeEmail lowercases and validates an email
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !emailRegex.MatchString(email) {
+		return "", errors.New("invalid email")
+	}
+	return email, nil
+}
+
+// PhoneBlindIndex is the lookup key for phone_bidx columns
+func PhoneBlindIndex(phone string) (string, error) {
+	normalized, err := NormalizePhone(phone)
+	if err != nil {
+		return "", err
+	}
+	return blindIndex("phone", normalized)
+}
+
+// EmailBlindIndex is the lookup key for email_bidx columns
+func EmailBlindIndex(email string) (string, error) {
+	normalized, err := NormalizeEmail(email)
+	if err != nil {
+		return "", err
+	}
+	return blindIndex("email", normalized)
+}
+
+// --- Masking ---
+// Masked forms are for API responses: enough to recognize a contact, not to use it.
+
+// MaskPhone keeps the country code and last three digits: +2547*****678
+func MaskPhone(phone string) string {
+	if len(phone) < 8 {
+		return strings.Repeat("*", len(phone))
+	}
+	return phone[:5] + strings.Repeat("*", len(phone)-8) + phone[len(phone)-3:]
+}
+
+// MaskEmail keeps the first character of the local part and the domain: j***@example.com
+func MaskEmail(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at < 1 {
+		return strings.Repeat("*", len(email))
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// MaskAccountNumber keeps the last four digits: ******7890
+func MaskAccountNumber(number string) string {
+	if len(number) <= 4 {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"weriKana/service/keymgmt"
+)
+
+func setupFieldEncryption(t *testing.T) {
+	t.Helper()
+	kms := keymgmt.NewLocalKMS()
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	SetFieldEncryption(keymgmt.NewEnvelope(kms))
+	SetBlindIndexKey([]byte(strings.Repeat("b", keymgmt.KeySize)))
+	t.Cleanup(func() {
+		SetFieldEncryption(nil)
+		SetBlindIndexKey(nil)
+	})
+}
+
+func TestEncryptedStringRoundTrip(t *testing.T) {
+	setupFieldEncryption(t)
+
+	v, err := EncryptedString("jane@example.com").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := v.(string)
+	if !keymgmt.IsEnvelope(stored) || strings.Contains(stored, "jane") {
+		t.Fatalf("stored value is not ciphertext: %q", stored)
+	}
+
+	for _, raw := range []interface{}{stored, []byte(stored)} {
+		var got EncryptedString
+		if err := got.Scan(raw); err != nil {
+			t.Fatal(err)
+		}
+		if got != "jane@example.com" {
+			t.Fatalf("Scan(%T) = %q", raw, got)
+		}
+	}
+
+	var empty EncryptedString
+	if err := empty.Scan(nil); err != nil || empty != "" {
+		t.Fatalf("Scan(nil) = %q, %v", empty, err)
+	}
+	if v, err := EncryptedString("").Value(); err != nil || v != "" {
+		t.Fatalf("empty Value = %v, %v", v, err)
+	}
+}
+
+func TestSenderSettersBlindIndex(t *testing.T) {
+	setupFieldEncryption(t)
+
+	var a, b Sender
+	if err := a.SetPhone("0712345678"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetPhone("+254712345678"); err != nil {
+		t.Fatal(err)
+	}
+	if a.PhoneNumber != "+254712345678" || a.PhoneIndex != b.PhoneIndex {
+		t.Fatalf("phone not normalized before indexing: %q %q %q", a.PhoneNumber, a.PhoneIndex, b.PhoneIndex)
+	}
+	if idx, _ := PhoneBlindIndex("0712345678"); idx != a.PhoneIndex {
+		t.Fatal("PhoneBlindIndex disagrees with SetPhone")
+	}
+
+	if err := a.SetEmail(" Jane@Example.com "); err != nil {
+		t.Fatal(err)
+	}
+	if idx, _ := EmailBlindIndex("jane@example.com"); idx != a.EmailIndex {
+		t.Fatal("email index is not case-insensitive")
+	}
+	if a.EmailIndex == a.PhoneIndex || len(a.EmailIndex) != 64 {
+		t.Fatalf("unexpected index %q", a.EmailIndex)
+	}
+
+	if err := a.SetPhone("12345"); err == nil {
+		t.Fatal("invalid phone accepted")
+	}
+}
+
+func TestMasking(t *testing.T) {
+	cases := []struct{ got, want string }{
+		{MaskPhone("+254712345678"), "+2547*****678"},
+		{MaskPhone("123"), "***"},
+		{MaskEmail("jane@example.com"), "j***@example.com"},
+		{MaskEmail("nope"), "****"},
+		{MaskAccountNumber("1234567890"), "******7890"},
+		{MaskAccountNumber("12"), "**"},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("got %q, want %q", c.got, c.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FeeOperation is the kind of money movement a fee schedule prices
+type FeeOperation string
+
+const (
+	FeeDeposit  FeeOperation = "deposit"
+	FeeWithdraw FeeOperation = "withdraw"
+	FeeTrade    FeeOperation = "trade"
+)
+
+var FeeOperations = []FeeOperation{FeeDeposit, FeeWithdraw, FeeTrade}
+
+var (
+	ErrInvalidFeeSchedule = errors.New("invalid fee schedule")
+	ErrOutsideTariff      = errors.New("amount is above the fee tariff's top band")
+	ErrFeeExceedsAmount   = errors.New("amount does not cover the fee")
+)
+
+// FeeBand charges FeeCents on amounts up to and including UpToCents that
+// are above the previous band
+type FeeBand struct {
+	UpToCents int64 `json:"up_to_cents"`
+	FeeCents  int64 `json:"fee_cents"`
+}
+
+// FeeBands is a JSON column of bands in ascending order
+type FeeBands []FeeBand
+
+// Scan implements sql.Scanner
+func (b *FeeBands) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*b = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, b)
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	}
+	return fmt.Errorf("cannot scan %T into FeeBands", value)
+}
+
+// Value implements driver.Valuer
+func (b FeeBands) Value() (driver.Value, error) {
+	if b == nil {
+		return "[]", nil
+	}
+	return json.Marshal(b)
+}
+
+// FeeSchedule prices one operation, for one bookie or (with no BookieID)
+// for all of them. The fee is the flat part, plus the percentage part, plus
+// the band the amount falls in, held within MinCents and MaxCents. Tiered
+// bands model M-Pesa's STK and B2C tariffs; a zero maximum means no maximum.
+type FeeSchedule struct {
+	ID        uuid.UUID    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name      string       `gorm:"size:100;not null" json:"name"`
+	Operation FeeOperation `gorm:"size:20;not null;index" json:"operation"`
+	BookieID  *uuid.UUID   `gorm:"type:uuid;index" json:"bookie_id,omitempty"`
+	FlatCents int64        `gorm:"type:bigint;not null;default:0" json:"flat_cents,omitempty"`
+	Bps       int64        `gorm:"not null;default:0" json:"bps,omitempty"` // hundredths of a percent
+	Bands     FeeBands     `gorm:"type:jsonb" json:"bands,omitempty"`
+	MinCents  int64        `gorm:"type:bigint;not null;default:0" json:"min_cents,omitempty"`
+	MaxCents  int64        `gorm:"type:bigint;not null;default:0" json:"max_cents,omitempty"`
+	Enabled   bool         `gorm:"not null" json:"enabled"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+func (FeeSchedule) TableName() string {
+	return "fee_schedules"
+}
+
+// Validate checks that the schedule can price every amount up to its top band
+func (f *FeeSchedule) Validate() error {
+	if f.Name == "" || !slices.Contains(FeeOperations, f.Operation) {
+		return fmt.Errorf("%w: name and a known operation are required", ErrInvalidFeeSchedule)
+	}
+	if f.FlatCents < 0 || f.Bps < 0 || f.Bps > 10000 || f.MinCents < 0 || f.MaxCents < 0 || (f.MaxCents > 0 && f.MinCents > f.MaxCents) {
+		return fmt.Errorf("%w: amounts must be non-negative, at most 100%% and min at most max", ErrInvalidFeeSchedule)
+	}
+	var last int64
+	for _, b := range f.Bands {
+		if b.UpToCents <= last || b.FeeCents < 0 {
+			return fmt.Errorf("%w: bands must ascend and charge non-negative fees", ErrInvalidFeeSchedule)
+		}
+		last = b.UpToCents
+	}
+	return nil
+}
+
+// Fee is what the schedule charges on grossCents
+func (f *FeeSchedule) Fee(grossCents int64) (int64, error) {
+	fee := f.FlatCents + grossCents*f.Bps/10000
+	if len(f.Bands) > 0 {
+		i := slices.IndexFunc(f.Bands, func(b FeeBand) bool { return grossCents <= b.UpToCents })
+		if i < 0 {
+			return 0, ErrOutsideTariff
+		}
+		fee += f.Bands[i].FeeCents
+	}
+	if fee < f.MinCents {
+		fee = f.MinCents
+	}
+	if f.MaxCents > 0 && fee > f.MaxCents {
+		fee = f.MaxCents
+	}
+	return fee, nil
+}
+
+// FeeQuote is the price of one money movement. The gross amount is paid in,
+// debited or staked; the net amount is credited, paid out or placed.
+type FeeQuote struct {
+	ScheduleID *uuid.UUID   `json:"schedule_id,omitempty"`
+	Operation  FeeOperation `json:"operation"`
+	GrossCents int64        `json:"gross_cents"`
+	FeeCents   int64        `json:"fee_cents"`
+	NetCents   int64        `json:"net_cents"`
+}
+
+// NoFee quotes a movement nothing is charged on, such as practice money
+func NoFee(op FeeOperation, grossCents int64) FeeQuote {
+	return FeeQuote{Operation: op, GrossCents: grossCents, NetCents: grossCents}
+}
+
+// Apply writes the quote's gross, fee and net amounts onto t
+func (q FeeQuote) Apply(t *Transaction) {
+	t.AmountCents, t.FeeCents, t.NetAmountCents = q.GrossCents, q.FeeCents, q.NetCents
+	if q.ScheduleID != nil {
+		if t.Metadata == nil {
+			t.Metadata = JSONMap{}
+		}
+		t.Metadata["fee_schedule_id"] = q.ScheduleID.String()
+	}
+}
+
+// RevenueEntry books a fee or FX spread as revenue, apart from the customer's ledger row
+// it was charged on. Refunded fees are negative entries against the reversal.
+type RevenueEntry struct {
+	ID              uuid.UUID       `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	TransactionID   uuid.UUID       `gorm:"type:uuid;uniqueIndex;not null" json:"transaction_id"`
+	CustomerID      uuid.UUID       `gorm:"type:uuid;index;not null" json:"customer_id"`
+	TransactionType TransactionType `gorm:"size:20;not null" json:"transaction_type"`
+	ScheduleID      *uuid.UUID      `gorm:"type:uuid" json:"schedule_id,omitempty"`
+	AmountCents     int64           `gorm:"type:bigint;not null" json:"amount_cents"`
+	Currency        string          `gorm:"size:3;not null" json:"currency"`
+	CreatedAt       time.Time       `gorm:"index" json:"created_at"`
+}
+
+func (RevenueEntry) TableName() string {
+	return "revenue_entries"
+}
+
+// RecordFee books amountCents of fee revenue against t; zero books nothing
+func RecordFee(db *gorm.DB, t *Transaction, scheduleID *uuid.UUID, amountCents int64) error {
+	currency := t.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return RecordRevenue(db, t, scheduleID, amountCents, currency)
+}
+
+// RecordRevenue books amountCents of currency as revenue against t, for
+// income such as an FX spread that is not kept in t's own currency
+func RecordRevenue(db *gorm.DB, t *Transaction, scheduleID *uuid.UUID, amountCents int64, currency string) error {
+	if amountCents == 0 {
+		return nil
+	}
+	return db.Create(&RevenueEntry{
+		ID:              uuid.New(),
+		TransactionID:   t.ID,
+		CustomerID:      t.CustomerID,
+		TransactionType: t.Type,
+		ScheduleID:      scheduleID,
+		AmountCents:     amountCents,
+		Currency:        currency,
+	}).Error
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FXQuote is a priced conversion between two of an account's currencies. It
+// holds the rate until ExpiresAt and can be executed once.
+type FXQuote struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CustomerID      uuid.UUID  `gorm:"type:uuid;index;not null" json:"-"`
+	AccountType     string     `gorm:"size:10;not null" json:"account_type"`
+	AccountID       uuid.UUID  `gorm:"type:uuid;not null" json:"account_id"`
+	IsReal          bool       `gorm:"not null" json:"is_real"`
+	Currency        string     `gorm:"size:3;not null" json:"currency"`           // sold
+	AmountCents     int64      `gorm:"type:bigint;not null" json:"amount_cents"`  // minor units of Currency
+	CounterCurrency string     `gorm:"size:3;not null" json:"counter_currency"`   // bought
+	CounterCents    int64      `gorm:"type:bigint;not null" json:"counter_cents"` // minor units of CounterCurrency credited
+	MidRate         string     `gorm:"size:32;not null" json:"mid_rate"`
+	Rate            string     `gorm:"size:32;not null" json:"rate"` // mid rate less the spread
+	SpreadBps       int64      `gorm:"not null" json:"spread_bps"`
+	SpreadCents     int64      `gorm:"type:bigint;not null" json:"spread_cents"` // minor units of CounterCurrency
+	RateAsOf        time.Time  `json:"rate_as_of"`
+	ExpiresAt       time.Time  `gorm:"not null" json:"expires_at"`
+	TransactionID   *uuid.UUID `gorm:"type:uuid" json:"transaction_id,omitempty"` // set once executed
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (FXQuote) TableName() string {
+	return "fx_quotes"
+}
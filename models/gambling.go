@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GamblingLimitKind is what a customer-set limit caps
+type GamblingLimitKind string
+
+const (
+	GamblingLimitDeposit GamblingLimitKind = "deposit" // real money deposited
+	GamblingLimitLoss    GamblingLimitKind = "loss"    // real money deposited and not withdrawn
+)
+
+// GamblingPeriod is the rolling window of a customer-set limit
+type GamblingPeriod string
+
+const (
+	GamblingDaily   GamblingPeriod = "daily"
+	GamblingWeekly  GamblingPeriod = "weekly"
+	GamblingMonthly GamblingPeriod = "monthly"
+)
+
+// Window is the rolling window the period covers
+func (p GamblingPeriod) Window() time.Duration {
+	switch p {
+	case GamblingDaily:
+		return 24 * time.Hour
+	case GamblingWeekly:
+		return 7 * 24 * time.Hour
+	case GamblingMonthly:
+		return 30 * 24 * time.Hour
+	}
+	return 0
+}
+
+// GamblingLimit is a limit a customer set on themselves. Lowering it takes
+// effect at once; raising or removing it waits until PendingAt so the
+// decision is not made in the heat of the moment.
+type GamblingLimit struct {
+	ID           uuid.UUID         `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CustomerID   uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex:idx_gambling_limit" json:"customer_id"`
+	Kind         GamblingLimitKind `gorm:"size:20;not null;uniqueIndex:idx_gambling_limit" json:"kind"`
+	Period       GamblingPeriod    `gorm:"size:20;not null;uniqueIndex:idx_gambling_limit" json:"period"`
+	AmountCents  int64             `gorm:"type:bigint;not null" json:"amount_cents"`
+	PendingCents *int64            `gorm:"type:bigint" json:"pending_cents,omitempty"` // 0 removes the limit
+	PendingAt    *time.Time        `json:"pending_at,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+func (GamblingLimit) TableName() string {
+	return "gambling_limits"
+}
+
+// GamblingExclusionKind distinguishes a short break from self-exclusion
+type GamblingExclusionKind string
+
+const (
+	GamblingCoolOff       GamblingExclusionKind = "cool_off"
+	GamblingSelfExclusion GamblingExclusionKind = "self_exclusion"
+)
+
+// GamblingExclusion blocks deposits and real-money trades until EndsAt. It
+// cannot be shortened or lifted early.
+type GamblingExclusion struct {
+	ID         uuid.UUID             `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CustomerID uuid.UUID             `gorm:"type:uuid;not null;index" json:"customer_id"`
+	Kind       GamblingExclusionKind `gorm:"size:20;not null" json:"kind"`
+	StartsAt   time.Time             `gorm:"not null" json:"starts_at"`
+	EndsAt     time.Time             `gorm:"not null;index" json:"ends_at"`
+	Reason     string                `gorm:"size:500" json:"reason,omitempty"`
+	CreatedAt  time.Time             `json:"created_at"`
+}
+
+func (GamblingExclusion) TableName() string {
+	return "gambling_exclusions"
+}
+
+// RealityCheck is a customer's reminder of time and money spent while
+// trading with real money
+type RealityCheck struct {
+	CustomerID      uuid.UUID  `gorm:"type:uuid;primaryKey" json:"customer_id"`
+	IntervalMinutes int        `gorm:"not null" json:"interval_minutes"` // 0 turns reminders off
+	LastSentAt      *time.Time `json:"last_sent_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (RealityCheck) TableName() string {
+	return "reality_checks"
+}
+
+// GamblingBlock explains why player protection refused a deposit or trade
+type GamblingBlock struct {
+	Reason         string                `json:"reason"`
+	Exclusion      GamblingExclusionKind `json:"exclusion,omitempty"`
+	Until          *time.Time            `json:"until,omitempty"`
+	Limit          GamblingLimitKind     `json:"limit,omitempty"`
+	Period         GamblingPeriod        `json:"period,omitempty"`
+	LimitCents     int64                 `json:"limit_cents,omitempty"`
+	UsedCents      int64                 `json:"used_cents,omitempty"`
+	AttemptedCents int64                 `json:"attempted_cents,omitempty"`
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KYCStatus is where a Sender or Recipient is in identity verification
+type KYCStatus string
+
+const (
+	KYCUnverified KYCStatus = "unverified"
+	KYCPending    KYCStatus = "pending"
+	KYCVerified   KYCStatus = "verified"
+	KYCRejected   KYCStatus = "rejected"
+	KYCExpired    KYCStatus = "expired"
+)
+
+// kycTransitions lists the allowed moves; everything else is refused
+var kycTransitions = map[KYCStatus][]KYCStatus{
+	KYCUnverified: {KYCPending},
+	KYCPending:    {KYCVerified, KYCRejected},
+	KYCVerified:   {KYCExpired},
+	KYCRejected:   {KYCPending},
+	KYCExpired:    {KYCPending},
+}
+
+// CanTransition reports whether s may move to next
+func (s KYCStatus) CanTransition(next KYCStatus) bool {
+	return slices.Contains(kycTransitions[s], next)
+}
+
+// Editable reports whether identity details and documents may still change
+func (s KYCStatus) Editable() bool {
+	return s == KYCUnverified || s == KYCRejected || s == KYCExpired || s == ""
+}
+
+// MinimumAge is the youngest a verified person may be
+const MinimumAge = 18
+
+var (
+	ErrUnsupportedIDType = errors.New("unsupported identification type")
+	ErrInvalidIDNumber   = errors.New("invalid identification number")
+	ErrIDExpired         = errors.New("identification document expired")
+	ErrIDExpiryRequired  = errors.New("identification expiry is required for this document")
+	ErrBirthDateRequired = errors.New("birth date is required")
+	ErrUnderage          = errors.New("must be at least 18 years old")
+)
+
+var (
+	kenyanNationalIDRegex = regexp.MustCompile(`^[0-9]{7,8}$`)
+	passportNumberRegex   = regexp.MustCompile(`^[A-Z0-9]{6,9}$`)
+	alienCardRegex        = regexp.MustCompile(`^[0-9]{6,9}$`)
+)
+
+// Identity is the identification data KYC validates
+type Identity struct {
+	Type      IdentificationType `json:"identification_type"`
+	Number    string             `json:"identification_number"`
+	Expiry    time.Time          `json:"identification_expiry"`
+	BirthDate time.Time          `json:"birth_date"`
+}
+
+// Normalize trims and uppercases the document number
+func (id Identity) Normalize() Identity {
+	id.Number = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(id.Number), " ", ""))
+	return id
+}
+
+// Validate applies the per-type rules: Kenyan national IDs are 7-8 digits and
+// do not expire; passports and alien cards must carry a future expiry. Holders
+// must be adults either way.
+func (id Identity) Validate(now time.Time) error {
+	id = id.Normalize()
+	switch id.Type {
+	case IDNational:
+		if !kenyanNationalIDRegex.MatchString(id.Number) {
+			return ErrInvalidIDNumber
+		}
+	case IDPassport, IDAlien:
+		re := passportNumberRegex
+		if id.Type == IDAlien {
+			re = alienCardRegex
+		}
+		if !re.MatchString(id.Number) {
+			return ErrInvalidIDNumber
+		}
+		if id.Expiry.IsZero() {
+			return ErrIDExpiryRequired
+		}
+	default:
+		return ErrUnsupportedIDType
+	}
+	if !id.Expiry.IsZero() && !id.Expiry.After(now) {
+		return ErrIDExpired
+	}
+	if id.BirthDate.IsZero() {
+		return ErrBirthDateRequired
+	}
+	if AgeOn(id.BirthDate, now) < MinimumAge {
+		return ErrUnderage
+	}
+	return nil
+}
+
+// AgeOn returns completed years between birth and now
+func AgeOn(birth, now time.Time) int {
+	years := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		years--
+	}
+	return years
+}
+
+// KYCSubject says which table a KYC record belongs to
+type KYCSubject string
+
+const (
+	KYCSubjectSender    KYCSubject = "sender"
+	KYCSubjectRecipient KYCSubject = "recipient"
+)
+
+// KYCDocument is metadata for an uploaded identity document. The file itself
+// lives in object storage under StorageKey; SHA256 pins its content.
+type KYCDocument struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CustomerID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"-"`
+	SubjectType KYCSubject `gorm:"size:20;not null;index:idx_kyc_doc_subject" json:"subject_type"`
+	SubjectID   uuid.UUID  `gorm:"type:uuid;not null;index:idx_kyc_doc_subject" json:"subject_id"`
+	Kind        string     `gorm:"size:30;not null" json:"kind"` // id_front, id_back, passport_bio, selfie, proof_of_address
+	StorageKey  string     `gorm:"size:500;not null" json:"storage_key"`
+	SHA256      string     `gorm:"column:sha256;size:64;not null" json:"sha256"`
+	ContentType string     `gorm:"size:50;not null" json:"content_type"`
+	SizeBytes   int64      `gorm:"not null" json:"size_bytes"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (KYCDocument) TableName() string {
+	return "kyc_documents"
+}
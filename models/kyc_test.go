@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdentityValidate(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	adult := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	nextYear := now.AddDate(1, 0, 0)
+
+	cases := map[string]struct {
+		id   Identity
+		want error
+	}{
+		"national id":         {Identity{Type: IDNational, Number: "12345678", BirthDate: adult}, nil},
+		"national id spaced":  {Identity{Type: IDNational, Number: " 1234 567 ", BirthDate: adult}, nil},
+		"national id letters": {Identity{Type: IDNational, Number: "A1234567", BirthDate: adult}, ErrInvalidIDNumber},
+		"national id short":   {Identity{Type: IDNational, Number: "123456", BirthDate: adult}, ErrInvalidIDNumber},
+		"passport":            {Identity{Type: IDPassport, Number: "ak1234567", Expiry: nextYear, BirthDate: adult}, nil},
+		"passport no expiry":  {Identity{Type: IDPassport, Number: "AK1234567", BirthDate: adult}, ErrIDExpiryRequired},
+		"passport expired":    {Identity{Type: IDPassport, Number: "AK1234567", Expiry: now.AddDate(0, 0, -1), BirthDate: adult}, ErrIDExpired},
+		"alien card":          {Identity{Type: IDAlien, Number: "1234567", Expiry: nextYear, BirthDate: adult}, nil},
+		"unknown type":        {Identity{Type: "driving_licence", Number: "1234567", BirthDate: adult}, ErrUnsupportedIDType},
+		"no birth date":       {Identity{Type: IDNational, Number: "12345678"}, ErrBirthDateRequired},
+		"turns 18 tomorrow":   {Identity{Type: IDNational, Number: "12345678", BirthDate: time.Date(2007, 6, 16, 0, 0, 0, 0, time.UTC)}, ErrUnderage},
+		"turned 18 today":     {Identity{Type: IDNational, Number: "12345678", BirthDate: time.Date(2007, 6, 15, 0, 0, 0, 0, time.UTC)}, nil},
+	}
+	for name, tc := range cases {
+		if err := tc.id.Validate(now); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestKYCTransitions(t *testing.T) {
+	allowed := [][2]KYCStatus{
+		{KYCUnverified, KYCPending},
+		{KYCPending, KYCVerified},
+		{KYCPending, KYCRejected},
+		{KYCVerified, KYCExpired},
+		{KYCRejected, KYCPending},
+		{KYCExpired, KYCPending},
+	}
+	for _, tr := range allowed {
+		if !tr[0].CanTransition(tr[1]) {
+			t.Errorf("%s -> %s should be allowed", tr[0], tr[1])
+		}
+	}
+	for _, tr := range [][2]KYCStatus{{KYCUnverified, KYCVerified}, {KYCRejected, KYCVerified}, {KYCVerified, KYCPending}} {
+		if tr[0].CanTransition(tr[1]) {
+			t.Errorf("%s -> %s should be refused", tr[0], tr[1])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LimitAction is the kind of money movement a limit rule caps
+type LimitAction string
+
+const (
+	LimitDeposit  LimitAction = "deposit"
+	LimitWithdraw LimitAction = "withdraw"
+	LimitTrade    LimitAction = "trade"
+)
+
+// LimitKind says what a rule measures
+type LimitKind string
+
+const (
+	LimitPerTransaction LimitKind = "per_transaction" // a single amount
+	LimitWindowSum      LimitKind = "window_sum"      // total amount over the window
+	LimitWindowCount    LimitKind = "window_count"    // number of transactions over the window
+)
+
+// Book selectors for LimitRule.Book; empty means both
+const (
+	BookReal = "real"
+	BookFake = "fake"
+)
+
+var ErrInvalidLimitRule = errors.New("invalid limit rule")
+
+// LimitRule caps one action. Empty AssetClass, Book and KYCStatus match
+// everything, so a rule can be as broad or narrow as needed; every matching
+// rule must pass.
+type LimitRule struct {
+	ID            uuid.UUID   `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name          string      `gorm:"size:100;not null" json:"name"`
+	Action        LimitAction `gorm:"size:20;not null;index" json:"action"`
+	Kind          LimitKind   `gorm:"size:20;not null" json:"kind"`
+	AssetClass    string      `gorm:"size:20" json:"asset_class,omitempty"`
+	Book          string      `gorm:"size:4" json:"book,omitempty"`
+	KYCStatus     KYCStatus   `gorm:"size:20" json:"kyc_status,omitempty"`
+	MaxCents      int64       `gorm:"type:bigint" json:"max_cents,omitempty"`
+	MaxCount      int64       `json:"max_count,omitempty"`
+	WindowSeconds int64       `json:"window_seconds,omitempty"`
+	Enabled       bool        `gorm:"not null" json:"enabled"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+func (LimitRule) TableName() string {
+	return "limit_rules"
+}
+
+// Window is the rolling window of sum and count rules
+func (r *LimitRule) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+// Validate checks that the rule is complete for its kind
+func (r *LimitRule) Validate() error {
+	if r.Name == "" || !slices.Contains([]LimitAction{LimitDeposit, LimitWithdraw, LimitTrade}, r.Action) {
+		return ErrInvalidLimitRule
+	}
+	if r.AssetClass != "" && !slices.Contains(AccountTypes, r.AssetClass) {
+		return ErrInvalidLimitRule
+	}
+	if r.Book != "" && r.Book != BookReal && r.Book != BookFake {
+		return ErrInvalidLimitRule
+	}
+	switch r.Kind {
+	case LimitPerTransaction:
+		if r.MaxCents <= 0 {
+			return ErrInvalidLimitRule
+		}
+	case LimitWindowSum:
+		if r.MaxCents <= 0 || r.WindowSeconds <= 0 {
+			return ErrInvalidLimitRule
+		}
+	case LimitWindowCount:
+		if r.MaxCount <= 0 || r.WindowSeconds <= 0 {
+			return ErrInvalidLimitRule
+		}
+	default:
+		return ErrInvalidLimitRule
+	}
+	return nil
+}
+
+// Applies reports whether the rule covers req made by a customer at kycStatus
+func (r *LimitRule) Applies(req LimitRequest, kycStatus KYCStatus) bool {
+	if !r.Enabled || r.Action != req.Action {
+		return false
+	}
+	if r.AssetClass != "" && r.AssetClass != req.AssetClass {
+		return false
+	}
+	if r.Book != "" && (r.Book == BookReal) != req.IsReal {
+		return false
+	}
+	return r.KYCStatus == "" || r.KYCStatus == kycStatus
+}
+
+// LimitRequest is a money movement about to happen. AssetClass and AccountID
+// are empty for smart deposits and withdrawals that span accounts.
+type LimitRequest struct {
+	CustomerID  uuid.UUID
+	AccountID   uuid.UUID
+	AssetClass  string
+	Action      LimitAction
+	IsReal      bool
+	AmountCents int64
+}
+
+// LimitViolation explains why a request was refused
+type LimitViolation struct {
+	RuleID         *uuid.UUID `json:"rule_id,omitempty"`
+	Rule           string     `json:"rule"`
+	Kind           LimitKind  `json:"kind"`
+	Reason         string     `json:"reason"`
+	LimitCents     int64      `json:"limit_cents,omitempty"`
+	LimitCount     int64      `json:"limit_count,omitempty"`
+	UsedCents      int64      `json:"used_cents,omitempty"`
+	UsedCount      int64      `json:"used_count,omitempty"`
+	AttemptedCents int64      `json:"attempted_cents"`
+	WindowSeconds  int64      `json:"window_seconds,omitempty"`
+}
@@ -0,0 +1,27 @@
+// models/otp_code.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OTPCode is the single active one-time code for a customer and purpose.
+// Only an HMAC of the code is stored; rows are hard-deleted once consumed.
+type OTPCode struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	CustomerID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_otp_customer_purpose"`
+	Purpose     string     `gorm:"size:20;not null;uniqueIndex:idx_otp_customer_purpose"` // "withdraw", "login", ...
+	CodeHash    string     `gorm:"size:64"`                                                // empty while locked out
+	ExpiresAt   time.Time  `gorm:"not null"`
+	Attempts    int        `gorm:"default:0"`
+	LockedUntil *time.Time `gorm:"type:timestamp"`
+	LastSentAt  time.Time  `gorm:"not null"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (OTPCode) TableName() string {
+	return "otp_codes"
+}
@@ -2,7 +2,6 @@
 package models
 
 import (
-    "errors"
     "gorm.io/gorm"
     "github.com/google/uuid"
     "time"
@@ -24,14 +23,26 @@ type Recipient struct {
     PostalCode           string `gorm:"size:20"`
     City                 string `gorm:"size:100"`
 
-    // Bank Account Details
+    // KYC (see service/kyc)
+    KYCStatus       KYCStatus  `gorm:"size:20;default:'unverified';index"`
+    KYCSubmittedAt  *time.Time `gorm:"type:timestamp"`
+    KYCReviewedAt   *time.Time `gorm:"type:timestamp"`
+    KYCReviewerID   *uuid.UUID `gorm:"type:uuid"`
+    KYCRejectReason string     `gorm:"size:255"`
+
+    // Bank Account Details (BankCode is from the payout bank catalog, see service/recipients)
+    BankCode             string    `gorm:"size:10"`
     BankAccountType      BankAccountType
     BankAccountNumber    string    `gorm:"size:100"`
     BankName             string    `gorm:"size:255"`
 
-    // Encrypted Fields
-    EmailEnc       string `gorm:"column:email_enc;size:500"`
-    PhoneNumberEnc string `gorm:"column:phone_enc;size:500"`
+    // Encrypted Fields (plaintext in memory, ciphertext in the column)
+    Email       EncryptedString `gorm:"column:email_enc;size:500"`
+    PhoneNumber EncryptedString `gorm:"column:phone_enc;size:500"`
+
+    // Blind Indexes (keyed HMAC, for lookups)
+    EmailIndex string `gorm:"column:email_bidx;size:64;index"`
+    PhoneIndex string `gorm:"column:phone_bidx;size:64;index"`
 
     // Plaintext
     ExternalID string `gorm:"size:100;uniqueIndex"`
@@ -40,31 +51,36 @@ type Recipient struct {
     Customer Customer `gorm:"foreignKey:CustomerID;constraint:OnDelete:RESTRICT"`
 }
 
-// Setters with Encryption & Validation
+// Identity returns the fields KYC validates
+func (r *Recipient) Identity() Identity {
+    return Identity{Type: r.IdentificationType, Number: r.IdentificationNumber, Expiry: r.IdentificationExpiry, BirthDate: r.BirthDate}
+}
+
+// Setters with Validation & Blind Index
 func (r *Recipient) SetEmail(email string) error {
-    if !emailRegex.MatchString(email) {
-        return errors.New("invalid email")
+    normalized, err := NormalizeEmail(email)
+    if err != nil {
+        return err
     }
-    enc, err := encrypt(email)
+    idx, err := blindIndex("email", normalized)
     if err != nil {
         return err
     }
-    r.EmailEnc = enc
+    r.Email = EncryptedString(normalized)
+    r.EmailIndex = idx
     return nil
 }
 
 func (r *Recipient) SetPhone(phone string) error {
-    if !kenyanPhoneRegex.MatchString(phone) {
-        return errors.New("invalid Kenyan phone")
-    }
-    if phone[0] == '0' {
-        phone = "+254" + phone[1:]
+    normalized, err := NormalizePhone(phone)
+    if err != nil {
+        return err
     }
-    enc, err := encrypt(phone)
+    idx, err := blindIndex("phone", normalized)
     if err != nil {
         return err
     }
-    r.PhoneNumberEnc = enc
+    r.PhoneNumber = EncryptedString(normalized)
+    r.PhoneIndex = idx
     return nil
 }
-
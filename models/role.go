@@ -0,0 +1,21 @@
+// models/role.go
+package models
+
+// Role is the identity class carried in access tokens
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleSupport  Role = "support" // read-only back office
+	RoleFinance  Role = "finance" // support + payment reversals
+	RoleAdmin    Role = "admin"   // everything, including role changes
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	switch r {
+	case RoleCustomer, RoleSupport, RoleFinance, RoleAdmin:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScreeningStatus is the outcome of checking a person against sanctions and
+// PEP lists
+type ScreeningStatus string
+
+const (
+	ScreeningClear     ScreeningStatus = "clear"          // no list entry came close
+	ScreeningPending   ScreeningStatus = "pending_review" // possible match awaiting a reviewer
+	ScreeningCleared   ScreeningStatus = "cleared"        // reviewer ruled the matches false positives
+	ScreeningConfirmed ScreeningStatus = "confirmed"      // reviewer confirmed a true match
+)
+
+// Blocks reports whether transfers involving the subject must stop
+func (s ScreeningStatus) Blocks() bool {
+	return s == ScreeningPending || s == ScreeningConfirmed
+}
+
+// ScreeningMatch is one list entry a name came close to
+type ScreeningMatch struct {
+	EntryID string  `json:"entry_id"`
+	Source  string  `json:"source"` // e.g. "UN", "OFAC", "KE-PEP"
+	Kind    string  `json:"kind"`   // "sanction" or "pep"
+	Name    string  `json:"name"`   // the listed name or alias that matched
+	Score   float64 `json:"score"`
+}
+
+// Key identifies the entry across list reloads
+func (m ScreeningMatch) Key() string {
+	return m.Source + ":" + m.EntryID
+}
+
+// ScreeningMatches is a JSON column of matches
+type ScreeningMatches []ScreeningMatch
+
+// Scan implements sql.Scanner
+func (m *ScreeningMatches) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	}
+	return fmt.Errorf("cannot scan %T into ScreeningMatches", value)
+}
+
+// Value implements driver.Valuer
+func (m ScreeningMatches) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+// ScreeningResult is the latest screening of a sender or recipient. Matches
+// a reviewer cleared are remembered so a list refresh does not raise them
+// again.
+type ScreeningResult struct {
+	ID          uuid.UUID        `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	SubjectType KYCSubject       `gorm:"size:20;not null;uniqueIndex:idx_screening_subject" json:"subject_type"`
+	SubjectID   uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_screening_subject" json:"subject_id"`
+	CustomerID  uuid.UUID        `gorm:"type:uuid;index;not null" json:"customer_id"`
+	Status      ScreeningStatus  `gorm:"size:20;not null;index" json:"status"`
+	Name        string           `gorm:"size:200" json:"name"` // as screened
+	Score       float64          `json:"score"`                // best match
+	Matches     ScreeningMatches `gorm:"type:jsonb" json:"matches"`
+	Cleared     ScreeningMatches `gorm:"type:jsonb" json:"cleared,omitempty"`
+	ListVersion string           `gorm:"size:64" json:"list_version"`
+	ScreenedAt  time.Time        `json:"screened_at"`
+	ReviewerID  *uuid.UUID       `gorm:"type:uuid" json:"reviewer_id,omitempty"`
+	ReviewedAt  *time.Time       `json:"reviewed_at,omitempty"`
+	ReviewNote  string           `gorm:"size:500" json:"review_note,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+func (ScreeningResult) TableName() string {
+	return "screening_results"
+}
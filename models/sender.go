@@ -31,10 +31,21 @@ type Sender struct {
     PostalCode           string `gorm:"size:20"`
     City                 string `gorm:"size:100"`
 
-    // Encrypted Fields
-    EmailEnc       string `gorm:"column:email_enc;size:500"`
-    PhoneNumberEnc string `gorm:"column:phone_enc;size:500"`
-    IPAddressEnc   string `gorm:"column:ip_address_enc;size:500"`
+    // KYC (see service/kyc)
+    KYCStatus       KYCStatus  `gorm:"size:20;default:'unverified';index"`
+    KYCSubmittedAt  *time.Time `gorm:"type:timestamp"`
+    KYCReviewedAt   *time.Time `gorm:"type:timestamp"`
+    KYCReviewerID   *uuid.UUID `gorm:"type:uuid"`
+    KYCRejectReason string     `gorm:"size:255"`
+
+    // Encrypted Fields (plaintext in memory, ciphertext in the column)
+    Email       EncryptedString `gorm:"column:email_enc;size:500"`
+    PhoneNumber EncryptedString `gorm:"column:phone_enc;size:500"`
+    IPAddress   EncryptedString `gorm:"column:ip_address_enc;size:500"`
+
+    // Blind Indexes (keyed HMAC, for lookups)
+    EmailIndex string `gorm:"column:email_bidx;size:64;index"`
+    PhoneIndex string `gorm:"column:phone_bidx;size:64;index"`
 
     // Plaintext
     ExternalID string `gorm:"size:100;uniqueIndex"`
@@ -56,40 +67,67 @@ func (s *Sender) BeforeCreate(tx *gorm.DB) error {
     return nil
 }
 
-// Setters with Encryption & Validation
+// Identity returns the fields KYC validates
+func (s *Sender) Identity() Identity {
+    return Identity{Type: s.IdentificationType, Number: s.IdentificationNumber, Expiry: s.IdentificationExpiry, BirthDate: s.BirthDate}
+}
+
+// Setters with Validation & Blind Index
 func (s *Sender) SetEmail(email string) error {
-    if !emailRegex.MatchString(email) {
-        return errors.New("invalid email")
+    normalized, err := NormalizeEmail(email)
+    if err != nil {
+        return err
     }
-    enc, err := encrypt(email)
+    idx, err := blindIndex("email", normalized)
     if err != nil {
         return err
     }
-    s.EmailEnc = enc
+    s.Email = EncryptedString(normalized)
+    s.EmailIndex = idx
     return nil
 }
 
 func (s *Sender) SetPhone(phone string) error {
-    if !kenyanPhoneRegex.MatchString(phone) {
-        return errors.New("invalid Kenyan phone")
-    }
-    if phone[0] == '0' {
-        phone = "+254" + phone[1:]
+    normalized, err := NormalizePhone(phone)
+    if err != nil {
+        return err
     }
-    enc, err := encrypt(phone)
+    idx, err := blindIndex("phone", normalized)
     if err != nil {
         return err
     }
-    s.PhoneNumberEnc = enc
+    s.PhoneNumber = EncryptedString(normalized)
+    s.PhoneIndex = idx
     return nil
 }
 
 func (s *Sender) SetIPAddress(ip string) error {
-    enc, err := encrypt(ip)
+    s.IPAddress = EncryptedString(ip)
+    return nil
+}
+
+// FindSenderByPhone looks a sender up through phone_bidx
+func FindSenderByPhone(db *gorm.DB, phone string) (*Sender, error) {
+    idx, err := PhoneBlindIndex(phone)
     if err != nil {
-        return err
+        return nil, err
     }
-    s.IPAddressEnc = enc
-    return nil
+    var s Sender
+    if err := db.Where("phone_bidx = ?", idx).First(&s).Error; err != nil {
+        return nil, err
+    }
+    return &s, nil
 }
 
+// FindSenderByEmail looks a sender up through email_bidx
+func FindSenderByEmail(db *gorm.DB, email string) (*Sender, error) {
+    idx, err := EmailBlindIndex(email)
+    if err != nil {
+        return nil, err
+    }
+    var s Sender
+    if err := db.Where("email_bidx = ?", idx).First(&s).Error; err != nil {
+        return nil, err
+    }
+    return &s, nil
+}
@@ -0,0 +1,35 @@
+// models/session.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one logged-in device. It holds the hash of the current refresh
+// token and of the one it replaced, so reuse of a rotated token is detectable.
+type Session struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CustomerID   uuid.UUID  `gorm:"type:uuid;index;not null" json:"-"`
+	Scope        string     `gorm:"size:255" json:"scope"` // granted at login, kept across refreshes
+	Role         Role       `gorm:"size:20;default:'customer'" json:"role"`
+	RefreshHash  string     `gorm:"size:64;not null" json:"-"`
+	PreviousHash string     `gorm:"size:64" json:"-"`
+	UserAgent    string     `gorm:"size:255" json:"user_agent"`
+	IPAddress    string     `gorm:"size:64" json:"ip_address"`
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `gorm:"type:timestamp" json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"-"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// Active reports whether the session can still mint access tokens
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
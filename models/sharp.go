@@ -25,10 +25,6 @@ type Sharp struct {
 	ForexBank      ForexBank      `gorm:"foreignKey:ForexBankID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
 	CryptoBank     CryptoBank     `gorm:"foreignKey:CryptoBankID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
 	SharpAccounts  []SharpAccount `gorm:"foreignKey:SharpID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
-	SportsAccounts []SportsAccount `gorm:"foreignKey:SharpID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
-	StockAccounts  []StockAccount  `gorm:"foreignKey:SharpID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
-	ForexAccounts  []ForexAccount  `gorm:"foreignKey:SharpID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
-	CryptoAccounts []CryptoAccount `gorm:"foreignKey:SharpID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	DeletedAt      gorm.DeletedAt `gorm:"index"`
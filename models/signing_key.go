@@ -0,0 +1,32 @@
+// models/signing_key.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SigningKey is a per-customer HMAC key for request signing. Several may be
+// active at once so clients can roll keys without downtime.
+type SigningKey struct {
+	ID         uuid.UUID       `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"-"`
+	KeyID      string          `gorm:"size:40;uniqueIndex;not null" json:"key_id"` // public identifier sent in X-Key-Id
+	CustomerID uuid.UUID       `gorm:"type:uuid;index;not null" json:"-"`
+	Secret     EncryptedString `gorm:"column:secret_enc;size:500;not null" json:"-"`
+	Label      string          `gorm:"size:100" json:"label"`
+	ExpiresAt  *time.Time      `gorm:"type:timestamp" json:"expires_at,omitempty"` // set on the old key during rotation
+	RevokedAt  *time.Time      `gorm:"type:timestamp" json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time      `gorm:"type:timestamp" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"-"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
+
+// Active reports whether the key may still verify signatures
+func (k *SigningKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
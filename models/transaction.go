@@ -13,14 +13,18 @@ const (
 	TransactionTypeDeposit  TransactionType = "deposit"
 	TransactionTypeWithdraw TransactionType = "withdraw"
 	TransactionTypeTrade    TransactionType = "trade"
+	TransactionTypeReversal TransactionType = "reversal"
+	TransactionTypeTransfer TransactionType = "transfer"
+	TransactionTypeConvert  TransactionType = "convert"
 )
 
 type TransactionStatus string
 
 const (
-	StatusPending TransactionStatus = "pending"
-	StatusSuccess TransactionStatus = "success"
-	StatusFailed  TransactionStatus = "failed"
+	StatusPending  TransactionStatus = "pending"
+	StatusSuccess  TransactionStatus = "success"
+	StatusFailed   TransactionStatus = "failed"
+	StatusReversed TransactionStatus = "reversed"
 )
 
 type Transaction struct {
@@ -35,9 +39,15 @@ type Transaction struct {
 	SenderID        uuid.UUID         `gorm:"type:uuid;index"`
 	Reference       string            `gorm:"size:50;uniqueIndex"`
 	Type            TransactionType   `gorm:"not null"`
-	AmountCents     int64             `gorm:"type:bigint;not null"`
+	AmountCents     int64             `gorm:"type:bigint;not null"` // gross: paid in, debited or staked
+	FeeCents        int64             `gorm:"type:bigint;not null;default:0"`
+	NetAmountCents  int64             `gorm:"type:bigint;not null;default:0"` // gross less fee: credited, paid out or placed
 	IsReal          bool              `gorm:"not null"`
-	Currency        string            `gorm:"size:3;default:'KES'"`
+	Currency        string            `gorm:"size:3;default:'KES'"`           // amounts are minor units of this currency
+	CounterCurrency string            `gorm:"size:3"`                         // conversions: the currency bought
+	CounterCents    int64             `gorm:"type:bigint;not null;default:0"` // conversions: minor units of CounterCurrency credited
+	FXRate          string            `gorm:"size:32"`                        // conversions: CounterCurrency per unit of Currency, after spread
+	FXSpreadCents   int64             `gorm:"type:bigint;not null;default:0"` // conversions: minor units of CounterCurrency kept as spread
 	Status          TransactionStatus `gorm:"size:20;default:'pending'"`
 	Metadata        JSONMap           `gorm:"type:jsonb"` // e.g., {"ev": 100, "market": "EPL"}
 	ExternalID      string            `gorm:"size:100;index"`
@@ -76,5 +86,57 @@ func (t *Transaction) BeforeCreate(tx *gorm.DB) error {
 	if t.AmountCents <= 0 {
 		return fmt.Errorf("amount must be positive")
 	}
+	if t.FeeCents < 0 || t.FeeCents > t.AmountCents {
+		return fmt.Errorf("fee must be between zero and the amount")
+	}
+	if t.NetAmountCents == 0 {
+		t.NetAmountCents = t.AmountCents - t.FeeCents
+	}
+	return nil
+}
+
+// Net is the amount after fees; rows written before fees were recorded have
+// no fee, so their net is the gross amount
+func (t *Transaction) Net() int64 {
+	if t.NetAmountCents == 0 && t.FeeCents == 0 {
+		return t.AmountCents
+	}
+	return t.NetAmountCents
+}
+
+// Account returns the type and ID of the single account the transaction touches
+func (t *Transaction) Account() (string, uuid.UUID) {
+	switch {
+	case t.SharpAccountID != uuid.Nil:
+		return "sharp", t.SharpAccountID
+	case t.SportsAccountID != uuid.Nil:
+		return "sports", t.SportsAccountID
+	case t.StockAccountID != uuid.Nil:
+		return "stock", t.StockAccountID
+	case t.ForexAccountID != uuid.Nil:
+		return "forex", t.ForexAccountID
+	case t.CryptoAccountID != uuid.Nil:
+		return "crypto", t.CryptoAccountID
+	}
+	return "", uuid.Nil
+}
+
+// SetAccount points the transaction at a single account of the given type
+func (t *Transaction) SetAccount(accountType string, id uuid.UUID) error {
+	t.SharpAccountID, t.SportsAccountID, t.StockAccountID, t.ForexAccountID, t.CryptoAccountID = uuid.Nil, uuid.Nil, uuid.Nil, uuid.Nil, uuid.Nil
+	switch accountType {
+	case "sharp":
+		t.SharpAccountID = id
+	case "sports":
+		t.SportsAccountID = id
+	case "stock":
+		t.StockAccountID = id
+	case "forex":
+		t.ForexAccountID = id
+	case "crypto":
+		t.CryptoAccountID = id
+	default:
+		return fmt.Errorf("unknown account type %s", accountType)
+	}
 	return nil
 }
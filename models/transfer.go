@@ -0,0 +1,106 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransferStatus is where a remittance is in its lifecycle
+type TransferStatus string
+
+const (
+	TransferQuoted        TransferStatus = "quoted"
+	TransferAwaitingFunds TransferStatus = "awaiting_funds"
+	TransferFunded        TransferStatus = "funded"
+	TransferPayoutPending TransferStatus = "payout_pending"
+	TransferPaid          TransferStatus = "paid"
+	TransferFailed        TransferStatus = "failed"
+	TransferCancelled     TransferStatus = "cancelled"
+)
+
+// transferTransitions lists the allowed moves. A failed payout may be retried
+// or cancelled (refunding the sender); paid and cancelled are final.
+var transferTransitions = map[TransferStatus][]TransferStatus{
+	TransferQuoted:        {TransferAwaitingFunds, TransferFunded, TransferCancelled},
+	TransferAwaitingFunds: {TransferFunded, TransferCancelled},
+	TransferFunded:        {TransferPayoutPending, TransferCancelled},
+	TransferPayoutPending: {TransferPaid, TransferFailed},
+	TransferFailed:        {TransferPayoutPending, TransferCancelled},
+}
+
+// CanTransition reports whether s may move to next
+func (s TransferStatus) CanTransition(next TransferStatus) bool {
+	return slices.Contains(transferTransitions[s], next)
+}
+
+// Final reports whether no further transition is possible
+func (s TransferStatus) Final() bool {
+	return len(transferTransitions[s]) == 0
+}
+
+// PayoutMethod is how the recipient receives the money
+type PayoutMethod string
+
+const (
+	PayoutMpesaB2C PayoutMethod = "mpesa_b2c"
+	PayoutBank     PayoutMethod = "bank"
+)
+
+// FundingSource is where the sender's money comes from
+type FundingSource string
+
+const (
+	FundingAccount FundingSource = "account"
+	FundingMpesa   FundingSource = "mpesa"
+)
+
+// Transfer is a remittance from a customer's Sender to one of their Recipients.
+// TotalCents (amount plus fee) is what the sender pays; AmountCents is what
+// the recipient receives.
+type Transfer struct {
+	ID               uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CustomerID       uuid.UUID      `gorm:"type:uuid;index;not null" json:"-"`
+	SenderID         uuid.UUID      `gorm:"type:uuid;index;not null" json:"sender_id"`
+	RecipientID      uuid.UUID      `gorm:"type:uuid;index;not null" json:"recipient_id"`
+	AmountCents      int64          `gorm:"type:bigint;not null" json:"amount_cents"`
+	FeeCents         int64          `gorm:"type:bigint;not null" json:"fee_cents"`
+	TotalCents       int64          `gorm:"type:bigint;not null" json:"total_cents"`
+	Currency         string         `gorm:"size:3;default:'KES'" json:"currency"`
+	PayoutMethod     PayoutMethod   `gorm:"size:20;not null" json:"payout_method"`
+	Status           TransferStatus `gorm:"size:20;not null;index" json:"status"`
+	QuoteExpiresAt   time.Time      `gorm:"not null" json:"quote_expires_at"`
+	FundingSource    FundingSource  `gorm:"size:20" json:"funding_source,omitempty"`
+	FundingAccountID *uuid.UUID     `gorm:"type:uuid" json:"funding_account_id,omitempty"`
+	FundingType      string         `gorm:"size:20" json:"funding_account_type,omitempty"`
+	FundingPhone     string         `gorm:"size:20" json:"-"`
+	FundingRef       string         `gorm:"size:100;index" json:"funding_ref,omitempty"`
+	PayoutRef        string         `gorm:"size:100;index" json:"payout_ref,omitempty"`
+	PayoutAttempts   int            `gorm:"not null;default:0" json:"payout_attempts"`
+	RefundRef        string         `gorm:"size:100" json:"refund_ref,omitempty"`
+	FailureReason    string         `gorm:"size:255" json:"failure_reason,omitempty"`
+	FundedAt         *time.Time     `json:"funded_at,omitempty"`
+	PaidAt           *time.Time     `json:"paid_at,omitempty"`
+	CancelledAt      *time.Time     `json:"cancelled_at,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+func (Transfer) TableName() string {
+	return "transfers"
+}
+
+// TransferEvent records one state change of a Transfer
+type TransferEvent struct {
+	ID         uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	TransferID uuid.UUID      `gorm:"type:uuid;index;not null" json:"transfer_id"`
+	From       TransferStatus `gorm:"column:from_status;size:20" json:"from"`
+	To         TransferStatus `gorm:"column:to_status;size:20;not null" json:"to"`
+	Note       string         `gorm:"size:255" json:"note,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+func (TransferEvent) TableName() string {
+	return "transfer_events"
+}
@@ -5,37 +5,165 @@ import (
     "github.com/nats-io/nats.go"
     "weriKana/api/handlers"
     "weriKana/middleware"
+    "weriKana/models"
+    "weriKana/service/aml"
+    "weriKana/service/apikeys"
     "weriKana/service/dd_rr"
+    "weriKana/service/auth"
+    "weriKana/service/backoffice"
+    "weriKana/service/bookieaccounts"
+    "weriKana/service/bookies"
+    "weriKana/service/fees"
+    "weriKana/service/fx"
+    "weriKana/service/gambling"
+    "weriKana/service/jwtkeys"
+    "weriKana/service/keystore"
+    "weriKana/service/kyc"
+    "weriKana/service/limits"
+    "weriKana/service/onboarding"
+    "weriKana/service/otp"
+    "weriKana/service/recipients"
+    "weriKana/service/remittance"
+    "weriKana/service/screening"
+    "weriKana/service/session"
+    "weriKana/service/totp"
     "gorm.io/gorm"
 )
 
 // SetupRoutes configures the API routes for the Fiber app
-func SetupRoutes(app *fiber.App, db *gorm.DB, secretKey string, keyStore *handlers.KeyStore, otpSvc *handlers.OTPService, nc *nats.Conn, crypto *securewithdrawal.CryptoEngine) {
+func SetupRoutes(app *fiber.App, db *gorm.DB, jwtKeys *jwtkeys.KeySet, keyStore *keystore.KeyStore, authSvc *auth.Service, apiKeySvc *apikeys.Service, onboardSvc *onboarding.Service, sessionSvc *session.Service, backOffice *backoffice.Service, bookieSvc *bookies.Service, bookieAccountSvc *bookieaccounts.Service, feeSvc *fees.Service, fxSvc *fx.Service, kycSvc *kyc.Service, limitSvc *limits.Service, amlSvc *aml.Service, gamblingSvc *gambling.Service, recipientSvc *recipients.Service, remitSvc *remittance.Service, screenSvc *screening.Service, otpSvc *otp.Service, totpSvc *totp.Service, nc *nats.Conn, crypto *securewithdrawal.CryptoEngine) {
+    // Token verification keys for the execution engine and other services
+    app.Get("/.well-known/jwks.json", handlers.JWKS(jwtKeys))
+
     // API group version 1
     v1 := app.Group("/api/v1")
 
     // Public routes (no JWT required)
-    v1.Post("/token", handlers.Login(db, secretKey))                      // Login to get JWT
+    v1.Post("/token", handlers.Login(authSvc, sessionSvc))            // Login to get access + refresh token
+    v1.Post("/token/refresh", handlers.RefreshToken(sessionSvc))          // Rotate refresh token
+    v1.Post("/auth/register", handlers.Register(authSvc))                 // Create customer with password
+    v1.Post("/customers", handlers.CreateCustomer(onboardSvc))            // Onboard customer with nexus, profiles and accounts
+    v1.Post("/auth/password/forgot", handlers.RequestPasswordReset(authSvc)) // Text a reset OTP
+    v1.Post("/auth/password/reset", handlers.ResetPassword(authSvc, sessionSvc)) // Reset password with OTP
     v1.Post("/withdraw/otp", handlers.RequestWithdrawOTP(db, otpSvc))     // Request OTP for withdrawal
 
-    // Authorized routes (require JWT)
-    authorized := v1.Group("/", middleware.AuthMiddleware(secretKey))
+    // Authorized routes (require JWT or API key)
+    authorized := v1.Group("/", middleware.AuthMiddleware(jwtKeys, sessionSvc, apiKeySvc))
+
+    // Credential management needs an interactive login, not an API key
+    interactive := middleware.RequireSession()
+    authorized.Put("/auth/password", interactive, handlers.ChangePassword(authSvc, sessionSvc)) // Change password, logging out other sessions
+    authorized.Post("/logout", interactive, handlers.Logout(sessionSvc))               // Revoke current session
+    authorized.Get("/sessions", interactive, handlers.ListSessions(sessionSvc))        // List my sessions
+    authorized.Delete("/sessions/:id", interactive, handlers.RevokeSession(sessionSvc)) // Revoke one of my sessions
+
+    // API keys for bots and partner integrations
+    authorized.Post("/api-keys", interactive, handlers.CreateAPIKey(apiKeySvc))        // Create key; raw key shown once
+    authorized.Get("/api-keys", interactive, handlers.ListAPIKeys(apiKeySvc))          // List my keys
+    authorized.Delete("/api-keys/:id", interactive, handlers.RevokeAPIKey(apiKeySvc))  // Revoke immediately
 
-    // Account-related routes
-    authorized.Get("/account", handlers.GetAccount(db))                   // Get account details
-    authorized.Post("/account/deposit", handlers.AccountDeposit(db)) // Single-account deposit
-    authorized.Post("/account/smart-deposit", handlers.SmartDeposit(db, nc)) // Smart deposit
-    authorized.Post("/account/deposit", handlers.Deposit(db))             // Deposit funds
-    authorized.Post("/account/trade", handlers.PlaceTrade(db))            // Place a trade
-    authorized.Post("/account/fake-topup", handlers.FakeTopup(db))        // Fake balance top-up
+    // Per-account routes: the token is customer-scoped, the account comes from the path
+    read := middleware.RequireScope(middleware.ScopeAccountsRead)
+    authorized.Get("/accounts", read, handlers.ListAccounts(db))          // List my accounts
+    account := authorized.Group("/accounts/:id", middleware.AccountAccess(db))
+    account.Get("/", read, handlers.GetAccount(db))                       // Get account details
+    account.Get("/sharp-profile", read, handlers.GetSharpProfile(db))     // Sharp profile for the account's asset class
+    account.Get("/balances", read, handlers.GetAccountBalances(db))       // Balances in every currency held
+    realMoney := middleware.RequireKYCForRealMoney(kycSvc)
+    protectDeposit := middleware.EnforcePlayerProtection(gamblingSvc, models.LimitDeposit)
+    account.Post("/deposit", middleware.RequireScope(middleware.ScopeAccountsDeposit), realMoney, protectDeposit, middleware.EnforceLimits(limitSvc, models.LimitDeposit), handlers.AccountDeposit(db, feeSvc)) // Single-account deposit
+    account.Post("/trade", middleware.RequireScope(middleware.ScopeAccountsTrade), middleware.EnforcePlayerProtection(gamblingSvc, models.LimitTrade), middleware.EnforceLimits(limitSvc, models.LimitTrade), handlers.PlaceTrade(db, feeSvc))         // Place a trade
+    account.Post("/fx-quotes", middleware.RequireScope(middleware.ScopeAccountsTrade), realMoney, handlers.QuoteConversion(fxSvc))  // Price a currency conversion
+    account.Post("/convert", middleware.RequireScope(middleware.ScopeAccountsTrade), realMoney, handlers.ExecuteConversion(fxSvc))  // Convert at a quoted rate
+
+    authorized.Post("/account/deposit", middleware.RequireScope(middleware.ScopeAccountsDeposit), realMoney, protectDeposit, middleware.EnforceLimits(limitSvc, models.LimitDeposit), handlers.Deposit(db))             // Deposit funds
+    authorized.Post("/account/fake-topup", middleware.RequireScope(middleware.ScopeAccountsDeposit), handlers.FakeTopup(db))        // Fake balance top-up
 
     // Asset and Nexus-related routes
-    authorized.Get("/asset-nexus", handlers.GetAssetNexus(db))            // Get asset nexus data
-    authorized.Get("/sharp-profile", handlers.GetSharpProfile(db))        // Get sharp profile data
+    authorized.Get("/asset-nexus", read, handlers.GetAssetNexus(db))      // Get asset nexus data
+
+    // Smart deposit and withdraw routes (span all of the customer's accounts)
+    authorized.Post("/account/smart-deposit", middleware.RequireScope(middleware.ScopeAccountsDeposit), realMoney, protectDeposit, middleware.EnforceLimits(limitSvc, models.LimitDeposit), handlers.SmartDeposit(db, nc, feeSvc)) // Smart deposit
+    authorized.Post("/account/smart-withdraw", middleware.RequireScope(middleware.ScopeAccountsWithdraw), middleware.RequireSignedRequest(keyStore), realMoney, middleware.EnforceLimits(limitSvc, models.LimitWithdraw), handlers.SmartWithdraw(db, otpSvc, totpSvc, feeSvc, crypto, nc)) // Smart withdraw with CryptoEngine
+
+    // Bookie accounts I hold myself; a link is active once its test deposit succeeds
+    authorized.Post("/bookie-accounts", interactive, handlers.LinkBookieAccount(bookieAccountSvc))                    // Link; credentials stored encrypted
+    authorized.Get("/bookie-accounts", read, handlers.ListBookieAccounts(bookieAccountSvc))                           // List my links
+    authorized.Post("/bookie-accounts/:id/deactivate", interactive, handlers.DeactivateBookieAccount(bookieAccountSvc)) // Stop deposits and withdrawals
+    authorized.Post("/bookie-accounts/:id/relink", interactive, handlers.RelinkBookieAccount(bookieAccountSvc))       // New number or credentials, verify again
+
+    // Request-signing keys (HMAC), required by signed routes such as smart-withdraw
+    authorized.Post("/signing-keys", interactive, handlers.EnrollSigningKey(keyStore))              // Create key; secret shown once
+    authorized.Get("/signing-keys", interactive, handlers.ListSigningKeys(keyStore))                // List usable keys
+    authorized.Post("/signing-keys/:kid/rotate", interactive, handlers.RotateSigningKey(keyStore))  // Replace key, old one in grace
+    authorized.Delete("/signing-keys/:kid", interactive, handlers.RevokeSigningKey(keyStore))       // Revoke immediately
+
+    // Second factor (authenticator app)
+    authorized.Post("/2fa/totp/enroll", interactive, handlers.EnrollTOTP(db, totpSvc))       // Start TOTP enrollment
+    authorized.Post("/2fa/totp/confirm", interactive, handlers.ConfirmTOTP(totpSvc))         // Confirm and get recovery codes
+    authorized.Put("/2fa/policy", interactive, handlers.UpdateSecondFactorPolicy(db, totpSvc)) // sms | totp | any
+
+    // KYC: identity details and document metadata for me (sender) and my recipients
+    authorized.Get("/kyc", interactive, handlers.GetKYC(kycSvc))                               // My KYC status and documents
+    authorized.Put("/kyc/sender", interactive, handlers.SaveSenderKYC(kycSvc))                 // My identity details
+    authorized.Put("/kyc/recipients/:id", interactive, handlers.SaveRecipientKYC(kycSvc))      // A recipient's identity details
+    authorized.Post("/kyc/documents", interactive, handlers.AddKYCDocument(kycSvc))            // Record an uploaded document
+    authorized.Post("/kyc/submit", interactive, handlers.SubmitKYC(kycSvc))                    // Send for review
+
+    // Responsible gambling: self-set limits, cool-off, self-exclusion and reality checks
+    authorized.Get("/responsible-gambling", interactive, handlers.GetPlayerProtection(gamblingSvc))           // My limits and any exclusion
+    authorized.Put("/responsible-gambling/limits", interactive, handlers.SetGamblingLimit(gamblingSvc))       // Lower now, raise after a delay
+    authorized.Post("/responsible-gambling/exclusions", interactive, handlers.StartExclusion(gamblingSvc))    // Cool-off or self-exclude
+    authorized.Put("/responsible-gambling/reality-check", interactive, handlers.SetRealityCheck(gamblingSvc)) // Reminder interval in minutes
+
+    // Recipient address book; contact details are masked in responses
+    send := middleware.RequireScope(middleware.ScopeAccountsWithdraw)
+    authorized.Get("/recipients/banks", read, handlers.ListPayoutBanks())                   // Payout bank catalog
+    authorized.Post("/recipients", send, handlers.CreateRecipient(recipientSvc))            // Add a recipient
+    authorized.Get("/recipients", read, handlers.ListRecipients(recipientSvc))              // List my recipients
+    authorized.Get("/recipients/:id", read, handlers.GetRecipient(recipientSvc))            // Get one recipient
+    authorized.Put("/recipients/:id", send, handlers.UpdateRecipient(recipientSvc))         // Replace contact and bank details
+    authorized.Delete("/recipients/:id", send, handlers.DeleteRecipient(recipientSvc))      // Remove (soft delete)
+
+    // Remittance: quote, fund, pay out or cancel transfers to my recipients
+    authorized.Post("/transfers", send, handlers.CreateTransfer(remitSvc))                  // Quote a transfer
+    authorized.Get("/transfers", read, handlers.ListTransfers(remitSvc))                    // List my transfers
+    authorized.Get("/transfers/:id", read, handlers.GetTransfer(remitSvc))                  // Transfer with status history
+    authorized.Post("/transfers/:id/fund", send, handlers.FundTransfer(remitSvc))           // Fund from an account or M-Pesa
+    authorized.Post("/transfers/:id/send", send, handlers.SendTransfer(remitSvc))           // Pay out (or retry a failed payout)
+    authorized.Post("/transfers/:id/cancel", send, handlers.CancelTransfer(remitSvc))       // Cancel before payout, refunding if funded
 
-    // Smart deposit and withdraw routes
-    authorized.Post("/account/smart-deposit", handlers.SmartDeposit(db)) // Smart deposit
-    authorized.Post("/account/smart-withdraw", handlers.SmartWithdraw(db, keyStore, crypto)) // Smart withdraw with CryptoEngine
+    // Back office: staff roles only, each route checks its own permission
+    admin := v1.Group("/admin", middleware.AuthMiddleware(jwtKeys, sessionSvc, nil), middleware.RequireStaff())
+    admin.Get("/customers/:id", middleware.RequirePermission(middleware.PermCustomersRead), handlers.AdminGetCustomer(backOffice))
+    admin.Get("/customers/:id/transactions", middleware.RequirePermission(middleware.PermTransactionsRead), handlers.AdminListTransactions(backOffice))
+    admin.Post("/transactions/:id/reverse", middleware.RequirePermission(middleware.PermTransactionsReverse), handlers.AdminReverseTransaction(backOffice))
+    admin.Put("/customers/:id/role", middleware.RequirePermission(middleware.PermRolesManage), handlers.AdminSetRole(backOffice, sessionSvc))
+    admin.Get("/kyc/pending", middleware.RequirePermission(middleware.PermKYCReview), handlers.AdminPendingKYC(kycSvc))
+    admin.Post("/kyc/:type/:id/review", middleware.RequirePermission(middleware.PermKYCReview), handlers.AdminReviewKYC(kycSvc))
+    admin.Get("/limits", middleware.RequirePermission(middleware.PermLimitsManage), handlers.AdminListLimits(limitSvc))
+    admin.Post("/limits", middleware.RequirePermission(middleware.PermLimitsManage), handlers.AdminCreateLimit(limitSvc))
+    admin.Put("/limits/:id", middleware.RequirePermission(middleware.PermLimitsManage), handlers.AdminUpdateLimit(limitSvc))
+    admin.Delete("/limits/:id", middleware.RequirePermission(middleware.PermLimitsManage), handlers.AdminDeleteLimit(limitSvc))
+    admin.Get("/bookies", middleware.RequirePermission(middleware.PermBookiesManage), handlers.AdminListBookies(bookieSvc))
+    admin.Post("/bookies", middleware.RequirePermission(middleware.PermBookiesManage), handlers.AdminCreateBookie(bookieSvc))
+    admin.Get("/bookies/:id", middleware.RequirePermission(middleware.PermBookiesManage), handlers.AdminGetBookie(bookieSvc))
+    admin.Put("/bookies/:id", middleware.RequirePermission(middleware.PermBookiesManage), handlers.AdminUpdateBookie(bookieSvc))
+    admin.Delete("/bookies/:id", middleware.RequirePermission(middleware.PermBookiesManage), handlers.AdminDeleteBookie(bookieSvc))
+    admin.Get("/fee-schedules", middleware.RequirePermission(middleware.PermFeesManage), handlers.AdminListFeeSchedules(feeSvc))
+    admin.Post("/fee-schedules", middleware.RequirePermission(middleware.PermFeesManage), handlers.AdminCreateFeeSchedule(feeSvc))
+    admin.Put("/fee-schedules/:id", middleware.RequirePermission(middleware.PermFeesManage), handlers.AdminUpdateFeeSchedule(feeSvc))
+    admin.Delete("/fee-schedules/:id", middleware.RequirePermission(middleware.PermFeesManage), handlers.AdminDeleteFeeSchedule(feeSvc))
+    admin.Get("/fees/revenue", middleware.RequirePermission(middleware.PermFeesManage), handlers.AdminFeeRevenue(feeSvc))
+    admin.Get("/aml/cases", middleware.RequirePermission(middleware.PermAMLReview), handlers.AdminListAMLCases(amlSvc))
+    admin.Get("/aml/cases/:id", middleware.RequirePermission(middleware.PermAMLReview), handlers.AdminGetAMLCase(amlSvc))
+    admin.Put("/aml/cases/:id/assignee", middleware.RequirePermission(middleware.PermAMLReview), handlers.AdminAssignAMLCase(amlSvc))
+    admin.Post("/aml/cases/:id/status", middleware.RequirePermission(middleware.PermAMLReview), handlers.AdminSetAMLCaseStatus(amlSvc))
+    admin.Post("/aml/cases/:id/notes", middleware.RequirePermission(middleware.PermAMLReview), handlers.AdminAddAMLCaseNote(amlSvc))
+    admin.Get("/screening/pending", middleware.RequirePermission(middleware.PermScreeningReview), handlers.AdminPendingScreening(screenSvc))
+    admin.Post("/screening/refresh", middleware.RequirePermission(middleware.PermScreeningReview), handlers.AdminRefreshScreening(screenSvc))
+    admin.Post("/screening/:id/review", middleware.RequirePermission(middleware.PermScreeningReview), handlers.AdminReviewScreening(screenSvc))
+    admin.Get("/audit-log", middleware.RequirePermission(middleware.PermAuditRead), handlers.AdminAuditLog(backOffice))
 
     // Start NATS consumer for MPESA STK sequence (background task)
     go handlers.StartStkSequenceConsumer(db, nc)
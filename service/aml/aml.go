@@ -0,0 +1,287 @@
+package aml
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var (
+	ErrCaseNotFound      = errors.New("aml case not found")
+	ErrInvalidTransition = errors.New("case cannot move to that status")
+	ErrCaseClosed        = errors.New("case is closed")
+	ErrNoteRequired      = errors.New("a note is required")
+	ErrInvalidAssignee   = errors.New("assignee must be a staff member")
+)
+
+// Service runs the monitoring rules and manages the resulting case queue
+type Service struct {
+	db    *gorm.DB
+	rules []Rule
+	now   func() time.Time
+}
+
+func NewService(db *gorm.DB, rules []Rule) *Service {
+	return &Service{db: db, rules: rules, now: time.Now}
+}
+
+// Observe evaluates a transaction against every rule and returns the cases
+// it opened or added to. Only settled real-money rows are monitored, and a
+// transaction already recorded on a case does not count twice, so repeated
+// events are harmless.
+func (s *Service) Observe(txID uuid.UUID) ([]models.AMLCase, error) {
+	var t models.Transaction
+	if err := s.db.First(&t, "id = ?", txID).Error; err != nil {
+		return nil, err
+	}
+	if !t.IsReal || t.Status != models.StatusSuccess {
+		return nil, nil
+	}
+	var cases []models.AMLCase
+	for _, r := range s.rules {
+		f, err := r.Evaluate(s.db, &t)
+		if err != nil {
+			return cases, err
+		}
+		if f == nil {
+			continue
+		}
+		c, err := s.raise(r.Name(), t.CustomerID, f)
+		if err != nil {
+			return cases, err
+		}
+		if c != nil {
+			cases = append(cases, *c)
+		}
+	}
+	return cases, nil
+}
+
+// raise opens a case for the finding, or folds it into the customer's open
+// case for the same rule. It returns nil when the finding adds nothing new.
+func (s *Service) raise(rule string, customerID uuid.UUID, f *Finding) (*models.AMLCase, error) {
+	var out *models.AMLCase
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var c models.AMLCase
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("customer_id = ? AND rule = ? AND status NOT IN ?", customerID, rule, closedStatuses).
+			First(&c).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c = models.AMLCase{
+				ID:         uuid.New(),
+				CustomerID: customerID,
+				Rule:       rule,
+				Severity:   f.Severity,
+				Status:     models.AMLCaseOpen,
+				Summary:    f.Summary,
+				Evidence:   evidence(f, nil),
+				Hits:       1,
+			}
+			out = &c
+			return tx.Create(&c).Error
+		}
+		if err != nil {
+			return err
+		}
+		known := caseTransactions(&c)
+		if !slices.ContainsFunc(f.Transactions, func(id uuid.UUID) bool { return !slices.Contains(known, id.String()) }) {
+			return nil
+		}
+		if f.Severity.Rank() > c.Severity.Rank() {
+			c.Severity = f.Severity
+		}
+		c.Summary = f.Summary
+		c.Evidence = evidence(f, known)
+		c.Hits++
+		out = &c
+		return tx.Model(&c).Select("Severity", "Summary", "Evidence", "Hits").Updates(&c).Error
+	})
+	return out, err
+}
+
+var closedStatuses = []models.AMLCaseStatus{models.AMLCaseDismissed, models.AMLCaseReported}
+
+// evidence records the finding's details together with every transaction
+// the case has collected
+func evidence(f *Finding, known []string) models.JSONMap {
+	ids := slices.Clone(known)
+	for _, id := range f.Transactions {
+		if !slices.Contains(ids, id.String()) {
+			ids = append(ids, id.String())
+		}
+	}
+	e := models.JSONMap{}
+	for k, v := range f.Details {
+		e[k] = v
+	}
+	e["transactions"] = ids
+	return e
+}
+
+// caseTransactions reads back the transaction IDs stored by evidence
+func caseTransactions(c *models.AMLCase) []string {
+	var ids []string
+	switch v := c.Evidence["transactions"].(type) {
+	case []string:
+		ids = v
+	case []any:
+		for _, id := range v {
+			if s, ok := id.(string); ok {
+				ids = append(ids, s)
+			}
+		}
+	}
+	return ids
+}
+
+// CaseFilter narrows the case queue; zero fields match everything
+type CaseFilter struct {
+	Status     models.AMLCaseStatus
+	AssigneeID *uuid.UUID
+	CustomerID *uuid.UUID
+}
+
+// Cases lists the queue, newest first
+func (s *Service) Cases(f CaseFilter, limit, offset int) ([]models.AMLCase, int64, error) {
+	q := s.db.Model(&models.AMLCase{})
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.AssigneeID != nil {
+		q = q.Where("assignee_id = ?", *f.AssigneeID)
+	}
+	if f.CustomerID != nil {
+		q = q.Where("customer_id = ?", *f.CustomerID)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var cases []models.AMLCase
+	err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&cases).Error
+	return cases, total, err
+}
+
+// Case returns a case and its notes, oldest note first
+func (s *Service) Case(id uuid.UUID) (*models.AMLCase, []models.AMLCaseNote, error) {
+	var c models.AMLCase
+	if err := s.db.First(&c, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrCaseNotFound
+		}
+		return nil, nil, err
+	}
+	var notes []models.AMLCaseNote
+	err := s.db.Where("case_id = ?", id).Order("created_at").Find(&notes).Error
+	return &c, notes, err
+}
+
+// Assign hands an open case to a staff member; a nil assignee returns it to
+// the queue
+func (s *Service) Assign(actor backoffice.Actor, id uuid.UUID, assignee *uuid.UUID) (*models.AMLCase, error) {
+	return s.update(id, func(tx *gorm.DB, c *models.AMLCase) error {
+		if assignee != nil {
+			var staff int64
+			if err := tx.Model(&models.Customer{}).Where("id = ? AND role IN ?", *assignee,
+				[]models.Role{models.RoleSupport, models.RoleFinance, models.RoleAdmin}).Count(&staff).Error; err != nil {
+				return err
+			}
+			if staff == 0 {
+				return ErrInvalidAssignee
+			}
+		}
+		c.AssigneeID = assignee
+		if err := tx.Model(c).Select("AssigneeID").Updates(c).Error; err != nil {
+			return err
+		}
+		details := models.JSONMap{"assignee_id": nil}
+		if assignee != nil {
+			details["assignee_id"] = assignee.String()
+		}
+		return backoffice.Audit(tx, actor, "aml.assign", "aml_case", id, details)
+	})
+}
+
+// SetStatus moves a case through review. Every move needs a note explaining
+// it, which is kept on the case.
+func (s *Service) SetStatus(actor backoffice.Actor, id uuid.UUID, status models.AMLCaseStatus, note string) (*models.AMLCase, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, ErrNoteRequired
+	}
+	return s.update(id, func(tx *gorm.DB, c *models.AMLCase) error {
+		if !c.Status.CanTransition(status) {
+			return ErrInvalidTransition
+		}
+		from := c.Status
+		c.Status = status
+		if status.Final() {
+			now := s.now()
+			c.ClosedAt = &now
+		}
+		if err := tx.Model(c).Select("Status", "ClosedAt").Updates(c).Error; err != nil {
+			return err
+		}
+		if _, err := addNote(tx, actor, id, note); err != nil {
+			return err
+		}
+		return backoffice.Audit(tx, actor, "aml.status", "aml_case", id, models.JSONMap{
+			"from": string(from),
+			"to":   string(status),
+		})
+	})
+}
+
+// AddNote appends a reviewer's note to an open case
+func (s *Service) AddNote(actor backoffice.Actor, id uuid.UUID, body string) (*models.AMLCaseNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrNoteRequired
+	}
+	var n *models.AMLCaseNote
+	_, err := s.update(id, func(tx *gorm.DB, c *models.AMLCase) (err error) {
+		n, err = addNote(tx, actor, id, body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func addNote(tx *gorm.DB, actor backoffice.Actor, caseID uuid.UUID, body string) (*models.AMLCaseNote, error) {
+	n := models.AMLCaseNote{ID: uuid.New(), CaseID: caseID, AuthorID: actor.ID, Body: body}
+	if err := tx.Create(&n).Error; err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// update locks an open case and applies fn to it
+func (s *Service) update(id uuid.UUID, fn func(tx *gorm.DB, c *models.AMLCase) error) (*models.AMLCase, error) {
+	var c models.AMLCase
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&c, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCaseNotFound
+			}
+			return err
+		}
+		if c.Status.Final() {
+			return ErrCaseClosed
+		}
+		return fn(tx, &c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
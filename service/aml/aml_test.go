@@ -0,0 +1,228 @@
+package aml
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db := testdb.Open(t, &models.Transaction{}, &models.AMLCase{}, &models.AMLCaseNote{}, &models.AuditLog{}, &models.Customer{})
+	s := NewService(db, DefaultRules())
+	s.now = func() time.Time { return testNow }
+	return s, db
+}
+
+type ledgerRow struct {
+	typ    models.TransactionType
+	amount int64
+	age    time.Duration
+	phone  string
+	sports uuid.UUID // bookie account; a forex account otherwise
+}
+
+// ledger writes successful real-money transactions and returns their IDs
+func ledger(t *testing.T, db *gorm.DB, customer uuid.UUID, rows ...ledgerRow) []uuid.UUID {
+	t.Helper()
+	var ids []uuid.UUID
+	for _, r := range rows {
+		tx := models.Transaction{
+			ID:             uuid.New(),
+			CustomerID:     customer,
+			Type:           r.typ,
+			AmountCents:    r.amount,
+			IsReal:         true,
+			Status:         models.StatusSuccess,
+			Reference:      uuid.NewString()[:8],
+			IdempotencyKey: uuid.NewString(),
+		}
+		if r.sports != uuid.Nil {
+			tx.SportsAccountID = r.sports
+		} else {
+			tx.ForexAccountID = uuid.New()
+		}
+		if r.phone != "" {
+			tx.Metadata = models.JSONMap{"phone": r.phone}
+		}
+		tx.CreatedAt = testNow.Add(-r.age)
+		if err := db.Omit(clause.Associations).Create(&tx).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tx.ID)
+	}
+	return ids
+}
+
+func observe(t *testing.T, s *Service, id uuid.UUID) []models.AMLCase {
+	t.Helper()
+	cases, err := s.Observe(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cases
+}
+
+func TestStructuring(t *testing.T) {
+	s, db := newTestService(t)
+	customer := uuid.New()
+	ids := ledger(t, db, customer,
+		ledgerRow{typ: models.TransactionTypeDeposit, amount: 14500000, age: 30 * time.Hour},
+		ledgerRow{typ: models.TransactionTypeDeposit, amount: 5000000, age: 20 * time.Hour}, // not near the cap
+		ledgerRow{typ: models.TransactionTypeDeposit, amount: 14900000, age: 10 * time.Hour},
+	)
+	if cases := observe(t, s, ids[2]); len(cases) != 0 {
+		t.Fatalf("two deposits under the cap raised %+v", cases)
+	}
+	ids = append(ids, ledger(t, db, customer, ledgerRow{typ: models.TransactionTypeDeposit, amount: 14990000})...)
+	cases := observe(t, s, ids[3])
+	if len(cases) != 1 || cases[0].Rule != "structuring" || cases[0].Status != models.AMLCaseOpen {
+		t.Fatalf("cases = %+v", cases)
+	}
+	if got := caseTransactions(&cases[0]); len(got) != 3 || slices.Contains(got, ids[1].String()) {
+		t.Fatalf("evidence transactions = %v", got)
+	}
+
+	// Redelivery adds nothing; a further hit joins the open case.
+	if again := observe(t, s, ids[3]); len(again) != 0 {
+		t.Fatalf("redelivered event raised %+v", again)
+	}
+	more := ledger(t, db, customer, ledgerRow{typ: models.TransactionTypeDeposit, amount: 14000000})
+	again := observe(t, s, more[0])
+	if len(again) != 1 || again[0].ID != cases[0].ID || again[0].Hits != 2 {
+		t.Fatalf("second hit = %+v", again)
+	}
+	var n int64
+	db.Model(&models.AMLCase{}).Count(&n)
+	if n != 1 {
+		t.Fatalf("cases = %d", n)
+	}
+}
+
+func TestRapidInOut(t *testing.T) {
+	s, db := newTestService(t)
+	quick, trader := uuid.New(), uuid.New()
+	ids := ledger(t, db, quick,
+		ledgerRow{typ: models.TransactionTypeDeposit, amount: 10000000, age: 5 * time.Hour},
+		ledgerRow{typ: models.TransactionTypeTrade, amount: 200000, age: 4 * time.Hour},
+		ledgerRow{typ: models.TransactionTypeWithdraw, amount: 9500000},
+	)
+	if cases := observe(t, s, ids[2]); len(cases) != 1 || cases[0].Rule != "rapid_in_out" || cases[0].Severity != models.AMLSeverityHigh {
+		t.Fatalf("pass-through withdrawal: %+v", cases)
+	}
+
+	ids = ledger(t, db, trader,
+		ledgerRow{typ: models.TransactionTypeDeposit, amount: 10000000, age: 5 * time.Hour},
+		ledgerRow{typ: models.TransactionTypeTrade, amount: 4000000, age: 4 * time.Hour},
+		ledgerRow{typ: models.TransactionTypeWithdraw, amount: 9500000},
+	)
+	if cases := observe(t, s, ids[2]); len(cases) != 0 {
+		t.Fatalf("traded funds raised %+v", cases)
+	}
+}
+
+func TestManyPhones(t *testing.T) {
+	s, db := newTestService(t)
+	customer := uuid.New()
+	var rows []ledgerRow
+	for _, phone := range []string{"+254700000001", "+254700000002", "+254700000003", "+254700000001"} {
+		rows = append(rows, ledgerRow{typ: models.TransactionTypeDeposit, amount: 100000, age: time.Hour, phone: phone})
+	}
+	ids := ledger(t, db, customer, rows...)
+	if cases := observe(t, s, ids[3]); len(cases) != 0 {
+		t.Fatalf("three phones raised %+v", cases)
+	}
+	ids = ledger(t, db, customer, ledgerRow{typ: models.TransactionTypeWithdraw, amount: 100000, phone: "+254700000004"})
+	cases := observe(t, s, ids[0])
+	if len(cases) != 1 || cases[0].Rule != "many_phones" {
+		t.Fatalf("cases = %+v", cases)
+	}
+	phones, _ := cases[0].Evidence["phones"].([]string)
+	if len(phones) != 4 || slices.Contains(phones, "+254700000004") {
+		t.Fatalf("phones must be masked: %v", cases[0].Evidence["phones"])
+	}
+}
+
+func TestBookieRoundTrip(t *testing.T) {
+	s, db := newTestService(t)
+	customer := uuid.New()
+	betika, sportpesa := uuid.New(), uuid.New()
+	ids := ledger(t, db, customer,
+		ledgerRow{typ: models.TransactionTypeDeposit, amount: 3000000, age: 20 * time.Hour, sports: betika},
+		ledgerRow{typ: models.TransactionTypeDeposit, amount: 3000000, age: 20 * time.Hour, sports: sportpesa},
+		ledgerRow{typ: models.TransactionTypeTrade, amount: 100000, age: 10 * time.Hour, sports: betika},
+		ledgerRow{typ: models.TransactionTypeWithdraw, amount: 2900000, age: time.Hour, sports: betika},
+	)
+	if cases := observe(t, s, ids[3]); len(cases) != 0 {
+		t.Fatalf("half withdrawn raised %+v", cases)
+	}
+	ids = ledger(t, db, customer, ledgerRow{typ: models.TransactionTypeWithdraw, amount: 2900000, sports: sportpesa})
+	cases := observe(t, s, ids[0])
+	if len(cases) != 1 || cases[0].Rule != "bookie_round_trip" {
+		t.Fatalf("cases = %+v", cases)
+	}
+}
+
+func TestCaseReview(t *testing.T) {
+	s, db := newTestService(t)
+	reviewer := backoffice.Actor{ID: uuid.New(), Role: models.RoleFinance}
+	analyst, customer := uuid.New(), uuid.New()
+	db.Exec("INSERT INTO customers (id, name, email, phone, role) VALUES (?, 'Analyst', 'analyst@example.com', '+254700000001', ?), (?, 'Customer', 'customer@example.com', '+254700000002', ?)",
+		analyst, models.RoleSupport, customer, models.RoleCustomer)
+
+	c := models.AMLCase{ID: uuid.New(), CustomerID: customer, Rule: "structuring", Severity: models.AMLSeverityMedium, Status: models.AMLCaseOpen, Hits: 1}
+	if err := db.Create(&c).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Assign(reviewer, c.ID, &customer); !errors.Is(err, ErrInvalidAssignee) {
+		t.Fatalf("assign to customer: %v", err)
+	}
+	if got, err := s.Assign(reviewer, c.ID, &analyst); err != nil || *got.AssigneeID != analyst {
+		t.Fatalf("assign: %+v, %v", got, err)
+	}
+	if list, total, err := s.Cases(CaseFilter{AssigneeID: &analyst}, 10, 0); err != nil || total != 1 || len(list) != 1 {
+		t.Fatalf("assigned queue: %d, %v", total, err)
+	}
+
+	if _, err := s.SetStatus(reviewer, c.ID, models.AMLCaseReported, "straight to report"); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("open -> reported: %v", err)
+	}
+	if _, err := s.SetStatus(reviewer, c.ID, models.AMLCaseInvestigating, " "); !errors.Is(err, ErrNoteRequired) {
+		t.Fatalf("blank note: %v", err)
+	}
+	if _, err := s.SetStatus(reviewer, c.ID, models.AMLCaseInvestigating, "pulling M-Pesa statements"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddNote(reviewer, c.ID, "deposits match salary dates"); err != nil {
+		t.Fatal(err)
+	}
+	closed, err := s.SetStatus(reviewer, c.ID, models.AMLCaseDismissed, "explained by payroll")
+	if err != nil || closed.ClosedAt == nil {
+		t.Fatalf("dismiss: %+v, %v", closed, err)
+	}
+	if _, err := s.AddNote(reviewer, c.ID, "late note"); !errors.Is(err, ErrCaseClosed) {
+		t.Fatalf("note on closed case: %v", err)
+	}
+
+	got, notes, err := s.Case(c.ID)
+	if err != nil || got.Status != models.AMLCaseDismissed || len(notes) != 3 {
+		t.Fatalf("case = %+v, %d notes, %v", got, len(notes), err)
+	}
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("target_type = ?", "aml_case").Count(&audits)
+	if audits != 3 {
+		t.Fatalf("audit rows = %d", audits)
+	}
+}
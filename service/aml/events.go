@@ -0,0 +1,69 @@
+package aml
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"gorm.io/gorm"
+
+	"weriKana/models"
+)
+
+// SubjectTransactions carries an Event whenever a transaction row is written
+const SubjectTransactions = "transactions.events"
+
+// Event names a transaction that was created or changed
+type Event struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+}
+
+// Publisher is the NATS subset used to emit events (*nats.Conn satisfies it)
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// PublishEvents registers gorm callbacks that emit an Event for every
+// transaction created or updated through a *models.Transaction, so the
+// monitor sees ledger activity from every code path. Publishing is best
+// effort: a failure never fails the write.
+func PublishEvents(db *gorm.DB, pub Publisher) error {
+	emit := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Table != "transactions" {
+			return
+		}
+		t, ok := tx.Statement.Model.(*models.Transaction)
+		if !ok || t.ID == uuid.Nil {
+			return
+		}
+		if data, err := json.Marshal(Event{TransactionID: t.ID}); err == nil {
+			_ = pub.Publish(SubjectTransactions, data)
+		}
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("aml:publish_create", emit); err != nil {
+		return err
+	}
+	return db.Callback().Update().After("gorm:update").Register("aml:publish_update", emit)
+}
+
+// Listen evaluates transactions as their events arrive. Events can overtake
+// the commit that produced them; those rows are skipped and picked up by the
+// transaction's next event.
+func (s *Service) Listen(nc *nats.Conn, logf func(string, ...interface{})) error {
+	_, err := nc.Subscribe(SubjectTransactions, func(m *nats.Msg) {
+		var e Event
+		if err := json.Unmarshal(m.Data, &e); err != nil || e.TransactionID == uuid.Nil {
+			logf("aml: bad event: %v", err)
+			return
+		}
+		cases, err := s.Observe(e.TransactionID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			logf("aml: transaction %s: %v", e.TransactionID, err)
+		}
+		for _, c := range cases {
+			logf("aml: case %s (%s, %s) for customer %s", c.ID, c.Rule, c.Severity, c.CustomerID)
+		}
+	})
+	return err
+}
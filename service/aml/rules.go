@@ -0,0 +1,239 @@
+package aml
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/models"
+)
+
+// Finding is a rule hit on one transaction
+type Finding struct {
+	Severity     models.AMLSeverity
+	Summary      string
+	Transactions []uuid.UUID
+	Details      models.JSONMap
+}
+
+// Rule inspects a settled real-money transaction against the customer's
+// recent history. It returns nil when nothing looks wrong.
+type Rule interface {
+	Name() string
+	Evaluate(db *gorm.DB, tx *models.Transaction) (*Finding, error)
+}
+
+// DefaultRules are the monitoring rules the platform runs with. Structuring
+// watches the KES 150,000 M-Pesa per-transaction cap that deposits are split
+// to fit under.
+func DefaultRules() []Rule {
+	return []Rule{
+		Structuring{ThresholdCents: 15000000, BandPercent: 10, MinCount: 3, Window: 72 * time.Hour},
+		RapidInOut{MinCents: 5000000, DepositPercent: 80, MaxTradePercent: 10, Window: 48 * time.Hour},
+		ManyPhones{MaxPhones: 3, Window: 30 * 24 * time.Hour},
+		BookieRoundTrip{MinCents: 1000000, MinBookies: 2, ReturnPercent: 80, MaxBetPercent: 20, Window: 72 * time.Hour},
+	}
+}
+
+// history loads the customer's successful real-money transactions of the
+// given types created in [since, until]
+func history(db *gorm.DB, customerID uuid.UUID, since, until time.Time, types ...models.TransactionType) ([]models.Transaction, error) {
+	var txs []models.Transaction
+	err := db.Where("customer_id = ? AND is_real = ? AND status = ? AND type IN ? AND created_at BETWEEN ? AND ?",
+		customerID, true, models.StatusSuccess, types, since, until).
+		Order("created_at").Find(&txs).Error
+	return txs, err
+}
+
+// Structuring flags repeated deposits sitting just under a threshold, the
+// classic way of splitting a large sum to stay below reporting and
+// per-transaction caps.
+type Structuring struct {
+	ThresholdCents int64
+	BandPercent    int64 // deposits within this percentage below the threshold count
+	MinCount       int
+	Window         time.Duration
+}
+
+func (Structuring) Name() string { return "structuring" }
+
+func (r Structuring) Evaluate(db *gorm.DB, tx *models.Transaction) (*Finding, error) {
+	floor := r.ThresholdCents * (100 - r.BandPercent) / 100
+	inBand := func(amount int64) bool { return amount >= floor && amount < r.ThresholdCents }
+	if tx.Type != models.TransactionTypeDeposit || !inBand(tx.AmountCents) {
+		return nil, nil
+	}
+	deposits, err := history(db, tx.CustomerID, tx.CreatedAt.Add(-r.Window), tx.CreatedAt, models.TransactionTypeDeposit)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
+	var total int64
+	for _, d := range deposits {
+		if inBand(d.AmountCents) {
+			ids = append(ids, d.ID)
+			total += d.AmountCents
+		}
+	}
+	if len(ids) < r.MinCount {
+		return nil, nil
+	}
+	severity := models.AMLSeverityMedium
+	if len(ids) >= 2*r.MinCount {
+		severity = models.AMLSeverityHigh
+	}
+	return &Finding{
+		Severity:     severity,
+		Summary:      fmt.Sprintf("%d deposits just under %d cents within %s", len(ids), r.ThresholdCents, r.Window),
+		Transactions: ids,
+		Details:      models.JSONMap{"count": len(ids), "total_cents": total, "threshold_cents": r.ThresholdCents},
+	}, nil
+}
+
+// RapidInOut flags a withdrawal mostly funded by recent deposits that were
+// barely traded: money passing through rather than being used.
+type RapidInOut struct {
+	MinCents        int64 // smaller withdrawals are ignored
+	DepositPercent  int64 // recent deposits must cover this share of the withdrawal
+	MaxTradePercent int64 // ...while trading less than this share of them
+	Window          time.Duration
+}
+
+func (RapidInOut) Name() string { return "rapid_in_out" }
+
+func (r RapidInOut) Evaluate(db *gorm.DB, tx *models.Transaction) (*Finding, error) {
+	if tx.Type != models.TransactionTypeWithdraw || tx.AmountCents < r.MinCents {
+		return nil, nil
+	}
+	recent, err := history(db, tx.CustomerID, tx.CreatedAt.Add(-r.Window), tx.CreatedAt,
+		models.TransactionTypeDeposit, models.TransactionTypeTrade)
+	if err != nil {
+		return nil, err
+	}
+	var deposited, traded int64
+	ids := []uuid.UUID{tx.ID}
+	for _, t := range recent {
+		if t.Type == models.TransactionTypeDeposit {
+			deposited += t.AmountCents
+			ids = append(ids, t.ID)
+		} else {
+			traded += t.AmountCents
+		}
+	}
+	if deposited*100 < tx.AmountCents*r.DepositPercent || traded*100 >= deposited*r.MaxTradePercent {
+		return nil, nil
+	}
+	return &Finding{
+		Severity:     models.AMLSeverityHigh,
+		Summary:      fmt.Sprintf("withdrawal of %d cents follows %d cents deposited within %s with %d cents traded", tx.AmountCents, deposited, r.Window, traded),
+		Transactions: ids,
+		Details:      models.JSONMap{"withdrawn_cents": tx.AmountCents, "deposited_cents": deposited, "traded_cents": traded},
+	}, nil
+}
+
+// ManyPhones flags customers moving money through more M-Pesa numbers than
+// one person plausibly holds
+type ManyPhones struct {
+	MaxPhones int
+	Window    time.Duration
+}
+
+func (ManyPhones) Name() string { return "many_phones" }
+
+func (r ManyPhones) Evaluate(db *gorm.DB, tx *models.Transaction) (*Finding, error) {
+	if phoneOf(tx) == "" {
+		return nil, nil
+	}
+	recent, err := history(db, tx.CustomerID, tx.CreatedAt.Add(-r.Window), tx.CreatedAt,
+		models.TransactionTypeDeposit, models.TransactionTypeWithdraw)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var masked []string
+	var ids []uuid.UUID
+	for _, t := range recent {
+		phone := phoneOf(&t)
+		if phone == "" {
+			continue
+		}
+		ids = append(ids, t.ID)
+		if !seen[phone] {
+			seen[phone] = true
+			masked = append(masked, models.MaskPhone(phone))
+		}
+	}
+	if len(seen) <= r.MaxPhones {
+		return nil, nil
+	}
+	return &Finding{
+		Severity:     models.AMLSeverityMedium,
+		Summary:      fmt.Sprintf("%d different phone numbers used within %s", len(seen), r.Window),
+		Transactions: ids,
+		Details:      models.JSONMap{"phones": masked},
+	}, nil
+}
+
+// phoneOf returns the M-Pesa number recorded on a deposit or withdrawal
+func phoneOf(tx *models.Transaction) string {
+	phone, _ := tx.Metadata["phone"].(string)
+	return phone
+}
+
+// BookieRoundTrip flags money spread across several bookie accounts and
+// withdrawn again with little betting in between, which launders it through
+// the bookies' payouts.
+type BookieRoundTrip struct {
+	MinCents      int64 // smaller withdrawals are ignored
+	MinBookies    int
+	ReturnPercent int64 // share of bookie deposits withdrawn again
+	MaxBetPercent int64 // share of bookie deposits actually bet
+	Window        time.Duration
+}
+
+func (BookieRoundTrip) Name() string { return "bookie_round_trip" }
+
+func (r BookieRoundTrip) Evaluate(db *gorm.DB, tx *models.Transaction) (*Finding, error) {
+	if tx.Type != models.TransactionTypeWithdraw || tx.SportsAccountID == uuid.Nil || tx.AmountCents < r.MinCents {
+		return nil, nil
+	}
+	var recent []models.Transaction
+	err := db.Where("customer_id = ? AND is_real = ? AND status = ? AND sports_account_id IS NOT NULL AND sports_account_id <> ? AND created_at BETWEEN ? AND ?",
+		tx.CustomerID, true, models.StatusSuccess, uuid.Nil, tx.CreatedAt.Add(-r.Window), tx.CreatedAt).
+		Find(&recent).Error
+	if err != nil {
+		return nil, err
+	}
+	var deposited, withdrawn, bet int64
+	bookies := map[uuid.UUID]bool{}
+	var ids []uuid.UUID
+	for _, t := range recent {
+		switch t.Type {
+		case models.TransactionTypeDeposit:
+			deposited += t.AmountCents
+		case models.TransactionTypeWithdraw:
+			withdrawn += t.AmountCents
+		case models.TransactionTypeTrade:
+			bet += t.AmountCents
+			continue
+		default:
+			continue
+		}
+		bookies[t.SportsAccountID] = true
+		ids = append(ids, t.ID)
+	}
+	if deposited == 0 || len(bookies) < r.MinBookies {
+		return nil, nil
+	}
+	if withdrawn*100 < deposited*r.ReturnPercent || bet*100 >= deposited*r.MaxBetPercent {
+		return nil, nil
+	}
+	return &Finding{
+		Severity:     models.AMLSeverityHigh,
+		Summary:      fmt.Sprintf("%d cents cycled through %d bookie accounts within %s with %d cents bet", withdrawn, len(bookies), r.Window, bet),
+		Transactions: ids,
+		Details:      models.JSONMap{"bookies": len(bookies), "deposited_cents": deposited, "withdrawn_cents": withdrawn, "bet_cents": bet},
+	}, nil
+}
@@ -0,0 +1,192 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/middleware"
+	"weriKana/models"
+)
+
+const (
+	PrefixTag     = "wk_"
+	MaxActiveKeys = 10
+	touchEvery    = time.Minute // last_used_at is refreshed at most this often
+)
+
+var (
+	ErrInvalidKey       = errors.New("invalid or expired API key")
+	ErrIPNotAllowed     = errors.New("API key not allowed from this address")
+	ErrKeyNotFound      = errors.New("API key not found")
+	ErrTooManyKeys      = errors.New("too many active API keys")
+	ErrInvalidScopes    = errors.New("invalid scopes")
+	ErrInvalidAllowlist = errors.New("allowed_ips must be IP addresses or CIDR ranges")
+	ErrInvalidExpiry    = errors.New("expires_at must be in the future")
+)
+
+// CreateParams describes a new key. Scopes are the same token scopes a login grants.
+type CreateParams struct {
+	Name       string
+	Scopes     []string
+	AllowedIPs []string
+	ExpiresAt  *time.Time
+}
+
+// Service issues and verifies hashed API keys
+type Service struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, now: time.Now}
+}
+
+// Create stores a new key and returns it with the raw "<prefix>.<secret>" value, shown once
+func (s *Service) Create(customerID uuid.UUID, p CreateParams) (*models.APIKey, string, error) {
+	if len(p.Scopes) == 0 || !middleware.ValidScopes(p.Scopes) {
+		return nil, "", ErrInvalidScopes
+	}
+	allowed, err := normalizeAllowlist(p.AllowedIPs)
+	if err != nil {
+		return nil, "", err
+	}
+	if p.ExpiresAt != nil && !p.ExpiresAt.After(s.now()) {
+		return nil, "", ErrInvalidExpiry
+	}
+
+	idBytes := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	key := &models.APIKey{
+		ID:         uuid.New(),
+		CustomerID: customerID,
+		Name:       p.Name,
+		Prefix:     PrefixTag + hex.EncodeToString(idBytes),
+		SecretHash: hashSecret(encoded),
+		Scopes:     middleware.JoinScopes(p.Scopes),
+		AllowedIPs: strings.Join(allowed, " "),
+		ExpiresAt:  p.ExpiresAt,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var active int64
+		if err := tx.Model(&models.APIKey{}).
+			Where("customer_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", customerID, s.now()).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active >= MaxActiveKeys {
+			return ErrTooManyKeys
+		}
+		return tx.Create(key).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, key.Prefix + "." + encoded, nil
+}
+
+// List returns the customer's unrevoked keys, newest first
+func (s *Service) List(customerID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.db.Where("customer_id = ? AND revoked_at IS NULL", customerID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// Revoke disables a key immediately
+func (s *Service) Revoke(customerID, keyID uuid.UUID) error {
+	res := s.db.Model(&models.APIKey{}).
+		Where("id = ? AND customer_id = ? AND revoked_at IS NULL", keyID, customerID).
+		Update("revoked_at", s.now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// Verify resolves a raw key presented from ip. It implements middleware.APIKeyVerifier.
+func (s *Service) Verify(raw, ip string) (*models.APIKey, error) {
+	prefix, secret, ok := strings.Cut(raw, ".")
+	if !ok || !strings.HasPrefix(prefix, PrefixTag) || secret == "" {
+		return nil, ErrInvalidKey
+	}
+	var key models.APIKey
+	if err := s.db.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+	if err := s.check(&key, secret, ip); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= touchEvery || key.LastUsedIP != ip {
+		s.db.Model(&key).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+	return &key, nil
+}
+
+// check validates secret, lifetime and source address, in that order
+func (s *Service) check(key *models.APIKey, secret, ip string) error {
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.SecretHash)) != 1 {
+		return ErrInvalidKey
+	}
+	if !key.Active(s.now()) {
+		return ErrInvalidKey
+	}
+	if !key.AllowsIP(ip) {
+		return ErrIPNotAllowed
+	}
+	return nil
+}
+
+// hashSecret is a plain SHA-256: the secret is 256 random bits, so a slow KDF adds nothing
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeAllowlist validates entries and canonicalizes them
+func normalizeAllowlist(entries []string) ([]string, error) {
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(e); err == nil {
+			out = append(out, prefix.Masked().String())
+			continue
+		}
+		addr, err := netip.ParseAddr(e)
+		if err != nil {
+			return nil, ErrInvalidAllowlist
+		}
+		out = append(out, addr.Unmap().String())
+	}
+	return out, nil
+}
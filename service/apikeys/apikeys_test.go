@@ -0,0 +1,102 @@
+package apikeys
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"weriKana/internal/testdb"
+	"weriKana/middleware"
+	"weriKana/models"
+)
+
+var testNow = time.Unix(1_700_000_000, 0)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db := testdb.Open(t, &models.APIKey{})
+	return &Service{db: db, now: func() time.Time { return testNow }}
+}
+
+func TestCreateAndVerify(t *testing.T) {
+	s := newTestService(t)
+	customer := uuid.New()
+	key, raw, err := s.Create(customer, CreateParams{
+		Name:       "bot",
+		Scopes:     []string{middleware.ScopeAccountsRead, middleware.ScopeAccountsTrade},
+		AllowedIPs: []string{"10.0.0.0/8", "192.168.1.7"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.SecretHash == "" || key.AllowedIPs != "10.0.0.0/8 192.168.1.7" {
+		t.Fatalf("key = %+v", key)
+	}
+
+	got, err := s.Verify(raw, "10.1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CustomerID != customer || got.Scopes != "accounts:read accounts:trade" {
+		t.Fatalf("verified key = %+v", got)
+	}
+
+	cases := map[string]struct {
+		raw, ip string
+		want    error
+	}{
+		"wrong secret":      {key.Prefix + ".nope", "10.1.2.3", ErrInvalidKey},
+		"malformed":         {"garbage", "10.1.2.3", ErrInvalidKey},
+		"unknown prefix":    {"wk_000000000000.x", "10.1.2.3", ErrInvalidKey},
+		"outside allowlist": {raw, "172.16.0.1", ErrIPNotAllowed},
+	}
+	for name, tc := range cases {
+		if _, err := s.Verify(tc.raw, tc.ip); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+
+	if err := s.Revoke(customer, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(raw, "10.1.2.3"); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("revoked key verified: %v", err)
+	}
+	if err := s.Revoke(uuid.New(), key.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("revoke by other customer: %v", err)
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	s := newTestService(t)
+	past := testNow.Add(-time.Hour)
+	cases := map[string]struct {
+		p    CreateParams
+		want error
+	}{
+		"no scopes":     {CreateParams{}, ErrInvalidScopes},
+		"unknown scope": {CreateParams{Scopes: []string{"admin"}}, ErrInvalidScopes},
+		"bad ip":        {CreateParams{Scopes: []string{middleware.ScopeAccountsRead}, AllowedIPs: []string{"10.0.0.1/99"}}, ErrInvalidAllowlist},
+		"expired":       {CreateParams{Scopes: []string{middleware.ScopeAccountsRead}, ExpiresAt: &past}, ErrInvalidExpiry},
+	}
+	for name, tc := range cases {
+		if _, _, err := s.Create(uuid.New(), tc.p); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestExpiredKey(t *testing.T) {
+	s := newTestService(t)
+	soon := testNow.Add(time.Hour)
+	_, raw, err := s.Create(uuid.New(), CreateParams{Scopes: []string{middleware.ScopeAccountsRead}, ExpiresAt: &soon})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return soon.Add(time.Second) }
+	if _, err := s.Verify(raw, "1.2.3.4"); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expired key verified: %v", err)
+	}
+}
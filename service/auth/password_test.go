@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters keep the tests fast; production uses DefaultArgon2Params
+var testParams = Argon2Params{Memory: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	hash, err := HashPassword("correct horse battery", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected encoding %q", hash)
+	}
+	if ok, err := CheckPassword("correct horse battery", hash); err != nil || !ok {
+		t.Fatalf("CheckPassword(correct) = %v, %v", ok, err)
+	}
+	if ok, err := CheckPassword("correct horse batterY", hash); err != nil || ok {
+		t.Fatalf("CheckPassword(wrong) = %v, %v", ok, err)
+	}
+
+	again, _ := HashPassword("correct horse battery", testParams)
+	if again == hash {
+		t.Fatal("salt reused across hashes")
+	}
+}
+
+func TestCheckPasswordBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("imported-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := CheckPassword("imported-password", string(legacy)); err != nil || !ok {
+		t.Fatalf("bcrypt correct = %v, %v", ok, err)
+	}
+	if ok, err := CheckPassword("nope-nope-nope", string(legacy)); err != nil || ok {
+		t.Fatalf("bcrypt wrong = %v, %v", ok, err)
+	}
+	if !NeedsRehash(string(legacy), testParams) {
+		t.Fatal("bcrypt hash should be upgraded to argon2id")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, _ := HashPassword("correct horse battery", testParams)
+	if NeedsRehash(hash, testParams) {
+		t.Fatal("fresh hash flagged for rehash")
+	}
+	stronger := testParams
+	stronger.Memory *= 2
+	if !NeedsRehash(hash, stronger) {
+		t.Fatal("weaker params not flagged")
+	}
+}
+
+func TestCheckPasswordRejectsUnknownFormats(t *testing.T) {
+	for _, h := range []string{"", "secret", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$aGFzaA", "$argon2id$v=19$m=x$a$b"} {
+		if _, err := CheckPassword("whatever-password", h); !errors.Is(err, ErrUnsupportedHash) {
+			t.Errorf("%q: want ErrUnsupportedHash, got %v", h, err)
+		}
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	if ValidatePassword("short") == nil {
+		t.Fatal("short password accepted")
+	}
+	if ValidatePassword(strings.Repeat("a", MaxPasswordLength+1)) == nil {
+		t.Fatal("overlong password accepted")
+	}
+	if err := ValidatePassword("long enough pass"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+	"weriKana/service/otp"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountLocked      = errors.New("account locked after repeated failures, try again later")
+	ErrAlreadyRegistered  = errors.New("phone or email already registered")
+)
+
+type Config struct {
+	Argon2        Argon2Params
+	MaxFailures   int           // failed logins before lockout
+	LockoutPeriod time.Duration
+}
+
+// DefaultConfig returns the production limits
+func DefaultConfig() Config {
+	return Config{
+		Argon2:        DefaultArgon2Params,
+		MaxFailures:   5,
+		LockoutPeriod: 15 * time.Minute,
+	}
+}
+
+// Service owns customer credentials: registration, login, password change and reset
+type Service struct {
+	db  *gorm.DB
+	otp *otp.Service
+	cfg Config
+	now func() time.Time
+}
+
+func NewService(db *gorm.DB, otpSvc *otp.Service, cfg Config) *Service {
+	return &Service{db: db, otp: otpSvc, cfg: cfg, now: time.Now}
+}
+
+// Register creates a customer with a hashed password
+func (s *Service) Register(name, email, phone, password string) (*models.Customer, error) {
+	if err := ValidatePassword(password); err != nil {
+		return nil, err
+	}
+	email, err := models.NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	phone, err = models.NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := HashPassword(password, s.cfg.Argon2)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Customer{}).Where("email = ? OR phone = ?", email, phone).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyRegistered
+	}
+
+	now := s.now()
+	customer := &models.Customer{
+		ID:                uuid.New(),
+		Name:              strings.TrimSpace(name),
+		Email:             email,
+		Phone:             phone,
+		PasswordHash:      hash,
+		PasswordChangedAt: &now,
+	}
+	if err := s.db.Create(customer).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrAlreadyRegistered
+		}
+		return nil, err
+	}
+	return customer, nil
+}
+
+// Authenticate checks identifier (phone or email) and password.
+// Unknown identifiers and wrong passwords return the same error.
+func (s *Service) Authenticate(identifier, password string) (*models.Customer, error) {
+	var customer *models.Customer
+	var result error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		c, err := findByIdentifier(tx, identifier, true)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Burn comparable time so response latency does not reveal registration.
+			_, _ = HashPassword(password, s.cfg.Argon2)
+			result = ErrInvalidCredentials
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := s.now()
+		if c.LockedUntil != nil && now.Before(*c.LockedUntil) {
+			result = ErrAccountLocked
+			return nil
+		}
+		ok := false
+		if c.PasswordHash != "" {
+			if ok, err = CheckPassword(password, c.PasswordHash); err != nil {
+				return err
+			}
+		}
+		if !ok {
+			c.FailedLogins++
+			result = ErrInvalidCredentials
+			if c.FailedLogins >= s.cfg.MaxFailures {
+				lockedUntil := now.Add(s.cfg.LockoutPeriod)
+				c.LockedUntil = &lockedUntil
+				c.FailedLogins = 0
+				result = ErrAccountLocked
+			}
+			return tx.Model(c).Select("failed_logins", "locked_until").Updates(c).Error
+		}
+
+		updates := map[string]interface{}{"failed_logins": 0, "locked_until": nil}
+		if NeedsRehash(c.PasswordHash, s.cfg.Argon2) {
+			if hash, err := HashPassword(password, s.cfg.Argon2); err == nil {
+				updates["password_hash"] = hash
+			}
+		}
+		customer = c
+		return tx.Model(c).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return customer, result
+}
+
+// ChangePassword replaces the password after re-checking the current one
+func (s *Service) ChangePassword(customerID uuid.UUID, current, next string) error {
+	if err := ValidatePassword(next); err != nil {
+		return err
+	}
+	var c models.Customer
+	if err := s.db.First(&c, "id = ?", customerID).Error; err != nil {
+		return err
+	}
+	if _, err := s.Authenticate(c.Phone, current); err != nil {
+		return err
+	}
+	return s.setPassword(customerID, next)
+}
+
+// RequestPasswordReset texts a reset OTP if identifier is registered.
+// Unknown identifiers succeed silently so the endpoint cannot enumerate customers.
+func (s *Service) RequestPasswordReset(identifier string) error {
+	c, err := findByIdentifier(s.db, identifier, false)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.otp.Send(c.ID, c.Phone, otp.PurposePasswordReset)
+}
+
+// ResetPassword sets a new password once the reset OTP verifies; it also clears any lockout.
+// It returns the customer's ID so callers can end existing sessions.
+func (s *Service) ResetPassword(identifier, code, next string) (uuid.UUID, error) {
+	if err := ValidatePassword(next); err != nil {
+		return uuid.Nil, err
+	}
+	c, err := findByIdentifier(s.db, identifier, false)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, otp.ErrInvalidCode
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := s.otp.Verify(c.ID, otp.PurposePasswordReset, code); err != nil {
+		return uuid.Nil, err
+	}
+	return c.ID, s.setPassword(c.ID, next)
+}
+
+func (s *Service) setPassword(customerID uuid.UUID, password string) error {
+	hash, err := HashPassword(password, s.cfg.Argon2)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&models.Customer{}).Where("id = ?", customerID).Updates(map[string]interface{}{
+		"password_hash":       hash,
+		"password_changed_at": s.now(),
+		"failed_logins":       0,
+		"locked_until":        nil,
+	}).Error
+}
+
+// findByIdentifier finds a customer by email (contains "@") or phone; lock requires a transaction
+func findByIdentifier(tx *gorm.DB, identifier string, lock bool) (*models.Customer, error) {
+	var column, value string
+	var err error
+	if strings.Contains(identifier, "@") {
+		column = "email"
+		value, err = models.NormalizeEmail(identifier)
+	} else {
+		column = "phone"
+		value, err = models.NormalizePhone(identifier)
+	}
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var c models.Customer
+	q := tx
+	if lock {
+		q = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	if err := q.Where(column+" = ?", value).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
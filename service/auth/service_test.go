@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/otp"
+)
+
+const testPassword = "correct horse battery"
+
+// lastSMS keeps the last message handed to the SMS gateway
+type lastSMS struct {
+	msg string
+}
+
+func (l *lastSMS) Publish(subject string, data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	l.msg = m["msg"]
+	return nil
+}
+
+var codeRe = regexp.MustCompile(`\d{6}`)
+
+func newTestService(t *testing.T) (*Service, *lastSMS, *time.Time) {
+	t.Helper()
+	sms := &lastSMS{}
+	cfg := DefaultConfig()
+	cfg.Argon2 = testParams
+	s := NewService(testdb.Open(t, &models.Customer{}), otp.New(otp.NewMemoryStore(), sms, otp.DefaultConfig([]byte("test-hash-key"))), cfg)
+	now := time.Unix(1_700_000_000, 0)
+	s.now = func() time.Time { return now }
+	return s, sms, &now
+}
+
+func register(t *testing.T, s *Service) *models.Customer {
+	t.Helper()
+	c, err := s.Register("Wanjiru Kamau", "Wanjiru@Example.com", "0712345678", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	s, _, _ := newTestService(t)
+	c := register(t, s)
+	if c.Email != "wanjiru@example.com" || c.Phone != "+254712345678" || c.PasswordHash == "" {
+		t.Fatalf("customer = %+v", c)
+	}
+
+	// Either identifier in any accepted format is taken.
+	for _, dup := range [][2]string{
+		{"WANJIRU@example.com", "0722000111"},
+		{"other@example.com", "+254712345678"},
+	} {
+		if _, err := s.Register("Someone Else", dup[0], dup[1], testPassword); !errors.Is(err, ErrAlreadyRegistered) {
+			t.Errorf("register %v: %v", dup, err)
+		}
+	}
+	if _, err := s.Register("Someone Else", "other@example.com", "0722000111", "short"); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("weak password: %v", err)
+	}
+	if _, err := s.Register("Someone Else", "other@example.com", "0722000111", testPassword); err != nil {
+		t.Fatalf("distinct customer: %v", err)
+	}
+}
+
+func TestAuthenticateLockout(t *testing.T) {
+	s, _, now := newTestService(t)
+	c := register(t, s)
+
+	if _, err := s.Authenticate("nobody@example.com", testPassword); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("unknown identifier: %v", err)
+	}
+	for i := 1; i < s.cfg.MaxFailures; i++ {
+		if _, err := s.Authenticate("0712345678", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+	if _, err := s.Authenticate("wanjiru@example.com", "wrong password"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("final attempt: %v", err)
+	}
+	if _, err := s.Authenticate("0712345678", testPassword); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("right password while locked: %v", err)
+	}
+
+	*now = now.Add(s.cfg.LockoutPeriod)
+	got, err := s.Authenticate("+254712345678", testPassword)
+	if err != nil || got.ID != c.ID {
+		t.Fatalf("after the lockout = %v, %v", got, err)
+	}
+	// Success clears the count, so a fresh run of failures is needed to lock again.
+	for i := 1; i < s.cfg.MaxFailures; i++ {
+		if _, err := s.Authenticate("0712345678", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d after success: %v", i, err)
+		}
+	}
+}
+
+func TestChangePasswordChecksCurrent(t *testing.T) {
+	s, _, _ := newTestService(t)
+	c := register(t, s)
+	const next = "purple monkey dishwasher"
+
+	if err := s.ChangePassword(c.ID, "wrong password", next); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong current password: %v", err)
+	}
+	if err := s.ChangePassword(c.ID, testPassword, "short"); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("weak new password: %v", err)
+	}
+	if _, err := s.Authenticate("0712345678", testPassword); err != nil {
+		t.Fatalf("password changed by a failed attempt: %v", err)
+	}
+	if err := s.ChangePassword(c.ID, testPassword, next); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate("0712345678", testPassword); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("old password: %v", err)
+	}
+	if _, err := s.Authenticate("0712345678", next); err != nil {
+		t.Fatalf("new password: %v", err)
+	}
+}
+
+func TestResetPasswordConsumesOTP(t *testing.T) {
+	s, sms, _ := newTestService(t)
+	c := register(t, s)
+	const next = "purple monkey dishwasher"
+
+	if err := s.RequestPasswordReset("nobody@example.com"); err != nil || sms.msg != "" {
+		t.Fatalf("unknown identifier = %v, sent %q", err, sms.msg)
+	}
+	if err := s.RequestPasswordReset("wanjiru@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	code := codeRe.FindString(sms.msg)
+	if code == "" {
+		t.Fatalf("no code in %q", sms.msg)
+	}
+
+	if _, err := s.ResetPassword("nobody@example.com", code, next); !errors.Is(err, otp.ErrInvalidCode) {
+		t.Fatalf("unknown identifier: %v", err)
+	}
+	id, err := s.ResetPassword("0712345678", code, next)
+	if err != nil || id != c.ID {
+		t.Fatalf("reset = %v, %v", id, err)
+	}
+	if _, err := s.Authenticate("0712345678", next); err != nil {
+		t.Fatalf("new password: %v", err)
+	}
+	if _, err := s.ResetPassword("0712345678", code, "yet another password"); !errors.Is(err, otp.ErrInvalidCode) {
+		t.Fatalf("code reused: %v", err)
+	}
+	if _, err := s.Authenticate("0712345678", next); err != nil {
+		t.Fatalf("password after a rejected reset: %v", err)
+	}
+}
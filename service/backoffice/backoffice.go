@@ -0,0 +1,186 @@
+package backoffice
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+)
+
+var (
+	ErrNotReversible  = errors.New("only successful deposits, withdrawals and trades can be reversed")
+	ErrReasonRequired = errors.New("a reason is required")
+	ErrInvalidRole    = errors.New("invalid role")
+	ErrSelfRoleChange = errors.New("operators cannot change their own role")
+)
+
+// Actor is the staff member performing an action
+type Actor struct {
+	ID   uuid.UUID
+	Role models.Role
+}
+
+// Service holds back-office operations; every mutation writes an AuditLog row
+// in the same transaction
+type Service struct {
+	db *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Customer returns a customer with credentials stripped by their json tags
+func (s *Service) Customer(id uuid.UUID) (*models.Customer, error) {
+	var c models.Customer
+	if err := s.db.First(&c, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Transactions pages through a customer's transactions, newest first
+func (s *Service) Transactions(customerID uuid.UUID, limit, offset int) ([]models.Transaction, int64, error) {
+	var total int64
+	q := s.db.Model(&models.Transaction{}).Where("customer_id = ?", customerID)
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var txs []models.Transaction
+	err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&txs).Error
+	return txs, total, err
+}
+
+// Reverse books a compensating reversal for a successful transaction, restores
+// the account balance and marks the original as reversed.
+func (s *Service) Reverse(actor Actor, txID uuid.UUID, reason string) (*models.Transaction, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	var reversal *models.Transaction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var orig models.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&orig, "id = ?", txID).Error; err != nil {
+			return err
+		}
+		if orig.Status != models.StatusSuccess || orig.Type == models.TransactionTypeReversal {
+			return ErrNotReversible
+		}
+
+		// Deposits put their net amount in, so reversing one takes it out; the
+		// rest took the gross amount out, which goes back. Either way the fee
+		// is refunded. A conversion also takes back the currency it bought.
+		delta := orig.AmountCents
+		if orig.Type == models.TransactionTypeDeposit {
+			delta = -orig.Net()
+		}
+		currency := orig.Currency
+		if currency == "" {
+			currency = models.DefaultCurrency
+		}
+		accountType, accountID := orig.Account()
+		if err := models.AdjustCurrencyBalance(tx, accountType, accountID, currency, orig.IsReal, delta); err != nil {
+			return err
+		}
+		if orig.Type == models.TransactionTypeConvert {
+			if err := models.AdjustCurrencyBalance(tx, accountType, accountID, orig.CounterCurrency, orig.IsReal, -orig.CounterCents); err != nil {
+				return err
+			}
+		}
+
+		reversal = &models.Transaction{
+			ID:              uuid.New(),
+			SharpAccountID:  orig.SharpAccountID,
+			SportsAccountID: orig.SportsAccountID,
+			StockAccountID:  orig.StockAccountID,
+			ForexAccountID:  orig.ForexAccountID,
+			CryptoAccountID: orig.CryptoAccountID,
+			CustomerID:      orig.CustomerID,
+			Type:            models.TransactionTypeReversal,
+			AmountCents:     orig.AmountCents,
+			IsReal:          orig.IsReal,
+			Currency:        orig.Currency,
+			CounterCurrency: orig.CounterCurrency,
+			CounterCents:    orig.CounterCents,
+			Status:          models.StatusSuccess,
+			Reference:       fmt.Sprintf("REV-%s", uuid.New().String()[:8]),
+			IdempotencyKey:  "reversal:" + orig.ID.String(),
+			Metadata: models.JSONMap{
+				"reverses":    orig.ID.String(),
+				"reason":      reason,
+				"operator_id": actor.ID.String(),
+			},
+		}
+		if err := tx.Create(reversal).Error; err != nil {
+			return err
+		}
+		if err := models.RecordFee(tx, reversal, nil, -orig.FeeCents); err != nil {
+			return err
+		}
+		if orig.IsReal && orig.Type == models.TransactionTypeConvert {
+			if err := models.RecordRevenue(tx, reversal, nil, -orig.FXSpreadCents, orig.CounterCurrency); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&orig).Update("status", models.StatusReversed).Error; err != nil {
+			return err
+		}
+		return Audit(tx, actor, "transaction.reverse", "transaction", orig.ID, models.JSONMap{
+			"reason":      reason,
+			"reversal_id": reversal.ID.String(),
+			"amount":      orig.AmountCents,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+// SetRole changes a customer's role; callers must revoke the target's sessions
+// so old tokens stop carrying the previous role.
+func (s *Service) SetRole(actor Actor, customerID uuid.UUID, role models.Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	if customerID == actor.ID {
+		return ErrSelfRoleChange
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var c models.Customer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&c, "id = ?", customerID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&c).Update("role", role).Error; err != nil {
+			return err
+		}
+		return Audit(tx, actor, "customer.set_role", "customer", customerID, models.JSONMap{
+			"from": string(c.Role),
+			"to":   string(role),
+		})
+	})
+}
+
+// AuditLog pages through back-office actions, newest first
+func (s *Service) AuditLog(limit, offset int) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	err := s.db.Order("created_at DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	return entries, err
+}
+
+// Audit appends an audit_logs row inside tx; other staff workflows use it too
+func Audit(tx *gorm.DB, actor Actor, action, targetType string, targetID uuid.UUID, details models.JSONMap) error {
+	return tx.Create(&models.AuditLog{
+		ID:         uuid.New(),
+		ActorID:    actor.ID,
+		ActorRole:  actor.Role,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+	}).Error
+}
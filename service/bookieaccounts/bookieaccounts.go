@@ -0,0 +1,361 @@
+package bookieaccounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+)
+
+var (
+	ErrAccountNotFound   = errors.New("bookie account not found")
+	ErrAlreadyLinked     = errors.New("an account with this bookie is already linked; relink it instead")
+	ErrBookieUnavailable = errors.New("bookie is not available for linking")
+	ErrNoNexus           = errors.New("complete onboarding before linking bookie accounts")
+	ErrInvalidLink       = errors.New("invalid bookie account details")
+	ErrTestDepositFailed = errors.New("verification deposit could not be started; relink to retry")
+	ErrUnknownReference  = errors.New("unknown or already settled reference")
+)
+
+// maxCredentialsLen bounds what a customer can store for one bookie login
+const maxCredentialsLen = 1024
+
+// Collector requests money from the account's phone and returns the provider
+// reference that ConfirmTestDeposit is later called with
+type Collector interface {
+	Collect(ctx context.Context, phone string, amountCents int64, idempotencyKey string) (string, error)
+}
+
+// Config sets the size of the verification deposit
+type Config struct {
+	TestDepositCents int64
+}
+
+func DefaultConfig() Config {
+	return Config{TestDepositCents: 1000} // KES 10
+}
+
+// LinkRequest links a bookie account. The M-Pesa number defaults to the
+// customer's preferred number; credentials are the customer's bookie login,
+// stored encrypted and never returned.
+type LinkRequest struct {
+	BookieID    uuid.UUID `json:"bookie_id"`
+	MpesaNumber string    `json:"mpesa_number"`
+	Credentials string    `json:"credentials"`
+}
+
+// RelinkRequest replaces the number or credentials of a link; empty fields
+// keep the current value
+type RelinkRequest struct {
+	MpesaNumber string `json:"mpesa_number"`
+	Credentials string `json:"credentials"`
+}
+
+// View is a linked account as shown to its owner
+type View struct {
+	ID               uuid.UUID               `json:"id"`
+	BookieID         uuid.UUID               `json:"bookie_id"`
+	BookieName       string                  `json:"bookie_name"`
+	MpesaNumber      string                  `json:"mpesa_number"`
+	Status           models.BookieLinkStatus `json:"status"`
+	IsActive         bool                    `json:"is_active"`
+	RealBalanceCents int64                   `json:"real_balance_cents"`
+	Currency         string                  `json:"currency"`
+	VerifiedAt       *time.Time              `json:"verified_at,omitempty"`
+	CreatedAt        time.Time               `json:"created_at"`
+}
+
+func NewView(a *models.SportsAccount) View {
+	return View{
+		ID:               a.ID,
+		BookieID:         a.BookieID,
+		BookieName:       a.Bookie.Name,
+		MpesaNumber:      a.MpesaNumber,
+		Status:           a.LinkStatus,
+		IsActive:         a.IsActive,
+		RealBalanceCents: a.RealBalanceCents,
+		Currency:         a.Currency,
+		VerifiedAt:       a.VerifiedAt,
+		CreatedAt:        a.CreatedAt,
+	}
+}
+
+// Service links customers' own bookie accounts. A link stays inactive, and
+// out of smart deposits and withdrawals, until a small test deposit from its
+// M-Pesa number succeeds.
+type Service struct {
+	db        *gorm.DB
+	collector Collector
+	cfg       Config
+	now       func() time.Time
+}
+
+func NewService(db *gorm.DB, collector Collector, cfg Config) *Service {
+	return &Service{db: db, collector: collector, cfg: cfg, now: time.Now}
+}
+
+// Link creates a pending link and starts its test deposit. If the deposit
+// cannot be started the link is returned with ErrTestDepositFailed.
+func (s *Service) Link(ctx context.Context, customerID uuid.UUID, req LinkRequest) (*models.SportsAccount, error) {
+	secret, err := sealCredentials(req.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("%w: credentials are required", ErrInvalidLink)
+	}
+	a := &models.SportsAccount{
+		ID:           uuid.New(),
+		CustomerID:   customerID,
+		BookieID:     req.BookieID,
+		EncryptedKey: secret,
+		LinkStatus:   models.BookieLinkPending,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var b models.Bookie
+		err := tx.Take(&b, "id = ? AND is_active = ?", req.BookieID, true).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !b.Supports("sports")) {
+			return ErrBookieUnavailable
+		}
+		if err != nil {
+			return err
+		}
+		var n int64
+		err = tx.Model(&models.SportsAccount{}).Where("customer_id = ? AND bookie_id = ?", customerID, req.BookieID).Count(&n).Error
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrAlreadyLinked
+		}
+		var nexus models.AssetNexus
+		err = tx.Select("sports_manager_id").Take(&nexus, "customer_id = ?", customerID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNoNexus
+		}
+		if err != nil {
+			return err
+		}
+		a.ManagerID = nexus.SportsManagerID
+		if a.MpesaNumber, err = s.phoneFor(tx, customerID, req.MpesaNumber); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Create(a).Error; err != nil {
+			return err
+		}
+		// Create swaps a false IsActive for the column default, so write it after.
+		return tx.Model(a).Update("is_active", false).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrAlreadyLinked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.startTestDeposit(ctx, a)
+}
+
+// List returns the customer's sports accounts, linked or onboarded
+func (s *Service) List(customerID uuid.UUID) ([]models.SportsAccount, error) {
+	var out []models.SportsAccount
+	err := s.db.Preload("Bookie").Where("customer_id = ?", customerID).Order("created_at").Find(&out).Error
+	return out, err
+}
+
+// Get returns one of the customer's sports accounts
+func (s *Service) Get(customerID, id uuid.UUID) (*models.SportsAccount, error) {
+	var a models.SportsAccount
+	err := s.db.Preload("Bookie").Take(&a, "id = ? AND customer_id = ?", id, customerID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Deactivate takes an account out of deposits and withdrawals. Its balance
+// stays; relinking verifies and reactivates it.
+func (s *Service) Deactivate(customerID, id uuid.UUID) (*models.SportsAccount, error) {
+	res := s.db.Model(&models.SportsAccount{}).Where("id = ? AND customer_id = ?", id, customerID).Update("is_active", false)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrAccountNotFound
+	}
+	return s.Get(customerID, id)
+}
+
+// Relink replaces a link's number or credentials and verifies it again with
+// a new test deposit; the account is inactive until that succeeds
+func (s *Service) Relink(ctx context.Context, customerID, id uuid.UUID, req RelinkRequest) (*models.SportsAccount, error) {
+	secret, err := sealCredentials(req.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	a, err := s.Get(customerID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.MpesaNumber != "" {
+		if a.MpesaNumber, err = s.phoneFor(s.db, customerID, req.MpesaNumber); err != nil {
+			return nil, err
+		}
+	}
+	if secret != "" {
+		a.EncryptedKey = secret
+	}
+	a.IsActive, a.LinkStatus, a.VerificationRef = false, models.BookieLinkPending, ""
+	err = s.db.Model(a).Select("MpesaNumber", "EncryptedKey", "IsActive", "LinkStatus", "VerificationRef").Updates(a).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.startTestDeposit(ctx, a)
+}
+
+// startTestDeposit records a pending deposit and prompts the account's phone
+// for it
+func (s *Service) startTestDeposit(ctx context.Context, a *models.SportsAccount) (*models.SportsAccount, error) {
+	if s.collector == nil {
+		return s.failVerification(a, nil)
+	}
+	deposit := &models.Transaction{
+		ID:              uuid.New(),
+		CustomerID:      a.CustomerID,
+		SportsAccountID: a.ID,
+		Type:            models.TransactionTypeDeposit,
+		AmountCents:     s.cfg.TestDepositCents,
+		IsReal:          true,
+		Status:          models.StatusPending,
+		Reference:       fmt.Sprintf("LNK-%s", uuid.New().String()[:8]),
+		Metadata:        models.JSONMap{"purpose": "bookie_link_verification"},
+	}
+	deposit.IdempotencyKey = "bookie-link:" + deposit.ID.String()
+	if err := s.db.Omit(clause.Associations).Create(deposit).Error; err != nil {
+		return nil, err
+	}
+	checkoutID, err := s.collector.Collect(ctx, a.MpesaNumber, deposit.AmountCents, deposit.ID.String())
+	if err != nil {
+		return s.failVerification(a, deposit)
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(deposit).Updates(map[string]interface{}{
+			"external_id": checkoutID,
+			"metadata":    models.JSONMap{"purpose": "bookie_link_verification", "third_party_ref": checkoutID},
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(a).Update("verification_ref", checkoutID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(a.CustomerID, a.ID)
+}
+
+func (s *Service) failVerification(a *models.SportsAccount, deposit *models.Transaction) (*models.SportsAccount, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if deposit != nil {
+			if err := tx.Model(deposit).Update("status", models.StatusFailed).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(a).Update("link_status", models.BookieLinkFailed).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	a, err = s.Get(a.CustomerID, a.ID)
+	if err != nil {
+		return nil, err
+	}
+	return a, ErrTestDepositFailed
+}
+
+// ConfirmTestDeposit applies the M-Pesa result for a test deposit. A
+// successful deposit is credited even when the link has since been relinked;
+// only the link's latest deposit verifies it.
+func (s *Service) ConfirmTestDeposit(ref string, success bool) (*models.SportsAccount, error) {
+	var a models.SportsAccount
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var deposit models.Transaction
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("external_id = ? AND status = ? AND reference LIKE ?", ref, models.StatusPending, "LNK-%").
+			Take(&deposit).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownReference
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Take(&a, "id = ?", deposit.SportsAccountID).Error; err != nil {
+			return err
+		}
+		latest := a.VerificationRef == ref && a.LinkStatus == models.BookieLinkPending
+		if !success {
+			if err := tx.Model(&deposit).Update("status", models.StatusFailed).Error; err != nil {
+				return err
+			}
+			if latest {
+				a.LinkStatus = models.BookieLinkFailed
+				return tx.Model(&a).Update("link_status", a.LinkStatus).Error
+			}
+			return nil
+		}
+		if err := tx.Model(&deposit).Update("status", models.StatusSuccess).Error; err != nil {
+			return err
+		}
+		if err := models.AdjustBalance(tx, "sports", a.ID, true, deposit.AmountCents); err != nil {
+			return err
+		}
+		if latest {
+			now := s.now()
+			a.LinkStatus, a.IsActive, a.VerifiedAt = models.BookieLinkVerified, true, &now
+			return tx.Model(&a).Select("LinkStatus", "IsActive", "VerifiedAt").Updates(&a).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(a.CustomerID, a.ID)
+}
+
+// phoneFor normalizes number, defaulting to the customer's payout number
+func (s *Service) phoneFor(tx *gorm.DB, customerID uuid.UUID, number string) (string, error) {
+	if number == "" {
+		var c struct{ Phone, PreferredMpesa string }
+		if err := tx.Table("customers").Select("phone, preferred_mpesa").Where("id = ?", customerID).Scan(&c).Error; err != nil {
+			return "", err
+		}
+		number = c.PreferredMpesa
+		if number == "" {
+			number = c.Phone
+		}
+	}
+	phone, err := models.NormalizePhone(number)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidLink, err)
+	}
+	return phone, nil
+}
+
+// sealCredentials encrypts a bookie login; empty stays empty
+func sealCredentials(credentials string) (string, error) {
+	if credentials == "" {
+		return "", nil
+	}
+	if len(credentials) > maxCredentialsLen {
+		return "", fmt.Errorf("%w: credentials are too long", ErrInvalidLink)
+	}
+	return models.EncryptSecret(credentials)
+}
@@ -0,0 +1,167 @@
+package bookieaccounts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/keymgmt"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+type fakeCollector struct {
+	phones  []string
+	amounts []int64
+	err     error
+}
+
+func (f *fakeCollector) Collect(_ context.Context, phone string, amount int64, key string) (string, error) {
+	f.phones = append(f.phones, phone)
+	f.amounts = append(f.amounts, amount)
+	return "ws_CO_" + key, f.err
+}
+
+type fixture struct {
+	s         *Service
+	db        *gorm.DB
+	collector *fakeCollector
+	customer  uuid.UUID
+	bookie    uuid.UUID
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	kms := keymgmt.NewLocalKMS()
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	models.SetFieldEncryption(keymgmt.NewEnvelope(kms))
+	t.Cleanup(func() { models.SetFieldEncryption(nil) })
+
+	db := testdb.Open(t, &models.Bookie{}, &models.SportsAccount{}, &models.AssetNexus{}, &models.Transaction{}, &models.Customer{})
+
+	f := &fixture{db: db, collector: &fakeCollector{}, customer: uuid.New(), bookie: uuid.New()}
+	db.Exec("INSERT INTO customers (id, name, email, phone, preferred_mpesa) VALUES (?, 'Achieng', 'achieng@example.com', '+254700000001', '')", f.customer)
+	db.Exec("INSERT INTO asset_nexus (id, customer_id, sports_manager_id) VALUES (?, ?, ?)", uuid.New(), f.customer, uuid.New())
+	b := models.Bookie{ID: f.bookie, Name: "SportPesa", PaybillNumber: "955100", AssetClasses: models.StringList{"sports"}, IsActive: true}
+	if err := db.Create(&b).Error; err != nil {
+		t.Fatal(err)
+	}
+	f.s = NewService(db, f.collector, DefaultConfig())
+	f.s.now = func() time.Time { return testNow }
+	return f
+}
+
+func (f *fixture) link(t *testing.T) *models.SportsAccount {
+	t.Helper()
+	a, err := f.s.Link(context.Background(), f.customer, LinkRequest{BookieID: f.bookie, Credentials: "punter:hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestLinkIsVerifiedByTestDeposit(t *testing.T) {
+	f := newFixture(t)
+	a := f.link(t)
+	if a.LinkStatus != models.BookieLinkPending || a.IsActive || a.MpesaNumber != "+254700000001" {
+		t.Fatalf("new link = %+v", a)
+	}
+	if creds, err := models.DecryptSecret(a.EncryptedKey); err != nil || creds != "punter:hunter2" {
+		t.Fatalf("credentials = %q, %v", creds, err)
+	}
+	if len(f.collector.amounts) != 1 || f.collector.amounts[0] != 1000 || a.VerificationRef == "" {
+		t.Fatalf("test deposit = %+v, ref %q", f.collector, a.VerificationRef)
+	}
+
+	if _, err := f.s.ConfirmTestDeposit("ws_CO_unknown", true); !errors.Is(err, ErrUnknownReference) {
+		t.Fatalf("unknown reference: %v", err)
+	}
+	a, err := f.s.ConfirmTestDeposit(a.VerificationRef, true)
+	if err != nil || a.LinkStatus != models.BookieLinkVerified || !a.IsActive || a.RealBalanceCents != 1000 || !a.VerifiedAt.Equal(testNow) {
+		t.Fatalf("verified = %+v, %v", a, err)
+	}
+	// Redelivery does not credit twice.
+	if _, err := f.s.ConfirmTestDeposit(a.VerificationRef, true); !errors.Is(err, ErrUnknownReference) {
+		t.Fatalf("redelivered result: %v", err)
+	}
+
+	if _, err := f.s.Link(context.Background(), f.customer, LinkRequest{BookieID: f.bookie, Credentials: "again"}); !errors.Is(err, ErrAlreadyLinked) {
+		t.Fatalf("second link: %v", err)
+	}
+	// The unique index backs up the check when two requests race.
+	dup := models.SportsAccount{ID: uuid.New(), CustomerID: f.customer, BookieID: f.bookie, ManagerID: uuid.New()}
+	if err := f.db.Omit("Bookie", "Customer", "Transactions").Create(&dup).Error; !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("duplicate insert: %v", err)
+	}
+}
+
+func TestLinkRefusals(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	if _, err := f.s.Link(ctx, f.customer, LinkRequest{BookieID: f.bookie}); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("no credentials: %v", err)
+	}
+	if _, err := f.s.Link(ctx, f.customer, LinkRequest{BookieID: f.bookie, Credentials: "x", MpesaNumber: "12345"}); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("bad number: %v", err)
+	}
+	if _, err := f.s.Link(ctx, f.customer, LinkRequest{BookieID: uuid.New(), Credentials: "x"}); !errors.Is(err, ErrBookieUnavailable) {
+		t.Fatalf("unknown bookie: %v", err)
+	}
+	f.db.Model(&models.Bookie{}).Where("id = ?", f.bookie).Update("asset_classes", models.StringList{"forex"})
+	if _, err := f.s.Link(ctx, f.customer, LinkRequest{BookieID: f.bookie, Credentials: "x"}); !errors.Is(err, ErrBookieUnavailable) {
+		t.Fatalf("forex-only bookie: %v", err)
+	}
+	f.db.Model(&models.Bookie{}).Where("id = ?", f.bookie).Update("asset_classes", models.StringList{"sports"})
+	if _, err := f.s.Link(ctx, uuid.New(), LinkRequest{BookieID: f.bookie, Credentials: "x"}); !errors.Is(err, ErrNoNexus) {
+		t.Fatalf("customer without nexus: %v", err)
+	}
+	if list, _ := f.s.List(f.customer); len(list) != 0 {
+		t.Fatalf("refused links were stored: %+v", list)
+	}
+}
+
+func TestDeactivateAndRelink(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	first := f.link(t)
+	if _, err := f.s.ConfirmTestDeposit(first.VerificationRef, true); err != nil {
+		t.Fatal(err)
+	}
+	a, err := f.s.Deactivate(f.customer, first.ID)
+	if err != nil || a.IsActive || a.LinkStatus != models.BookieLinkVerified {
+		t.Fatalf("deactivated = %+v, %v", a, err)
+	}
+	if _, err := f.s.Deactivate(uuid.New(), first.ID); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("someone else's account: %v", err)
+	}
+
+	// A failed push leaves the link failed and inactive until retried.
+	f.collector.err = errors.New("timeout")
+	a, err = f.s.Relink(ctx, f.customer, first.ID, RelinkRequest{MpesaNumber: "0712345678"})
+	if !errors.Is(err, ErrTestDepositFailed) || a.LinkStatus != models.BookieLinkFailed || a.IsActive {
+		t.Fatalf("relink with push failure = %+v, %v", a, err)
+	}
+	f.collector.err = nil
+	a, err = f.s.Relink(ctx, f.customer, first.ID, RelinkRequest{Credentials: "punter:new"})
+	if err != nil || a.MpesaNumber != "+254712345678" || a.LinkStatus != models.BookieLinkPending {
+		t.Fatalf("relink = %+v, %v", a, err)
+	}
+	if creds, _ := models.DecryptSecret(a.EncryptedKey); creds != "punter:new" {
+		t.Fatalf("credentials not replaced: %q", creds)
+	}
+	if f.collector.phones[len(f.collector.phones)-1] != "+254712345678" {
+		t.Fatalf("pushed to %v", f.collector.phones)
+	}
+	a, err = f.s.ConfirmTestDeposit(a.VerificationRef, false)
+	if err != nil || a.LinkStatus != models.BookieLinkFailed || a.IsActive || a.RealBalanceCents != 1000 {
+		t.Fatalf("declined deposit = %+v, %v", a, err)
+	}
+}
@@ -0,0 +1,33 @@
+package bookieaccounts
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SubjectTestDepositResult is where the M-Pesa bridge publishes the outcome
+// of verification deposits
+const SubjectTestDepositResult = "bookieaccounts.test_deposit.result"
+
+// Result is a provider callback relayed over NATS
+type Result struct {
+	Reference string `json:"reference"`
+	Success   bool   `json:"success"`
+}
+
+// Listen applies test deposit results as they arrive. Unknown or already
+// settled references are dropped, so redelivery is harmless.
+func (s *Service) Listen(nc *nats.Conn, logf func(string, ...interface{})) error {
+	_, err := nc.Subscribe(SubjectTestDepositResult, func(m *nats.Msg) {
+		var r Result
+		if err := json.Unmarshal(m.Data, &r); err != nil || r.Reference == "" {
+			logf("bookieaccounts: bad result on %s: %v", m.Subject, err)
+			return
+		}
+		if _, err := s.ConfirmTestDeposit(r.Reference, r.Success); err != nil && err != ErrUnknownReference {
+			logf("bookieaccounts: %s %s: %v", m.Subject, r.Reference, err)
+		}
+	})
+	return err
+}
@@ -0,0 +1,162 @@
+package bookies
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var (
+	ErrBookieNotFound = errors.New("bookie not found")
+	ErrBookieInUse    = errors.New("bookie has active accounts; deactivate it instead")
+)
+
+// accountTables hold customer accounts that reference a bookie
+var accountTables = []string{"sports_accounts", "stock_accounts", "forex_accounts", "crypto_accounts"}
+
+// Service manages the bookie catalog
+type Service struct {
+	db *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// List returns the catalog by name; inactive bookies only when asked
+func (s *Service) List(includeInactive bool) ([]models.Bookie, error) {
+	q := s.db.Order("name")
+	if !includeInactive {
+		q = q.Where("is_active = ?", true)
+	}
+	var out []models.Bookie
+	err := q.Find(&out).Error
+	return out, err
+}
+
+// Get returns one bookie, active or not
+func (s *Service) Get(id uuid.UUID) (*models.Bookie, error) {
+	var b models.Bookie
+	err := s.db.Take(&b, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookieNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ForAllocation returns the active bookies holding assetClass accounts, for
+// the deposit allocator
+func (s *Service) ForAllocation(assetClass string) ([]models.Bookie, error) {
+	all, err := s.List(false)
+	if err != nil {
+		return nil, err
+	}
+	out := all[:0]
+	for _, b := range all {
+		if b.Supports(assetClass) {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+// Create adds a bookie on behalf of a staff member
+func (s *Service) Create(actor backoffice.Actor, b models.Bookie) (*models.Bookie, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	b.ID = uuid.New()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Create swaps a false IsActive for the column default, so write it after.
+		active := b.IsActive
+		if err := tx.Create(&b).Error; err != nil {
+			return err
+		}
+		if !active {
+			if err := tx.Model(&b).Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		return backoffice.Audit(tx, actor, "bookies.create", "bookie", b.ID, bookieDetails(&b))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Update replaces a bookie's catalog settings on behalf of a staff member.
+// The allocator's return statistics are left as they are.
+func (s *Service) Update(actor backoffice.Actor, id uuid.UUID, b models.Bookie) (*models.Bookie, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	b.ID = id
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Bookie{}).Where("id = ?", id).Select(
+			"Name", "PaybillNumber", "TillNumber", "MpesaNumber",
+			"MinDepositCents", "MaxDepositCents", "MinWithdrawCents", "MaxWithdrawCents",
+			"Fees", "AssetClasses", "IsActive",
+		).Updates(&b)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrBookieNotFound
+		}
+		if err := tx.Take(&b, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return backoffice.Audit(tx, actor, "bookies.update", "bookie", id, bookieDetails(&b))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Delete removes a bookie no active account uses, on behalf of a staff
+// member
+func (s *Service) Delete(actor backoffice.Actor, id uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range accountTables {
+			var n int64
+			err := tx.Table(table).Where("bookie_id = ? AND is_active = ? AND deleted_at IS NULL", id, true).Count(&n).Error
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return ErrBookieInUse
+			}
+		}
+		res := tx.Delete(&models.Bookie{}, "id = ?", id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrBookieNotFound
+		}
+		return backoffice.Audit(tx, actor, "bookies.delete", "bookie", id, nil)
+	})
+}
+
+func bookieDetails(b *models.Bookie) models.JSONMap {
+	return models.JSONMap{
+		"name":               b.Name,
+		"paybill_number":     b.PaybillNumber,
+		"till_number":        b.TillNumber,
+		"min_deposit_cents":  b.MinDepositCents,
+		"max_deposit_cents":  b.MaxDepositCents,
+		"min_withdraw_cents": b.MinWithdrawCents,
+		"max_withdraw_cents": b.MaxWithdrawCents,
+		"asset_classes":      []string(b.AssetClasses),
+		"is_active":          b.IsActive,
+	}
+}
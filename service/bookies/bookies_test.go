@@ -0,0 +1,131 @@
+package bookies
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db := testdb.Open(t, &models.Bookie{}, &models.SportsAccount{}, &models.StockAccount{}, &models.ForexAccount{}, &models.CryptoAccount{}, &models.AuditLog{})
+	return NewService(db), db
+}
+
+func sportPesa() models.Bookie {
+	return models.Bookie{
+		Name:            "SportPesa",
+		PaybillNumber:   "955100",
+		MinDepositCents: 1000,
+		MaxDepositCents: 15000000,
+		Fees:            models.BookieFees{WithdrawFlatCents: 1600, WithdrawBps: 50},
+		AssetClasses:    models.StringList{"sports"},
+		IsActive:        true,
+	}
+}
+
+func TestBookieValidate(t *testing.T) {
+	cases := map[string]func(b *models.Bookie){
+		"no name":            func(b *models.Bookie) { b.Name = "" },
+		"no payment number":  func(b *models.Bookie) { b.PaybillNumber = "" },
+		"bad paybill":        func(b *models.Bookie) { b.PaybillNumber = "95-51" },
+		"bad phone":          func(b *models.Bookie) { b.MpesaNumber = "12345" },
+		"min above max":      func(b *models.Bookie) { b.MinDepositCents = b.MaxDepositCents + 1 },
+		"negative min":       func(b *models.Bookie) { b.MinWithdrawCents = -1 },
+		"fee over 100%":      func(b *models.Bookie) { b.Fees.DepositBps = 10001 },
+		"no asset class":     func(b *models.Bookie) { b.AssetClasses = nil },
+		"sharp asset class":  func(b *models.Bookie) { b.AssetClasses = models.StringList{"sharp"} },
+		"valid till instead": nil,
+	}
+	for name, mutate := range cases {
+		b := sportPesa()
+		if mutate == nil {
+			b.PaybillNumber, b.TillNumber = "", "5123456"
+			if err := b.Validate(); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+			continue
+		}
+		mutate(&b)
+		if err := b.Validate(); !errors.Is(err, models.ErrInvalidBookie) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+
+	b := sportPesa()
+	b.MpesaNumber = "0712345678"
+	if err := b.Validate(); err != nil || b.MpesaNumber != "+254712345678" {
+		t.Fatalf("phone not normalized: %q, %v", b.MpesaNumber, err)
+	}
+	if !b.DepositAllowed(1000) || b.DepositAllowed(999) || b.DepositAllowed(15000001) || !b.WithdrawAllowed(1) {
+		t.Fatal("deposit bounds")
+	}
+	if fee := b.Fees.Withdraw(100000); fee != 2100 {
+		t.Fatalf("withdraw fee = %d", fee)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	s, db := newTestService(t)
+	actor := backoffice.Actor{ID: uuid.New(), Role: models.RoleFinance}
+
+	created, err := s.Create(actor, sportPesa())
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := sportPesa()
+	closed.Name, closed.IsActive, closed.AssetClasses = "Closed Bets", false, models.StringList{"sports", "forex"}
+	if _, err := s.Create(actor, closed); err != nil {
+		t.Fatal(err)
+	}
+
+	active, err := s.List(false)
+	if err != nil || len(active) != 1 || active[0].ID != created.ID || active[0].Fees.WithdrawFlatCents != 1600 {
+		t.Fatalf("active = %+v, %v", active, err)
+	}
+	if all, _ := s.List(true); len(all) != 2 {
+		t.Fatalf("inactive entry was not kept inactive: %+v", all)
+	}
+	if forex, _ := s.ForAllocation("forex"); len(forex) != 0 {
+		t.Fatalf("inactive bookie offered to the allocator: %+v", forex)
+	}
+
+	change := sportPesa()
+	change.MaxDepositCents = 7000000
+	change.AssetClasses = models.StringList{"sports", "stock"}
+	updated, err := s.Update(actor, created.ID, change)
+	if err != nil || updated.MaxDepositCents != 7000000 || !updated.Supports("stock") {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+	if stock, _ := s.ForAllocation("stock"); len(stock) != 1 {
+		t.Fatalf("stock bookies = %+v", stock)
+	}
+	if _, err := s.Update(actor, uuid.New(), change); !errors.Is(err, ErrBookieNotFound) {
+		t.Fatalf("update missing: %v", err)
+	}
+
+	db.Exec("INSERT INTO sports_accounts (id, customer_id, bookie_id, manager_id, is_active) VALUES (?, ?, ?, ?, ?)", uuid.New(), uuid.New(), created.ID, uuid.New(), true)
+	if err := s.Delete(actor, created.ID); !errors.Is(err, ErrBookieInUse) {
+		t.Fatalf("delete in use: %v", err)
+	}
+	db.Exec("UPDATE sports_accounts SET is_active = ?", false)
+	if err := s.Delete(actor, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(created.ID); !errors.Is(err, ErrBookieNotFound) {
+		t.Fatalf("get deleted: %v", err)
+	}
+
+	var actions []string
+	db.Model(&models.AuditLog{}).Order("created_at").Pluck("action", &actions)
+	if !slices.Equal(actions, []string{"bookies.create", "bookies.create", "bookies.update", "bookies.delete"}) {
+		t.Fatalf("audit = %v", actions)
+	}
+}
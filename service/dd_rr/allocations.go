@@ -0,0 +1,133 @@
+package securewithdrawal
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/models"
+	"weriKana/service/fees"
+)
+
+// Allocation is one leg of a smart withdrawal: the share of the amount taken
+// from one of the customer's sports accounts
+type Allocation struct {
+	AccountID     uuid.UUID
+	BookieID      uuid.UUID
+	BookieName    string
+	EncryptedKey  string
+	Proportion    float64
+	AmountToSend  int64           // gross debited from the account
+	Fee           models.FeeQuote // the bookie's withdrawal fee, set by PriceLegs
+	TransactionID uuid.UUID       // the leg's ledger row, set by BookLegs
+}
+
+// CalculateAllocations splits amount across the customer's active sports
+// accounts in proportion to their balances, returning the legs and the pot
+// they were drawn from. No legs are returned when the pot can't cover amount.
+func CalculateAllocations(db *gorm.DB, customerID uuid.UUID, amount int64, isReal bool) ([]Allocation, int64, error) {
+	var accounts []models.SportsAccount
+	if err := db.Preload("Bookie").
+		Where("customer_id = ? AND is_active = ?", customerID, true).
+		Order("created_at").
+		Find(&accounts).Error; err != nil {
+		return nil, 0, err
+	}
+	balances := make([]int64, len(accounts))
+	var totalPot int64
+	for i, acct := range accounts {
+		balances[i] = acct.FakeBalanceCents
+		if isReal {
+			balances[i] = acct.RealBalanceCents
+		}
+		totalPot += balances[i]
+	}
+	if totalPot == 0 || totalPot < amount {
+		return nil, totalPot, nil
+	}
+
+	// Rounding down leaves a few cents over, taken from the first legs with room
+	shares := make([]int64, len(accounts))
+	remainder := amount
+	for i, balance := range balances {
+		shares[i] = int64(float64(amount) * float64(balance) / float64(totalPot))
+		remainder -= shares[i]
+	}
+	for i := 0; remainder > 0 && i < len(shares); i++ {
+		extra := min(remainder, balances[i]-shares[i])
+		shares[i] += extra
+		remainder -= extra
+	}
+
+	var allocs []Allocation
+	for i, acct := range accounts {
+		if shares[i] <= 0 {
+			continue
+		}
+		allocs = append(allocs, Allocation{
+			AccountID:    acct.ID,
+			BookieID:     acct.BookieID,
+			BookieName:   acct.Bookie.Name,
+			EncryptedKey: acct.EncryptedKey,
+			Proportion:   float64(balances[i]) / float64(totalPot),
+			AmountToSend: shares[i],
+			Fee:          models.NoFee(models.FeeWithdraw, shares[i]),
+		})
+	}
+	return allocs, totalPot, nil
+}
+
+// PriceLegs charges real-money legs their bookie's withdrawal fee, which
+// comes out of the payout; practice legs stay free
+func PriceLegs(feeSvc *fees.Service, isReal bool, allocs []Allocation) error {
+	if !isReal {
+		return nil
+	}
+	for i := range allocs {
+		quote, err := feeSvc.Quote(models.FeeWithdraw, allocs[i].BookieID, allocs[i].AmountToSend)
+		if err != nil {
+			return err
+		}
+		allocs[i].Fee = quote
+	}
+	return nil
+}
+
+// BookLegs debits each priced leg in full, logs it as a pending withdrawal
+// under parentRef and books its fee. Run it in a transaction: a failed leg
+// must not leave the others debited.
+func BookLegs(tx *gorm.DB, customerID uuid.UUID, isReal bool, parentRef string, allocs []Allocation) error {
+	for i := range allocs {
+		a := &allocs[i]
+		if err := models.DebitBalance(tx, "sports", a.AccountID, isReal, a.AmountToSend); err != nil {
+			return err
+		}
+		txn := models.Transaction{
+			ID:              uuid.New(),
+			SportsAccountID: a.AccountID,
+			CustomerID:      customerID,
+			Type:            models.TransactionTypeWithdraw,
+			IsReal:          isReal,
+			Currency:        models.DefaultCurrency,
+			Status:          models.StatusPending,
+			Reference:       fmt.Sprintf("WD-%s-%d", parentRef[:8], i+1),
+			Metadata: models.JSONMap{
+				"stage":       "execution_queued",
+				"parent_ref":  parentRef,
+				"proportion":  a.Proportion,
+				"bookie_name": a.BookieName,
+			},
+			IdempotencyKey: uuid.New().String(),
+		}
+		a.Fee.Apply(&txn)
+		if err := tx.Create(&txn).Error; err != nil {
+			return err
+		}
+		if err := fees.Post(tx, &txn, a.Fee); err != nil {
+			return err
+		}
+		a.TransactionID = txn.ID
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package securewithdrawal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/fees"
+)
+
+type withdrawFixture struct {
+	db       *gorm.DB
+	fees     *fees.Service
+	customer uuid.UUID
+	big      uuid.UUID // KES 3,000
+	small    uuid.UUID // KES 1,000
+}
+
+func newWithdrawFixture(t *testing.T) *withdrawFixture {
+	t.Helper()
+	db := testdb.Open(t, &models.SportsAccount{}, &models.Bookie{}, &models.Transaction{}, &models.FeeSchedule{}, &models.RevenueEntry{})
+	f := &withdrawFixture{db: db, fees: fees.NewService(db), customer: uuid.New(), big: uuid.New(), small: uuid.New()}
+	if err := f.fees.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	bookie := uuid.New()
+	db.Exec("INSERT INTO bookies (id, name) VALUES (?, 'SportPesa')", bookie)
+	insert := "INSERT INTO sports_accounts (id, customer_id, bookie_id, manager_id, real_balance_cents, fake_balance_cents, is_active, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	db.Exec(insert, f.big, f.customer, bookie, uuid.New(), 300000, 1000, true, "2025-01-01")
+	db.Exec(insert, f.small, f.customer, uuid.New(), uuid.New(), 100000, 0, true, "2025-01-02")
+	db.Exec(insert, uuid.New(), f.customer, uuid.New(), uuid.New(), 50000, 0, false, "2025-01-03")
+	db.Exec(insert, uuid.New(), uuid.New(), bookie, uuid.New(), 900000, 0, true, "2025-01-04")
+	return f
+}
+
+func (f *withdrawFixture) balance(id uuid.UUID) int64 {
+	var b int64
+	f.db.Raw("SELECT real_balance_cents FROM sports_accounts WHERE id = ?", id).Row().Scan(&b)
+	return b
+}
+
+func TestCalculateAllocations(t *testing.T) {
+	f := newWithdrawFixture(t)
+
+	allocs, pot, err := CalculateAllocations(f.db, f.customer, 200001, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The odd cent left by rounding down comes from the first leg.
+	if pot != 400000 || len(allocs) != 2 || allocs[0].AccountID != f.big || allocs[0].AmountToSend != 150001 ||
+		allocs[1].AccountID != f.small || allocs[1].AmountToSend != 50000 || allocs[0].BookieName != "SportPesa" {
+		t.Fatalf("allocations = %+v, pot %d", allocs, pot)
+	}
+
+	if allocs, pot, err := CalculateAllocations(f.db, f.customer, 400001, true); err != nil || allocs != nil || pot != 400000 {
+		t.Fatalf("above the pot = %+v, %d, %v", allocs, pot, err)
+	}
+	if allocs, pot, _ := CalculateAllocations(f.db, f.customer, 1000, false); pot != 1000 || len(allocs) != 1 || allocs[0].AmountToSend != 1000 {
+		t.Fatalf("practice allocations = %+v, pot %d", allocs, pot)
+	}
+}
+
+func TestBookLegsChargesWithdrawalFees(t *testing.T) {
+	f := newWithdrawFixture(t)
+	allocs, _, _ := CalculateAllocations(f.db, f.customer, 200001, true)
+	if err := PriceLegs(f.fees, true, allocs); err != nil {
+		t.Fatal(err)
+	}
+	err := f.db.Transaction(func(tx *gorm.DB) error {
+		return BookLegs(tx, f.customer, true, uuid.NewString(), allocs)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each leg is debited in full; the B2C tariff comes out of the payout.
+	if f.balance(f.big) != 149999 || f.balance(f.small) != 50000 {
+		t.Fatalf("balances = %d/%d", f.balance(f.big), f.balance(f.small))
+	}
+	if allocs[0].Fee.FeeCents != 3300 || allocs[0].Fee.NetCents != 146701 || allocs[1].Fee.FeeCents != 700 || allocs[1].Fee.NetCents != 49300 {
+		t.Fatalf("legs = %+v", allocs)
+	}
+	for _, a := range allocs {
+		var txn models.Transaction
+		if err := f.db.First(&txn, "id = ?", a.TransactionID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if txn.Type != models.TransactionTypeWithdraw || txn.Status != models.StatusPending || txn.SportsAccountID != a.AccountID ||
+			txn.AmountCents != a.AmountToSend || txn.FeeCents != a.Fee.FeeCents || txn.NetAmountCents != a.Fee.NetCents {
+			t.Errorf("leg row = %+v", txn)
+		}
+	}
+	var revenue int64
+	f.db.Model(&models.RevenueEntry{}).Select("SUM(amount_cents)").Row().Scan(&revenue)
+	if revenue != 4000 {
+		t.Fatalf("revenue = %d, want 4000", revenue)
+	}
+
+	// Practice money is never charged.
+	practice, _, _ := CalculateAllocations(f.db, f.customer, 1000, false)
+	if err := PriceLegs(f.fees, false, practice); err != nil {
+		t.Fatal(err)
+	}
+	if err := BookLegs(f.db, f.customer, false, uuid.NewString(), practice); err != nil {
+		t.Fatal(err)
+	}
+	if practice[0].Fee.FeeCents != 0 || practice[0].Fee.NetCents != 1000 {
+		t.Fatalf("practice leg = %+v", practice[0])
+	}
+}
+
+func TestBookLegsRollsBackOnFailedLeg(t *testing.T) {
+	f := newWithdrawFixture(t)
+	allocs, _, _ := CalculateAllocations(f.db, f.customer, 200000, true)
+	if err := PriceLegs(f.fees, true, allocs); err != nil {
+		t.Fatal(err)
+	}
+	// The second account is drained between planning and booking.
+	f.db.Exec("UPDATE sports_accounts SET real_balance_cents = 0 WHERE id = ?", f.small)
+
+	err := f.db.Transaction(func(tx *gorm.DB) error {
+		return BookLegs(tx, f.customer, true, uuid.NewString(), allocs)
+	})
+	if !errors.Is(err, models.ErrInsufficientFunds) {
+		t.Fatalf("drained leg: %v", err)
+	}
+	var legs, revenue int64
+	f.db.Model(&models.Transaction{}).Count(&legs)
+	f.db.Model(&models.RevenueEntry{}).Count(&revenue)
+	if f.balance(f.big) != 300000 || legs != 0 || revenue != 0 {
+		t.Fatalf("after rollback: balance %d, %d legs, %d revenue entries", f.balance(f.big), legs, revenue)
+	}
+}
@@ -6,22 +6,25 @@ import (
     "crypto/ed25519"
     "crypto/rand"
     "crypto/sha256"
+    "encoding/binary"
     "encoding/pem"
     "fmt"
     "io"
 
+    "github.com/google/uuid"
+    "golang.org/x/crypto/curve25519"
     "golang.org/x/crypto/hkdf"
 )
 
-// CryptoEngine holds our private key and the engine's public key (for OTP encryption)
+// CryptoEngine holds our signing key and the engine's public keys
 type CryptoEngine struct {
     PrivKey     ed25519.PrivateKey // 64 bytes
-    EnginePub   ed25519.PublicKey  // 32 bytes (used as AES key – pre-shared securely)
-    EnginePubX  []byte             // optional X25519 public for PFS
+    EnginePub   ed25519.PublicKey  // 32 bytes, engine identity
+    EnginePubX  []byte             // 32-byte X25519 public, target of OTP encryption
 }
 
 // NewCryptoEngine loads PEM-encoded keys
-func NewCryptoEngine(ourPrivPEM, enginePubPEM []byte) (*CryptoEngine, error) {
+func NewCryptoEngine(ourPrivPEM, enginePubPEM, engineXPubPEM []byte) (*CryptoEngine, error) {
     priv, err := pemToEd25519Private(ourPrivPEM)
     if err != nil {
         return nil, err
@@ -30,7 +33,11 @@ func NewCryptoEngine(ourPrivPEM, enginePubPEM []byte) (*CryptoEngine, error) {
     if err != nil {
         return nil, err
     }
-    return &CryptoEngine{PrivKey: priv, EnginePub: pub}, nil
+    pubX, err := pemToX25519Public(engineXPubPEM)
+    if err != nil {
+        return nil, err
+    }
+    return &CryptoEngine{PrivKey: priv, EnginePub: pub, EnginePubX: pubX}, nil
 }
 
 // Sign creates an Ed25519 signature over the exact msgpack bytes (excluding the signature field)
@@ -43,79 +50,118 @@ func Verify(pub ed25519.PublicKey, data, sig []byte) bool {
     return ed25519.Verify(pub, data, sig)
 }
 
-// EncryptOTP encrypts the OTP so **only** the execution engine can read it.
-// Uses the engine's public key as the AES-256 key (must be pre-shared via secure channel).
-func (c *CryptoEngine) EncryptOTP(otp string) (nonce, ciphertext []byte, err error) {
-    nonce = make([]byte, 12)
-    if _, err = rand.Read(nonce); err != nil {
-        return
-    }
-    block, err := aes.NewCipher(c.EnginePub) // 32-byte key
-    if err != nil {
+// ------------------------------------------------------------
+// OTP encryption (X25519 + HKDF + AES-GCM)
+//
+// Every OTP is sealed to the engine's X25519 key with a fresh ephemeral key,
+// so compromising either long-term key later does not expose past OTPs.
+// The AES key is derived by HKDF-SHA256 over the shared secret with both
+// public keys in the info string, and the transaction ID and timestamp are
+// bound in as GCM associated data so a ciphertext cannot be moved to
+// another envelope.
+
+const otpHKDFLabel = "secure-withdrawal-otp-v1"
+
+// EncryptOTPWithPFS seals otp for the execution engine
+func (c *CryptoEngine) EncryptOTPWithPFS(otp string, txID uuid.UUID, timestamp int64) (epub, nonce, ciphertext []byte, err error) {
+    ephPriv := make([]byte, curve25519.ScalarSize)
+    if _, err = rand.Read(ephPriv); err != nil {
         return
     }
-    aesgcm, err := cipher.NewGCM(block)
-    if err != nil {
+    nonce = make([]byte, 12)
+    if _, err = rand.Read(nonce); err != nil {
         return
     }
-    ciphertext = aesgcm.Seal(nil, nonce, []byte(otp), nil)
+    epub, ciphertext, err = sealOTP(ephPriv, c.EnginePubX, nonce, otp, txID, timestamp)
     return
 }
 
-// DecryptOTP (engine side)
-func DecryptOTP(enginePrivKey, nonce, ciphertext []byte) (string, error) {
-    // Derive AES key from engine's private key? Simpler: use same pre-shared pub as key.
-    // In production you would derive via HKDF from a shared secret.
-    block, err := aes.NewCipher(enginePrivKey[:32])
+// DecryptOTPWithPFS (engine side) opens an OTP sealed by EncryptOTPWithPFS
+func DecryptOTPWithPFS(enginePrivX, epub, nonce, ciphertext []byte, txID uuid.UUID, timestamp int64) (string, error) {
+    enginePubX, err := x25519ScalarMultBase(enginePrivX)
     if err != nil {
         return "", err
     }
-    aesgcm, err := cipher.NewGCM(block)
+    shared, err := x25519Shared(enginePrivX, epub)
     if err != nil {
         return "", err
     }
-    plain, err := aesgcm.Open(nil, nonce, ciphertext, nil)
+    gcm, err := otpAEAD(shared, epub, enginePubX)
+    if err != nil {
+        return "", err
+    }
+    if len(nonce) != gcm.NonceSize() {
+        return "", fmt.Errorf("invalid OTP nonce")
+    }
+    plain, err := gcm.Open(nil, nonce, ciphertext, otpAssociatedData(txID, timestamp))
     if err != nil {
         return "", err
     }
     return string(plain), nil
 }
 
-// ------------------------------------------------------------
-// Optional PFS version (X25519 + HKDF)
-
-// EncryptOTPWithPFS uses an ephemeral X25519 keypair and PFS to encrypt the OTP
-func (c *CryptoEngine) EncryptOTPWithPFS(otp string) (epub, nonce, ciphertext []byte, err error) {
-    // Generate ephemeral X25519 keypair
-    var priv [32]byte
-    if _, err = rand.Read(priv[:]); err != nil {
-        return
+// GenerateX25519Key creates an engine key pair for OTP encryption
+func GenerateX25519Key() (priv, pub []byte, err error) {
+    priv = make([]byte, curve25519.ScalarSize)
+    if _, err = rand.Read(priv); err != nil {
+        return nil, nil, err
     }
-    epubB := x25519ScalarMultBase(priv[:])
-    epub = epubB[:]
-
-    // Shared secret
-    shared := x25519Shared(priv[:], c.EnginePubX)
+    pub, err = x25519ScalarMultBase(priv)
+    if err != nil {
+        return nil, nil, err
+    }
+    return priv, pub, nil
+}
 
-    // Derive AES key
-    key := hkdfExpand(shared, []byte("secure-withdrawal-otp-v1"), 32)
+func sealOTP(ephPriv, enginePubX, nonce []byte, otp string, txID uuid.UUID, timestamp int64) (epub, ciphertext []byte, err error) {
+    epub, err = x25519ScalarMultBase(ephPriv)
+    if err != nil {
+        return nil, nil, err
+    }
+    shared, err := x25519Shared(ephPriv, enginePubX)
+    if err != nil {
+        return nil, nil, err
+    }
+    gcm, err := otpAEAD(shared, epub, enginePubX)
+    if err != nil {
+        return nil, nil, err
+    }
+    ciphertext = gcm.Seal(nil, nonce, []byte(otp), otpAssociatedData(txID, timestamp))
+    return epub, ciphertext, nil
+}
 
-    nonce = make([]byte, 12)
-    if _, err = rand.Read(nonce); err != nil {
-        return
+// otpAEAD derives the AES-256-GCM cipher for one OTP exchange
+func otpAEAD(shared, epub, enginePubX []byte) (cipher.AEAD, error) {
+    info := make([]byte, 0, len(otpHKDFLabel)+len(epub)+len(enginePubX))
+    info = append(info, otpHKDFLabel...)
+    info = append(info, epub...)
+    info = append(info, enginePubX...)
+    key, err := hkdfExpand(shared, info, 32)
+    if err != nil {
+        return nil, err
     }
-    block, _ := aes.NewCipher(key)
-    gcm, _ := cipher.NewGCM(block)
-    ciphertext = gcm.Seal(nil, nonce, []byte(otp), nil)
-    return
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+// otpAssociatedData is txID || big-endian timestamp
+func otpAssociatedData(txID uuid.UUID, timestamp int64) []byte {
+    ad := make([]byte, 0, len(txID)+8)
+    ad = append(ad, txID[:]...)
+    return binary.BigEndian.AppendUint64(ad, uint64(timestamp))
 }
 
 // Helper: HKDF-SHA256 expand
-func hkdfExpand(secret, info []byte, length int) []byte {
+func hkdfExpand(secret, info []byte, length int) ([]byte, error) {
     h := hkdf.New(sha256.New, secret, nil, info)
     out := make([]byte, length)
-    io.ReadFull(h, out)
-    return out
+    if _, err := io.ReadFull(h, out); err != nil {
+        return nil, err
+    }
+    return out, nil
 }
 
 // PEM helpers
@@ -135,18 +181,22 @@ func pemToEd25519Public(pemBytes []byte) (ed25519.PublicKey, error) {
     return ed25519.PublicKey(block.Bytes), nil
 }
 
+func pemToX25519Public(pemBytes []byte) ([]byte, error) {
+    block, _ := pem.Decode(pemBytes)
+    if block == nil || block.Type != "X25519 PUBLIC KEY" || len(block.Bytes) != curve25519.PointSize {
+        return nil, fmt.Errorf("invalid X25519 PEM")
+    }
+    return block.Bytes, nil
+}
+
 // ------------------------------------------------------------
-// Optional X25519 helpers for Perfect Forward Secrecy (PFS)
+// X25519 helpers
 
-func x25519ScalarMultBase(priv []byte) [32]byte {
-    // Perform the scalar multiplication with the base point and return the public key.
-    var result [32]byte
-    // The scalar multiplication code will be here
-    return result
+func x25519ScalarMultBase(priv []byte) ([]byte, error) {
+    return curve25519.X25519(priv, curve25519.Basepoint)
 }
 
-func x25519Shared(priv, pub []byte) []byte {
-    // Derive the shared secret using X25519 Diffie-Hellman
-    return []byte{}
+// x25519Shared fails on low-order peer points (all-zero shared secret)
+func x25519Shared(priv, pub []byte) ([]byte, error) {
+    return curve25519.X25519(priv, pub)
 }
-
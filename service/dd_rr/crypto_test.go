@@ -0,0 +1,149 @@
+package securewithdrawal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// RFC 7748 §6.1 test vectors
+const (
+	rfcAlicePriv = "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"
+	rfcAlicePub  = "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"
+	rfcBobPriv   = "5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb"
+	rfcBobPub    = "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f"
+	rfcShared    = "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestX25519KnownAnswer(t *testing.T) {
+	alicePriv, bobPriv := mustHex(t, rfcAlicePriv), mustHex(t, rfcBobPriv)
+
+	alicePub, err := x25519ScalarMultBase(alicePriv)
+	if err != nil || hex.EncodeToString(alicePub) != rfcAlicePub {
+		t.Fatalf("alice public = %x, %v", alicePub, err)
+	}
+	bobPub, err := x25519ScalarMultBase(bobPriv)
+	if err != nil || hex.EncodeToString(bobPub) != rfcBobPub {
+		t.Fatalf("bob public = %x, %v", bobPub, err)
+	}
+	for _, shared := range [][]byte{
+		mustShared(t, alicePriv, bobPub),
+		mustShared(t, bobPriv, alicePub),
+	} {
+		if hex.EncodeToString(shared) != rfcShared {
+			t.Fatalf("shared = %x", shared)
+		}
+	}
+}
+
+func mustShared(t *testing.T, priv, pub []byte) []byte {
+	t.Helper()
+	shared, err := x25519Shared(priv, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shared
+}
+
+func TestX25519RejectsLowOrderPoint(t *testing.T) {
+	if _, err := x25519Shared(mustHex(t, rfcAlicePriv), make([]byte, 32)); err == nil {
+		t.Fatal("expected error for all-zero peer key")
+	}
+}
+
+func TestSealOTPKnownAnswer(t *testing.T) {
+	txID := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	nonce := mustHex(t, "000102030405060708090a0b")
+	const timestamp = 1_700_000_000
+
+	epub, ciphertext, err := sealOTP(mustHex(t, rfcAlicePriv), mustHex(t, rfcBobPub), nonce, "123456", txID, timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(epub) != rfcAlicePub {
+		t.Fatalf("epub = %x", epub)
+	}
+	// Cross-checked against crypto/ecdh + crypto/hkdf from the standard library.
+	const wantCiphertext = "020161f35e83d4f633537214d6f4c1ae3156e4efea72"
+	if got := hex.EncodeToString(ciphertext); got != wantCiphertext {
+		t.Fatalf("ciphertext = %s, want %s", got, wantCiphertext)
+	}
+
+	otp, err := DecryptOTPWithPFS(mustHex(t, rfcBobPriv), epub, nonce, ciphertext, txID, timestamp)
+	if err != nil || otp != "123456" {
+		t.Fatalf("decrypt = %q, %v", otp, err)
+	}
+}
+
+func TestEncryptOTPWithPFSRoundTrip(t *testing.T) {
+	enginePriv, enginePub, err := GenerateX25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &CryptoEngine{EnginePubX: enginePub}
+	txID := uuid.New()
+	const timestamp = 1_700_000_000
+
+	epub, nonce, ciphertext, err := c.EncryptOTPWithPFS("654321", txID, timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otp, err := DecryptOTPWithPFS(enginePriv, epub, nonce, ciphertext, txID, timestamp)
+	if err != nil || otp != "654321" {
+		t.Fatalf("decrypt = %q, %v", otp, err)
+	}
+
+	epub2, _, _, err := c.EncryptOTPWithPFS("654321", txID, timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(epub, epub2) {
+		t.Fatal("ephemeral key reused across encryptions")
+	}
+
+	otherPriv, _, err := GenerateX25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipped := append([]byte(nil), ciphertext...)
+	flipped[0] ^= 0xff
+	badEpub := append([]byte(nil), epub...)
+	badEpub[0] ^= 0xff
+
+	failures := map[string]func() (string, error){
+		"wrong engine key": func() (string, error) {
+			return DecryptOTPWithPFS(otherPriv, epub, nonce, ciphertext, txID, timestamp)
+		},
+		"other transaction": func() (string, error) {
+			return DecryptOTPWithPFS(enginePriv, epub, nonce, ciphertext, uuid.New(), timestamp)
+		},
+		"other timestamp": func() (string, error) {
+			return DecryptOTPWithPFS(enginePriv, epub, nonce, ciphertext, txID, timestamp+1)
+		},
+		"tampered ciphertext": func() (string, error) {
+			return DecryptOTPWithPFS(enginePriv, epub, nonce, flipped, txID, timestamp)
+		},
+		"tampered ephemeral key": func() (string, error) {
+			return DecryptOTPWithPFS(enginePriv, badEpub, nonce, ciphertext, txID, timestamp)
+		},
+		"short nonce": func() (string, error) {
+			return DecryptOTPWithPFS(enginePriv, epub, nonce[:8], ciphertext, txID, timestamp)
+		},
+	}
+	for name, decrypt := range failures {
+		if otp, err := decrypt(); err == nil {
+			t.Errorf("%s: decrypted %q, want error", name, otp)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package securewithdrawal
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// DefaultEnvelopeWindow is how far an envelope Timestamp may drift from the
+// consumer's clock before it is rejected as stale.
+const DefaultEnvelopeWindow = 2 * time.Minute
+
+var (
+	ErrBadSignature     = errors.New("envelope signature invalid")
+	ErrStaleEnvelope    = errors.New("envelope timestamp outside window")
+	ErrReplayedEnvelope = errors.New("envelope idempotency key already seen")
+)
+
+// signableEnvelope is the canonical signing form of SecureWithdrawalEnvelope:
+// every field except Signature, in a fixed order.
+type signableEnvelope struct {
+	TransactionID uuid.UUID
+	Items         []WithdrawalItem
+	OTPEphemeral  []byte
+	OTPNonce      []byte
+	OTPEncrypted  []byte
+	Timestamp     int64
+	Idempotency   string
+}
+
+// SigningBytes returns the bytes covered by the Ed25519 signature
+func (e *SecureWithdrawalEnvelope) SigningBytes() ([]byte, error) {
+	return msgpack.Marshal(signableEnvelope{
+		TransactionID: e.TransactionID,
+		Items:         e.Items,
+		OTPEphemeral:  e.OTPEphemeral,
+		OTPNonce:      e.OTPNonce,
+		OTPEncrypted:  e.OTPEncrypted,
+		Timestamp:     e.Timestamp,
+		Idempotency:   e.Idempotency,
+	})
+}
+
+// ReplayCache remembers idempotency keys until they expire.
+// Seen records key and reports whether it was already present; Forget drops it
+// so a message whose processing failed can be redelivered.
+type ReplayCache interface {
+	Seen(key string, expiresAt time.Time) bool
+	Forget(key string)
+}
+
+// MemoryReplayCache is a process-local ReplayCache
+type MemoryReplayCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	now     func() time.Time
+}
+
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{entries: make(map[string]time.Time), now: time.Now}
+}
+
+func (c *MemoryReplayCache) Seen(key string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, exp := range c.entries {
+		if now.After(exp) {
+			delete(c.entries, k)
+		}
+	}
+	if _, ok := c.entries[key]; ok {
+		return true
+	}
+	c.entries[key] = expiresAt
+	return false
+}
+
+func (c *MemoryReplayCache) Forget(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// EnvelopeVerifier is the consumer-side check for SecureWithdrawalEnvelope
+type EnvelopeVerifier struct {
+	PubKey ed25519.PublicKey
+	Window time.Duration
+	Replay ReplayCache
+	Now    func() time.Time
+}
+
+// NewEnvelopeVerifier returns a verifier using DefaultEnvelopeWindow and an in-memory replay cache
+func NewEnvelopeVerifier(pub ed25519.PublicKey) *EnvelopeVerifier {
+	return &EnvelopeVerifier{
+		PubKey: pub,
+		Window: DefaultEnvelopeWindow,
+		Replay: NewMemoryReplayCache(),
+		Now:    time.Now,
+	}
+}
+
+// Verify decodes a msgpack payload and checks signature, freshness and replay, in that order.
+// The idempotency key is only recorded once the signature and timestamp are valid.
+func (v *EnvelopeVerifier) Verify(payload []byte) (*SecureWithdrawalEnvelope, error) {
+	var env SecureWithdrawalEnvelope
+	if err := msgpack.Unmarshal(payload, &env); err != nil {
+		return nil, fmt.Errorf("decode envelope: %w", err)
+	}
+	signable, err := env.SigningBytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Signature) != ed25519.SignatureSize || !Verify(v.PubKey, signable, env.Signature) {
+		return nil, ErrBadSignature
+	}
+
+	now := v.Now()
+	ts := time.Unix(env.Timestamp, 0)
+	if ts.Before(now.Add(-v.Window)) || ts.After(now.Add(v.Window)) {
+		return nil, ErrStaleEnvelope
+	}
+	if v.Replay.Seen(env.Idempotency, ts.Add(v.Window)) {
+		return nil, ErrReplayedEnvelope
+	}
+	return &env, nil
+}
+
+// SubscribeSecureWithdrawals consumes envelopes published by SendSecureWithdrawalViaNATS.
+// Envelopes failing verification are terminated; handler errors are redelivered.
+func SubscribeSecureWithdrawals(
+	nc *nats.Conn,
+	subject string,
+	v *EnvelopeVerifier,
+	handle func(*SecureWithdrawalEnvelope) error,
+) (*nats.Subscription, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	return js.Subscribe(subject, func(m *nats.Msg) {
+		env, err := v.Verify(m.Data)
+		if err != nil {
+			log.Printf("rejected secure withdrawal: %v", err)
+			m.Term()
+			return
+		}
+		if err := handle(env); err != nil {
+			log.Printf("secure withdrawal %s failed: %v", env.TransactionID, err)
+			v.Replay.Forget(env.Idempotency)
+			m.Nak()
+			return
+		}
+		m.Ack()
+	}, nats.ManualAck())
+}
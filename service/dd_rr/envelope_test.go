@@ -0,0 +1,167 @@
+package securewithdrawal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var testNow = time.Unix(1_700_000_000, 0)
+
+func newTestVerifier(t *testing.T) (*CryptoEngine, *EnvelopeVerifier) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewEnvelopeVerifier(pub)
+	v.Now = func() time.Time { return testNow }
+	v.Replay.(*MemoryReplayCache).now = v.Now
+	return &CryptoEngine{PrivKey: priv}, v
+}
+
+func testEnvelope() SecureWithdrawalEnvelope {
+	return SecureWithdrawalEnvelope{
+		TransactionID: uuid.New(),
+		Items: []WithdrawalItem{
+			{BookieAccountID: uuid.New(), AmountCents: 3000, EncryptedKey: []byte("key-a")},
+			{BookieAccountID: uuid.New(), AmountCents: 2000, EncryptedKey: []byte("key-b")},
+		},
+		OTPEphemeral: []byte("ephemeral-public-key"),
+		OTPNonce:     []byte("nonce-123456"),
+		OTPEncrypted: []byte("ciphertext"),
+		Timestamp:    testNow.Unix(),
+		Idempotency:  uuid.New().String(),
+	}
+}
+
+func sealEnvelope(t *testing.T, c *CryptoEngine, env SecureWithdrawalEnvelope) SecureWithdrawalEnvelope {
+	t.Helper()
+	signable, err := env.SigningBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Signature = c.Sign(signable)
+	return env
+}
+
+func marshalEnvelope(t *testing.T, env SecureWithdrawalEnvelope) []byte {
+	t.Helper()
+	data, err := msgpack.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEnvelopeVerifierAcceptsValid(t *testing.T) {
+	c, v := newTestVerifier(t)
+	env := sealEnvelope(t, c, testEnvelope())
+
+	got, err := v.Verify(marshalEnvelope(t, env))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.TransactionID != env.TransactionID || len(got.Items) != 2 {
+		t.Fatalf("decoded envelope mismatch: %+v", got)
+	}
+}
+
+func TestEnvelopeVerifierRejectsTampering(t *testing.T) {
+	tamper := map[string]func(e *SecureWithdrawalEnvelope){
+		"TransactionID":         func(e *SecureWithdrawalEnvelope) { e.TransactionID = uuid.New() },
+		"Items.BookieAccountID": func(e *SecureWithdrawalEnvelope) { e.Items[0].BookieAccountID = uuid.New() },
+		"Items.AmountCents":     func(e *SecureWithdrawalEnvelope) { e.Items[1].AmountCents++ },
+		"Items.EncryptedKey":    func(e *SecureWithdrawalEnvelope) { e.Items[0].EncryptedKey = []byte("key-x") },
+		"Items.appended":        func(e *SecureWithdrawalEnvelope) { e.Items = append(e.Items, WithdrawalItem{AmountCents: 1}) },
+		"Items.dropped":         func(e *SecureWithdrawalEnvelope) { e.Items = e.Items[:1] },
+		"OTPEphemeral":          func(e *SecureWithdrawalEnvelope) { e.OTPEphemeral[0] ^= 0xff },
+		"OTPNonce":              func(e *SecureWithdrawalEnvelope) { e.OTPNonce[0] ^= 0xff },
+		"OTPEncrypted":          func(e *SecureWithdrawalEnvelope) { e.OTPEncrypted[0] ^= 0xff },
+		"Timestamp":             func(e *SecureWithdrawalEnvelope) { e.Timestamp-- },
+		"Idempotency":           func(e *SecureWithdrawalEnvelope) { e.Idempotency = uuid.New().String() },
+		"Signature":             func(e *SecureWithdrawalEnvelope) { e.Signature[0] ^= 0xff },
+		"Signature.truncated":   func(e *SecureWithdrawalEnvelope) { e.Signature = e.Signature[:10] },
+	}
+	for name, mutate := range tamper {
+		t.Run(name, func(t *testing.T) {
+			c, v := newTestVerifier(t)
+			env := sealEnvelope(t, c, testEnvelope())
+			mutate(&env)
+			if _, err := v.Verify(marshalEnvelope(t, env)); !errors.Is(err, ErrBadSignature) {
+				t.Fatalf("want ErrBadSignature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEnvelopeVerifierRejectsWrongKey(t *testing.T) {
+	_, v := newTestVerifier(t)
+	other, _ := newTestVerifier(t)
+	env := sealEnvelope(t, other, testEnvelope())
+	if _, err := v.Verify(marshalEnvelope(t, env)); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("want ErrBadSignature, got %v", err)
+	}
+}
+
+func TestEnvelopeVerifierRejectsStale(t *testing.T) {
+	for name, offset := range map[string]time.Duration{
+		"past":   -DefaultEnvelopeWindow - time.Second,
+		"future": DefaultEnvelopeWindow + time.Second,
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, v := newTestVerifier(t)
+			env := testEnvelope()
+			env.Timestamp = testNow.Add(offset).Unix()
+			env = sealEnvelope(t, c, env)
+			if _, err := v.Verify(marshalEnvelope(t, env)); !errors.Is(err, ErrStaleEnvelope) {
+				t.Fatalf("want ErrStaleEnvelope, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEnvelopeVerifierRejectsReplay(t *testing.T) {
+	c, v := newTestVerifier(t)
+	data := marshalEnvelope(t, sealEnvelope(t, c, testEnvelope()))
+
+	if _, err := v.Verify(data); err != nil {
+		t.Fatalf("first Verify: %v", err)
+	}
+	if _, err := v.Verify(data); !errors.Is(err, ErrReplayedEnvelope) {
+		t.Fatalf("want ErrReplayedEnvelope, got %v", err)
+	}
+}
+
+func TestEnvelopeVerifierForgetAllowsRedelivery(t *testing.T) {
+	c, v := newTestVerifier(t)
+	env := sealEnvelope(t, c, testEnvelope())
+	data := marshalEnvelope(t, env)
+
+	if _, err := v.Verify(data); err != nil {
+		t.Fatalf("first Verify: %v", err)
+	}
+	v.Replay.Forget(env.Idempotency)
+	if _, err := v.Verify(data); err != nil {
+		t.Fatalf("Verify after Forget: %v", err)
+	}
+}
+
+func TestEnvelopeVerifierBadSignatureDoesNotConsumeKey(t *testing.T) {
+	c, v := newTestVerifier(t)
+	env := sealEnvelope(t, c, testEnvelope())
+
+	forged := env
+	forged.Signature = make([]byte, ed25519.SignatureSize)
+	if _, err := v.Verify(marshalEnvelope(t, forged)); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("want ErrBadSignature, got %v", err)
+	}
+	if _, err := v.Verify(marshalEnvelope(t, env)); err != nil {
+		t.Fatalf("genuine envelope rejected after forgery: %v", err)
+	}
+}
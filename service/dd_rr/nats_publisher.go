@@ -32,6 +32,7 @@ type WithdrawalItem struct {
 type SecureWithdrawalEnvelope struct {
 	TransactionID uuid.UUID
 	Items         []WithdrawalItem
+	OTPEphemeral  []byte // sender's ephemeral X25519 public key
 	OTPNonce      []byte
 	OTPEncrypted  []byte
 	Timestamp     int64
@@ -74,7 +75,8 @@ func (t *Transaction) SendSecureWithdrawalViaNATS(
 		if err != nil {
 			return err
 		}
-		nonce, ciphertext, err := crypto.EncryptOTP(otp)
+		timestamp := time.Now().Unix()
+		epub, nonce, ciphertext, err := crypto.EncryptOTPWithPFS(otp, t.ID, timestamp)
 		if err != nil {
 			return err
 		}
@@ -83,14 +85,17 @@ func (t *Transaction) SendSecureWithdrawalViaNATS(
 		envelope := SecureWithdrawalEnvelope{
 			TransactionID: t.ID,
 			Items:         items,
+			OTPEphemeral:  epub,
 			OTPNonce:      nonce,
 			OTPEncrypted:  ciphertext,
-			Timestamp:     time.Now().Unix(),
+			Timestamp:     timestamp,
 			Idempotency:   idempotency,
 		}
-		// 4. Sign (msgpack without signature field)
-		tmp, _ := msgpack.Marshal(envelope)
-		signable := tmp[:len(tmp)-len(envelope.Signature)] // strip placeholder
+		// 4. Sign (canonical msgpack without signature field)
+		signable, err := envelope.SigningBytes()
+		if err != nil {
+			return err
+		}
 		envelope.Signature = crypto.Sign(signable)
 		// 5. Final msgpack payload
 		payload, err := msgpack.Marshal(envelope)
@@ -117,6 +122,7 @@ func (t *Transaction) SendSecureWithdrawalViaNATS(
 		t.Metadata = JSONMap{
 			"nats_stream":    ack.Stream,
 			"nats_seq":       ack.Sequence,
+			"otp_ephemeral":  base64.StdEncoding.EncodeToString(epub),
 			"otp_nonce":      base64.StdEncoding.EncodeToString(nonce),
 			"otp_encrypted":  base64.StdEncoding.EncodeToString(ciphertext),
 			"signature":      base64.StdEncoding.EncodeToString(envelope.Signature),
@@ -0,0 +1,250 @@
+package fees
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var (
+	ErrScheduleNotFound  = errors.New("fee schedule not found")
+	ErrDuplicateSchedule = errors.New("an enabled schedule already prices this operation for this bookie")
+	ErrAccountNotFound   = errors.New("account not found")
+)
+
+// bands turns KES tariff rows of {up to, fee} into cents
+func bands(rows [][2]int64) models.FeeBands {
+	out := make(models.FeeBands, len(rows))
+	for i, r := range rows {
+		out[i] = models.FeeBand{UpToCents: r[0] * 100, FeeCents: r[1] * 100}
+	}
+	return out
+}
+
+// DefaultSchedules are installed by EnsureDefaults on an empty table. They
+// pass on Safaricom's published customer tariffs: Pay Bill for STK deposits
+// and Send Money for B2C withdrawals. Keep them in step through the admin API
+// when the tariffs change; trades are free until a schedule is added.
+func DefaultSchedules() []models.FeeSchedule {
+	return []models.FeeSchedule{
+		{Name: "M-Pesa STK deposit tariff", Operation: models.FeeDeposit, Bands: bands([][2]int64{
+			{100, 0}, {500, 5}, {1000, 10}, {1500, 15}, {2500, 20}, {3500, 25}, {5000, 34},
+			{7500, 42}, {10000, 48}, {15000, 57}, {20000, 62}, {35000, 67}, {50000, 72}, {250000, 77},
+		})},
+		{Name: "M-Pesa B2C withdrawal tariff", Operation: models.FeeWithdraw, Bands: bands([][2]int64{
+			{100, 0}, {500, 7}, {1000, 13}, {1500, 23}, {2500, 33}, {3500, 53}, {5000, 57},
+			{7500, 78}, {10000, 90}, {15000, 100}, {20000, 105}, {250000, 108},
+		})},
+	}
+}
+
+// Service prices money movements and books the fees as revenue
+type Service struct {
+	db *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// EnsureDefaults installs DefaultSchedules when no schedules exist yet
+func (s *Service) EnsureDefaults() error {
+	var n int64
+	if err := s.db.Model(&models.FeeSchedule{}).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	schedules := DefaultSchedules()
+	for i := range schedules {
+		schedules[i].ID = uuid.New()
+		schedules[i].Enabled = true
+	}
+	return s.db.Create(&schedules).Error
+}
+
+// Quote prices a real-money op on grossCents through bookieID, which is
+// uuid.Nil when no bookie is involved. A bookie's own schedule wins over the
+// general one; with neither, nothing is charged.
+func (s *Service) Quote(op models.FeeOperation, bookieID uuid.UUID, grossCents int64) (models.FeeQuote, error) {
+	q := models.NoFee(op, grossCents)
+	var schedules []models.FeeSchedule
+	err := s.db.Where("operation = ? AND enabled = ? AND (bookie_id IS NULL OR bookie_id = ?)", op, true, bookieID).
+		Order("bookie_id IS NULL").Limit(1).Find(&schedules).Error
+	if err != nil || len(schedules) == 0 {
+		return q, err
+	}
+	sched := schedules[0]
+	fee, err := sched.Fee(grossCents)
+	if err != nil {
+		return q, err
+	}
+	if fee >= grossCents {
+		return q, models.ErrFeeExceedsAmount
+	}
+	q.ScheduleID, q.FeeCents, q.NetCents = &sched.ID, fee, grossCents-fee
+	return q, nil
+}
+
+// QuoteAccount prices op on one of the customer's accounts, through the
+// account's bookie if it has one. Practice money is never charged.
+func (s *Service) QuoteAccount(op models.FeeOperation, accountType string, accountID uuid.UUID, isReal bool, grossCents int64) (models.FeeQuote, error) {
+	if !isReal {
+		return models.NoFee(op, grossCents), nil
+	}
+	bookieID := uuid.Nil
+	if accountType != "sharp" {
+		var ids []uuid.UUID
+		err := s.db.Table(accountType+"_accounts").Where("id = ? AND deleted_at IS NULL", accountID).Limit(1).Pluck("bookie_id", &ids).Error
+		if err != nil {
+			return models.FeeQuote{}, err
+		}
+		if len(ids) == 0 {
+			return models.FeeQuote{}, ErrAccountNotFound
+		}
+		bookieID = ids[0]
+	}
+	return s.Quote(op, bookieID, grossCents)
+}
+
+// Post books the fee on t, which must already be written with q applied
+func Post(tx *gorm.DB, t *models.Transaction, q models.FeeQuote) error {
+	return models.RecordFee(tx, t, q.ScheduleID, q.FeeCents)
+}
+
+// Schedules returns every schedule, by operation then name
+func (s *Service) Schedules() ([]models.FeeSchedule, error) {
+	var out []models.FeeSchedule
+	err := s.db.Order("operation, name").Find(&out).Error
+	return out, err
+}
+
+// CreateSchedule adds a schedule on behalf of a staff member
+func (s *Service) CreateSchedule(actor backoffice.Actor, f models.FeeSchedule) (*models.FeeSchedule, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	f.ID = uuid.New()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkUnique(tx, &f); err != nil {
+			return err
+		}
+		if err := tx.Create(&f).Error; err != nil {
+			return err
+		}
+		return backoffice.Audit(tx, actor, "fees.create", "fee_schedule", f.ID, scheduleDetails(&f))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// UpdateSchedule replaces a schedule on behalf of a staff member
+func (s *Service) UpdateSchedule(actor backoffice.Actor, id uuid.UUID, f models.FeeSchedule) (*models.FeeSchedule, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	f.ID = id
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkUnique(tx, &f); err != nil {
+			return err
+		}
+		res := tx.Model(&models.FeeSchedule{}).Where("id = ?", id).Select(
+			"Name", "Operation", "BookieID", "FlatCents", "Bps", "Bands", "MinCents", "MaxCents", "Enabled",
+		).Updates(&f)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrScheduleNotFound
+		}
+		if err := tx.Take(&f, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return backoffice.Audit(tx, actor, "fees.update", "fee_schedule", id, scheduleDetails(&f))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// DeleteSchedule removes a schedule on behalf of a staff member. Revenue
+// already booked keeps its schedule ID.
+func (s *Service) DeleteSchedule(actor backoffice.Actor, id uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&models.FeeSchedule{}, "id = ?", id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrScheduleNotFound
+		}
+		return backoffice.Audit(tx, actor, "fees.delete", "fee_schedule", id, nil)
+	})
+}
+
+// RevenueTotal is the fee revenue booked on one kind of transaction
+type RevenueTotal struct {
+	TransactionType models.TransactionType `json:"transaction_type"`
+	Currency        string                 `json:"currency"`
+	Entries         int64                  `json:"entries"`
+	AmountCents     int64                  `json:"amount_cents"`
+}
+
+// Revenue totals fee revenue booked in [from, to), refunds included
+func (s *Service) Revenue(from, to time.Time) ([]RevenueTotal, error) {
+	var out []RevenueTotal
+	err := s.db.Model(&models.RevenueEntry{}).
+		Select("transaction_type, currency, COUNT(*) AS entries, SUM(amount_cents) AS amount_cents").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("transaction_type, currency").Order("transaction_type, currency").
+		Scan(&out).Error
+	return out, err
+}
+
+// checkUnique refuses a second enabled schedule for the same operation and
+// bookie, since Quote could not choose between them
+func checkUnique(tx *gorm.DB, f *models.FeeSchedule) error {
+	if !f.Enabled {
+		return nil
+	}
+	q := tx.Model(&models.FeeSchedule{}).Where("operation = ? AND enabled = ? AND id <> ?", f.Operation, true, f.ID)
+	if f.BookieID == nil {
+		q = q.Where("bookie_id IS NULL")
+	} else {
+		q = q.Where("bookie_id = ?", *f.BookieID)
+	}
+	var n int64
+	if err := q.Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrDuplicateSchedule
+	}
+	return nil
+}
+
+func scheduleDetails(f *models.FeeSchedule) models.JSONMap {
+	d := models.JSONMap{
+		"name":       f.Name,
+		"operation":  f.Operation,
+		"flat_cents": f.FlatCents,
+		"bps":        f.Bps,
+		"bands":      len(f.Bands),
+		"min_cents":  f.MinCents,
+		"max_cents":  f.MaxCents,
+		"enabled":    f.Enabled,
+	}
+	if f.BookieID != nil {
+		d["bookie_id"] = f.BookieID.String()
+	}
+	return d
+}
@@ -0,0 +1,187 @@
+package fees
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db := testdb.Open(t, &models.FeeSchedule{}, &models.RevenueEntry{}, &models.Transaction{}, &models.SportsAccount{}, &models.SharpAccount{}, &models.AuditLog{})
+	return NewService(db), db
+}
+
+var staff = backoffice.Actor{ID: uuid.New(), Role: models.RoleFinance}
+
+func TestScheduleFee(t *testing.T) {
+	f := models.FeeSchedule{Name: "mixed", Operation: models.FeeTrade, FlatCents: 100, Bps: 150, MinCents: 500, MaxCents: 15000,
+		Bands: models.FeeBands{{UpToCents: 100000, FeeCents: 0}, {UpToCents: 1000000, FeeCents: 1000}}}
+	if err := f.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for gross, want := range map[int64]int64{
+		10000:   500,   // 100 + 150 raised to the minimum
+		100000:  1600,  // 100 + 1500, first band free
+		100001:  2600,  // next band
+		1000000: 15000, // 100 + 15000 + 1000 capped
+	} {
+		if got, err := f.Fee(gross); err != nil || got != want {
+			t.Errorf("fee on %d = %d, %v; want %d", gross, got, err, want)
+		}
+	}
+	if _, err := f.Fee(1000001); !errors.Is(err, models.ErrOutsideTariff) {
+		t.Fatalf("above the top band: %v", err)
+	}
+
+	for name, bad := range map[string]models.FeeSchedule{
+		"unknown operation": {Name: "x", Operation: "bet"},
+		"over 100%":         {Name: "x", Operation: models.FeeTrade, Bps: 10001},
+		"min above max":     {Name: "x", Operation: models.FeeTrade, MinCents: 10, MaxCents: 5},
+		"bands descend":     {Name: "x", Operation: models.FeeTrade, Bands: models.FeeBands{{UpToCents: 10}, {UpToCents: 10}}},
+	} {
+		if err := bad.Validate(); !errors.Is(err, models.ErrInvalidFeeSchedule) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, d := range DefaultSchedules() {
+		if err := d.Validate(); err != nil {
+			t.Errorf("%s: %v", d.Name, err)
+		}
+	}
+}
+
+func TestQuotePrefersBookieSchedule(t *testing.T) {
+	s, db := newTestService(t)
+	if err := s.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	bookie, account := uuid.New(), uuid.New()
+	db.Exec("INSERT INTO sports_accounts (id, customer_id, bookie_id, manager_id) VALUES (?, ?, ?, ?)", account, uuid.New(), bookie, uuid.New())
+
+	// KES 1,200 falls in the STK tariff's KES 15 band.
+	q, err := s.QuoteAccount(models.FeeDeposit, "sports", account, true, 120000)
+	if err != nil || q.FeeCents != 1500 || q.NetCents != 118500 || q.ScheduleID == nil {
+		t.Fatalf("default quote = %+v, %v", q, err)
+	}
+	if q, _ := s.QuoteAccount(models.FeeDeposit, "sports", account, false, 120000); q.FeeCents != 0 || q.NetCents != 120000 {
+		t.Fatalf("practice quote = %+v", q)
+	}
+	if _, err := s.QuoteAccount(models.FeeDeposit, "sports", uuid.New(), true, 120000); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("unknown account: %v", err)
+	}
+	if q, err := s.Quote(models.FeeTrade, bookie, 120000); err != nil || q.FeeCents != 0 {
+		t.Fatalf("unpriced trade = %+v, %v", q, err)
+	}
+	if _, err := s.Quote(models.FeeWithdraw, bookie, 30000000); !errors.Is(err, models.ErrOutsideTariff) {
+		t.Fatalf("withdrawal above the tariff: %v", err)
+	}
+
+	own, err := s.CreateSchedule(staff, models.FeeSchedule{Name: "SportPesa deposits", Operation: models.FeeDeposit, BookieID: &bookie, Bps: 100, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, _ := s.QuoteAccount(models.FeeDeposit, "sports", account, true, 120000); q.FeeCents != 1200 || *q.ScheduleID != own.ID {
+		t.Fatalf("bookie quote = %+v", q)
+	}
+	if q, _ := s.Quote(models.FeeDeposit, uuid.New(), 120000); q.FeeCents != 1500 {
+		t.Fatalf("other bookie quote = %+v", q)
+	}
+	if _, err := s.Quote(models.FeeDeposit, bookie, 100); err != nil {
+		t.Fatalf("tiny deposit: %v", err)
+	}
+
+	if _, err := s.CreateSchedule(staff, models.FeeSchedule{Name: "again", Operation: models.FeeDeposit, BookieID: &bookie, Enabled: true}); !errors.Is(err, ErrDuplicateSchedule) {
+		t.Fatalf("second enabled schedule: %v", err)
+	}
+	disabled, err := s.CreateSchedule(staff, models.FeeSchedule{Name: "draft", Operation: models.FeeDeposit, BookieID: &bookie, FlatCents: 100000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateSchedule(staff, disabled.ID, *disabled); err != nil {
+		t.Fatal(err)
+	}
+	draft := *disabled
+	draft.Enabled = true
+	if _, err := s.UpdateSchedule(staff, disabled.ID, draft); !errors.Is(err, ErrDuplicateSchedule) {
+		t.Fatalf("enabling a clashing schedule: %v", err)
+	}
+	if err := s.DeleteSchedule(staff, own.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateSchedule(staff, disabled.ID, draft); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Quote(models.FeeDeposit, bookie, 100000); !errors.Is(err, models.ErrFeeExceedsAmount) {
+		t.Fatalf("fee above the amount: %v", err)
+	}
+	if err := s.DeleteSchedule(staff, own.ID); !errors.Is(err, ErrScheduleNotFound) {
+		t.Fatalf("delete twice: %v", err)
+	}
+}
+
+func TestFeesAreBookedAndRefunded(t *testing.T) {
+	s, db := newTestService(t)
+	if err := s.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	customer, account := uuid.New(), uuid.New()
+	db.Exec("INSERT INTO sports_accounts (id, customer_id, bookie_id, manager_id, real_balance_cents) VALUES (?, ?, ?, ?, 0)", account, customer, uuid.New(), uuid.New())
+
+	q, err := s.QuoteAccount(models.FeeDeposit, "sports", account, true, 500000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit := &models.Transaction{
+		ID: uuid.New(), CustomerID: customer, SportsAccountID: account, Type: models.TransactionTypeDeposit,
+		IsReal: true, Status: models.StatusSuccess, Reference: "DEP-1", IdempotencyKey: "dep-1",
+	}
+	q.Apply(deposit)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(deposit).Error; err != nil {
+			return err
+		}
+		if err := models.AdjustBalance(tx, "sports", account, true, deposit.NetAmountCents); err != nil {
+			return err
+		}
+		return Post(tx, deposit, q)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deposit.AmountCents != 500000 || deposit.FeeCents != 3400 || deposit.NetAmountCents != 496600 || deposit.Metadata["fee_schedule_id"] == nil {
+		t.Fatalf("deposit = %+v", deposit)
+	}
+
+	reversal, err := backoffice.NewService(db).Reverse(staff, deposit.ID, "chargeback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var balance int64
+	db.Table("sports_accounts").Where("id = ?", account).Pluck("real_balance_cents", &balance)
+	if balance != 0 {
+		t.Fatalf("balance after reversal = %d", balance)
+	}
+	var entries []models.RevenueEntry
+	db.Order("amount_cents DESC").Find(&entries)
+	if len(entries) != 2 || entries[0].TransactionID != deposit.ID || entries[0].AmountCents != 3400 ||
+		entries[1].TransactionID != reversal.ID || entries[1].AmountCents != -3400 {
+		t.Fatalf("revenue entries = %+v", entries)
+	}
+
+	db.Model(&models.RevenueEntry{}).Where("1 = 1").Update("created_at", testNow)
+	totals, err := s.Revenue(testNow.Add(-time.Hour), testNow.Add(time.Hour))
+	if err != nil || len(totals) != 2 || totals[0].TransactionType != models.TransactionTypeDeposit || totals[0].AmountCents != 3400 || totals[1].AmountCents != -3400 {
+		t.Fatalf("revenue = %+v, %v", totals, err)
+	}
+}
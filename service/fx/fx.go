@@ -0,0 +1,205 @@
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+)
+
+var (
+	ErrSameCurrency   = errors.New("cannot convert a currency into itself")
+	ErrStaleRate      = errors.New("exchange rate is too old to quote")
+	ErrAmountTooSmall = errors.New("amount is too small to convert")
+	ErrQuoteNotFound  = errors.New("fx quote not found")
+	ErrQuoteExpired   = errors.New("fx quote has expired")
+	ErrQuoteUsed      = errors.New("fx quote has already been executed")
+)
+
+// Config holds the spread charged on conversions and how long prices last
+type Config struct {
+	SpreadBps  int64         // taken off the mid rate, in hundredths of a percent
+	QuoteTTL   time.Duration // how long a quoted rate is held
+	MaxRateAge time.Duration // rates older than this are not quoted
+}
+
+func DefaultConfig() Config {
+	return Config{SpreadBps: 150, QuoteTTL: 30 * time.Second, MaxRateAge: 24 * time.Hour}
+}
+
+// Service converts money between the currencies an account holds at quoted
+// rates, keeping the spread as revenue
+type Service struct {
+	db    *gorm.DB
+	rates RateProvider
+	cfg   Config
+	now   func() time.Time
+}
+
+func NewService(db *gorm.DB, rates RateProvider, cfg Config) *Service {
+	return &Service{db: db, rates: rates, cfg: cfg, now: time.Now}
+}
+
+// Quote prices selling amountCents minor units of from for to on an account
+// and holds the price for the configured time
+func (s *Service) Quote(ref *models.AccountRef, isReal bool, from, to string, amountCents int64) (*models.FXQuote, error) {
+	from, err := models.NormalizeCurrency(from)
+	if err != nil {
+		return nil, err
+	}
+	to, err = models.NormalizeCurrency(to)
+	if err != nil {
+		return nil, err
+	}
+	if from == to {
+		return nil, ErrSameCurrency
+	}
+	if amountCents <= 0 {
+		return nil, ErrAmountTooSmall
+	}
+	rate, err := s.rates.Rate(from, to)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if now.Sub(rate.AsOf) > s.cfg.MaxRateAge {
+		return nil, ErrStaleRate
+	}
+	applied := new(big.Rat).Mul(rate.Mid, big.NewRat(10000-s.cfg.SpreadBps, 10000))
+	atMid, err := convert(amountCents, from, to, rate.Mid)
+	if err != nil {
+		return nil, err
+	}
+	counter, err := convert(amountCents, from, to, applied)
+	if err != nil {
+		return nil, err
+	}
+	if counter <= 0 {
+		return nil, ErrAmountTooSmall
+	}
+
+	q := &models.FXQuote{
+		ID:              uuid.New(),
+		CustomerID:      ref.CustomerID,
+		AccountType:     ref.Type,
+		AccountID:       ref.ID,
+		IsReal:          isReal,
+		Currency:        from,
+		AmountCents:     amountCents,
+		CounterCurrency: to,
+		CounterCents:    counter,
+		MidRate:         rateString(rate.Mid),
+		Rate:            rateString(applied),
+		SpreadBps:       s.cfg.SpreadBps,
+		SpreadCents:     atMid - counter,
+		RateAsOf:        rate.AsOf,
+		ExpiresAt:       now.Add(s.cfg.QuoteTTL),
+	}
+	if err := s.db.Create(q).Error; err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Convert executes a quote on the account it was made for: the sold amount
+// is debited, the bought amount credited and the spread booked as revenue,
+// all on one ledger row. isReal must match the quote, so callers gate real
+// money on what the request declares.
+func (s *Service) Convert(ref *models.AccountRef, isReal bool, quoteID uuid.UUID) (*models.Transaction, error) {
+	var t *models.Transaction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var q models.FXQuote
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND account_id = ? AND customer_id = ? AND is_real = ?", quoteID, ref.ID, ref.CustomerID, isReal).
+			Take(&q).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrQuoteNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if q.TransactionID != nil {
+			return ErrQuoteUsed
+		}
+		if !s.now().Before(q.ExpiresAt) {
+			return ErrQuoteExpired
+		}
+
+		if err := models.DebitCurrencyBalance(tx, q.AccountType, q.AccountID, q.Currency, q.IsReal, q.AmountCents); err != nil {
+			return err
+		}
+		if err := models.AdjustCurrencyBalance(tx, q.AccountType, q.AccountID, q.CounterCurrency, q.IsReal, q.CounterCents); err != nil {
+			return err
+		}
+		t = &models.Transaction{
+			ID:              uuid.New(),
+			CustomerID:      q.CustomerID,
+			Type:            models.TransactionTypeConvert,
+			AmountCents:     q.AmountCents,
+			IsReal:          q.IsReal,
+			Currency:        q.Currency,
+			CounterCurrency: q.CounterCurrency,
+			CounterCents:    q.CounterCents,
+			FXRate:          q.Rate,
+			FXSpreadCents:   q.SpreadCents,
+			Status:          models.StatusSuccess,
+			Reference:       fmt.Sprintf("FX-%s", uuid.New().String()[:8]),
+			IdempotencyKey:  "fx:" + q.ID.String(),
+			Metadata: models.JSONMap{
+				"fx_quote_id": q.ID.String(),
+				"mid_rate":    q.MidRate,
+				"spread_bps":  q.SpreadBps,
+			},
+		}
+		if err := t.SetAccount(q.AccountType, q.AccountID); err != nil {
+			return err
+		}
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		if q.IsReal {
+			if err := models.RecordRevenue(tx, t, nil, q.SpreadCents, q.CounterCurrency); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&q).Update("transaction_id", t.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// convert turns minor units of from into minor units of to at rate,
+// rounding down
+func convert(amountCents int64, from, to string, rate *big.Rat) (int64, error) {
+	fromScale, err := models.MinorScale(from)
+	if err != nil {
+		return 0, err
+	}
+	toScale, err := models.MinorScale(to)
+	if err != nil {
+		return 0, err
+	}
+	r := new(big.Rat).SetInt64(amountCents)
+	r.Mul(r, rate)
+	r.Mul(r, big.NewRat(toScale, fromScale))
+	out := new(big.Int).Quo(r.Num(), r.Denom())
+	if !out.IsInt64() {
+		return 0, models.ErrInvalidAmount
+	}
+	return out.Int64(), nil
+}
+
+// rateString writes a rate to eight decimal places without trailing zeros
+func rateString(r *big.Rat) string {
+	s := strings.TrimRight(r.FloatString(8), "0")
+	return strings.TrimSuffix(s, ".")
+}
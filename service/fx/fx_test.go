@@ -0,0 +1,183 @@
+package fx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func writeRates(t *testing.T, path, rates string, mtime time.Time) {
+	t.Helper()
+	doc := `{"as_of": "` + testNow.Format(time.RFC3339) + `", "rates": {` + rates + `}}`
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db := testdb.Open(t, &models.FXQuote{}, &models.AccountBalance{}, &models.Transaction{}, &models.ForexAccount{}, &models.RevenueEntry{}, &models.AuditLog{})
+
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `"USD/KES": "129.25", "KES/UGX": "28.6"`, testNow)
+	rates, err := NewFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(db, rates, DefaultConfig())
+	s.now = func() time.Time { return testNow }
+	return s, db
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `"usd/kes": "129.25"`, testNow)
+	src, err := NewFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := src.Rate("KES", "USD"); err != nil || r.Mid.FloatString(6) != "0.007737" || !r.AsOf.Equal(testNow) {
+		t.Fatalf("inverse rate = %+v, %v", r, err)
+	}
+	if _, err := src.Rate("EUR", "KES"); !errors.Is(err, ErrNoRate) {
+		t.Fatalf("missing pair: %v", err)
+	}
+
+	writeRates(t, path, `"USD/KES": "130"`, testNow.Add(time.Minute))
+	if r, _ := src.Rate("USD", "KES"); r.Mid.FloatString(2) != "130.00" {
+		t.Fatalf("rate after the file changed = %s", r.Mid.FloatString(2))
+	}
+	writeRates(t, path, `"USD/KES": "-1"`, testNow.Add(2*time.Minute))
+	if r, _ := src.Rate("USD", "KES"); r.Mid.FloatString(2) != "130.00" {
+		t.Fatalf("a bad file replaced the rates: %s", r.Mid.FloatString(2))
+	}
+}
+
+func TestQuoteUsesMinorUnits(t *testing.T) {
+	s, _ := newTestService(t)
+	ref := &models.AccountRef{ID: uuid.New(), CustomerID: uuid.New(), Type: "forex"}
+
+	// KES 1,292.50 is USD 10.00 at mid; the 1.5% spread leaves USD 9.85.
+	q, err := s.Quote(ref, true, "kes", "USD", 129250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.CounterCents != 985 || q.SpreadCents != 15 || q.Rate != "0.00762089" || !q.ExpiresAt.Equal(testNow.Add(30*time.Second)) {
+		t.Fatalf("quote = %+v", q)
+	}
+	// UGX has no minor units: KES 100.50 is UGX 2,874.30 at mid, 2,831.19 after spread.
+	if q, err := s.Quote(ref, true, "KES", "UGX", 10050); err != nil || q.CounterCents != 2831 || q.SpreadCents != 43 {
+		t.Fatalf("UGX quote = %+v, %v", q, err)
+	}
+
+	if _, err := s.Quote(ref, true, "KES", "KES", 100); !errors.Is(err, ErrSameCurrency) {
+		t.Fatalf("same currency: %v", err)
+	}
+	if _, err := s.Quote(ref, true, "KES", "XYZ", 100); !errors.Is(err, models.ErrUnknownCurrency) {
+		t.Fatalf("unknown currency: %v", err)
+	}
+	if _, err := s.Quote(ref, true, "KES", "EUR", 100); !errors.Is(err, ErrNoRate) {
+		t.Fatalf("no rate: %v", err)
+	}
+	if _, err := s.Quote(ref, true, "KES", "USD", 1); !errors.Is(err, ErrAmountTooSmall) {
+		t.Fatalf("a cent: %v", err)
+	}
+	s.now = func() time.Time { return testNow.Add(25 * time.Hour) }
+	if _, err := s.Quote(ref, true, "KES", "USD", 129250); !errors.Is(err, ErrStaleRate) {
+		t.Fatalf("stale rate: %v", err)
+	}
+}
+
+func TestConvertAndReverse(t *testing.T) {
+	s, db := newTestService(t)
+	ref := &models.AccountRef{ID: uuid.New(), CustomerID: uuid.New(), Type: "forex"}
+	db.Exec("INSERT INTO forex_accounts (id, customer_id, bookie_id, manager_id, currency, real_balance_cents, fake_balance_cents) VALUES (?, ?, ?, ?, 'KES', 1000000, 0)",
+		ref.ID, ref.CustomerID, uuid.New(), uuid.New())
+	balances := func() map[string]int64 {
+		list, err := models.CurrencyBalances(db, "forex", ref.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]int64{}
+		for _, b := range list {
+			if b.IsReal {
+				out[b.Currency] = b.AmountCents
+			}
+		}
+		return out
+	}
+
+	q, err := s.Quote(ref, true, "KES", "USD", 129250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &models.AccountRef{ID: uuid.New(), CustomerID: ref.CustomerID, Type: "forex"}
+	if _, err := s.Convert(other, true, q.ID); !errors.Is(err, ErrQuoteNotFound) {
+		t.Fatalf("another account's quote: %v", err)
+	}
+	if _, err := s.Convert(ref, false, q.ID); !errors.Is(err, ErrQuoteNotFound) {
+		t.Fatalf("real-money quote executed as practice: %v", err)
+	}
+	conv, err := s.Convert(ref, true, q.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.Type != models.TransactionTypeConvert || conv.Currency != "KES" || conv.AmountCents != 129250 ||
+		conv.CounterCurrency != "USD" || conv.CounterCents != 985 || conv.FXRate != q.Rate || conv.FXSpreadCents != 15 {
+		t.Fatalf("conversion = %+v", conv)
+	}
+	if b := balances(); b["KES"] != 870750 || b["USD"] != 985 {
+		t.Fatalf("balances after conversion = %v", b)
+	}
+	var spread models.RevenueEntry
+	if err := db.Take(&spread, "transaction_id = ?", conv.ID).Error; err != nil || spread.Currency != "USD" || spread.AmountCents != 15 {
+		t.Fatalf("spread revenue = %+v, %v", spread, err)
+	}
+	if _, err := s.Convert(ref, true, q.ID); !errors.Is(err, ErrQuoteUsed) {
+		t.Fatalf("second execution: %v", err)
+	}
+
+	// Back to shillings at the inverse rate: USD 5 is KES 646.25 at mid.
+	back, err := s.Quote(ref, true, "USD", "KES", 500)
+	if err != nil || back.CounterCents != 63655 {
+		t.Fatalf("inverse quote = %+v, %v", back, err)
+	}
+	s.now = func() time.Time { return testNow.Add(time.Minute) }
+	if _, err := s.Convert(ref, true, back.ID); !errors.Is(err, ErrQuoteExpired) {
+		t.Fatalf("expired quote: %v", err)
+	}
+	tooMuch, err := s.Quote(ref, true, "USD", "KES", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Convert(ref, true, tooMuch.ID); !errors.Is(err, models.ErrInsufficientFunds) {
+		t.Fatalf("converting more than is held: %v", err)
+	}
+
+	staff := backoffice.Actor{ID: uuid.New(), Role: models.RoleFinance}
+	if _, err := backoffice.NewService(db).Reverse(staff, conv.ID, "customer complaint"); err != nil {
+		t.Fatal(err)
+	}
+	if b := balances(); b["KES"] != 1000000 || b["USD"] != 0 {
+		t.Fatalf("balances after reversal = %v", b)
+	}
+	var net int64
+	db.Model(&models.RevenueEntry{}).Where("currency = ?", "USD").Select("SUM(amount_cents)").Scan(&net)
+	if net != 0 {
+		t.Fatalf("spread revenue after reversal = %d", net)
+	}
+}
@@ -0,0 +1,113 @@
+package fx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoRate = errors.New("no exchange rate for this currency pair")
+
+// Rate is the mid-market price of one unit of Base in Quote
+type Rate struct {
+	Base  string
+	Quote string
+	Mid   *big.Rat
+	AsOf  time.Time
+}
+
+// RateProvider supplies mid-market rates. Implementations must be safe for
+// concurrent use.
+type RateProvider interface {
+	Rate(base, quote string) (Rate, error)
+}
+
+// NoRates quotes nothing; it stands in until a rate source is configured
+type NoRates struct{}
+
+func (NoRates) Rate(base, quote string) (Rate, error) {
+	return Rate{}, fmt.Errorf("%w: %s/%s", ErrNoRate, base, quote)
+}
+
+// rateFile is the layout FileSource reads:
+//
+//	{"as_of": "2025-06-15T12:00:00Z", "rates": {"USD/KES": "129.25", "KES/UGX": "28.6"}}
+//
+// Rates are decimal strings so they are read exactly.
+type rateFile struct {
+	AsOf  time.Time         `json:"as_of"`
+	Rates map[string]string `json:"rates"`
+}
+
+// FileSource serves rates from a local JSON file, rereading it whenever it
+// changes. A pair is served in either direction; there are no cross rates.
+type FileSource struct {
+	path  string
+	mu    sync.Mutex
+	mtime time.Time
+	asOf  time.Time
+	rates map[[2]string]*big.Rat
+}
+
+// NewFileSource reads path, failing if it cannot be read or parsed
+func NewFileSource(path string) (*FileSource, error) {
+	f := &FileSource{path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Rate implements RateProvider. If the file has changed but no longer
+// parses, the rates last read stay in force.
+func (f *FileSource) Rate(base, quote string) (Rate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info, err := os.Stat(f.path); err == nil && !info.ModTime().Equal(f.mtime) {
+		_ = f.reloadLocked()
+	}
+	if mid, ok := f.rates[[2]string{base, quote}]; ok {
+		return Rate{Base: base, Quote: quote, Mid: new(big.Rat).Set(mid), AsOf: f.asOf}, nil
+	}
+	if mid, ok := f.rates[[2]string{quote, base}]; ok {
+		return Rate{Base: base, Quote: quote, Mid: new(big.Rat).Inv(mid), AsOf: f.asOf}, nil
+	}
+	return Rate{}, fmt.Errorf("%w: %s/%s", ErrNoRate, base, quote)
+}
+
+func (f *FileSource) reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reloadLocked()
+}
+
+func (f *FileSource) reloadLocked() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	var doc rateFile
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	rates := make(map[[2]string]*big.Rat, len(doc.Rates))
+	for pair, v := range doc.Rates {
+		base, quote, ok := strings.Cut(strings.ToUpper(pair), "/")
+		mid, valid := new(big.Rat).SetString(v)
+		if !ok || !valid || mid.Sign() <= 0 {
+			return fmt.Errorf("%s: bad rate %q: %q", f.path, pair, v)
+		}
+		rates[[2]string{base, quote}] = mid
+	}
+	f.mtime, f.asOf, f.rates = info.ModTime(), doc.AsOf, rates
+	return nil
+}
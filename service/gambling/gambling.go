@@ -0,0 +1,391 @@
+package gambling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+)
+
+var (
+	ErrInvalidLimit        = errors.New("invalid gambling limit")
+	ErrLimitNotFound       = errors.New("gambling limit not found")
+	ErrInvalidExclusion    = errors.New("invalid exclusion period")
+	ErrAlreadyExcluded     = errors.New("an existing exclusion already covers this period")
+	ErrInvalidRealityCheck = errors.New("invalid reality check interval")
+)
+
+// Publisher is the NATS subset used to hand SMS to the gateway (*nats.Conn satisfies it)
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+type Config struct {
+	IncreaseDelay    time.Duration // wait before a raised or removed limit applies
+	CoolOffMin       time.Duration
+	CoolOffMax       time.Duration
+	SelfExclusionMin time.Duration
+	SelfExclusionMax time.Duration
+	RealityCheckMin  time.Duration // shortest reminder interval a customer may pick
+	RealityCheckMax  time.Duration
+}
+
+// DefaultConfig follows the BCLB guidance: a day's wait before loosening a
+// limit, cool-offs of a day to six weeks and self-exclusion of six months to
+// five years
+func DefaultConfig() Config {
+	return Config{
+		IncreaseDelay:    24 * time.Hour,
+		CoolOffMin:       24 * time.Hour,
+		CoolOffMax:       42 * 24 * time.Hour,
+		SelfExclusionMin: 182 * 24 * time.Hour,
+		SelfExclusionMax: 5 * 365 * 24 * time.Hour,
+		RealityCheckMin:  15 * time.Minute,
+		RealityCheckMax:  4 * time.Hour,
+	}
+}
+
+// countedStatuses are ledger rows that moved money; failed and reversed ones
+// did not
+var countedStatuses = []models.TransactionStatus{models.StatusPending, models.StatusSuccess}
+
+// Service keeps customer-set deposit and loss limits, cool-offs,
+// self-exclusions and reality-check reminders
+type Service struct {
+	db  *gorm.DB
+	sms Publisher
+	cfg Config
+	now func() time.Time
+}
+
+func NewService(db *gorm.DB, sms Publisher, cfg Config) *Service {
+	return &Service{db: db, sms: sms, cfg: cfg, now: time.Now}
+}
+
+// Status is a customer's player-protection settings
+type Status struct {
+	Limits              []models.GamblingLimit    `json:"limits"`
+	Exclusion           *models.GamblingExclusion `json:"exclusion,omitempty"`
+	RealityCheckMinutes int                       `json:"reality_check_minutes"`
+}
+
+// Status returns the customer's limits, any exclusion in force and their
+// reality-check interval
+func (s *Service) Status(customerID uuid.UUID) (*Status, error) {
+	limits, err := s.limits(s.db, customerID)
+	if err != nil {
+		return nil, err
+	}
+	exclusion, err := s.activeExclusion(s.db, customerID)
+	if err != nil {
+		return nil, err
+	}
+	var rc models.RealityCheck
+	err = s.db.Where("customer_id = ?", customerID).Take(&rc).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &Status{Limits: limits, Exclusion: exclusion, RealityCheckMinutes: rc.IntervalMinutes}, nil
+}
+
+// limits loads the customer's limits, first applying any pending change
+// that has come due
+func (s *Service) limits(tx *gorm.DB, customerID uuid.UUID) ([]models.GamblingLimit, error) {
+	var rows []models.GamblingLimit
+	if err := tx.Where("customer_id = ?", customerID).Order("kind, period").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := rows[:0]
+	for i := range rows {
+		kept, err := s.settle(tx, &rows[i])
+		if err != nil {
+			return nil, err
+		}
+		if kept {
+			out = append(out, rows[i])
+		}
+	}
+	return out, nil
+}
+
+// settle applies l's pending change once due. It reports false when the
+// change removed the limit.
+func (s *Service) settle(tx *gorm.DB, l *models.GamblingLimit) (bool, error) {
+	if l.PendingAt == nil || l.PendingAt.After(s.now()) {
+		return true, nil
+	}
+	if *l.PendingCents == 0 {
+		return false, tx.Delete(&models.GamblingLimit{}, "id = ?", l.ID).Error
+	}
+	l.AmountCents, l.PendingCents, l.PendingAt = *l.PendingCents, nil, nil
+	return true, tx.Model(l).Select("AmountCents", "PendingCents", "PendingAt").Updates(l).Error
+}
+
+// SetLimit sets the customer's limit for kind and period. A lower amount, or
+// a first limit, applies at once; a higher amount, or 0 to remove the limit,
+// applies after Config.IncreaseDelay.
+func (s *Service) SetLimit(customerID uuid.UUID, kind models.GamblingLimitKind, period models.GamblingPeriod, amountCents int64) (*models.GamblingLimit, error) {
+	if (kind != models.GamblingLimitDeposit && kind != models.GamblingLimitLoss) || period.Window() == 0 || amountCents < 0 {
+		return nil, ErrInvalidLimit
+	}
+	var l models.GamblingLimit
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("customer_id = ? AND kind = ? AND period = ?", customerID, kind, period).Take(&l).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if amountCents == 0 {
+				return ErrLimitNotFound
+			}
+			l = models.GamblingLimit{ID: uuid.New(), CustomerID: customerID, Kind: kind, Period: period, AmountCents: amountCents}
+			return tx.Create(&l).Error
+		}
+		if err != nil {
+			return err
+		}
+		kept, err := s.settle(tx, &l)
+		if err != nil {
+			return err
+		}
+		if !kept {
+			if amountCents == 0 {
+				return ErrLimitNotFound
+			}
+			l = models.GamblingLimit{ID: uuid.New(), CustomerID: customerID, Kind: kind, Period: period, AmountCents: amountCents}
+			return tx.Create(&l).Error
+		}
+		if amountCents > 0 && amountCents <= l.AmountCents {
+			l.AmountCents, l.PendingCents, l.PendingAt = amountCents, nil, nil
+		} else {
+			at := s.now().Add(s.cfg.IncreaseDelay)
+			l.PendingCents, l.PendingAt = &amountCents, &at
+		}
+		return tx.Model(&l).Select("AmountCents", "PendingCents", "PendingAt").Updates(&l).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Exclude starts a cool-off or self-exclusion lasting d from now. Neither
+// can be shortened, so a request already covered by one in force is refused.
+func (s *Service) Exclude(customerID uuid.UUID, kind models.GamblingExclusionKind, d time.Duration, reason string) (*models.GamblingExclusion, error) {
+	lo, hi := s.cfg.CoolOffMin, s.cfg.CoolOffMax
+	switch kind {
+	case models.GamblingCoolOff:
+	case models.GamblingSelfExclusion:
+		lo, hi = s.cfg.SelfExclusionMin, s.cfg.SelfExclusionMax
+	default:
+		return nil, ErrInvalidExclusion
+	}
+	if d < lo || d > hi {
+		return nil, ErrInvalidExclusion
+	}
+	now := s.now()
+	e := models.GamblingExclusion{
+		ID:         uuid.New(),
+		CustomerID: customerID,
+		Kind:       kind,
+		StartsAt:   now,
+		EndsAt:     now.Add(d),
+		Reason:     truncate(reason, 500),
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		current, err := s.activeExclusion(tx, customerID)
+		if err != nil {
+			return err
+		}
+		if current != nil && !current.EndsAt.Before(e.EndsAt) {
+			return ErrAlreadyExcluded
+		}
+		return tx.Create(&e).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// activeExclusion returns the exclusion in force that ends last, or nil
+func (s *Service) activeExclusion(tx *gorm.DB, customerID uuid.UUID) (*models.GamblingExclusion, error) {
+	now := s.now()
+	var e models.GamblingExclusion
+	err := tx.Where("customer_id = ? AND starts_at <= ? AND ends_at > ?", customerID, now, now).
+		Order("ends_at DESC").Take(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// SetRealityCheck sets how often the customer is reminded of their real-money
+// play; 0 turns reminders off
+func (s *Service) SetRealityCheck(customerID uuid.UUID, minutes int) (*models.RealityCheck, error) {
+	d := time.Duration(minutes) * time.Minute
+	if minutes != 0 && (d < s.cfg.RealityCheckMin || d > s.cfg.RealityCheckMax) {
+		return nil, ErrInvalidRealityCheck
+	}
+	rc := models.RealityCheck{CustomerID: customerID, IntervalMinutes: minutes}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "customer_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"interval_minutes", "updated_at"}),
+	}).Create(&rc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rc, nil
+}
+
+// Check returns why req must be refused, or nil if it may proceed. An
+// exclusion blocks every deposit and real-money trades; deposit and loss
+// limits apply to real-money deposits. The ledger records stakes but not
+// winnings, so a loss is money deposited and not yet withdrawn.
+func (s *Service) Check(req models.LimitRequest) (*models.GamblingBlock, error) {
+	if req.Action != models.LimitDeposit && !(req.Action == models.LimitTrade && req.IsReal) {
+		return nil, nil
+	}
+	exclusion, err := s.activeExclusion(s.db, req.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if exclusion != nil {
+		reason := "you are on a cool-off break"
+		if exclusion.Kind == models.GamblingSelfExclusion {
+			reason = "you have self-excluded"
+		}
+		return &models.GamblingBlock{Reason: reason, Exclusion: exclusion.Kind, Until: &exclusion.EndsAt}, nil
+	}
+	if req.Action != models.LimitDeposit || !req.IsReal {
+		return nil, nil
+	}
+	limits, err := s.limits(s.db, req.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range limits {
+		used, err := s.usage(req.CustomerID, l.Kind, l.Period)
+		if err != nil {
+			return nil, err
+		}
+		if used+req.AmountCents > l.AmountCents {
+			return &models.GamblingBlock{
+				Reason:         fmt.Sprintf("amount would exceed your %s %s limit; %d cents remaining", l.Period, l.Kind, max(l.AmountCents-used, 0)),
+				Limit:          l.Kind,
+				Period:         l.Period,
+				LimitCents:     l.AmountCents,
+				UsedCents:      used,
+				AttemptedCents: req.AmountCents,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// usage is what counts against a limit of kind over period
+func (s *Service) usage(customerID uuid.UUID, kind models.GamblingLimitKind, period models.GamblingPeriod) (int64, error) {
+	var row struct {
+		Deposits    int64
+		Withdrawals int64
+	}
+	err := s.db.Model(&models.Transaction{}).
+		Where("customer_id = ? AND is_real = ? AND status IN ? AND created_at >= ?",
+			customerID, true, countedStatuses, s.now().Add(-period.Window())).
+		Select("COALESCE(SUM(CASE WHEN type = ? THEN amount_cents ELSE 0 END), 0) AS deposits, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN amount_cents ELSE 0 END), 0) AS withdrawals",
+			models.TransactionTypeDeposit, models.TransactionTypeWithdraw).
+		Scan(&row).Error
+	if err != nil {
+		return 0, err
+	}
+	if kind == models.GamblingLimitLoss {
+		return max(row.Deposits-row.Withdrawals, 0), nil
+	}
+	return row.Deposits, nil
+}
+
+// SendRealityChecks texts every customer who traded with real money since
+// their last reminder and whose interval has passed. It returns how many
+// reminders were sent.
+func (s *Service) SendRealityChecks() (int, error) {
+	var checks []models.RealityCheck
+	if err := s.db.Where("interval_minutes > 0").Find(&checks).Error; err != nil {
+		return 0, err
+	}
+	now := s.now()
+	sent := 0
+	for _, rc := range checks {
+		interval := time.Duration(rc.IntervalMinutes) * time.Minute
+		since := now.Add(-interval)
+		if rc.LastSentAt != nil && rc.LastSentAt.After(since) {
+			continue
+		}
+		var play struct {
+			Trades int64
+			Staked int64
+		}
+		err := s.db.Model(&models.Transaction{}).
+			Where("customer_id = ? AND type = ? AND is_real = ? AND status IN ? AND created_at >= ?",
+				rc.CustomerID, models.TransactionTypeTrade, true, countedStatuses, since).
+			Select("COUNT(*) AS trades, COALESCE(SUM(amount_cents), 0) AS staked").
+			Scan(&play).Error
+		if err != nil {
+			return sent, err
+		}
+		if play.Trades == 0 {
+			continue
+		}
+		var phone string
+		if err := s.db.Model(&models.Customer{}).Where("id = ?", rc.CustomerID).Select("phone").Scan(&phone).Error; err != nil {
+			return sent, err
+		}
+		msg, err := json.Marshal(map[string]string{
+			"to": phone,
+			"msg": fmt.Sprintf("BankRoll reality check: in the last %d min you placed %d real-money trades totalling KES %d.%02d. Take a break or set limits in the app.",
+				rc.IntervalMinutes, play.Trades, play.Staked/100, play.Staked%100),
+		})
+		if err != nil {
+			return sent, err
+		}
+		if err := s.sms.Publish("sms.send", msg); err != nil {
+			return sent, err
+		}
+		if err := s.db.Model(&models.RealityCheck{}).Where("customer_id = ?", rc.CustomerID).Update("last_sent_at", now).Error; err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Run sends due reality checks every interval
+func (s *Service) Run(ctx context.Context, interval time.Duration, logf func(string, ...interface{})) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.SendRealityChecks(); err != nil {
+				logf("gambling: reality checks: %v", err)
+			}
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,207 @@
+package gambling
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+type sentSMS []map[string]string
+
+func (s *sentSMS) Publish(subject string, data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	m["subject"] = subject
+	*s = append(*s, m)
+	return nil
+}
+
+func newTestService(t *testing.T) (*Service, *gorm.DB, *sentSMS) {
+	t.Helper()
+	db := testdb.Open(t, &models.GamblingLimit{}, &models.GamblingExclusion{}, &models.RealityCheck{}, &models.Transaction{}, &models.Customer{})
+	sms := &sentSMS{}
+	s := NewService(db, sms, DefaultConfig())
+	s.now = func() time.Time { return testNow }
+	return s, db, sms
+}
+
+// ledger writes a successful real-money transaction at the given age
+func ledger(t *testing.T, db *gorm.DB, customer uuid.UUID, typ models.TransactionType, amount int64, age time.Duration) {
+	t.Helper()
+	tx := models.Transaction{
+		ID:              uuid.New(),
+		CustomerID:      customer,
+		SportsAccountID: uuid.New(),
+		Type:            typ,
+		AmountCents:     amount,
+		IsReal:          true,
+		Status:          models.StatusSuccess,
+		Reference:       uuid.NewString()[:8],
+		IdempotencyKey:  uuid.NewString(),
+	}
+	tx.CreatedAt = testNow.Add(-age)
+	if err := db.Omit(clause.Associations).Create(&tx).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func deposit(customer uuid.UUID, amount int64) models.LimitRequest {
+	return models.LimitRequest{CustomerID: customer, Action: models.LimitDeposit, IsReal: true, AmountCents: amount}
+}
+
+func TestLimitChangesAreDelayedWhenLoosened(t *testing.T) {
+	s, db, _ := newTestService(t)
+	customer := uuid.New()
+	ledger(t, db, customer, models.TransactionTypeDeposit, 40000, 2*time.Hour)
+	ledger(t, db, customer, models.TransactionTypeDeposit, 90000, 8*24*time.Hour) // outside the week
+
+	if _, err := s.SetLimit(customer, models.GamblingLimitDeposit, models.GamblingWeekly, 100000); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := s.Check(deposit(customer, 60000)); err != nil || b != nil {
+		t.Fatalf("deposit up to the limit: %+v, %v", b, err)
+	}
+	b, err := s.Check(deposit(customer, 60001))
+	if err != nil || b == nil || b.UsedCents != 40000 || b.LimitCents != 100000 {
+		t.Fatalf("deposit over the limit: %+v, %v", b, err)
+	}
+	if b, _ := s.Check(models.LimitRequest{CustomerID: customer, Action: models.LimitDeposit, AmountCents: 500000}); b != nil {
+		t.Fatalf("practice deposit counted against a real-money limit: %+v", b)
+	}
+
+	// Raising waits a day; lowering is immediate and cancels the raise.
+	l, err := s.SetLimit(customer, models.GamblingLimitDeposit, models.GamblingWeekly, 500000)
+	if err != nil || l.AmountCents != 100000 || *l.PendingCents != 500000 || !l.PendingAt.Equal(testNow.Add(24*time.Hour)) {
+		t.Fatalf("raise = %+v, %v", l, err)
+	}
+	if b, _ := s.Check(deposit(customer, 60001)); b == nil {
+		t.Fatal("raise applied before the delay")
+	}
+	s.now = func() time.Time { return testNow.Add(25 * time.Hour) }
+	if b, _ := s.Check(deposit(customer, 60001)); b != nil {
+		t.Fatalf("raise not applied after the delay: %+v", b)
+	}
+	l, err = s.SetLimit(customer, models.GamblingLimitDeposit, models.GamblingWeekly, 50000)
+	if err != nil || l.AmountCents != 50000 || l.PendingAt != nil {
+		t.Fatalf("lower = %+v, %v", l, err)
+	}
+
+	// Removing is a loosening too.
+	if l, err = s.SetLimit(customer, models.GamblingLimitDeposit, models.GamblingWeekly, 0); err != nil || l.PendingAt == nil {
+		t.Fatalf("remove = %+v, %v", l, err)
+	}
+	s.now = func() time.Time { return testNow.Add(50 * time.Hour) }
+	st, err := s.Status(customer)
+	if err != nil || len(st.Limits) != 0 {
+		t.Fatalf("status after removal = %+v, %v", st, err)
+	}
+	if _, err := s.SetLimit(customer, models.GamblingLimitDeposit, models.GamblingWeekly, 0); !errors.Is(err, ErrLimitNotFound) {
+		t.Fatalf("removing a missing limit: %v", err)
+	}
+	if _, err := s.SetLimit(customer, "bets", models.GamblingDaily, 100); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("unknown kind: %v", err)
+	}
+}
+
+func TestLossLimitNetsWithdrawals(t *testing.T) {
+	s, db, _ := newTestService(t)
+	customer := uuid.New()
+	ledger(t, db, customer, models.TransactionTypeDeposit, 80000, time.Hour)
+	ledger(t, db, customer, models.TransactionTypeWithdraw, 50000, time.Hour)
+	if _, err := s.SetLimit(customer, models.GamblingLimitLoss, models.GamblingDaily, 50000); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := s.Check(deposit(customer, 20000)); b != nil {
+		t.Fatalf("net loss 30000 + 20000 refused: %+v", b)
+	}
+	if b, _ := s.Check(deposit(customer, 20001)); b == nil || b.Limit != models.GamblingLimitLoss || b.UsedCents != 30000 {
+		t.Fatalf("loss limit block = %+v", b)
+	}
+}
+
+func TestExclusionBlocksDepositsAndRealTrades(t *testing.T) {
+	s, _, _ := newTestService(t)
+	customer := uuid.New()
+	realTrade := models.LimitRequest{CustomerID: customer, Action: models.LimitTrade, IsReal: true, AmountCents: 100}
+	practiceTrade := models.LimitRequest{CustomerID: customer, Action: models.LimitTrade, AmountCents: 100}
+
+	if _, err := s.Exclude(customer, models.GamblingCoolOff, time.Hour, ""); !errors.Is(err, ErrInvalidExclusion) {
+		t.Fatalf("one-hour cool-off: %v", err)
+	}
+	e, err := s.Exclude(customer, models.GamblingCoolOff, 7*24*time.Hour, "taking a week off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := s.Check(realTrade); b == nil || b.Exclusion != models.GamblingCoolOff || !b.Until.Equal(e.EndsAt) {
+		t.Fatalf("real trade during cool-off = %+v", b)
+	}
+	if b, _ := s.Check(deposit(customer, 100)); b == nil {
+		t.Fatal("deposit allowed during cool-off")
+	}
+	if b, _ := s.Check(practiceTrade); b != nil {
+		t.Fatalf("practice trade blocked: %+v", b)
+	}
+	if b, _ := s.Check(models.LimitRequest{CustomerID: customer, Action: models.LimitWithdraw, IsReal: true, AmountCents: 100}); b != nil {
+		t.Fatalf("withdrawal blocked: %+v", b)
+	}
+
+	// A shorter break cannot cut the current one short; self-exclusion extends it.
+	if _, err := s.Exclude(customer, models.GamblingCoolOff, 2*24*time.Hour, ""); !errors.Is(err, ErrAlreadyExcluded) {
+		t.Fatalf("shorter cool-off: %v", err)
+	}
+	if _, err := s.Exclude(customer, models.GamblingSelfExclusion, 365*24*time.Hour, ""); err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return testNow.Add(30 * 24 * time.Hour) }
+	if b, _ := s.Check(realTrade); b == nil || b.Exclusion != models.GamblingSelfExclusion {
+		t.Fatalf("after the cool-off ended = %+v", b)
+	}
+	s.now = func() time.Time { return testNow.Add(366 * 24 * time.Hour) }
+	if b, _ := s.Check(realTrade); b != nil {
+		t.Fatalf("after self-exclusion ended = %+v", b)
+	}
+}
+
+func TestRealityChecks(t *testing.T) {
+	s, db, sms := newTestService(t)
+	playing, idle := uuid.New(), uuid.New()
+	db.Exec("INSERT INTO customers (id, name, email, phone) VALUES (?, 'Playing', 'playing@example.com', ?), (?, 'Idle', 'idle@example.com', ?)",
+		playing, "+254712000001", idle, "+254712000002")
+	for _, c := range []uuid.UUID{playing, idle} {
+		if _, err := s.SetRealityCheck(c, 30); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.SetRealityCheck(playing, 5); !errors.Is(err, ErrInvalidRealityCheck) {
+		t.Fatalf("five-minute interval: %v", err)
+	}
+	ledger(t, db, playing, models.TransactionTypeTrade, 25050, 10*time.Minute)
+	ledger(t, db, playing, models.TransactionTypeTrade, 10000, 20*time.Minute)
+	ledger(t, db, idle, models.TransactionTypeTrade, 10000, 2*time.Hour)
+
+	sent, err := s.SendRealityChecks()
+	if err != nil || sent != 1 || len(*sms) != 1 {
+		t.Fatalf("sent %d, %v: %+v", sent, err, *sms)
+	}
+	msg := (*sms)[0]
+	if msg["to"] != "+254712000001" || !strings.Contains(msg["msg"], "2 real-money trades totalling KES 350.50") {
+		t.Fatalf("message = %+v", msg)
+	}
+	// Not again until the interval has passed.
+	if sent, _ := s.SendRealityChecks(); sent != 0 {
+		t.Fatalf("sent %d within the interval", sent)
+	}
+}
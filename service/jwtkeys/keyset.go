@@ -0,0 +1,383 @@
+package jwtkeys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms. HS256 exists for local development only.
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+	AlgHS256 = "HS256"
+)
+
+// DefaultDevSecret is the historical JWT_SECRET default; it is refused outside dev
+const DefaultDevSecret = "your-secret-key"
+
+var ErrNoSigningKey = errors.New("no jwt signing key available")
+
+type Config struct {
+	Alg           string        // EdDSA (default), RS256, or HS256 (dev only)
+	Dir           string        // PEM files, one per key
+	RotateEvery   time.Duration // age at which a new key is minted
+	ActivateAfter time.Duration // new keys are published this long before they sign
+	VerifyFor     time.Duration // retired keys stay verifiable this long (>= access token TTL)
+	HMACSecret    []byte        // HS256 only
+}
+
+// DefaultConfig returns production rollover timings for alg keys stored in dir
+func DefaultConfig(alg, dir string) Config {
+	return Config{
+		Alg:           alg,
+		Dir:           dir,
+		RotateEvery:   30 * 24 * time.Hour,
+		ActivateAfter: 10 * time.Minute,
+		VerifyFor:     24 * time.Hour,
+	}
+}
+
+// Key is one signing key version
+type Key struct {
+	ID      string
+	Alg     string
+	Created time.Time
+	private crypto.PrivateKey // ed25519.PrivateKey, *rsa.PrivateKey or []byte
+	public  crypto.PublicKey  // ed25519.PublicKey, *rsa.PublicKey or []byte
+}
+
+// KeySet holds every key that may still verify tokens and picks the one that signs
+type KeySet struct {
+	mu   sync.RWMutex
+	cfg  Config
+	keys []*Key // newest first
+	now  func() time.Time
+}
+
+// Open loads the key directory, minting the first key if none exist
+func Open(cfg Config) (*KeySet, error) {
+	ks := &KeySet{cfg: cfg, now: time.Now}
+	switch cfg.Alg {
+	case AlgHS256:
+		if len(cfg.HMACSecret) == 0 {
+			return nil, errors.New("HS256 requires a secret")
+		}
+		ks.keys = []*Key{{ID: "hs256-dev", Alg: AlgHS256, private: cfg.HMACSecret, public: cfg.HMACSecret}}
+		return ks, nil
+	case AlgEdDSA, AlgRS256:
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", cfg.Alg)
+	}
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	if _, err := ks.MaybeRotate(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload re-reads the key directory so instances sharing it converge
+func (ks *KeySet) Reload() error {
+	if ks.cfg.Alg == AlgHS256 {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(ks.cfg.Dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	keys := make([]*Key, 0, len(paths))
+	for _, p := range paths {
+		k, err := readKey(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.After(keys[j].Created) })
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+// Rotate mints and publishes a new key; it starts signing after ActivateAfter
+func (ks *KeySet) Rotate() (string, error) {
+	if ks.cfg.Alg == AlgHS256 {
+		return "", errors.New("HS256 keys do not rotate")
+	}
+	k, err := generateKey(ks.cfg.Alg, ks.now())
+	if err != nil {
+		return "", err
+	}
+	if err := writeKey(filepath.Join(ks.cfg.Dir, k.ID+".pem"), k); err != nil {
+		return "", err
+	}
+	ks.mu.Lock()
+	ks.keys = append([]*Key{k}, ks.keys...)
+	ks.mu.Unlock()
+	return k.ID, nil
+}
+
+// MaybeRotate mints a key when there is none or the newest is older than RotateEvery
+func (ks *KeySet) MaybeRotate() (bool, error) {
+	if ks.cfg.Alg == AlgHS256 {
+		return false, nil
+	}
+	ks.mu.RLock()
+	due := len(ks.keys) == 0 || ks.now().Sub(ks.keys[0].Created) >= ks.cfg.RotateEvery
+	ks.mu.RUnlock()
+	if !due {
+		return false, nil
+	}
+	_, err := ks.Rotate()
+	return err == nil, err
+}
+
+// Prune deletes keys that stopped signing more than VerifyFor ago
+func (ks *KeySet) Prune() error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	signer := ks.signingIndex()
+	if signer < 0 {
+		return nil
+	}
+	cutoff := ks.now().Add(-ks.cfg.VerifyFor)
+	kept := append([]*Key(nil), ks.keys[:signer+1]...)
+	for i := signer + 1; i < len(ks.keys); i++ {
+		// key i signed until key i-1 took over
+		if retiredAt := ks.keys[i-1].Created.Add(ks.cfg.ActivateAfter); retiredAt.After(cutoff) {
+			kept = append(kept, ks.keys[i])
+			continue
+		}
+		if err := os.Remove(filepath.Join(ks.cfg.Dir, ks.keys[i].ID+".pem")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	ks.keys = kept
+	return nil
+}
+
+// Run reloads, rotates and prunes every interval until ctx is done
+func (ks *KeySet) Run(ctx context.Context, interval time.Duration, logf func(string, ...interface{})) {
+	if ks.cfg.Alg == AlgHS256 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := ks.Reload(); err != nil {
+			logf("jwt key reload failed: %v", err)
+			continue
+		}
+		if rotated, err := ks.MaybeRotate(); err != nil {
+			logf("jwt key rotation failed: %v", err)
+		} else if rotated {
+			logf("jwt signing key rotated")
+		}
+		if err := ks.Prune(); err != nil {
+			logf("jwt key prune failed: %v", err)
+		}
+	}
+}
+
+// SigningKey returns the key that signs new tokens: the newest key past its
+// activation delay, or the newest key if none is yet.
+func (ks *KeySet) SigningKey() (kid string, method jwt.SigningMethod, key crypto.PrivateKey, err error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	i := ks.signingIndex()
+	if i < 0 {
+		return "", nil, nil, ErrNoSigningKey
+	}
+	k := ks.keys[i]
+	return k.ID, jwt.GetSigningMethod(k.Alg), k.private, nil
+}
+
+// VerificationKey implements middleware.KeyResolver
+func (ks *KeySet) VerificationKey(kid string) (alg string, key crypto.PublicKey, ok bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, k := range ks.keys {
+		if k.ID == kid {
+			return k.Alg, k.public, true
+		}
+	}
+	return "", nil, false
+}
+
+func (ks *KeySet) signingIndex() int {
+	if len(ks.keys) == 0 {
+		return -1
+	}
+	now := ks.now()
+	for i, k := range ks.keys {
+		if !now.Before(k.Created.Add(ks.cfg.ActivateAfter)) {
+			return i
+		}
+	}
+	return 0
+}
+
+// ------------------------------------------------------------
+// JWKS (RFC 7517)
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists every public verification key; HMAC keys are never published
+func (ks *KeySet) JWKS() JWKSet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Alg}
+		switch pub := k.public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", b64(pub)
+		case *rsa.PublicKey:
+			jwk.Kty, jwk.N = "RSA", b64(pub.N.Bytes())
+			jwk.E = b64(bigEndian(pub.E))
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// ------------------------------------------------------------
+// key files: PKCS#8 PEM with a Created header
+
+func generateKey(alg string, created time.Time) (*Key, error) {
+	var priv crypto.PrivateKey
+	var pub crypto.PublicKey
+	switch alg {
+	case AlgEdDSA:
+		p, s, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		priv, pub = s, p
+	case AlgRS256:
+		s, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		priv, pub = s, &s.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", alg)
+	}
+	kid, err := keyID(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{ID: kid, Alg: alg, Created: created.UTC().Truncate(time.Second), private: priv, public: pub}, nil
+}
+
+func writeKey(path string, k *Key) error {
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return err
+	}
+	block := &pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{"Created": k.Created.Format(time.RFC3339)},
+		Bytes:   der,
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(block), 0600)
+}
+
+func readKey(path string) (*Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("not a PKCS#8 PEM key")
+	}
+	created, err := time.Parse(time.RFC3339, block.Headers["Created"])
+	if err != nil {
+		return nil, fmt.Errorf("bad Created header: %w", err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	k := &Key{Created: created}
+	switch s := parsed.(type) {
+	case ed25519.PrivateKey:
+		k.Alg, k.private, k.public = AlgEdDSA, s, s.Public()
+	case *rsa.PrivateKey:
+		k.Alg, k.private, k.public = AlgRS256, s, &s.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	if k.ID, err = keyID(k.public); err != nil {
+		return nil, err
+	}
+	if name := strings.TrimSuffix(filepath.Base(path), ".pem"); name != k.ID {
+		return nil, fmt.Errorf("file name %q does not match kid %q", name, k.ID)
+	}
+	return k, nil
+}
+
+// keyID is the first 16 base64url chars of SHA-256 over the SPKI encoding
+func keyID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return b64(sum[:])[:16], nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func bigEndian(n int) []byte {
+	var out []byte
+	for ; n > 0; n >>= 8 {
+		out = append([]byte{byte(n)}, out...)
+	}
+	return out
+}
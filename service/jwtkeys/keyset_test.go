@@ -0,0 +1,163 @@
+package jwtkeys
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func openAt(t *testing.T, cfg Config, now *time.Time) *KeySet {
+	t.Helper()
+	ks := &KeySet{cfg: cfg, now: func() time.Time { return *now }}
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.MaybeRotate(); err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func sign(t *testing.T, ks *KeySet) string {
+	t.Helper()
+	kid, method, key, err := ks.SigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{Subject: "c1"})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func verify(ks *KeySet, signed string) error {
+	_, err := jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		alg, key, ok := ks.VerificationKey(kid)
+		if !ok || alg != token.Method.Alg() {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+		return key, nil
+	})
+	return err
+}
+
+func TestRolloverKeepsOldTokensValid(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	cfg := DefaultConfig(AlgEdDSA, t.TempDir())
+	ks := openAt(t, cfg, &now)
+
+	firstKid, _, _, _ := ks.SigningKey()
+	oldToken := sign(t, ks)
+
+	now = now.Add(cfg.RotateEvery)
+	if rotated, err := ks.MaybeRotate(); err != nil || !rotated {
+		t.Fatalf("MaybeRotate = %v, %v", rotated, err)
+	}
+	if kid, _, _, _ := ks.SigningKey(); kid != firstKid {
+		t.Fatal("new key signed before its activation delay")
+	}
+	if len(ks.JWKS().Keys) != 2 {
+		t.Fatal("new key not published ahead of activation")
+	}
+
+	now = now.Add(cfg.ActivateAfter)
+	secondKid, _, _, _ := ks.SigningKey()
+	if secondKid == firstKid {
+		t.Fatal("new key not active after delay")
+	}
+	if err := verify(ks, oldToken); err != nil {
+		t.Fatalf("token from previous key rejected: %v", err)
+	}
+	if err := verify(ks, sign(t, ks)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance sharing the directory sees both keys.
+	peer := openAt(t, cfg, &now)
+	if err := verify(peer, oldToken); err != nil {
+		t.Fatalf("peer rejected old token: %v", err)
+	}
+
+	if err := ks.Prune(); err != nil || len(ks.JWKS().Keys) != 2 {
+		t.Fatalf("pruned too early: %v", err)
+	}
+	now = now.Add(cfg.VerifyFor)
+	if err := ks.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := ks.VerificationKey(firstKid); ok {
+		t.Fatal("retired key still verifiable after VerifyFor")
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Dir, firstKid+".pem")); !os.IsNotExist(err) {
+		t.Fatalf("retired key file not removed: %v", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	for _, alg := range []string{AlgEdDSA, AlgRS256} {
+		t.Run(alg, func(t *testing.T) {
+			ks := openAt(t, DefaultConfig(alg, t.TempDir()), &now)
+			set := ks.JWKS()
+			if len(set.Keys) != 1 {
+				t.Fatalf("keys = %+v", set.Keys)
+			}
+			k := set.Keys[0]
+			kid, _, _, _ := ks.SigningKey()
+			if k.Kid != kid || k.Alg != alg || k.Use != "sig" {
+				t.Fatalf("jwk = %+v", k)
+			}
+			switch alg {
+			case AlgEdDSA:
+				x, err := base64.RawURLEncoding.DecodeString(k.X)
+				if k.Kty != "OKP" || k.Crv != "Ed25519" || err != nil || len(x) != 32 {
+					t.Fatalf("bad OKP jwk %+v", k)
+				}
+			case AlgRS256:
+				if k.Kty != "RSA" || k.E != "AQAB" || len(k.N) < 340 {
+					t.Fatalf("bad RSA jwk %+v", k)
+				}
+			}
+			if err := verify(ks, sign(t, ks)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestHS256IsNeverPublished(t *testing.T) {
+	ks, err := Open(Config{Alg: AlgHS256, HMACSecret: []byte("dev-secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ks.JWKS().Keys); n != 0 {
+		t.Fatalf("HMAC key published in JWKS (%d keys)", n)
+	}
+	if err := verify(ks, sign(t, ks)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadKeyRejectsMismatchedName(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	dir := t.TempDir()
+	ks := openAt(t, DefaultConfig(AlgEdDSA, dir), &now)
+	kid, _, _, _ := ks.SigningKey()
+	if err := os.Rename(filepath.Join(dir, kid+".pem"), filepath.Join(dir, "other.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Reload(); err == nil {
+		t.Fatal("expected error for renamed key file")
+	}
+}
@@ -0,0 +1,116 @@
+package keymgmt
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ciphertextVersion prefixes every value produced by Envelope.Encrypt
+const ciphertextVersion = "v1"
+
+var ErrMalformedCiphertext = errors.New("malformed envelope ciphertext")
+
+// Envelope does envelope encryption: each value gets a fresh data key,
+// which is wrapped by the provider's current KEK.
+//
+// Ciphertext format: v1:<kek id>:<base64 wrapped data key>:<base64 nonce||ciphertext>
+type Envelope struct {
+	provider KeyProvider
+}
+
+func NewEnvelope(p KeyProvider) *Envelope {
+	return &Envelope{provider: p}
+}
+
+// Provider returns the underlying key provider
+func (e *Envelope) Provider() KeyProvider {
+	return e.provider
+}
+
+// Encrypt seals plaintext under the current key; ad is authenticated but not stored
+func (e *Envelope) Encrypt(plaintext, ad []byte) (string, error) {
+	keyID, err := e.provider.CurrentKeyID()
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := newKey()
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := e.provider.WrapKey(keyID, dataKey)
+	if err != nil {
+		return "", err
+	}
+	body, err := seal(dataKey, plaintext, ad)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		ciphertextVersion,
+		keyID,
+		base64.StdEncoding.EncodeToString(wrapped),
+		base64.StdEncoding.EncodeToString(body),
+	}, ":"), nil
+}
+
+// Decrypt opens a ciphertext produced under any key version the provider still holds
+func (e *Envelope) Decrypt(ciphertext string, ad []byte) ([]byte, error) {
+	keyID, wrapped, body, err := parse(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := e.provider.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, body, ad)
+}
+
+// NeedsRotation reports whether ciphertext was sealed under a key other than the current one
+func (e *Envelope) NeedsRotation(ciphertext string) (bool, error) {
+	keyID, err := KeyID(ciphertext)
+	if err != nil {
+		return false, err
+	}
+	current, err := e.provider.CurrentKeyID()
+	if err != nil {
+		return false, err
+	}
+	return keyID != current, nil
+}
+
+// Reencrypt decrypts and seals ciphertext again under the current key
+func (e *Envelope) Reencrypt(ciphertext string, ad []byte) (string, error) {
+	plain, err := e.Decrypt(ciphertext, ad)
+	if err != nil {
+		return "", err
+	}
+	return e.Encrypt(plain, ad)
+}
+
+// IsEnvelope reports whether s looks like an Envelope ciphertext
+func IsEnvelope(s string) bool {
+	return strings.HasPrefix(s, ciphertextVersion+":")
+}
+
+// KeyID returns the KEK id embedded in ciphertext
+func KeyID(ciphertext string) (string, error) {
+	keyID, _, _, err := parse(ciphertext)
+	return keyID, err
+}
+
+func parse(ciphertext string) (keyID string, wrapped, body []byte, err error) {
+	parts := strings.Split(ciphertext, ":")
+	if len(parts) != 4 || parts[0] != ciphertextVersion || parts[1] == "" {
+		return "", nil, nil, ErrMalformedCiphertext
+	}
+	if wrapped, err = base64.StdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, fmt.Errorf("%w: %v", ErrMalformedCiphertext, err)
+	}
+	if body, err = base64.StdEncoding.DecodeString(parts[3]); err != nil {
+		return "", nil, nil, fmt.Errorf("%w: %v", ErrMalformedCiphertext, err)
+	}
+	return parts[1], wrapped, body, nil
+}
@@ -0,0 +1,247 @@
+package keymgmt
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvelopeRoundTripAndRotation(t *testing.T) {
+	kms := NewLocalKMS()
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	env := NewEnvelope(kms)
+
+	ct, err := env.Encrypt([]byte("+254712345678"), []byte("senders.phone_enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := KeyID(ct); id != "k1" {
+		t.Fatalf("key id = %q, want k1", id)
+	}
+	if _, err := env.Decrypt(ct, []byte("recipients.phone_enc")); err == nil {
+		t.Fatal("decrypt with wrong associated data succeeded")
+	}
+
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := env.NeedsRotation(ct); err != nil || !stale {
+		t.Fatalf("NeedsRotation = %v, %v", stale, err)
+	}
+	plain, err := env.Decrypt(ct, []byte("senders.phone_enc"))
+	if err != nil || string(plain) != "+254712345678" {
+		t.Fatalf("old ciphertext after rotation = %q, %v", plain, err)
+	}
+
+	ct2, err := env.Reencrypt(ct, []byte("senders.phone_enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := KeyID(ct2); id != "k2" {
+		t.Fatalf("re-encrypted key id = %q, want k2", id)
+	}
+}
+
+func TestEnvelopeRejectsTampering(t *testing.T) {
+	kms := NewLocalKMS()
+	kms.Rotate()
+	env := NewEnvelope(kms)
+	ct, err := env.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(ct, ":")
+
+	body, _ := base64.StdEncoding.DecodeString(parts[3])
+	body[len(body)-1] ^= 1
+	tampered := strings.Join([]string{parts[0], parts[1], parts[2], base64.StdEncoding.EncodeToString(body)}, ":")
+	if _, err := env.Decrypt(tampered, nil); err == nil {
+		t.Fatal("tampered body decrypted")
+	}
+
+	relabelled := strings.Join([]string{parts[0], "k9", parts[2], parts[3]}, ":")
+	if _, err := env.Decrypt(relabelled, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("want ErrUnknownKey, got %v", err)
+	}
+	if _, err := env.Decrypt("not-an-envelope", nil); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatalf("want ErrMalformedCiphertext, got %v", err)
+	}
+}
+
+func TestFileProviderPersistsVersions(t *testing.T) {
+	dir := t.TempDir()
+	fp, err := NewFileProvider(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fp.CurrentKeyID(); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("empty dir: want ErrUnknownKey, got %v", err)
+	}
+	fp.Rotate()
+	ct, err := NewEnvelope(fp).Encrypt([]byte("session-key"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp.Rotate()
+
+	reloaded, err := NewFileProvider(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := reloaded.CurrentKeyID(); id != "k2" {
+		t.Fatalf("current after reload = %q, want k2", id)
+	}
+	plain, err := NewEnvelope(reloaded).Decrypt(ct, nil)
+	if err != nil || string(plain) != "session-key" {
+		t.Fatalf("decrypt after reload = %q, %v", plain, err)
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(make([]byte, KeySize))
+	k2 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", KeySize)))
+	t.Setenv("KEYMGMT_KEYS", "k1:"+k1+", k2:"+k2)
+	t.Setenv("KEYMGMT_CURRENT_KEY", "k2")
+
+	ep, err := NewEnvProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := NewEnvelope(ep).Encrypt([]byte("x"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := KeyID(ct); id != "k2" {
+		t.Fatalf("key id = %q, want k2", id)
+	}
+
+	t.Setenv("KEYMGMT_CURRENT_KEY", "k3")
+	if _, err := NewEnvProvider(); err == nil {
+		t.Fatal("missing current key accepted")
+	}
+	t.Setenv("KEYMGMT_KEYS", "k1:c2hvcnQ=")
+	t.Setenv("KEYMGMT_CURRENT_KEY", "k1")
+	if _, err := NewEnvProvider(); err == nil {
+		t.Fatal("short key accepted")
+	}
+}
+
+func TestStaticKeysStayOutOfKeyRing(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("KEY_DIR", dir)
+	t.Setenv("BLIND_INDEX_KEY", "")
+	t.Setenv("OTP_HASH_KEY", "")
+
+	// A blind index key where earlier versions kept it, beside the KEKs
+	legacy := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", KeySize)))
+	if err := os.WriteFile(filepath.Join(dir, "blind_index.key"), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fp, err := NewFileProvider(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fp.keys["blind_index"]; ok || len(fp.keys) != 0 {
+		t.Fatalf("legacy static key loaded as a KEK: %v", fp.keys)
+	}
+
+	bidx, err := LoadBlindIndexKey()
+	if err != nil || string(bidx) != strings.Repeat("b", KeySize) {
+		t.Fatalf("blind index key = %q, %v", bidx, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blind_index.key")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("legacy key file left beside the KEKs: %v", err)
+	}
+	if _, err := LoadOTPHashKey(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fp.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewFileProvider(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.keys) != 1 || reloaded.keys["k1"] == nil {
+		t.Fatalf("key ring = %v, want only k1", reloaded.keys)
+	}
+	if again, _ := LoadBlindIndexKey(); string(again) != string(bidx) {
+		t.Fatal("blind index key changed after the move")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "current"), []byte("otp_hash"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileProvider(dir); err == nil {
+		t.Fatal("an HMAC key was accepted as the current KEK")
+	}
+}
+
+func TestFileProviderReload(t *testing.T) {
+	dir := t.TempDir()
+	rotator, err := NewFileProvider(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotator.Rotate()
+	server, err := NewFileProvider(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cmd/rotate-keys mints k2 and rewraps a row under it
+	rotator.Rotate()
+	ct, err := NewEnvelope(rotator).Encrypt([]byte("pii"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewEnvelope(server).Decrypt(ct, nil)
+	if err != nil || string(plain) != "pii" {
+		t.Fatalf("decrypt under a key minted elsewhere = %q, %v", plain, err)
+	}
+	if id, _ := server.CurrentKeyID(); id != "k2" {
+		t.Fatalf("current after reload = %q, want k2", id)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "current")); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Reload(); err == nil {
+		t.Fatal("reload accepted a missing current pointer")
+	}
+	if id, _ := server.CurrentKeyID(); id != "k2" {
+		t.Fatalf("current after a failed reload = %q, want k2", id)
+	}
+}
+
+func TestProviderFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("KEY_DIR", dir)
+	t.Setenv("KEY_PROVIDER", "file")
+	p, err := NewProviderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CurrentKeyID(); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("a key was minted without being asked for: %v", err)
+	}
+	if paths, _ := filepath.Glob(filepath.Join(dir, "*.key")); len(paths) != 0 {
+		t.Fatalf("key files written: %v", paths)
+	}
+
+	t.Setenv("KEY_PROVIDER", "local-kms")
+	t.Setenv("ENV", "prod")
+	if _, err := NewProviderFromEnv(); err == nil {
+		t.Fatal("local-kms accepted outside dev")
+	}
+	t.Setenv("ENV", "dev")
+	if _, err := NewProviderFromEnv(); err != nil {
+		t.Fatalf("local-kms in dev: %v", err)
+	}
+}
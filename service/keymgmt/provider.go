@@ -0,0 +1,362 @@
+package keymgmt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeySize is the length of every key-encryption and data key (AES-256)
+const KeySize = 32
+
+var ErrUnknownKey = errors.New("unknown key id")
+
+// KeyProvider holds key-encryption keys (KEKs) and wraps data keys with them.
+// Raw KEKs never leave the provider, so a remote KMS can implement it too.
+type KeyProvider interface {
+	CurrentKeyID() (string, error)
+	WrapKey(keyID string, dataKey []byte) ([]byte, error)
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Rotator is implemented by providers that can mint a new key version
+type Rotator interface {
+	Rotate() (string, error)
+}
+
+// NewProviderFromEnv picks a provider from KEY_PROVIDER ("file", "env" or "local-kms").
+// The file provider reads KEY_DIR (default ".keys"). No key is minted here: a
+// provider without a current key is returned as is, and the caller decides
+// whether a first key is safe to create. local-kms forgets its keys on
+// restart, so it is refused unless ENV is "dev".
+func NewProviderFromEnv() (KeyProvider, error) {
+	switch os.Getenv("KEY_PROVIDER") {
+	case "", "file":
+		dir := os.Getenv("KEY_DIR")
+		if dir == "" {
+			dir = ".keys"
+		}
+		return NewFileProvider(dir)
+	case "env":
+		return NewEnvProvider()
+	case "local-kms":
+		if os.Getenv("ENV") != "dev" {
+			return nil, errors.New("KEY_PROVIDER local-kms loses its keys on restart and is only allowed with ENV=dev")
+		}
+		return NewLocalKMS(), nil
+	default:
+		return nil, fmt.Errorf("unsupported KEY_PROVIDER %q", os.Getenv("KEY_PROVIDER"))
+	}
+}
+
+// ------------------------------------------------------------
+// keyRing – shared in-memory KEK set used by every provider below
+
+type keyRing struct {
+	mu      sync.RWMutex
+	keys    map[string][]byte
+	current string
+}
+
+func (r *keyRing) CurrentKeyID() (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.current == "" {
+		return "", ErrUnknownKey
+	}
+	return r.current, nil
+}
+
+func (r *keyRing) kek(keyID string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	k, ok := r.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	return k, nil
+}
+
+func (r *keyRing) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	kek, err := r.kek(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return seal(kek, dataKey, []byte(keyID))
+}
+
+func (r *keyRing) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	kek, err := r.kek(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return open(kek, wrapped, []byte(keyID))
+}
+
+// nextKeyID returns "k<N+1>" for the highest existing "k<N>"
+func (r *keyRing) nextKeyID() string {
+	max := 0
+	for id := range r.keys {
+		if n, err := strconv.Atoi(strings.TrimPrefix(id, "k")); err == nil && n > max {
+			max = n
+		}
+	}
+	return "k" + strconv.Itoa(max+1)
+}
+
+// ------------------------------------------------------------
+// FileProvider – one base64 file per key version plus a "current" pointer
+
+// kekFileID matches the ids Rotate mints; other *.key files in the
+// directory, such as HMAC keys from earlier versions, are not KEKs
+var kekFileID = regexp.MustCompile(`^k[0-9]+$`)
+
+type FileProvider struct {
+	keyRing
+	dir string
+}
+
+// NewFileProvider loads <dir>/k<N>.key files and <dir>/current
+func NewFileProvider(dir string) (*FileProvider, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	keys, current, err := loadKeyDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &FileProvider{keyRing: keyRing{keys: keys, current: current}, dir: dir}, nil
+}
+
+// Reload rereads the key files, picking up keys minted and made current by
+// another process such as cmd/rotate-keys. Loaded keys are never dropped, and
+// on error the ring is left as it was.
+func (fp *FileProvider) Reload() error {
+	keys, current, err := loadKeyDir(fp.dir)
+	if err != nil {
+		return err
+	}
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	if current == "" && fp.current != "" {
+		return fmt.Errorf("%s: current key pointer has gone", fp.dir)
+	}
+	for id, key := range keys {
+		fp.keys[id] = key
+	}
+	fp.current = current
+	return nil
+}
+
+// UnwrapKey rereads the key files once when keyID is not loaded yet, so rows
+// rewrapped under a newer key can be read straight away
+func (fp *FileProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, err := fp.keyRing.UnwrapKey(keyID, wrapped)
+	if errors.Is(err, ErrUnknownKey) && kekFileID.MatchString(keyID) {
+		if rerr := fp.Reload(); rerr != nil {
+			return nil, rerr
+		}
+		return fp.keyRing.UnwrapKey(keyID, wrapped)
+	}
+	return key, err
+}
+
+// Run reloads the key files every interval until ctx is done, so new values
+// are wrapped under a key made current since startup
+func (fp *FileProvider) Run(ctx context.Context, interval time.Duration, logf func(string, ...interface{})) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			before, _ := fp.CurrentKeyID()
+			if err := fp.Reload(); err != nil {
+				logf("Field encryption key reload failed: %v", err)
+				continue
+			}
+			if after, _ := fp.CurrentKeyID(); after != before {
+				logf("Field encryption now wraps with key %s", after)
+			}
+		}
+	}
+}
+
+// loadKeyDir reads the KEK files and current pointer under dir; current is
+// empty when no key has been minted yet
+func loadKeyDir(dir string) (map[string][]byte, string, error) {
+	keys := make(map[string][]byte)
+	paths, err := filepath.Glob(filepath.Join(dir, "*.key"))
+	if err != nil {
+		return nil, "", err
+	}
+	for _, p := range paths {
+		id := strings.TrimSuffix(filepath.Base(p), ".key")
+		if !kekFileID.MatchString(id) {
+			continue
+		}
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			return nil, "", err
+		}
+		key, err := decodeKey(strings.TrimSpace(string(raw)))
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", p, err)
+		}
+		keys[id] = key
+	}
+	cur, err := os.ReadFile(filepath.Join(dir, "current"))
+	if os.IsNotExist(err) {
+		return keys, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	current := strings.TrimSpace(string(cur))
+	if _, ok := keys[current]; !ok {
+		return nil, "", fmt.Errorf("current key %q has no key file", current)
+	}
+	return keys, current, nil
+}
+
+// Rotate writes a new key version and makes it current
+func (fp *FileProvider) Rotate() (string, error) {
+	key, err := newKey()
+	if err != nil {
+		return "", err
+	}
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	id := fp.nextKeyID()
+	if err := os.WriteFile(filepath.Join(fp.dir, id+".key"), []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(fp.dir, "current"), []byte(id), 0600); err != nil {
+		return "", err
+	}
+	fp.keys[id] = key
+	fp.current = id
+	return id, nil
+}
+
+// ------------------------------------------------------------
+// EnvProvider – KEYMGMT_KEYS="k1:<base64>,k2:<base64>" and KEYMGMT_CURRENT_KEY="k2"
+
+type EnvProvider struct {
+	keyRing
+}
+
+func NewEnvProvider() (*EnvProvider, error) {
+	ep := &EnvProvider{keyRing: keyRing{keys: make(map[string][]byte)}}
+	for _, entry := range strings.Split(os.Getenv("KEYMGMT_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("KEYMGMT_KEYS entry %q is not id:key", entry)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("KEYMGMT_KEYS %s: %w", id, err)
+		}
+		ep.keys[id] = key
+	}
+	ep.current = os.Getenv("KEYMGMT_CURRENT_KEY")
+	if _, ok := ep.keys[ep.current]; !ok {
+		return nil, fmt.Errorf("KEYMGMT_CURRENT_KEY %q not in KEYMGMT_KEYS", ep.current)
+	}
+	return ep, nil
+}
+
+// ------------------------------------------------------------
+// LocalKMS – in-process stand-in for a managed KMS (dev and tests only;
+// keys are lost on restart)
+
+type LocalKMS struct {
+	keyRing
+}
+
+func NewLocalKMS() *LocalKMS {
+	return &LocalKMS{keyRing: keyRing{keys: make(map[string][]byte)}}
+}
+
+func (k *LocalKMS) Rotate() (string, error) {
+	key, err := newKey()
+	if err != nil {
+		return "", err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	id := k.nextKeyID()
+	k.keys[id] = key
+	k.current = id
+	return id, nil
+}
+
+// ------------------------------------------------------------
+// helpers
+
+func newKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// seal is AES-256-GCM with the nonce prepended
+func seal(key, plaintext, ad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func open(key, data, ad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], ad)
+}
@@ -0,0 +1,63 @@
+package keymgmt
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// staticKeyDir holds HMAC keys under KEY_DIR, apart from the KEK files so
+// they can never be loaded into the key ring
+const staticKeyDir = "static"
+
+// LoadBlindIndexKey returns the HMAC key for blind indexes, from
+// BLIND_INDEX_KEY or <KEY_DIR>/static/blind_index.key. Unlike KEKs this key
+// is not versioned: changing it invalidates every stored index.
+func LoadBlindIndexKey() ([]byte, error) {
+	return loadStaticKey("BLIND_INDEX_KEY", "blind_index.key")
+}
+
+// LoadOTPHashKey returns the HMAC key for stored OTP codes, from
+// OTP_HASH_KEY or <KEY_DIR>/static/otp_hash.key.
+func LoadOTPHashKey() ([]byte, error) {
+	return loadStaticKey("OTP_HASH_KEY", "otp_hash.key")
+}
+
+// loadStaticKey reads a base64 key from envVar, else from fileName under
+// <KEY_DIR>/static (KEY_DIR defaults to ".keys"), creating the file on first
+// use. A key left at <KEY_DIR>/<fileName> by earlier versions is moved there.
+func loadStaticKey(envVar, fileName string) ([]byte, error) {
+	if encoded := os.Getenv(envVar); encoded != "" {
+		return decodeKey(encoded)
+	}
+	dir := os.Getenv("KEY_DIR")
+	if dir == "" {
+		dir = ".keys"
+	}
+	path := filepath.Join(dir, staticKeyDir, fileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(filepath.Join(dir, fileName), path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	raw, err := os.ReadFile(path)
+	if err == nil {
+		return decodeKey(strings.TrimSpace(string(raw)))
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
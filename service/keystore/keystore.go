@@ -1,31 +1,223 @@
 package keystore
 
 import (
-    "fmt"
-    "sync"
-    "github.com/google/uuid"
     "crypto/hmac"
+    "crypto/rand"
     "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "weriKana/models"
+)
+
+const (
+    KeySize          = 32
+    MaxActiveKeys    = 5               // per customer, including keys in their rotation grace period
+    DefaultGrace     = 24 * time.Hour  // how long a rotated-out key keeps verifying
+    SignatureWindow  = 5 * time.Minute // allowed clock skew for X-Timestamp
+)
+
+var (
+    ErrUnknownKey      = errors.New("unknown or inactive signing key")
+    ErrTooManyKeys     = errors.New("too many active signing keys")
+    ErrBadSignature    = errors.New("invalid request signature")
+    ErrStaleRequest    = errors.New("request timestamp outside allowed window")
+    ErrReplayedRequest = errors.New("request nonce already used")
 )
 
+// KeyStore persists encrypted per-customer HMAC keys and verifies signed requests
 type KeyStore struct {
-    keys map[uuid.UUID][]byte // customer_id → 32-byte HMAC key
-    mu   sync.RWMutex
+    db     *gorm.DB
+    nonces NonceCache
+    now    func() time.Time
 }
 
-// hmacSHA256 computes the HMAC-SHA256 of a given payload using a given key.
-func hmacSHA256(payload string, key []byte) string {
-    mac := hmac.New(sha256.New, key)
-    mac.Write([]byte(payload))
-    return fmt.Sprintf("%x", mac.Sum(nil)) // Return the result as a hexadecimal string
+func New(db *gorm.DB) *KeyStore {
+    return &KeyStore{db: db, nonces: NewMemoryNonceCache(), now: time.Now}
 }
 
-func (ks *KeyStore) Verify(customerID uuid.UUID, payload, signature string) bool {
-    ks.mu.RLock()
-    key := ks.keys[customerID]
-    ks.mu.RUnlock()
+// Enroll creates a new active key and returns its id and secret (shown once)
+func (ks *KeyStore) Enroll(customerID uuid.UUID, label string) (*models.SigningKey, []byte, error) {
+    var key *models.SigningKey
+    var secret []byte
+    err := ks.db.Transaction(func(tx *gorm.DB) error {
+        var err error
+        key, secret, err = ks.create(tx, customerID, label)
+        return err
+    })
+    return key, secret, err
+}
+
+// Rotate issues a replacement for keyID; the old key keeps verifying for grace
+func (ks *KeyStore) Rotate(customerID uuid.UUID, keyID string, grace time.Duration) (*models.SigningKey, []byte, error) {
+    var key *models.SigningKey
+    var secret []byte
+    err := ks.db.Transaction(func(tx *gorm.DB) error {
+        old, err := activeKey(tx, customerID, keyID, ks.now())
+        if err != nil {
+            return err
+        }
+        expiresAt := ks.now().Add(grace)
+        if old.ExpiresAt == nil || expiresAt.Before(*old.ExpiresAt) {
+            if err := tx.Model(old).Update("expires_at", expiresAt).Error; err != nil {
+                return err
+            }
+        }
+        label := old.Label
+        key, secret, err = ks.create(tx, customerID, label)
+        return err
+    })
+    return key, secret, err
+}
+
+// Revoke disables keyID immediately
+func (ks *KeyStore) Revoke(customerID uuid.UUID, keyID string) error {
+    res := ks.db.Model(&models.SigningKey{}).
+        Where("key_id = ? AND customer_id = ? AND revoked_at IS NULL", keyID, customerID).
+        Update("revoked_at", ks.now())
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrUnknownKey
+    }
+    return nil
+}
+
+// List returns the customer's keys that can still verify, newest first
+func (ks *KeyStore) List(customerID uuid.UUID) ([]models.SigningKey, error) {
+    var keys []models.SigningKey
+    err := ks.db.Where("customer_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", customerID, ks.now()).
+        Order("created_at DESC").
+        Find(&keys).Error
+    return keys, err
+}
 
-    expected := hmacSHA256(payload, key)
-    return hmac.Equal([]byte(signature), []byte(expected))
+// SignedRequest is what a client signed, as seen by the server
+type SignedRequest struct {
+    KeyID     string
+    Method    string
+    Path      string // path and query exactly as sent
+    Timestamp string // unix seconds
+    Nonce     string
+    Body      []byte
+    Signature string // hex HMAC-SHA256 of CanonicalRequest
 }
 
+// VerifyRequest checks that req was signed by one of the customer's active keys,
+// is fresh, and has not been seen before.
+func (ks *KeyStore) VerifyRequest(customerID uuid.UUID, req SignedRequest) error {
+    key, err := activeKey(ks.db, customerID, req.KeyID, ks.now())
+    if err != nil {
+        return err
+    }
+    if err := ks.verify(key, req); err != nil {
+        return err
+    }
+    ks.db.Model(key).Update("last_used_at", ks.now())
+    return nil
+}
+
+// verify runs the signature, freshness and replay checks in that order, so a
+// forged request cannot burn a legitimate nonce
+func (ks *KeyStore) verify(key *models.SigningKey, req SignedRequest) error {
+    secret, err := base64.StdEncoding.DecodeString(key.Secret.String())
+    if err != nil {
+        return err
+    }
+    expected := Sign(secret, CanonicalRequest(req.Method, req.Path, req.Timestamp, req.Nonce, req.Body))
+    if !hmac.Equal([]byte(strings.ToLower(req.Signature)), []byte(expected)) {
+        return ErrBadSignature
+    }
+
+    ts, err := strconv.ParseInt(req.Timestamp, 10, 64)
+    if err != nil {
+        return ErrStaleRequest
+    }
+    now := ks.now()
+    signedAt := time.Unix(ts, 0)
+    if signedAt.Before(now.Add(-SignatureWindow)) || signedAt.After(now.Add(SignatureWindow)) {
+        return ErrStaleRequest
+    }
+    if req.Nonce == "" || len(req.Nonce) > 128 {
+        return ErrBadSignature
+    }
+    if ks.nonces.Seen(req.KeyID+":"+req.Nonce, signedAt.Add(SignatureWindow)) {
+        return ErrReplayedRequest
+    }
+    return nil
+}
+
+// CanonicalRequest is the string clients sign:
+//
+//  METHOD \n PATH?QUERY \n TIMESTAMP \n NONCE \n hex(SHA-256(body))
+func CanonicalRequest(method, path, timestamp, nonce string, body []byte) string {
+    sum := sha256.Sum256(body)
+    return strings.Join([]string{
+        strings.ToUpper(method),
+        path,
+        timestamp,
+        nonce,
+        hex.EncodeToString(sum[:]),
+    }, "\n")
+}
+
+// Sign returns hex(HMAC-SHA256(secret, canonical))
+func Sign(secret []byte, canonical string) string {
+    mac := hmac.New(sha256.New, secret)
+    mac.Write([]byte(canonical))
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (ks *KeyStore) create(tx *gorm.DB, customerID uuid.UUID, label string) (*models.SigningKey, []byte, error) {
+    var active int64
+    if err := tx.Model(&models.SigningKey{}).
+        Where("customer_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", customerID, ks.now()).
+        Count(&active).Error; err != nil {
+        return nil, nil, err
+    }
+    if active >= MaxActiveKeys {
+        return nil, nil, ErrTooManyKeys
+    }
+
+    secret := make([]byte, KeySize)
+    if _, err := rand.Read(secret); err != nil {
+        return nil, nil, err
+    }
+    idBytes := make([]byte, 8)
+    if _, err := rand.Read(idBytes); err != nil {
+        return nil, nil, err
+    }
+    key := &models.SigningKey{
+        ID:         uuid.New(),
+        KeyID:      "sk_" + hex.EncodeToString(idBytes),
+        CustomerID: customerID,
+        Secret:     models.EncryptedString(base64.StdEncoding.EncodeToString(secret)),
+        Label:      label,
+    }
+    if err := tx.Create(key).Error; err != nil {
+        return nil, nil, err
+    }
+    return key, secret, nil
+}
+
+func activeKey(db *gorm.DB, customerID uuid.UUID, keyID string, now time.Time) (*models.SigningKey, error) {
+    var key models.SigningKey
+    err := db.Where("key_id = ? AND customer_id = ?", keyID, customerID).First(&key).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, ErrUnknownKey
+    }
+    if err != nil {
+        return nil, err
+    }
+    if !key.Active(now) {
+        return nil, ErrUnknownKey
+    }
+    return &key, nil
+}
@@ -0,0 +1,113 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"weriKana/models"
+)
+
+var testNow = time.Unix(1_700_000_000, 0)
+
+func testStore() *KeyStore {
+	nonces := NewMemoryNonceCache()
+	nonces.now = func() time.Time { return testNow }
+	return &KeyStore{nonces: nonces, now: func() time.Time { return testNow }}
+}
+
+func signedRequest(secret []byte, ts time.Time, nonce string) SignedRequest {
+	req := SignedRequest{
+		KeyID:     "sk_test",
+		Method:    "POST",
+		Path:      "/api/v1/account/smart-withdraw",
+		Timestamp: strconv.FormatInt(ts.Unix(), 10),
+		Nonce:     nonce,
+		Body:      []byte(`{"amount":5000}`),
+	}
+	req.Signature = Sign(secret, CanonicalRequest(req.Method, req.Path, req.Timestamp, req.Nonce, req.Body))
+	return req
+}
+
+func TestCanonicalRequest(t *testing.T) {
+	got := CanonicalRequest("post", "/a?b=1", "1700000000", "n1", []byte(""))
+	want := "POST\n/a?b=1\n1700000000\nn1\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got != want {
+		t.Fatalf("canonical = %q", got)
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	key := &models.SigningKey{KeyID: "sk_test", Secret: models.EncryptedString(base64.StdEncoding.EncodeToString(secret))}
+
+	t.Run("valid then replay", func(t *testing.T) {
+		ks := testStore()
+		req := signedRequest(secret, testNow, "nonce-1")
+		if err := ks.verify(key, req); err != nil {
+			t.Fatal(err)
+		}
+		if err := ks.verify(key, req); !errors.Is(err, ErrReplayedRequest) {
+			t.Fatalf("want ErrReplayedRequest, got %v", err)
+		}
+	})
+
+	tamper := map[string]func(r *SignedRequest){
+		"method":    func(r *SignedRequest) { r.Method = "PUT" },
+		"path":      func(r *SignedRequest) { r.Path += "?x=1" },
+		"body":      func(r *SignedRequest) { r.Body = []byte(`{"amount":9000}`) },
+		"timestamp": func(r *SignedRequest) { r.Timestamp = strconv.FormatInt(testNow.Unix()+1, 10) },
+		"nonce":     func(r *SignedRequest) { r.Nonce = "nonce-x" },
+		"signature": func(r *SignedRequest) { r.Signature = Sign([]byte("other"), "x") },
+	}
+	for name, mutate := range tamper {
+		t.Run("tampered "+name, func(t *testing.T) {
+			ks := testStore()
+			req := signedRequest(secret, testNow, "nonce-2")
+			mutate(&req)
+			if err := ks.verify(key, req); !errors.Is(err, ErrBadSignature) {
+				t.Fatalf("want ErrBadSignature, got %v", err)
+			}
+		})
+	}
+
+	t.Run("stale", func(t *testing.T) {
+		ks := testStore()
+		for _, offset := range []time.Duration{-SignatureWindow - time.Second, SignatureWindow + time.Second} {
+			if err := ks.verify(key, signedRequest(secret, testNow.Add(offset), "nonce-3")); !errors.Is(err, ErrStaleRequest) {
+				t.Fatalf("offset %v: want ErrStaleRequest, got %v", offset, err)
+			}
+		}
+	})
+
+	t.Run("forgery does not burn nonce", func(t *testing.T) {
+		ks := testStore()
+		forged := signedRequest([]byte("wrong-secret"), testNow, "nonce-4")
+		if err := ks.verify(key, forged); !errors.Is(err, ErrBadSignature) {
+			t.Fatalf("want ErrBadSignature, got %v", err)
+		}
+		if err := ks.verify(key, signedRequest(secret, testNow, "nonce-4")); err != nil {
+			t.Fatalf("genuine request rejected after forgery: %v", err)
+		}
+	})
+}
+
+func TestSigningKeyActive(t *testing.T) {
+	past, future := testNow.Add(-time.Minute), testNow.Add(time.Minute)
+	cases := map[string]struct {
+		key  models.SigningKey
+		want bool
+	}{
+		"fresh":         {models.SigningKey{}, true},
+		"in grace":      {models.SigningKey{ExpiresAt: &future}, true},
+		"grace elapsed": {models.SigningKey{ExpiresAt: &past}, false},
+		"revoked":       {models.SigningKey{RevokedAt: &past}, false},
+	}
+	for name, tc := range cases {
+		if got := tc.key.Active(testNow); got != tc.want {
+			t.Errorf("%s: Active = %v, want %v", name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package keystore
+
+import (
+    "sync"
+    "time"
+)
+
+// NonceCache remembers request nonces until they could no longer pass the
+// timestamp check. A shared implementation (Redis, NATS KV) is needed when
+// running more than one API instance.
+type NonceCache interface {
+    // Seen records key and reports whether it was already present
+    Seen(key string, expiresAt time.Time) bool
+}
+
+// MemoryNonceCache is an in-process NonceCache
+type MemoryNonceCache struct {
+    mu      sync.Mutex
+    entries map[string]time.Time
+    now     func() time.Time
+}
+
+func NewMemoryNonceCache() *MemoryNonceCache {
+    return &MemoryNonceCache{entries: make(map[string]time.Time), now: time.Now}
+}
+
+func (m *MemoryNonceCache) Seen(key string, expiresAt time.Time) bool {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    now := m.now()
+    for k, exp := range m.entries {
+        if now.After(exp) {
+            delete(m.entries, k)
+        }
+    }
+    if _, ok := m.entries[key]; ok {
+        return true
+    }
+    m.entries[key] = expiresAt
+    return false
+}
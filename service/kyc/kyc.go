@@ -0,0 +1,390 @@
+package kyc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+const MaxDocumentBytes = 10 << 20
+
+var (
+	ErrSubjectNotFound   = errors.New("KYC subject not found")
+	ErrNameRequired      = errors.New("first_name and last_name are required")
+	ErrLocked            = errors.New("KYC details cannot change while pending or verified")
+	ErrInvalidTransition = errors.New("KYC status does not allow this action")
+	ErrNoDocuments       = errors.New("upload at least one identity document before submitting")
+	ErrInvalidDocument   = errors.New("invalid document metadata")
+	ErrNotVerified       = errors.New("KYC verification required for real-money transfers")
+)
+
+var (
+	documentKinds   = []string{"id_front", "id_back", "passport_bio", "selfie", "proof_of_address"}
+	documentTypes   = []string{"image/jpeg", "image/png", "application/pdf"}
+	sha256HexRegex  = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	subjectTables   = map[models.KYCSubject]string{models.KYCSubjectSender: "senders", models.KYCSubjectRecipient: "recipients"}
+	noExpiryCeiling = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC) // zero expiry columns sort below this
+)
+
+// Subject names a Sender or Recipient
+type Subject struct {
+	Type models.KYCSubject `json:"subject_type"`
+	ID   uuid.UUID         `json:"subject_id"`
+}
+
+// SenderDetails is what a customer submits about themselves
+type SenderDetails struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	models.Identity
+}
+
+// Record is the part of a sender or recipient row that KYC reads and writes
+type Record struct {
+	ID                   uuid.UUID                 `json:"id"`
+	CustomerID           uuid.UUID                 `json:"customer_id"`
+	KYCStatus            models.KYCStatus          `json:"kyc_status"`
+	KYCSubmittedAt       *time.Time                `json:"kyc_submitted_at,omitempty"`
+	IdentificationType   models.IdentificationType `json:"identification_type"`
+	IdentificationNumber string                    `json:"identification_number"`
+	IdentificationExpiry time.Time                 `json:"identification_expiry"`
+	BirthDate            time.Time                 `json:"birth_date"`
+}
+
+func (r *Record) identity() models.Identity {
+	return models.Identity{Type: r.IdentificationType, Number: r.IdentificationNumber, Expiry: r.IdentificationExpiry, BirthDate: r.BirthDate}
+}
+
+func (r *Record) status() models.KYCStatus {
+	if r.KYCStatus == "" {
+		return models.KYCUnverified
+	}
+	return r.KYCStatus
+}
+
+// Screener checks a saved sender or recipient against sanctions and PEP
+// lists (screening.Service implements it)
+type Screener interface {
+	ScreenSubject(tx *gorm.DB, subject models.KYCSubject, id uuid.UUID) error
+}
+
+// Service runs the KYC state machine for senders and recipients
+type Service struct {
+	db     *gorm.DB
+	screen Screener // optional
+	now    func() time.Time
+}
+
+func NewService(db *gorm.DB, screen Screener) *Service {
+	return &Service{db: db, screen: screen, now: time.Now}
+}
+
+// rescreen screens a subject whose name or birth date was just written
+func (s *Service) rescreen(tx *gorm.DB, subject models.KYCSubject, id uuid.UUID) error {
+	if s.screen == nil {
+		return nil
+	}
+	return s.screen.ScreenSubject(tx, subject, id)
+}
+
+// Status is the customer's own KYC status (their Sender's), unverified if none
+func (s *Service) Status(customerID uuid.UUID) (models.KYCStatus, error) {
+	var row Record
+	err := s.db.Table("senders").Where("customer_id = ? AND deleted_at IS NULL", customerID).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.KYCUnverified, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return row.status(), nil
+}
+
+// RequireVerified returns ErrNotVerified unless the customer's KYC is verified
+func (s *Service) RequireVerified(customerID uuid.UUID) error {
+	status, err := s.Status(customerID)
+	if err != nil {
+		return err
+	}
+	if status != models.KYCVerified {
+		return ErrNotVerified
+	}
+	return nil
+}
+
+// SaveSender creates or updates the customer's Sender identity while it is editable
+func (s *Service) SaveSender(customerID uuid.UUID, d SenderDetails) (*models.Sender, error) {
+	d.Identity = d.Identity.Normalize()
+	d.FirstName, d.LastName = strings.TrimSpace(d.FirstName), strings.TrimSpace(d.LastName)
+	if d.FirstName == "" || d.LastName == "" {
+		return nil, ErrNameRequired
+	}
+	if err := d.Identity.Validate(s.now()); err != nil {
+		return nil, err
+	}
+
+	var sender models.Sender
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("customer_id = ?", customerID).Take(&sender).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sender = models.Sender{
+				ID:         uuid.New(),
+				CustomerID: customerID,
+				Type:       models.SenderTypePerson,
+				KYCStatus:  models.KYCUnverified,
+			}
+			applySenderDetails(&sender, d)
+			// external_id is assigned by the remittance partner; leave it NULL until then
+			if err := tx.Omit("ExternalID", clause.Associations).Create(&sender).Error; err != nil {
+				return err
+			}
+			return s.rescreen(tx, models.KYCSubjectSender, sender.ID)
+		}
+		if err != nil {
+			return err
+		}
+		if !sender.KYCStatus.Editable() {
+			return ErrLocked
+		}
+		applySenderDetails(&sender, d)
+		err = tx.Model(&sender).Select("first_name", "last_name", "birth_date", "identification_type",
+			"identification_number", "identification_expiry").Updates(&sender).Error
+		if err != nil {
+			return err
+		}
+		return s.rescreen(tx, models.KYCSubjectSender, sender.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sender, nil
+}
+
+func applySenderDetails(sender *models.Sender, d SenderDetails) {
+	sender.FirstName = d.FirstName
+	sender.LastName = d.LastName
+	sender.BirthDate = d.BirthDate
+	sender.IdentificationType = d.Type
+	sender.IdentificationNumber = d.Number
+	sender.IdentificationExpiry = d.Expiry
+}
+
+// SaveRecipientIdentity updates one of the customer's recipients while it is editable
+func (s *Service) SaveRecipientIdentity(customerID, recipientID uuid.UUID, id models.Identity) error {
+	id = id.Normalize()
+	if err := id.Validate(s.now()); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		row, err := loadSubject(tx, customerID, Subject{Type: models.KYCSubjectRecipient, ID: recipientID})
+		if err != nil {
+			return err
+		}
+		if !row.status().Editable() {
+			return ErrLocked
+		}
+		err = tx.Table("recipients").Where("id = ?", recipientID).Updates(map[string]interface{}{
+			"identification_type":   id.Type,
+			"identification_number": id.Number,
+			"identification_expiry": id.Expiry,
+			"birth_date":            id.BirthDate,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return s.rescreen(tx, models.KYCSubjectRecipient, recipientID)
+	})
+}
+
+// AddDocument records metadata for an uploaded file while the subject is editable
+func (s *Service) AddDocument(customerID uuid.UUID, subject Subject, doc models.KYCDocument) (*models.KYCDocument, error) {
+	doc.SHA256 = strings.ToLower(doc.SHA256)
+	if !slices.Contains(documentKinds, doc.Kind) || !slices.Contains(documentTypes, doc.ContentType) ||
+		!sha256HexRegex.MatchString(doc.SHA256) || doc.StorageKey == "" ||
+		doc.SizeBytes <= 0 || doc.SizeBytes > MaxDocumentBytes {
+		return nil, ErrInvalidDocument
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		row, err := loadSubject(tx, customerID, subject)
+		if err != nil {
+			return err
+		}
+		if !row.status().Editable() {
+			return ErrLocked
+		}
+		doc.ID = uuid.New()
+		doc.CustomerID = customerID
+		doc.SubjectType = subject.Type
+		doc.SubjectID = row.ID
+		return tx.Create(&doc).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Documents lists the metadata recorded for a subject
+func (s *Service) Documents(customerID uuid.UUID, subject Subject) ([]models.KYCDocument, error) {
+	row, err := loadSubject(s.db, customerID, subject)
+	if err != nil {
+		return nil, err
+	}
+	var docs []models.KYCDocument
+	err = s.db.Where("subject_type = ? AND subject_id = ?", subject.Type, row.ID).Order("created_at").Find(&docs).Error
+	return docs, err
+}
+
+// Submit moves a subject to pending review once its identity and documents are in place
+func (s *Service) Submit(customerID uuid.UUID, subject Subject) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		row, err := loadSubject(tx, customerID, subject)
+		if err != nil {
+			return err
+		}
+		if !row.status().CanTransition(models.KYCPending) {
+			return ErrInvalidTransition
+		}
+		if err := row.identity().Validate(s.now()); err != nil {
+			return err
+		}
+		var docs int64
+		if err := tx.Model(&models.KYCDocument{}).Where("subject_type = ? AND subject_id = ?", subject.Type, row.ID).Count(&docs).Error; err != nil {
+			return err
+		}
+		if docs == 0 {
+			return ErrNoDocuments
+		}
+		return tx.Table(subjectTables[subject.Type]).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"kyc_status":        models.KYCPending,
+			"kyc_submitted_at":  s.now(),
+			"kyc_reject_reason": "",
+		}).Error
+	})
+}
+
+// Pending lists subjects awaiting review, oldest submission first
+func (s *Service) Pending(subjectType models.KYCSubject, limit, offset int) ([]Record, error) {
+	table, ok := subjectTables[subjectType]
+	if !ok {
+		return nil, ErrSubjectNotFound
+	}
+	var rows []Record
+	err := s.db.Table(table).Where("kyc_status = ? AND deleted_at IS NULL", models.KYCPending).
+		Order("kyc_submitted_at").Limit(limit).Offset(offset).Find(&rows).Error
+	return rows, err
+}
+
+// Review approves or rejects a pending subject. Approval re-checks the
+// identity rules so a document that lapsed in the queue is not verified.
+func (s *Service) Review(actor backoffice.Actor, subject Subject, approve bool, reason string) (models.KYCStatus, error) {
+	table, ok := subjectTables[subject.Type]
+	if !ok {
+		return "", ErrSubjectNotFound
+	}
+	next := models.KYCRejected
+	if approve {
+		next = models.KYCVerified
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var row Record
+		err := tx.Table(table).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deleted_at IS NULL", subject.ID).Take(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSubjectNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !row.status().CanTransition(next) {
+			return ErrInvalidTransition
+		}
+		if approve {
+			if err := row.identity().Validate(s.now()); err != nil {
+				return fmt.Errorf("cannot verify: %w", err)
+			}
+		}
+		if err := tx.Table(table).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"kyc_status":        next,
+			"kyc_reviewed_at":   s.now(),
+			"kyc_reviewer_id":   actor.ID,
+			"kyc_reject_reason": reason,
+		}).Error; err != nil {
+			return err
+		}
+		return backoffice.Audit(tx, actor, "kyc."+string(next), string(subject.Type), row.ID, models.JSONMap{
+			"customer_id": row.CustomerID.String(),
+			"reason":      reason,
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// ExpireDue moves verified subjects whose document has lapsed to expired
+func (s *Service) ExpireDue() (int64, error) {
+	var total int64
+	for _, table := range subjectTables {
+		res := s.db.Table(table).
+			Where("kyc_status = ? AND identification_expiry > ? AND identification_expiry <= ?", models.KYCVerified, noExpiryCeiling, s.now()).
+			Updates(map[string]interface{}{"kyc_status": models.KYCExpired})
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+	}
+	return total, nil
+}
+
+// Run expires lapsed documents every interval until ctx is done
+func (s *Service) Run(ctx context.Context, interval time.Duration, logf func(string, ...interface{})) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if n, err := s.ExpireDue(); err != nil {
+			logf("kyc expiry sweep failed: %v", err)
+		} else if n > 0 {
+			logf("kyc expired %d verifications", n)
+		}
+	}
+}
+
+// loadSubject reads a subject owned by customerID. Sender subjects are found by
+// customer, so their ID may be left empty.
+func loadSubject(tx *gorm.DB, customerID uuid.UUID, subject Subject) (*Record, error) {
+	table, ok := subjectTables[subject.Type]
+	if !ok {
+		return nil, ErrSubjectNotFound
+	}
+	q := tx.Table(table).Where("customer_id = ? AND deleted_at IS NULL", customerID)
+	if subject.Type == models.KYCSubjectRecipient {
+		q = q.Where("id = ?", subject.ID)
+	}
+	var row Record
+	err := q.Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSubjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
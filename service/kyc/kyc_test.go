@@ -0,0 +1,159 @@
+package kyc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db := testdb.Open(t, &models.Sender{}, &models.Recipient{}, &models.KYCDocument{}, &models.AuditLog{})
+	return &Service{db: db, now: func() time.Time { return testNow }}, db
+}
+
+func passportDetails(expiry time.Time) SenderDetails {
+	return SenderDetails{
+		FirstName: "Achieng",
+		LastName:  "Otieno",
+		Identity: models.Identity{
+			Type:      models.IDPassport,
+			Number:    "AK1234567",
+			Expiry:    expiry,
+			BirthDate: time.Date(1992, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func testDocument() models.KYCDocument {
+	return models.KYCDocument{
+		Kind:        "passport_bio",
+		StorageKey:  "kyc/abc/passport.jpg",
+		SHA256:      strings.Repeat("ab", 32),
+		ContentType: "image/jpeg",
+		SizeBytes:   120_000,
+	}
+}
+
+func TestSenderLifecycle(t *testing.T) {
+	s, _ := newTestService(t)
+	customer := uuid.New()
+	sender := Subject{Type: models.KYCSubjectSender}
+	staff := backoffice.Actor{ID: uuid.New(), Role: models.RoleSupport}
+
+	if err := s.RequireVerified(customer); !errors.Is(err, ErrNotVerified) {
+		t.Fatalf("no sender yet: %v", err)
+	}
+	saved, err := s.SaveSender(customer, passportDetails(testNow.AddDate(0, 2, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Submit(customer, sender); !errors.Is(err, ErrNoDocuments) {
+		t.Fatalf("submit without documents: %v", err)
+	}
+	if _, err := s.AddDocument(customer, sender, testDocument()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Submit(customer, sender); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := s.Status(customer); status != models.KYCPending {
+		t.Fatalf("status after submit = %s", status)
+	}
+	if _, err := s.SaveSender(customer, passportDetails(testNow.AddDate(1, 0, 0))); !errors.Is(err, ErrLocked) {
+		t.Fatalf("edit while pending: %v", err)
+	}
+
+	next, err := s.Review(staff, Subject{Type: models.KYCSubjectSender, ID: saved.ID}, true, "")
+	if err != nil || next != models.KYCVerified {
+		t.Fatalf("review = %s, %v", next, err)
+	}
+	if err := s.RequireVerified(customer); err != nil {
+		t.Fatal(err)
+	}
+
+	// The passport lapses: the sweep expires the verification and real money is blocked again.
+	s.now = func() time.Time { return testNow.AddDate(0, 3, 0) }
+	if n, err := s.ExpireDue(); err != nil || n != 1 {
+		t.Fatalf("ExpireDue = %d, %v", n, err)
+	}
+	if status, _ := s.Status(customer); status != models.KYCExpired {
+		t.Fatalf("status after expiry = %s", status)
+	}
+	if _, err := s.SaveSender(customer, passportDetails(testNow.AddDate(2, 0, 0))); err != nil {
+		t.Fatalf("renewal edit: %v", err)
+	}
+	if err := s.Submit(customer, sender); err != nil {
+		t.Fatalf("resubmit: %v", err)
+	}
+}
+
+func TestReviewRules(t *testing.T) {
+	s, db := newTestService(t)
+	customer := uuid.New()
+	staff := backoffice.Actor{ID: uuid.New(), Role: models.RoleAdmin}
+	saved, err := s.SaveSender(customer, passportDetails(testNow.AddDate(0, 0, 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := Subject{Type: models.KYCSubjectSender, ID: saved.ID}
+
+	if _, err := s.Review(staff, subject, true, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("review before submit: %v", err)
+	}
+	if _, err := s.AddDocument(customer, subject, testDocument()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Submit(customer, subject); err != nil {
+		t.Fatal(err)
+	}
+
+	// The passport expires while the file sits in the queue.
+	s.now = func() time.Time { return testNow.AddDate(0, 1, 0) }
+	if _, err := s.Review(staff, subject, true, ""); !errors.Is(err, models.ErrIDExpired) {
+		t.Fatalf("approve lapsed document: %v", err)
+	}
+	if next, err := s.Review(staff, subject, false, "document expired"); err != nil || next != models.KYCRejected {
+		t.Fatalf("reject = %s, %v", next, err)
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action = ?", "kyc.rejected").Count(&audits)
+	if audits != 1 {
+		t.Fatalf("audit rows = %d", audits)
+	}
+}
+
+func TestAddDocumentValidation(t *testing.T) {
+	s, _ := newTestService(t)
+	customer := uuid.New()
+	if _, err := s.SaveSender(customer, passportDetails(testNow.AddDate(1, 0, 0))); err != nil {
+		t.Fatal(err)
+	}
+	sender := Subject{Type: models.KYCSubjectSender}
+	for name, edit := range map[string]func(d *models.KYCDocument){
+		"kind":         func(d *models.KYCDocument) { d.Kind = "tax_return" },
+		"content type": func(d *models.KYCDocument) { d.ContentType = "text/html" },
+		"hash":         func(d *models.KYCDocument) { d.SHA256 = "abc" },
+		"too large":    func(d *models.KYCDocument) { d.SizeBytes = MaxDocumentBytes + 1 },
+	} {
+		doc := testDocument()
+		edit(&doc)
+		if _, err := s.AddDocument(customer, sender, doc); !errors.Is(err, ErrInvalidDocument) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+	if _, err := s.AddDocument(uuid.New(), Subject{Type: models.KYCSubjectRecipient, ID: uuid.New()}, testDocument()); !errors.Is(err, ErrSubjectNotFound) {
+		t.Errorf("foreign recipient: %v", err)
+	}
+}
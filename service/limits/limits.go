@@ -0,0 +1,288 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var ErrRuleNotFound = errors.New("limit rule not found")
+
+const day = 24 * 60 * 60
+
+// KYCStatusReader reports the customer's KYC level for tiered rules
+type KYCStatusReader interface {
+	Status(customerID uuid.UUID) (models.KYCStatus, error)
+}
+
+// actionTypes maps a limited action to the ledger rows that count against it
+var actionTypes = map[models.LimitAction][]models.TransactionType{
+	models.LimitDeposit:  {models.TransactionTypeDeposit},
+	models.LimitWithdraw: {models.TransactionTypeWithdraw},
+	models.LimitTrade:    {models.TransactionTypeTrade},
+}
+
+// countedStatuses are ledger rows that used up allowance; failed and
+// reversed ones gave it back
+var countedStatuses = []models.TransactionStatus{models.StatusPending, models.StatusSuccess}
+
+// DefaultRules are installed by EnsureDefaults on an empty table. Real-money
+// caps follow M-Pesa's per-transaction and daily ceilings; fake-book deposits
+// are tiered so unverified customers get a smaller practice allowance.
+func DefaultRules() []models.LimitRule {
+	return []models.LimitRule{
+		{Name: "Real deposit per transaction", Action: models.LimitDeposit, Kind: models.LimitPerTransaction, Book: models.BookReal, MaxCents: 15000000},
+		{Name: "Real deposits per day", Action: models.LimitDeposit, Kind: models.LimitWindowSum, Book: models.BookReal, MaxCents: 30000000, WindowSeconds: day},
+		{Name: "Real deposits per 30 days", Action: models.LimitDeposit, Kind: models.LimitWindowSum, Book: models.BookReal, MaxCents: 300000000, WindowSeconds: 30 * day},
+		{Name: "Real deposit count per day", Action: models.LimitDeposit, Kind: models.LimitWindowCount, Book: models.BookReal, MaxCount: 20, WindowSeconds: day},
+		{Name: "Real withdrawal per transaction", Action: models.LimitWithdraw, Kind: models.LimitPerTransaction, Book: models.BookReal, MaxCents: 15000000},
+		{Name: "Real withdrawals per day", Action: models.LimitWithdraw, Kind: models.LimitWindowSum, Book: models.BookReal, MaxCents: 30000000, WindowSeconds: day},
+		{Name: "Real withdrawal count per day", Action: models.LimitWithdraw, Kind: models.LimitWindowCount, Book: models.BookReal, MaxCount: 10, WindowSeconds: day},
+		{Name: "Real trade per transaction", Action: models.LimitTrade, Kind: models.LimitPerTransaction, Book: models.BookReal, MaxCents: 10000000},
+		{Name: "Real trades per hour", Action: models.LimitTrade, Kind: models.LimitWindowCount, Book: models.BookReal, MaxCount: 120, WindowSeconds: 60 * 60},
+		{Name: "Fake trade per transaction", Action: models.LimitTrade, Kind: models.LimitPerTransaction, Book: models.BookFake, MaxCents: 10000000},
+		{Name: "Practice deposits per day (unverified)", Action: models.LimitDeposit, Kind: models.LimitWindowSum, Book: models.BookFake, KYCStatus: models.KYCUnverified, MaxCents: 10000000, WindowSeconds: day},
+		{Name: "Practice deposits per day (verified)", Action: models.LimitDeposit, Kind: models.LimitWindowSum, Book: models.BookFake, KYCStatus: models.KYCVerified, MaxCents: 100000000, WindowSeconds: day},
+	}
+}
+
+// Service evaluates limit rules against the ledger
+type Service struct {
+	db  *gorm.DB
+	kyc KYCStatusReader
+	now func() time.Time
+}
+
+func NewService(db *gorm.DB, kyc KYCStatusReader) *Service {
+	return &Service{db: db, kyc: kyc, now: time.Now}
+}
+
+// EnsureDefaults installs DefaultRules when no rules exist yet
+func (s *Service) EnsureDefaults() error {
+	var n int64
+	if err := s.db.Model(&models.LimitRule{}).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	rules := DefaultRules()
+	for i := range rules {
+		rules[i].ID = uuid.New()
+		rules[i].Enabled = true
+	}
+	return s.db.Create(&rules).Error
+}
+
+// Check returns the first limit req would break, or nil if it may proceed.
+// Checks read committed ledger rows, so two concurrent requests can both pass
+// a window rule they jointly exceed; the per-transaction caps still hold.
+func (s *Service) Check(req models.LimitRequest) (*models.LimitViolation, error) {
+	if v, err := s.checkSharpBounds(req); v != nil || err != nil {
+		return v, err
+	}
+	status := models.KYCUnverified
+	if s.kyc != nil {
+		var err error
+		if status, err = s.kyc.Status(req.CustomerID); err != nil {
+			return nil, err
+		}
+	}
+	var rules []models.LimitRule
+	if err := s.db.Where("action = ? AND enabled = ?", req.Action, true).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	// Cheapest first: per-transaction rules need no ledger query.
+	slices.SortStableFunc(rules, func(a, b models.LimitRule) int {
+		return kindOrder(a.Kind) - kindOrder(b.Kind)
+	})
+	for i := range rules {
+		r := &rules[i]
+		if !r.Applies(req, status) {
+			continue
+		}
+		v, err := s.evaluate(r, req)
+		if v != nil || err != nil {
+			return v, err
+		}
+	}
+	return nil, nil
+}
+
+func kindOrder(k models.LimitKind) int {
+	if k == models.LimitPerTransaction {
+		return 0
+	}
+	return 1
+}
+
+func (s *Service) evaluate(r *models.LimitRule, req models.LimitRequest) (*models.LimitViolation, error) {
+	v := &models.LimitViolation{
+		RuleID:         &r.ID,
+		Rule:           r.Name,
+		Kind:           r.Kind,
+		AttemptedCents: req.AmountCents,
+		WindowSeconds:  r.WindowSeconds,
+	}
+	switch r.Kind {
+	case models.LimitPerTransaction:
+		if req.AmountCents > r.MaxCents {
+			v.LimitCents = r.MaxCents
+			v.Reason = fmt.Sprintf("amount exceeds the %d cent per-transaction limit", r.MaxCents)
+			return v, nil
+		}
+	case models.LimitWindowSum, models.LimitWindowCount:
+		sum, count, err := s.usage(r, req)
+		if err != nil {
+			return nil, err
+		}
+		if r.Kind == models.LimitWindowSum && sum+req.AmountCents > r.MaxCents {
+			v.LimitCents, v.UsedCents = r.MaxCents, sum
+			v.Reason = fmt.Sprintf("amount would exceed the %d cent limit for this period; %d remaining", r.MaxCents, max(r.MaxCents-sum, 0))
+			return v, nil
+		}
+		if r.Kind == models.LimitWindowCount && count+1 > r.MaxCount {
+			v.LimitCount, v.UsedCount = r.MaxCount, count
+			v.Reason = fmt.Sprintf("at most %d transactions are allowed in this period", r.MaxCount)
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// usage sums the customer's ledger rows that count against r over its window
+func (s *Service) usage(r *models.LimitRule, req models.LimitRequest) (sum, count int64, err error) {
+	q := s.db.Model(&models.Transaction{}).
+		Where("customer_id = ? AND type IN ? AND status IN ? AND created_at >= ?",
+			req.CustomerID, actionTypes[r.Action], countedStatuses, s.now().Add(-r.Window()))
+	if r.Book != "" {
+		q = q.Where("is_real = ?", r.Book == models.BookReal)
+	}
+	if r.AssetClass != "" {
+		column := r.AssetClass + "_account_id"
+		q = q.Where(column+" IS NOT NULL AND "+column+" <> ?", uuid.Nil)
+	}
+	var row struct {
+		Sum   int64
+		Count int64
+	}
+	err = q.Select("COALESCE(SUM(amount_cents), 0) AS sum, COUNT(*) AS count").Scan(&row).Error
+	return row.Sum, row.Count, err
+}
+
+// checkSharpBounds applies the Sharp's own MinTradeCents/MaxTradeCents to
+// trades on a sharp account
+func (s *Service) checkSharpBounds(req models.LimitRequest) (*models.LimitViolation, error) {
+	if req.Action != models.LimitTrade || req.AssetClass != "sharp" || req.AccountID == uuid.Nil {
+		return nil, nil
+	}
+	var bounds struct {
+		Name          string
+		MinTradeCents int64
+		MaxTradeCents int64
+	}
+	err := s.db.Table("sharp_accounts").
+		Select("sharps.name, sharps.min_trade_cents, sharps.max_trade_cents").
+		Joins("JOIN sharps ON sharps.id = sharp_accounts.sharp_id").
+		Where("sharp_accounts.id = ?", req.AccountID).
+		Scan(&bounds).Error
+	if err != nil {
+		return nil, err
+	}
+	v := &models.LimitViolation{Kind: models.LimitPerTransaction, AttemptedCents: req.AmountCents}
+	switch {
+	case bounds.MinTradeCents > 0 && req.AmountCents < bounds.MinTradeCents:
+		v.Rule = bounds.Name + " minimum trade"
+		v.LimitCents = bounds.MinTradeCents
+		v.Reason = fmt.Sprintf("amount is below the %d cent minimum trade", bounds.MinTradeCents)
+		return v, nil
+	case bounds.MaxTradeCents > 0 && req.AmountCents > bounds.MaxTradeCents:
+		v.Rule = bounds.Name + " maximum trade"
+		v.LimitCents = bounds.MaxTradeCents
+		v.Reason = fmt.Sprintf("amount exceeds the %d cent maximum trade", bounds.MaxTradeCents)
+		return v, nil
+	}
+	return nil, nil
+}
+
+// Rules lists every rule, enabled or not
+func (s *Service) Rules() ([]models.LimitRule, error) {
+	var rules []models.LimitRule
+	err := s.db.Order("action, kind, name").Find(&rules).Error
+	return rules, err
+}
+
+// CreateRule adds a rule on behalf of a staff member
+func (s *Service) CreateRule(actor backoffice.Actor, rule models.LimitRule) (*models.LimitRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	rule.ID = uuid.New()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rule).Error; err != nil {
+			return err
+		}
+		return backoffice.Audit(tx, actor, "limits.create", "limit_rule", rule.ID, ruleDetails(&rule))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateRule replaces a rule's settings on behalf of a staff member
+func (s *Service) UpdateRule(actor backoffice.Actor, id uuid.UUID, rule models.LimitRule) (*models.LimitRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	rule.ID = id
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.LimitRule{}).Where("id = ?", id).Select(
+			"Name", "Action", "Kind", "AssetClass", "Book", "KYCStatus", "MaxCents", "MaxCount", "WindowSeconds", "Enabled",
+		).Updates(&rule)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRuleNotFound
+		}
+		return backoffice.Audit(tx, actor, "limits.update", "limit_rule", id, ruleDetails(&rule))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteRule removes a rule on behalf of a staff member
+func (s *Service) DeleteRule(actor backoffice.Actor, id uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&models.LimitRule{}, "id = ?", id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRuleNotFound
+		}
+		return backoffice.Audit(tx, actor, "limits.delete", "limit_rule", id, nil)
+	})
+}
+
+func ruleDetails(r *models.LimitRule) models.JSONMap {
+	return models.JSONMap{
+		"name":           r.Name,
+		"action":         string(r.Action),
+		"kind":           string(r.Kind),
+		"max_cents":      r.MaxCents,
+		"max_count":      r.MaxCount,
+		"window_seconds": r.WindowSeconds,
+		"enabled":        r.Enabled,
+	}
+}
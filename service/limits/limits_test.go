@@ -0,0 +1,176 @@
+package limits
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+type kycStatuses map[uuid.UUID]models.KYCStatus
+
+func (k kycStatuses) Status(id uuid.UUID) (models.KYCStatus, error) {
+	if s, ok := k[id]; ok {
+		return s, nil
+	}
+	return models.KYCUnverified, nil
+}
+
+func newTestService(t *testing.T, kyc kycStatuses) (*Service, *gorm.DB) {
+	t.Helper()
+	db := testdb.Open(t, &models.LimitRule{}, &models.Transaction{}, &models.AuditLog{}, &models.Sharp{}, &models.SharpAccount{})
+	s := NewService(db, kyc)
+	s.now = func() time.Time { return testNow }
+	if err := s.EnsureDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	return s, db
+}
+
+// ledger writes a successful transaction at the given age
+func ledger(t *testing.T, db *gorm.DB, customer uuid.UUID, typ models.TransactionType, isReal bool, amount int64, age time.Duration) {
+	t.Helper()
+	tx := models.Transaction{
+		ID:             uuid.New(),
+		CustomerID:     customer,
+		ForexAccountID: uuid.New(),
+		Type:           typ,
+		AmountCents:    amount,
+		IsReal:         isReal,
+		Status:         models.StatusSuccess,
+		Reference:      uuid.NewString()[:8],
+		IdempotencyKey: uuid.NewString(),
+	}
+	tx.CreatedAt = testNow.Add(-age)
+	if err := db.Omit(clause.Associations).Create(&tx).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWindowSum(t *testing.T) {
+	s, db := newTestService(t, nil)
+	customer := uuid.New()
+	deposit := models.LimitRequest{CustomerID: customer, Action: models.LimitDeposit, IsReal: true, AmountCents: 10000000}
+
+	ledger(t, db, customer, models.TransactionTypeDeposit, true, 15000000, 2*time.Hour)
+	ledger(t, db, customer, models.TransactionTypeDeposit, true, 100, 3*time.Hour)
+	ledger(t, db, customer, models.TransactionTypeDeposit, true, 15000000, 25*time.Hour) // outside the day
+	ledger(t, db, customer, models.TransactionTypeDeposit, false, 15000000, time.Hour)   // other book
+
+	if v, err := s.Check(deposit); err != nil || v != nil {
+		t.Fatalf("within limit: %+v, %v", v, err)
+	}
+	deposit.AmountCents = 15000001
+	v, err := s.Check(deposit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v == nil || v.Kind != models.LimitPerTransaction {
+		t.Fatalf("per-transaction cap checked first: %+v", v)
+	}
+	deposit.AmountCents = 15000000
+	v, err = s.Check(deposit)
+	if err != nil || v == nil {
+		t.Fatalf("daily sum: %+v, %v", v, err)
+	}
+	if v.Kind != models.LimitWindowSum || v.UsedCents != 15000100 || v.LimitCents != 30000000 || v.WindowSeconds != day {
+		t.Fatalf("violation = %+v", v)
+	}
+}
+
+func TestWindowCountAndTiers(t *testing.T) {
+	verified := uuid.New()
+	s, db := newTestService(t, kycStatuses{verified: models.KYCVerified})
+	for i := 0; i < 10; i++ {
+		ledger(t, db, verified, models.TransactionTypeWithdraw, true, 100, time.Minute)
+	}
+	v, err := s.Check(models.LimitRequest{CustomerID: verified, Action: models.LimitWithdraw, IsReal: true, AmountCents: 100})
+	if err != nil || v == nil || v.Kind != models.LimitWindowCount || v.UsedCount != 10 {
+		t.Fatalf("withdraw count: %+v, %v", v, err)
+	}
+
+	// Practice deposits: the unverified tier is capped at KES 100,000 a day, verified at ten times that.
+	practice := models.LimitRequest{Action: models.LimitDeposit, AmountCents: 20000000}
+	practice.CustomerID = uuid.New()
+	if v, _ := s.Check(practice); v == nil || v.LimitCents != 10000000 {
+		t.Fatalf("unverified practice deposit: %+v", v)
+	}
+	practice.CustomerID = verified
+	if v, _ := s.Check(practice); v != nil {
+		t.Fatalf("verified practice deposit: %+v", v)
+	}
+}
+
+func TestSharpBounds(t *testing.T) {
+	s, db := newTestService(t, nil)
+	sharp, account := uuid.New(), uuid.New()
+	db.Exec("INSERT INTO sharps (id, name, account_number, min_trade_cents, max_trade_cents) VALUES (?, 'Sharp One', 'SH-1', 500, 50000)", sharp)
+	db.Exec("INSERT INTO sharp_accounts (id, sharp_id, customer_id, sharp_profile_id) VALUES (?, ?, ?, ?)", account, sharp, uuid.New(), uuid.New())
+
+	trade := models.LimitRequest{CustomerID: uuid.New(), AccountID: account, AssetClass: "sharp", Action: models.LimitTrade, AmountCents: 100}
+	if v, _ := s.Check(trade); v == nil || v.LimitCents != 500 {
+		t.Fatalf("below minimum: %+v", v)
+	}
+	trade.AmountCents = 60000
+	if v, _ := s.Check(trade); v == nil || v.Rule != "Sharp One maximum trade" {
+		t.Fatalf("above maximum: %+v", v)
+	}
+	trade.AmountCents = 10000
+	if v, err := s.Check(trade); v != nil || err != nil {
+		t.Fatalf("within bounds: %+v, %v", v, err)
+	}
+}
+
+func TestRuleAdministration(t *testing.T) {
+	s, db := newTestService(t, nil)
+	admin := backoffice.Actor{ID: uuid.New(), Role: models.RoleAdmin}
+	customer := uuid.New()
+
+	if _, err := s.CreateRule(admin, models.LimitRule{Name: "bad", Action: models.LimitTrade, Kind: models.LimitWindowSum, MaxCents: 1}); !errors.Is(err, models.ErrInvalidLimitRule) {
+		t.Fatalf("window rule without window: %v", err)
+	}
+	rule, err := s.CreateRule(admin, models.LimitRule{
+		Name: "Crypto trade cap", Action: models.LimitTrade, Kind: models.LimitPerTransaction,
+		AssetClass: "crypto", MaxCents: 1000, Enabled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crypto := models.LimitRequest{CustomerID: customer, AssetClass: "crypto", Action: models.LimitTrade, AmountCents: 2000}
+	if v, _ := s.Check(crypto); v == nil || *v.RuleID != rule.ID {
+		t.Fatalf("asset class rule: %+v", v)
+	}
+	crypto.AssetClass = "stock"
+	if v, _ := s.Check(crypto); v != nil {
+		t.Fatalf("rule leaked to another class: %+v", v)
+	}
+
+	rule.Enabled = false
+	if _, err := s.UpdateRule(admin, rule.ID, *rule); err != nil {
+		t.Fatal(err)
+	}
+	crypto.AssetClass = "crypto"
+	if v, _ := s.Check(crypto); v != nil {
+		t.Fatalf("disabled rule applied: %+v", v)
+	}
+	if err := s.DeleteRule(admin, rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteRule(admin, rule.ID); !errors.Is(err, ErrRuleNotFound) {
+		t.Fatalf("second delete: %v", err)
+	}
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("target_type = ?", "limit_rule").Count(&audits)
+	if audits != 3 {
+		t.Fatalf("audit rows = %d", audits)
+	}
+}
@@ -0,0 +1,272 @@
+package onboarding
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+	"weriKana/service/auth"
+)
+
+var (
+	ErrInvalidCustomer  = errors.New("invalid customer")
+	ErrInvalidAccount   = errors.New("invalid account request")
+	ErrUnknownProvider  = errors.New("unknown bookie or sharp")
+	ErrDuplicateAccount = errors.New("account requested twice")
+)
+
+// AccountRequest asks for one account. ProviderID is the bookie for asset
+// accounts and the sharp for "sharp" accounts.
+type AccountRequest struct {
+	Type        string    `json:"type"`
+	ProviderID  uuid.UUID `json:"provider_id"`
+	MpesaNumber string    `json:"mpesa_number"` // defaults to the customer's M-PESA number
+}
+
+// Request is everything needed to onboard a customer
+type Request struct {
+	Name           string           `json:"name"`
+	Email          string           `json:"email"`
+	Phone          string           `json:"phone"`
+	PreferredMpesa string           `json:"preferred_mpesa"`
+	Password       string           `json:"password"`
+	Accounts       []AccountRequest `json:"accounts"`
+}
+
+// Nexus summarizes the customer's AssetNexus and its managers
+type Nexus struct {
+	ID       uuid.UUID            `json:"id"`
+	Name     string               `json:"name"`
+	Managers map[string]uuid.UUID `json:"managers"` // asset class -> manager id
+}
+
+// Profile is a created SharpProfile row
+type Profile struct {
+	ID         uuid.UUID `json:"id"`
+	AssetClass string    `json:"asset_class"`
+}
+
+// Account is a created account of any type
+type Account struct {
+	ID          uuid.UUID `json:"id"`
+	Type        string    `json:"account_type"`
+	ProviderID  uuid.UUID `json:"provider_id"`
+	ManagerID   uuid.UUID `json:"manager_id,omitempty"`
+	MpesaNumber string    `json:"mpesa_number"`
+	Currency    string    `json:"currency"`
+}
+
+// Graph is what Onboard created
+type Graph struct {
+	Customer      *models.Customer `json:"customer"`
+	Nexus         Nexus            `json:"asset_nexus"`
+	SharpProfiles []Profile        `json:"sharp_profiles"`
+	Accounts      []Account        `json:"accounts"`
+}
+
+// Service provisions a customer together with their nexus, profiles and accounts
+type Service struct {
+	db     *gorm.DB
+	argon2 auth.Argon2Params
+	now    func() time.Time
+}
+
+func NewService(db *gorm.DB, argon2 auth.Argon2Params) *Service {
+	return &Service{db: db, argon2: argon2, now: time.Now}
+}
+
+// Onboard validates req and creates the whole graph in one transaction
+func (s *Service) Onboard(req Request) (*Graph, error) {
+	now := s.now()
+	customer := &models.Customer{
+		ID:             uuid.New(),
+		Name:           strings.TrimSpace(req.Name),
+		Email:          strings.ToLower(strings.TrimSpace(req.Email)),
+		Phone:          strings.TrimSpace(req.Phone),
+		PreferredMpesa: strings.TrimSpace(req.PreferredMpesa),
+	}
+	if customer.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidCustomer)
+	}
+	if customer.Email != "" {
+		if _, err := models.NormalizeEmail(customer.Email); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCustomer, err)
+		}
+	}
+	// Same rules (and normalization) the insert hook applies, checked up front
+	// so bad input is a 400 rather than a failed transaction.
+	if err := customer.BeforeCreate(nil); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCustomer, err)
+	}
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		return nil, err
+	}
+	if err := validateAccounts(req.Accounts); err != nil {
+		return nil, err
+	}
+	hash, err := auth.HashPassword(req.Password, s.argon2)
+	if err != nil {
+		return nil, err
+	}
+	customer.PasswordHash = hash
+	customer.PasswordChangedAt = &now
+
+	graph := &Graph{Customer: customer}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Customer{}).Where("email = ? OR phone = ?", customer.Email, customer.Phone).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return auth.ErrAlreadyRegistered
+		}
+		if err := tx.Omit(clause.Associations).Create(customer).Error; err != nil {
+			return err
+		}
+
+		nexus, err := createNexus(tx, customer.ID)
+		if err != nil {
+			return err
+		}
+		graph.Nexus = nexus
+
+		profiles := make(map[string]uuid.UUID, len(models.AccountTypes))
+		for _, class := range models.AccountTypes {
+			p := &models.SharpProfile{ID: uuid.New(), CustomerID: customer.ID, AssetClass: class}
+			if err := tx.Omit(clause.Associations).Create(p).Error; err != nil {
+				return err
+			}
+			profiles[class] = p.ID
+			graph.SharpProfiles = append(graph.SharpProfiles, Profile{ID: p.ID, AssetClass: class})
+		}
+
+		defaultMpesa := customer.PreferredMpesa
+		if defaultMpesa == "" {
+			defaultMpesa = customer.Phone
+		}
+		for _, a := range req.Accounts {
+			mpesa := defaultMpesa
+			if a.MpesaNumber != "" {
+				// validated in validateAccounts
+				mpesa, _ = models.NormalizePhone(a.MpesaNumber)
+			}
+			acct, err := createAccount(tx, customer.ID, a, mpesa, nexus.Managers[a.Type], profiles["sharp"])
+			if err != nil {
+				return err
+			}
+			graph.Accounts = append(graph.Accounts, acct)
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, auth.ErrAlreadyRegistered
+	}
+	if err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// validateAccounts checks types, providers and duplicates before anything is written
+func validateAccounts(reqs []AccountRequest) error {
+	seen := make(map[string]bool, len(reqs))
+	for _, a := range reqs {
+		valid := false
+		for _, t := range models.AccountTypes {
+			valid = valid || a.Type == t
+		}
+		if !valid {
+			return fmt.Errorf("%w: unknown account type %q", ErrInvalidAccount, a.Type)
+		}
+		if a.ProviderID == uuid.Nil {
+			return fmt.Errorf("%w: provider_id is required", ErrInvalidAccount)
+		}
+		if a.MpesaNumber != "" {
+			if _, err := models.NormalizePhone(a.MpesaNumber); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidAccount, err)
+			}
+		}
+		key := a.Type + ":" + a.ProviderID.String()
+		if seen[key] {
+			return ErrDuplicateAccount
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// createNexus creates the four asset managers and the nexus that links them
+func createNexus(tx *gorm.DB, customerID uuid.UUID) (Nexus, error) {
+	managers := map[string]uuid.UUID{
+		"sports": uuid.New(),
+		"stock":  uuid.New(),
+		"forex":  uuid.New(),
+		"crypto": uuid.New(),
+	}
+	rows := []interface{}{
+		&models.SportsManager{ID: managers["sports"]},
+		&models.StockManager{ID: managers["stock"]},
+		&models.ForexManager{ID: managers["forex"]},
+		&models.CryptoManager{ID: managers["crypto"]},
+	}
+	for _, m := range rows {
+		if err := tx.Create(m).Error; err != nil {
+			return Nexus{}, err
+		}
+	}
+
+	nexus := &models.AssetNexus{
+		ID:              uuid.New(),
+		CustomerID:      customerID,
+		Name:            "Customer Asset Nexus",
+		SportsManagerID: managers["sports"],
+		StockManagerID:  managers["stock"],
+		ForexManagerID:  managers["forex"],
+		CryptoManagerID: managers["crypto"],
+	}
+	if err := tx.Omit(clause.Associations).Create(nexus).Error; err != nil {
+		return Nexus{}, err
+	}
+	return Nexus{ID: nexus.ID, Name: nexus.Name, Managers: managers}, nil
+}
+
+// createAccount inserts one account row, checking its bookie or sharp exists
+func createAccount(tx *gorm.DB, customerID uuid.UUID, a AccountRequest, mpesa string, managerID, sharpProfileID uuid.UUID) (Account, error) {
+	providerTable := "bookies"
+	if a.Type == "sharp" {
+		providerTable = "sharps"
+	}
+	var exists int64
+	if err := tx.Table(providerTable).Where("id = ? AND deleted_at IS NULL", a.ProviderID).Count(&exists).Error; err != nil {
+		return Account{}, err
+	}
+	if exists == 0 {
+		return Account{}, fmt.Errorf("%w: %s", ErrUnknownProvider, a.ProviderID)
+	}
+
+	id := uuid.New()
+	var row interface{}
+	switch a.Type {
+	case "sharp":
+		row = &models.SharpAccount{ID: id, SharpID: a.ProviderID, CustomerID: customerID, SharpProfileID: sharpProfileID, MpesaNumber: mpesa, IsActive: true}
+		managerID = uuid.Nil
+	case "sports":
+		row = &models.SportsAccount{ID: id, CustomerID: customerID, BookieID: a.ProviderID, ManagerID: managerID, MpesaNumber: mpesa, IsActive: true}
+	case "stock":
+		row = &models.StockAccount{ID: id, CustomerID: customerID, BookieID: a.ProviderID, ManagerID: managerID, MpesaNumber: mpesa, IsActive: true}
+	case "forex":
+		row = &models.ForexAccount{ID: id, CustomerID: customerID, BookieID: a.ProviderID, ManagerID: managerID, MpesaNumber: mpesa, IsActive: true}
+	case "crypto":
+		row = &models.CryptoAccount{ID: id, CustomerID: customerID, BookieID: a.ProviderID, ManagerID: managerID, MpesaNumber: mpesa, IsActive: true}
+	}
+	if err := tx.Omit(clause.Associations).Create(row).Error; err != nil {
+		return Account{}, err
+	}
+	return Account{ID: id, Type: a.Type, ProviderID: a.ProviderID, ManagerID: managerID, MpesaNumber: mpesa, Currency: "KES"}, nil
+}
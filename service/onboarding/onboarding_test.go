@@ -0,0 +1,113 @@
+package onboarding
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/auth"
+)
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	db := testdb.Open(t,
+		&models.Customer{}, &models.AssetNexus{}, &models.SharpProfile{},
+		&models.SportsManager{}, &models.StockManager{}, &models.ForexManager{}, &models.CryptoManager{},
+		&models.SportsAccount{}, &models.StockAccount{}, &models.ForexAccount{}, &models.CryptoAccount{},
+		&models.SharpAccount{}, &models.Bookie{}, &models.Sharp{},
+	)
+	params := auth.Argon2Params{Memory: 1024, Time: 1, Threads: 1, KeyLen: 32, SaltLen: 16}
+	return NewService(db, params), db
+}
+
+func validRequest() Request {
+	return Request{
+		Name:     "Wanjiru Kamau",
+		Email:    "Wanjiru@Example.com",
+		Phone:    "0712345678",
+		Password: "correct horse battery",
+	}
+}
+
+func TestOnboard(t *testing.T) {
+	s, db := newTestService(t)
+	bookie, sharp := uuid.New(), uuid.New()
+	db.Exec("INSERT INTO bookies (id, name) VALUES (?, 'SportPesa')", bookie)
+	db.Exec("INSERT INTO sharps (id, name, account_number) VALUES (?, 'Sharp One', 'SH-1')", sharp)
+
+	req := validRequest()
+	req.Accounts = []AccountRequest{
+		{Type: "sports", ProviderID: bookie},
+		{Type: "crypto", ProviderID: bookie, MpesaNumber: "0722000111"},
+		{Type: "sharp", ProviderID: sharp},
+	}
+	g, err := s.Onboard(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Customer.Phone != "+254712345678" || g.Customer.Email != "wanjiru@example.com" {
+		t.Fatalf("customer not normalized: %+v", g.Customer)
+	}
+	if len(g.SharpProfiles) != len(models.AccountTypes) || len(g.Nexus.Managers) != 4 {
+		t.Fatalf("graph = %+v", g)
+	}
+	if len(g.Accounts) != 3 {
+		t.Fatalf("accounts = %+v", g.Accounts)
+	}
+	if g.Accounts[0].ManagerID != g.Nexus.Managers["sports"] || g.Accounts[0].MpesaNumber != "+254712345678" {
+		t.Errorf("sports account = %+v", g.Accounts[0])
+	}
+	if g.Accounts[1].MpesaNumber != "+254722000111" {
+		t.Errorf("crypto account = %+v", g.Accounts[1])
+	}
+
+	refs, err := models.ListAccountRefs(db, g.Customer.ID)
+	if err != nil || len(refs) != 3 {
+		t.Fatalf("ListAccountRefs = %v, %v", refs, err)
+	}
+
+	if _, err := s.Onboard(validRequest()); !errors.Is(err, auth.ErrAlreadyRegistered) {
+		t.Fatalf("second onboard: %v", err)
+	}
+}
+
+func TestOnboardValidation(t *testing.T) {
+	s, db := newTestService(t)
+	bookie := uuid.New()
+	db.Exec("INSERT INTO bookies (id, name) VALUES (?, 'Betika')", bookie)
+
+	cases := map[string]struct {
+		edit func(r *Request)
+		want error
+	}{
+		"bad phone":     {func(r *Request) { r.Phone = "12345" }, ErrInvalidCustomer},
+		"bad email":     {func(r *Request) { r.Email = "nope" }, ErrInvalidCustomer},
+		"missing name":  {func(r *Request) { r.Name = " " }, ErrInvalidCustomer},
+		"bad mpesa":     {func(r *Request) { r.PreferredMpesa = "0800" }, ErrInvalidCustomer},
+		"weak password": {func(r *Request) { r.Password = "short" }, auth.ErrWeakPassword},
+		"bad type":      {func(r *Request) { r.Accounts = []AccountRequest{{Type: "bonds", ProviderID: bookie}} }, ErrInvalidAccount},
+		"duplicate": {func(r *Request) {
+			r.Accounts = []AccountRequest{{Type: "stock", ProviderID: bookie}, {Type: "stock", ProviderID: bookie}}
+		}, ErrDuplicateAccount},
+		"unknown bookie": {func(r *Request) { r.Accounts = []AccountRequest{{Type: "forex", ProviderID: uuid.New()}} }, ErrUnknownProvider},
+	}
+	for name, tc := range cases {
+		req := validRequest()
+		tc.edit(&req)
+		if _, err := s.Onboard(req); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+
+	// The unknown-bookie case failed mid-transaction; nothing may remain.
+	var customers, nexuses int64
+	db.Model(&models.Customer{}).Count(&customers)
+	db.Model(&models.AssetNexus{}).Count(&nexuses)
+	if customers != 0 || nexuses != 0 {
+		t.Fatalf("rolled-back onboarding left %d customers, %d nexuses", customers, nexuses)
+	}
+}
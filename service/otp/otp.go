@@ -1,57 +1,168 @@
 package otp
 
 import (
-    "fmt"
-    "crypto/rand"
-    "sync"
-    "time"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
-    "github.com/google/uuid"
-    "weriKana/service/natsAnish" // Import the natsAnish package
+	"github.com/google/uuid"
+
+	"weriKana/models"
+)
+
+// Purpose binds a code to the action it authorizes
+type Purpose string
+
+const (
+	PurposeWithdraw      Purpose = "withdraw"
+	PurposeLogin         Purpose = "login"
+	PurposePasswordReset Purpose = "password_reset"
 )
 
 var (
-    cache = make(map[uuid.UUID]string)
-    mu    sync.RWMutex
+	ErrInvalidCode = errors.New("invalid or expired OTP")
+	ErrLocked      = errors.New("too many OTP attempts, try again later")
+	ErrCooldown    = errors.New("OTP recently sent, wait before requesting another")
 )
 
-// GenerateOTP returns a 6-digit string (000000–999999)
-func GenerateOTP() (string, error) {
-        b := make([]byte, 3)
-        if _, err := rand.Read(b); err != nil {
-                return "", err
-        }
-        n := (uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])) % 1_000_000
-        return fmt.Sprintf("%06d", n), nil
+// Publisher is the NATS subset used to hand SMS to the gateway (*nats.Conn satisfies it)
+type Publisher interface {
+	Publish(subject string, data []byte) error
 }
 
-func Send(customerID uuid.UUID) string {
-    otp := fmt.Sprintf("%06d", GenerateOTP())
+type Config struct {
+	HashKey        []byte        // HMAC key for stored codes
+	TTL            time.Duration // code lifetime
+	MaxAttempts    int           // failed verifications before lockout
+	LockoutPeriod  time.Duration
+	ResendCooldown time.Duration // minimum gap between sends
+}
 
-    mu.Lock()
-    cache[customerID] = otp
-    mu.Unlock()
+// DefaultConfig returns the production limits for hashKey
+func DefaultConfig(hashKey []byte) Config {
+	return Config{
+		HashKey:        hashKey,
+		TTL:            5 * time.Minute,
+		MaxAttempts:    5,
+		LockoutPeriod:  15 * time.Minute,
+		ResendCooldown: time.Minute,
+	}
+}
 
-    go func() {
-        time.Sleep(5 * time.Minute)
-        mu.Lock()
-        delete(cache, customerID)
-        mu.Unlock()
-    }()
+type Service struct {
+	store Store
+	sms   Publisher
+	cfg   Config
+	now   func() time.Time
+}
 
-    // Use the Publish function from natsAnish
-    err := natsAnish.Publish("sms.send", []byte(fmt.Sprintf(`{"to":"user_phone","msg":"BankRoll OTP: %s"}`, otp)))
-    if err != nil {
-        fmt.Println("Error publishing to NATS:", err)
-    }
+func New(store Store, sms Publisher, cfg Config) *Service {
+	return &Service{store: store, sms: sms, cfg: cfg, now: time.Now}
+}
 
-    return otp
+// GenerateOTP returns a 6-digit string (000000–999999)
+func GenerateOTP() (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	n := (uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])) % 1_000_000
+	return fmt.Sprintf("%06d", n), nil
 }
 
-func Verify(customerID uuid.UUID, otp string) bool {
-    mu.RLock()
-    stored := cache[customerID]
-    mu.RUnlock()
-    return stored == otp
+// Send issues a fresh code for purpose, replacing any earlier one, and texts it to phone.
+// The code is only stored once the SMS is handed over, so a failed send
+// neither replaces the previous code nor starts the resend cooldown. Failed
+// attempts carry over to the new code until a lockout has run its course.
+func (s *Service) Send(customerID uuid.UUID, phone string, purpose Purpose) error {
+	code, err := GenerateOTP()
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(map[string]string{
+		"to":  phone,
+		"msg": fmt.Sprintf("BankRoll %s OTP: %s. Expires in %d min.", purpose, code, int(s.cfg.TTL.Minutes())),
+	})
+	if err != nil {
+		return err
+	}
+	return s.store.Update(customerID, purpose, func(rec *models.OTPCode) (*models.OTPCode, error) {
+		now := s.now()
+		if rec != nil {
+			if rec.LockedUntil != nil && now.Before(*rec.LockedUntil) {
+				return nil, ErrLocked
+			}
+			if now.Sub(rec.LastSentAt) < s.cfg.ResendCooldown {
+				return nil, ErrCooldown
+			}
+		} else {
+			rec = &models.OTPCode{ID: uuid.New(), CustomerID: customerID, Purpose: string(purpose)}
+		}
+		if err := s.sms.Publish("sms.send", msg); err != nil {
+			return nil, err
+		}
+		if rec.LockedUntil != nil { // the lockout has been served; start counting afresh
+			rec.Attempts = 0
+			rec.LockedUntil = nil
+		}
+		rec.CodeHash = s.hash(customerID, purpose, code)
+		rec.ExpiresAt = now.Add(s.cfg.TTL)
+		rec.LastSentAt = now
+		return rec, nil
+	})
 }
 
+// Verify checks code against the active one for purpose and consumes it on success.
+// Every failure counts towards MaxAttempts, across resends, until one succeeds.
+func (s *Service) Verify(customerID uuid.UUID, purpose Purpose, code string) error {
+	var result error
+	err := s.store.Update(customerID, purpose, func(rec *models.OTPCode) (*models.OTPCode, error) {
+		now := s.now()
+		switch {
+		case rec == nil:
+			result = ErrInvalidCode
+			return nil, nil
+		case rec.LockedUntil != nil && now.Before(*rec.LockedUntil):
+			result = ErrLocked
+			return rec, nil
+		case rec.CodeHash == "" || now.After(rec.ExpiresAt):
+			result = ErrInvalidCode
+			if rec.Attempts == 0 || rec.LockedUntil != nil {
+				return nil, nil
+			}
+			rec.CodeHash = "" // keep the failures for the next code
+			return rec, nil
+		}
+		if hmac.Equal([]byte(rec.CodeHash), []byte(s.hash(customerID, purpose, code))) {
+			return nil, nil // single use
+		}
+		rec.Attempts++
+		result = ErrInvalidCode
+		if rec.Attempts >= s.cfg.MaxAttempts {
+			lockedUntil := now.Add(s.cfg.LockoutPeriod)
+			rec.LockedUntil = &lockedUntil
+			rec.CodeHash = ""
+			result = ErrLocked
+		}
+		return rec, nil
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// hash is HMAC-SHA256(HashKey, customerID || purpose || code)
+func (s *Service) hash(customerID uuid.UUID, purpose Purpose, code string) string {
+	mac := hmac.New(sha256.New, s.cfg.HashKey)
+	mac.Write(customerID[:])
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
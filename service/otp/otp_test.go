@@ -0,0 +1,215 @@
+package otp
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeSMS struct {
+	sent []map[string]string
+	err  error // returned instead of sending when set
+}
+
+func (f *fakeSMS) Publish(subject string, data []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+var codeRe = regexp.MustCompile(`\d{6}`)
+
+func (f *fakeSMS) lastCode(t *testing.T) string {
+	t.Helper()
+	if len(f.sent) == 0 {
+		t.Fatal("no SMS sent")
+	}
+	return codeRe.FindString(f.sent[len(f.sent)-1]["msg"])
+}
+
+func newTestService() (*Service, *fakeSMS, *time.Time) {
+	sms := &fakeSMS{}
+	now := time.Unix(1_700_000_000, 0)
+	svc := New(NewMemoryStore(), sms, DefaultConfig([]byte("test-hash-key")))
+	svc.now = func() time.Time { return now }
+	return svc, sms, &now
+}
+
+func TestSendAndVerifySingleUse(t *testing.T) {
+	svc, sms, _ := newTestService()
+	customer := uuid.New()
+
+	if err := svc.Send(customer, "+254712345678", PurposeWithdraw); err != nil {
+		t.Fatal(err)
+	}
+	if sms.sent[0]["to"] != "+254712345678" {
+		t.Fatalf("SMS sent to %q", sms.sent[0]["to"])
+	}
+	code := sms.lastCode(t)
+
+	if err := svc.Verify(customer, PurposeWithdraw, code); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := svc.Verify(customer, PurposeWithdraw, code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("second Verify: want ErrInvalidCode, got %v", err)
+	}
+}
+
+func TestVerifyBindsPurposeAndCustomer(t *testing.T) {
+	svc, sms, _ := newTestService()
+	customer := uuid.New()
+	svc.Send(customer, "+254712345678", PurposeWithdraw)
+	code := sms.lastCode(t)
+
+	if err := svc.Verify(customer, PurposeLogin, code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("other purpose: want ErrInvalidCode, got %v", err)
+	}
+	if err := svc.Verify(uuid.New(), PurposeWithdraw, code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("other customer: want ErrInvalidCode, got %v", err)
+	}
+	if err := svc.Verify(customer, PurposeWithdraw, code); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	svc, sms, now := newTestService()
+	customer := uuid.New()
+	svc.Send(customer, "+254712345678", PurposeWithdraw)
+	code := sms.lastCode(t)
+
+	*now = now.Add(5*time.Minute + time.Second)
+	if err := svc.Verify(customer, PurposeWithdraw, code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("want ErrInvalidCode, got %v", err)
+	}
+}
+
+func TestVerifyLockout(t *testing.T) {
+	svc, sms, now := newTestService()
+	customer := uuid.New()
+	svc.Send(customer, "+254712345678", PurposeWithdraw)
+	code := sms.lastCode(t)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 1; i < svc.cfg.MaxAttempts; i++ {
+		if err := svc.Verify(customer, PurposeWithdraw, wrong); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: want ErrInvalidCode, got %v", i, err)
+		}
+	}
+	if err := svc.Verify(customer, PurposeWithdraw, wrong); !errors.Is(err, ErrLocked) {
+		t.Fatalf("final attempt: want ErrLocked, got %v", err)
+	}
+	if err := svc.Verify(customer, PurposeWithdraw, code); !errors.Is(err, ErrLocked) {
+		t.Fatalf("correct code while locked: want ErrLocked, got %v", err)
+	}
+	if err := svc.Send(customer, "+254712345678", PurposeWithdraw); !errors.Is(err, ErrLocked) {
+		t.Fatalf("send while locked: want ErrLocked, got %v", err)
+	}
+
+	*now = now.Add(svc.cfg.LockoutPeriod + time.Second)
+	if err := svc.Send(customer, "+254712345678", PurposeWithdraw); err != nil {
+		t.Fatalf("send after lockout: %v", err)
+	}
+	if err := svc.Verify(customer, PurposeWithdraw, sms.lastCode(t)); err != nil {
+		t.Fatalf("verify after lockout: %v", err)
+	}
+}
+
+func TestResendKeepsFailureCount(t *testing.T) {
+	svc, sms, now := newTestService()
+	customer := uuid.New()
+	svc.Send(customer, "+254712345678", PurposeWithdraw)
+	wrong := func() string {
+		if sms.lastCode(t) == "000000" {
+			return "111111"
+		}
+		return "000000"
+	}
+
+	for i := 1; i < svc.cfg.MaxAttempts; i++ {
+		if err := svc.Verify(customer, PurposeWithdraw, wrong()); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: want ErrInvalidCode, got %v", i, err)
+		}
+	}
+	// Neither a resend nor letting the code expire clears the count.
+	*now = now.Add(svc.cfg.ResendCooldown)
+	if err := svc.Send(customer, "+254712345678", PurposeWithdraw); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(svc.cfg.TTL + time.Second)
+	if err := svc.Verify(customer, PurposeWithdraw, sms.lastCode(t)); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expired code: want ErrInvalidCode, got %v", err)
+	}
+	if err := svc.Send(customer, "+254712345678", PurposeWithdraw); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Verify(customer, PurposeWithdraw, wrong()); !errors.Is(err, ErrLocked) {
+		t.Fatalf("attempt after resends: want ErrLocked, got %v", err)
+	}
+}
+
+func TestSendCooldown(t *testing.T) {
+	svc, sms, now := newTestService()
+	customer := uuid.New()
+	svc.Send(customer, "+254712345678", PurposeWithdraw)
+	first := sms.lastCode(t)
+
+	if err := svc.Send(customer, "+254712345678", PurposeWithdraw); !errors.Is(err, ErrCooldown) {
+		t.Fatalf("want ErrCooldown, got %v", err)
+	}
+	if len(sms.sent) != 1 {
+		t.Fatalf("SMS sent during cooldown")
+	}
+	if err := svc.Send(customer, "+254712345678", PurposeLogin); err != nil {
+		t.Fatalf("cooldown should be per purpose: %v", err)
+	}
+
+	*now = now.Add(time.Minute)
+	if err := svc.Send(customer, "+254712345678", PurposeWithdraw); err != nil {
+		t.Fatalf("send after cooldown: %v", err)
+	}
+	if second := sms.lastCode(t); second != first {
+		if err := svc.Verify(customer, PurposeWithdraw, first); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("superseded code accepted: %v", err)
+		}
+	}
+}
+
+func TestSendFailureKeepsPreviousCode(t *testing.T) {
+	svc, sms, now := newTestService()
+	customer := uuid.New()
+	svc.Send(customer, "+254712345678", PurposeWithdraw)
+	first := sms.lastCode(t)
+	svc.Send(customer, "+254712345678", PurposeLogin)
+
+	*now = now.Add(time.Minute)
+	sms.err = errors.New("nats: connection closed")
+	for _, p := range []Purpose{PurposeWithdraw, PurposeLogin} {
+		if err := svc.Send(customer, "+254712345678", p); !errors.Is(err, sms.err) {
+			t.Fatalf("%s: want the publish error, got %v", p, err)
+		}
+	}
+	sms.err = nil
+
+	// The unsent code did not replace the delivered one...
+	if err := svc.Verify(customer, PurposeWithdraw, first); err != nil {
+		t.Fatalf("code sent before the failure: %v", err)
+	}
+	// ...nor start a cooldown that blocks the retry.
+	if err := svc.Send(customer, "+254712345678", PurposeLogin); err != nil {
+		t.Fatalf("retry after a failed send: %v", err)
+	}
+}
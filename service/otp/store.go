@@ -0,0 +1,91 @@
+package otp
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+)
+
+// Store persists one OTP record per customer and purpose.
+// Update must run fn atomically: fn gets the stored record (nil if none) and
+// returns the record to keep, or nil to delete it. If fn errors nothing changes.
+type Store interface {
+	Update(customerID uuid.UUID, purpose Purpose, fn func(*models.OTPCode) (*models.OTPCode, error)) error
+}
+
+// ------------------------------------------------------------
+// DBStore – otp_codes table, row-locked per customer and purpose
+
+type DBStore struct {
+	db *gorm.DB
+}
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Update(customerID uuid.UUID, purpose Purpose, fn func(*models.OTPCode) (*models.OTPCode, error)) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var current *models.OTPCode
+		var rec models.OTPCode
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("customer_id = ? AND purpose = ?", customerID, string(purpose)).
+			First(&rec).Error
+		switch {
+		case err == nil:
+			current = &rec
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		next, err := fn(current)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			if current == nil {
+				return nil
+			}
+			return tx.Delete(current).Error
+		}
+		return tx.Save(next).Error
+	})
+}
+
+// ------------------------------------------------------------
+// MemoryStore – process-local, for tests and single-instance dev
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]models.OTPCode
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]models.OTPCode)}
+}
+
+func (s *MemoryStore) Update(customerID uuid.UUID, purpose Purpose, fn func(*models.OTPCode) (*models.OTPCode, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := customerID.String() + "/" + string(purpose)
+	var current *models.OTPCode
+	if rec, ok := s.records[key]; ok {
+		current = &rec
+	}
+	next, err := fn(current)
+	if err != nil {
+		return err
+	}
+	if next == nil {
+		delete(s.records, key)
+		return nil
+	}
+	s.records[key] = *next
+	return nil
+}
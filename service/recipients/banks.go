@@ -0,0 +1,46 @@
+package recipients
+
+import (
+	"regexp"
+	"slices"
+
+	"weriKana/models"
+)
+
+// Bank is a payout bank and the shape of its account numbers
+type Bank struct {
+	Code          string                   `json:"code"`
+	Name          string                   `json:"name"`
+	AccountTypes  []models.BankAccountType `json:"account_types"`
+	accountNumber *regexp.Regexp
+}
+
+var bothTypes = []models.BankAccountType{models.BankAccountTypeCurrent, models.BankAccountTypeSavings}
+
+// Banks is the payout catalog, keyed by CBK bank code
+var Banks = []Bank{
+	{Code: "01", Name: "KCB Bank Kenya", AccountTypes: bothTypes, accountNumber: regexp.MustCompile(`^[0-9]{10}$`)},
+	{Code: "02", Name: "Standard Chartered Bank Kenya", AccountTypes: bothTypes, accountNumber: regexp.MustCompile(`^[0-9]{13}$`)},
+	{Code: "03", Name: "Absa Bank Kenya", AccountTypes: bothTypes, accountNumber: regexp.MustCompile(`^[0-9]{10}$`)},
+	{Code: "07", Name: "NCBA Bank Kenya", AccountTypes: bothTypes, accountNumber: regexp.MustCompile(`^[0-9]{10,12}$`)},
+	{Code: "11", Name: "Co-operative Bank of Kenya", AccountTypes: bothTypes, accountNumber: regexp.MustCompile(`^[0-9]{14}$`)},
+	{Code: "31", Name: "Stanbic Bank Kenya", AccountTypes: bothTypes, accountNumber: regexp.MustCompile(`^[0-9]{13}$`)},
+	{Code: "57", Name: "I&M Bank", AccountTypes: bothTypes, accountNumber: regexp.MustCompile(`^[0-9]{10,14}$`)},
+	{Code: "63", Name: "Diamond Trust Bank", AccountTypes: bothTypes, accountNumber: regexp.MustCompile(`^[0-9]{10}$`)},
+	{Code: "68", Name: "Equity Bank Kenya", AccountTypes: bothTypes, accountNumber: regexp.MustCompile(`^[0-9]{13}$`)},
+	{Code: "70", Name: "Family Bank", AccountTypes: []models.BankAccountType{models.BankAccountTypeSavings}, accountNumber: regexp.MustCompile(`^[0-9]{12,13}$`)},
+}
+
+// LookupBank finds a catalog bank by code
+func LookupBank(code string) (Bank, bool) {
+	i := slices.IndexFunc(Banks, func(b Bank) bool { return b.Code == code })
+	if i < 0 {
+		return Bank{}, false
+	}
+	return Banks[i], true
+}
+
+// ValidAccount reports whether number and accountType are acceptable at this bank
+func (b Bank) ValidAccount(accountType models.BankAccountType, number string) bool {
+	return slices.Contains(b.AccountTypes, accountType) && b.accountNumber.MatchString(number)
+}
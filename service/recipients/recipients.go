@@ -0,0 +1,304 @@
+package recipients
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+)
+
+// MaxRecipients caps the address book per customer
+const MaxRecipients = 100
+
+var (
+	ErrRecipientNotFound  = errors.New("recipient not found")
+	ErrInvalidRecipient   = errors.New("invalid recipient")
+	ErrDuplicateRecipient = errors.New("a recipient with this phone number already exists")
+	ErrUnknownBank        = errors.New("unknown bank code")
+	ErrInvalidBankAccount = errors.New("bank account type or number is not valid for this bank")
+	ErrRecipientInUse     = errors.New("recipient has transfers in progress")
+	ErrTooManyRecipients  = errors.New("recipient limit reached")
+)
+
+var (
+	countryCodeRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+	genders          = []models.GenderType{models.GenderMale, models.GenderFemale, models.GenderOther}
+	// openTransfers can still move money to the recipient
+	openTransfers = []models.TransferStatus{models.TransferAwaitingFunds, models.TransferFunded, models.TransferPayoutPending, models.TransferFailed}
+)
+
+// Details is what a customer submits about a recipient. A recipient needs a
+// phone (M-Pesa) or full bank details to be payable.
+type Details struct {
+	FirstName         string                 `json:"first_name"`
+	LastName          string                 `json:"last_name"`
+	Email             string                 `json:"email"`
+	Phone             string                 `json:"phone"`
+	Gender            models.GenderType      `json:"gender"`
+	CountryCode       string                 `json:"country_code"`
+	Street            string                 `json:"street"`
+	PostalCode        string                 `json:"postal_code"`
+	City              string                 `json:"city"`
+	BankCode          string                 `json:"bank_code"`
+	BankAccountType   models.BankAccountType `json:"bank_account_type"`
+	BankAccountNumber string                 `json:"bank_account_number"`
+}
+
+// View is a recipient as returned by the API, with contact details masked
+type View struct {
+	ID                uuid.UUID              `json:"id"`
+	FirstName         string                 `json:"first_name"`
+	LastName          string                 `json:"last_name"`
+	Email             string                 `json:"email,omitempty"`
+	Phone             string                 `json:"phone,omitempty"`
+	Gender            models.GenderType      `json:"gender,omitempty"`
+	CountryCode       string                 `json:"country_code"`
+	City              string                 `json:"city,omitempty"`
+	BankCode          string                 `json:"bank_code,omitempty"`
+	BankName          string                 `json:"bank_name,omitempty"`
+	BankAccountType   models.BankAccountType `json:"bank_account_type,omitempty"`
+	BankAccountNumber string                 `json:"bank_account_number,omitempty"`
+	KYCStatus         models.KYCStatus       `json:"kyc_status"`
+	CreatedAt         time.Time              `json:"created_at"`
+}
+
+// NewView masks r for a response
+func NewView(r *models.Recipient) View {
+	v := View{
+		ID:              r.ID,
+		FirstName:       r.FirstName,
+		LastName:        r.LastName,
+		Gender:          r.Gender,
+		CountryCode:     r.CountryCode,
+		City:            r.City,
+		BankCode:        r.BankCode,
+		BankName:        r.BankName,
+		BankAccountType: r.BankAccountType,
+		KYCStatus:       r.KYCStatus,
+		CreatedAt:       r.CreatedAt,
+	}
+	if r.Email != "" {
+		v.Email = models.MaskEmail(r.Email.String())
+	}
+	if r.PhoneNumber != "" {
+		v.Phone = models.MaskPhone(r.PhoneNumber.String())
+	}
+	if r.BankAccountNumber != "" {
+		v.BankAccountNumber = models.MaskAccountNumber(r.BankAccountNumber)
+	}
+	if v.KYCStatus == "" {
+		v.KYCStatus = models.KYCUnverified
+	}
+	return v
+}
+
+// Screener checks a saved recipient against sanctions and PEP lists
+// (screening.Service implements it)
+type Screener interface {
+	ScreenSubject(tx *gorm.DB, subject models.KYCSubject, id uuid.UUID) error
+}
+
+// Service manages a customer's recipient address book
+type Service struct {
+	db     *gorm.DB
+	screen Screener // optional
+}
+
+func NewService(db *gorm.DB, screen Screener) *Service {
+	return &Service{db: db, screen: screen}
+}
+
+// rescreen screens a recipient whose name was just written
+func (s *Service) rescreen(tx *gorm.DB, id uuid.UUID) error {
+	if s.screen == nil {
+		return nil
+	}
+	return s.screen.ScreenSubject(tx, models.KYCSubjectRecipient, id)
+}
+
+// Create adds a recipient, refusing a second one with the same phone
+func (s *Service) Create(customerID uuid.UUID, d Details) (*models.Recipient, error) {
+	r := &models.Recipient{ID: uuid.New(), CustomerID: customerID, KYCStatus: models.KYCUnverified}
+	if err := apply(r, d); err != nil {
+		return nil, err
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&models.Recipient{}).Where("customer_id = ?", customerID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n >= MaxRecipients {
+			return ErrTooManyRecipients
+		}
+		if err := checkDuplicate(tx, r); err != nil {
+			return err
+		}
+		// external_id is assigned by the remittance partner; leave it NULL until then
+		if err := tx.Omit("ExternalID", clause.Associations).Create(r).Error; err != nil {
+			return err
+		}
+		return s.rescreen(tx, r.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// List returns the customer's recipients by name
+func (s *Service) List(customerID uuid.UUID, limit, offset int) ([]models.Recipient, error) {
+	var out []models.Recipient
+	err := s.db.Where("customer_id = ?", customerID).
+		Order("first_name, last_name").
+		Limit(limit).Offset(offset).
+		Find(&out).Error
+	return out, err
+}
+
+// Get returns one of the customer's recipients
+func (s *Service) Get(customerID, id uuid.UUID) (*models.Recipient, error) {
+	return find(s.db, customerID, id)
+}
+
+// Update replaces the recipient's contact and bank details. Identity fields
+// belong to KYC and are left alone.
+func (s *Service) Update(customerID, id uuid.UUID, d Details) (*models.Recipient, error) {
+	var r *models.Recipient
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = find(tx.Clauses(clause.Locking{Strength: "UPDATE"}), customerID, id); err != nil {
+			return err
+		}
+		if err := apply(r, d); err != nil {
+			return err
+		}
+		if err := checkDuplicate(tx, r); err != nil {
+			return err
+		}
+		err = tx.Model(&models.Recipient{}).Where("id = ? AND customer_id = ?", r.ID, customerID).Select(
+			"FirstName", "LastName", "Email", "EmailIndex", "PhoneNumber", "PhoneIndex", "Gender",
+			"CountryCode", "Street", "PostalCode", "City",
+			"BankCode", "BankName", "BankAccountType", "BankAccountNumber",
+		).Updates(r).Error
+		if err != nil {
+			return err
+		}
+		return s.rescreen(tx, r.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Delete soft-deletes a recipient no transfer is still paying
+func (s *Service) Delete(customerID, id uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		r, err := find(tx, customerID, id)
+		if err != nil {
+			return err
+		}
+		var open int64
+		err = tx.Model(&models.Transfer{}).
+			Where("recipient_id = ? AND status IN ?", r.ID, openTransfers).
+			Count(&open).Error
+		if err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrRecipientInUse
+		}
+		return tx.Where("id = ? AND customer_id = ?", r.ID, customerID).Delete(&models.Recipient{}).Error
+	})
+}
+
+func find(tx *gorm.DB, customerID, id uuid.UUID) (*models.Recipient, error) {
+	var r models.Recipient
+	err := tx.Where("id = ? AND customer_id = ?", id, customerID).Take(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRecipientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// checkDuplicate looks for another live recipient of the same customer with
+// the same phone through phone_bidx
+func checkDuplicate(tx *gorm.DB, r *models.Recipient) error {
+	if r.PhoneIndex == "" {
+		return nil
+	}
+	var n int64
+	err := tx.Model(&models.Recipient{}).
+		Where("customer_id = ? AND phone_bidx = ? AND id <> ?", r.CustomerID, r.PhoneIndex, r.ID).
+		Count(&n).Error
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrDuplicateRecipient
+	}
+	return nil
+}
+
+// apply validates d and copies it onto r
+func apply(r *models.Recipient, d Details) error {
+	r.FirstName = strings.TrimSpace(d.FirstName)
+	r.LastName = strings.TrimSpace(d.LastName)
+	if r.FirstName == "" || r.LastName == "" {
+		return fmt.Errorf("%w: first_name and last_name are required", ErrInvalidRecipient)
+	}
+	if d.Gender != "" && !slices.Contains(genders, d.Gender) {
+		return fmt.Errorf("%w: gender", ErrInvalidRecipient)
+	}
+	r.Gender = d.Gender
+	r.CountryCode = strings.ToUpper(strings.TrimSpace(d.CountryCode))
+	if r.CountryCode == "" {
+		r.CountryCode = "KE"
+	}
+	if !countryCodeRegex.MatchString(r.CountryCode) {
+		return fmt.Errorf("%w: country_code", ErrInvalidRecipient)
+	}
+	r.Street, r.PostalCode, r.City = strings.TrimSpace(d.Street), strings.TrimSpace(d.PostalCode), strings.TrimSpace(d.City)
+
+	r.Email, r.EmailIndex = "", ""
+	if strings.TrimSpace(d.Email) != "" {
+		if err := r.SetEmail(d.Email); err != nil {
+			return fmt.Errorf("%w: email", ErrInvalidRecipient)
+		}
+	}
+	r.PhoneNumber, r.PhoneIndex = "", ""
+	if strings.TrimSpace(d.Phone) != "" {
+		if err := r.SetPhone(d.Phone); err != nil {
+			return fmt.Errorf("%w: phone", ErrInvalidRecipient)
+		}
+	}
+
+	r.BankCode, r.BankName, r.BankAccountType, r.BankAccountNumber = "", "", "", ""
+	number := strings.NewReplacer(" ", "", "-", "").Replace(d.BankAccountNumber)
+	if d.BankCode != "" || number != "" || d.BankAccountType != "" {
+		bank, ok := LookupBank(strings.TrimSpace(d.BankCode))
+		if !ok {
+			return ErrUnknownBank
+		}
+		if !bank.ValidAccount(d.BankAccountType, number) {
+			return ErrInvalidBankAccount
+		}
+		r.BankCode, r.BankName, r.BankAccountType, r.BankAccountNumber = bank.Code, bank.Name, d.BankAccountType, number
+	}
+
+	if r.PhoneNumber == "" && r.BankCode == "" {
+		return fmt.Errorf("%w: a phone number or bank account is required", ErrInvalidRecipient)
+	}
+	return nil
+}
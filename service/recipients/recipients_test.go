@@ -0,0 +1,131 @@
+package recipients
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/keymgmt"
+)
+
+func newTestService(t *testing.T) (*Service, *gorm.DB) {
+	t.Helper()
+	kms := keymgmt.NewLocalKMS()
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	models.SetFieldEncryption(keymgmt.NewEnvelope(kms))
+	models.SetBlindIndexKey([]byte(strings.Repeat("b", keymgmt.KeySize)))
+	t.Cleanup(func() {
+		models.SetFieldEncryption(nil)
+		models.SetBlindIndexKey(nil)
+	})
+
+	db := testdb.Open(t, &models.Recipient{}, &models.Transfer{})
+	return NewService(db, nil), db
+}
+
+func mpesaRecipient() Details {
+	return Details{FirstName: "Baraka", LastName: "Mwangi", Phone: "0722000111", Email: "Baraka@Example.com"}
+}
+
+func TestRecipientCRUD(t *testing.T) {
+	s, _ := newTestService(t)
+	customer := uuid.New()
+
+	r, err := s.Create(customer, mpesaRecipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewView(r)
+	if v.Phone != "+2547*****111" || v.Email != "b***@example.com" || v.CountryCode != "KE" || v.KYCStatus != models.KYCUnverified {
+		t.Fatalf("view = %+v", v)
+	}
+
+	// Same phone in another format is a duplicate; another customer may save it.
+	dup := mpesaRecipient()
+	dup.Phone = "+254722000111"
+	if _, err := s.Create(customer, dup); !errors.Is(err, ErrDuplicateRecipient) {
+		t.Fatalf("duplicate: %v", err)
+	}
+	if _, err := s.Create(uuid.New(), dup); err != nil {
+		t.Fatalf("other customer: %v", err)
+	}
+
+	update := mpesaRecipient()
+	update.City = "Kisumu"
+	update.BankCode, update.BankAccountType, update.BankAccountNumber = "68", models.BankAccountTypeSavings, "0123 4567 89012"
+	if _, err := s.Update(customer, r.ID, update); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(customer, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.City != "Kisumu" || got.BankName != "Equity Bank Kenya" || got.BankAccountNumber != "0123456789012" || got.PhoneNumber != "+254722000111" {
+		t.Fatalf("after update = %+v", got)
+	}
+	if NewView(got).BankAccountNumber != "*********9012" {
+		t.Fatalf("account not masked: %q", NewView(got).BankAccountNumber)
+	}
+	if _, err := s.Get(uuid.New(), r.ID); !errors.Is(err, ErrRecipientNotFound) {
+		t.Fatalf("foreign get: %v", err)
+	}
+
+	if err := s.Delete(customer, r.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.List(customer, 50, 0); len(list) != 0 {
+		t.Fatalf("deleted recipient listed: %+v", list)
+	}
+	if _, err := s.Create(customer, mpesaRecipient()); err != nil {
+		t.Fatalf("re-adding a deleted recipient: %v", err)
+	}
+}
+
+func TestRecipientValidation(t *testing.T) {
+	s, _ := newTestService(t)
+	cases := map[string]struct {
+		edit func(d *Details)
+		want error
+	}{
+		"no name":         {func(d *Details) { d.LastName = " " }, ErrInvalidRecipient},
+		"bad phone":       {func(d *Details) { d.Phone = "0800123" }, ErrInvalidRecipient},
+		"bad email":       {func(d *Details) { d.Email = "nope" }, ErrInvalidRecipient},
+		"bad gender":      {func(d *Details) { d.Gender = "x" }, ErrInvalidRecipient},
+		"no payout route": {func(d *Details) { d.Phone = "" }, ErrInvalidRecipient},
+		"unknown bank":    {func(d *Details) { d.BankCode, d.BankAccountNumber = "99", "1234567890" }, ErrUnknownBank},
+		"short account": {func(d *Details) {
+			d.BankCode, d.BankAccountType, d.BankAccountNumber = "11", models.BankAccountTypeCurrent, "12345"
+		}, ErrInvalidBankAccount},
+		"type not offered": {func(d *Details) {
+			d.BankCode, d.BankAccountType, d.BankAccountNumber = "70", models.BankAccountTypeCurrent, "123456789012"
+		}, ErrInvalidBankAccount},
+	}
+	for name, tc := range cases {
+		d := mpesaRecipient()
+		tc.edit(&d)
+		if _, err := s.Create(uuid.New(), d); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestDeleteRefusedWithOpenTransfer(t *testing.T) {
+	s, db := newTestService(t)
+	customer := uuid.New()
+	r, err := s.Create(customer, mpesaRecipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("INSERT INTO transfers (id, customer_id, sender_id, recipient_id, amount_cents, fee_cents, total_cents, payout_method, status, quote_expires_at) VALUES (?, ?, ?, ?, 0, 0, 0, ?, ?, CURRENT_TIMESTAMP)",
+		uuid.New(), customer, uuid.New(), r.ID, models.PayoutMpesaB2C, models.TransferFunded)
+	if err := s.Delete(customer, r.ID); !errors.Is(err, ErrRecipientInUse) {
+		t.Fatalf("delete with funded transfer: %v", err)
+	}
+}
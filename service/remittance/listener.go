@@ -0,0 +1,48 @@
+package remittance
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Subjects the M-Pesa and bank bridges publish provider results on
+const (
+	SubjectFundingResult = "remittance.funding.result"
+	SubjectPayoutResult  = "remittance.payout.result"
+)
+
+// Result is a provider callback relayed over NATS
+type Result struct {
+	Reference string `json:"reference"`
+	Success   bool   `json:"success"`
+	Reason    string `json:"reason"`
+}
+
+// Listen applies funding and payout results as they arrive. Unknown or
+// already settled references are dropped, so redelivery is harmless.
+func (s *Service) Listen(nc *nats.Conn, logf func(string, ...interface{})) error {
+	handle := func(apply func(ref string, ok bool, reason string) error) nats.MsgHandler {
+		return func(m *nats.Msg) {
+			var r Result
+			if err := json.Unmarshal(m.Data, &r); err != nil || r.Reference == "" {
+				logf("remittance: bad result on %s: %v", m.Subject, err)
+				return
+			}
+			if err := apply(r.Reference, r.Success, r.Reason); err != nil && err != ErrUnknownReference {
+				logf("remittance: %s %s: %v", m.Subject, r.Reference, err)
+			}
+		}
+	}
+	if _, err := nc.Subscribe(SubjectFundingResult, handle(func(ref string, ok bool, reason string) error {
+		_, err := s.ConfirmFunding(ref, ok, reason)
+		return err
+	})); err != nil {
+		return err
+	}
+	_, err := nc.Subscribe(SubjectPayoutResult, handle(func(ref string, ok bool, reason string) error {
+		_, err := s.SettlePayout(ref, ok, reason)
+		return err
+	}))
+	return err
+}
@@ -0,0 +1,92 @@
+package remittance
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"weriKana/models"
+	"weriKana/service/mpesa"
+)
+
+// Payout is one instruction to pay a recipient. Reference is unique per
+// attempt and doubles as the provider idempotency key.
+type Payout struct {
+	TransferID        uuid.UUID
+	Reference         string
+	AmountCents       int64
+	Currency          string
+	RecipientName     string
+	Phone             string
+	BankCode          string
+	BankName          string
+	BankAccountNumber string
+	BankAccountType   models.BankAccountType
+}
+
+// PayoutReceipt is the provider's answer. Settled is true when the money has
+// already moved; otherwise the result arrives later through SettlePayout.
+type PayoutReceipt struct {
+	Reference string
+	Settled   bool
+}
+
+// PayoutAdapter sends money to a recipient over one payout method. Bank
+// integrations implement this and are registered under models.PayoutBank.
+type PayoutAdapter interface {
+	Pay(ctx context.Context, p Payout) (PayoutReceipt, error)
+}
+
+// Collector requests money from a sender's phone and returns the provider
+// reference that ConfirmFunding is later called with
+type Collector interface {
+	Collect(ctx context.Context, phone string, amountCents int64, idempotencyKey string) (string, error)
+}
+
+// payoutFor builds the recipient part of a payout, or ErrPayoutDetails if the
+// recipient cannot be paid by method
+func payoutFor(r *models.Recipient, method models.PayoutMethod) (Payout, error) {
+	p := Payout{RecipientName: strings.TrimSpace(r.FirstName + " " + r.LastName)}
+	switch method {
+	case models.PayoutMpesaB2C:
+		if r.PhoneNumber == "" {
+			return p, ErrPayoutDetails
+		}
+		p.Phone = r.PhoneNumber.String()
+	case models.PayoutBank:
+		if r.BankName == "" || r.BankAccountNumber == "" {
+			return p, ErrPayoutDetails
+		}
+		p.BankCode = r.BankCode
+		p.BankName = r.BankName
+		p.BankAccountNumber = r.BankAccountNumber
+		p.BankAccountType = r.BankAccountType
+	default:
+		return p, ErrUnsupportedPayout
+	}
+	return p, nil
+}
+
+// MpesaB2C pays recipients through the M-Pesa B2C API. Results arrive
+// asynchronously keyed by the conversation ID.
+type MpesaB2C struct{}
+
+func (MpesaB2C) Pay(_ context.Context, p Payout) (PayoutReceipt, error) {
+	resp, err := mpesa.SendB2C(p.Phone, p.AmountCents, p.Reference)
+	if err != nil {
+		return PayoutReceipt{}, err
+	}
+	return PayoutReceipt{Reference: resp.ConversationID}, nil
+}
+
+// MpesaSTK collects funding with an STK push to the sender's phone
+type MpesaSTK struct{}
+
+func (MpesaSTK) Collect(_ context.Context, phone string, amountCents int64, idempotencyKey string) (string, error) {
+	resp, err := mpesa.SendSTKPush(phone, amountCents, idempotencyKey)
+	if err != nil {
+		return "", err
+	}
+	return resp.CheckoutRequestID, nil
+}
@@ -0,0 +1,604 @@
+package remittance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+)
+
+var (
+	ErrInvalidAmount     = errors.New("transfer amount is outside the allowed range")
+	ErrUnsupportedPayout = errors.New("payout method is not available")
+	ErrNoSender          = errors.New("complete your sender profile before sending money")
+	ErrRecipientNotFound = errors.New("recipient not found")
+	ErrPayoutDetails     = errors.New("recipient has no details for this payout method")
+	ErrTransferNotFound  = errors.New("transfer not found")
+	ErrQuoteExpired      = errors.New("quote has expired; request a new one")
+	ErrInvalidState      = errors.New("transfer status does not allow this action")
+	ErrInvalidFunding    = errors.New("invalid funding source")
+	ErrAccountNotFound   = errors.New("funding account not found")
+	ErrCollectionFailed  = errors.New("M-Pesa collection could not be started")
+	ErrRefundUnavailable = errors.New("refund channel is not available")
+	ErrUnknownReference  = errors.New("unknown or already settled reference")
+)
+
+// KYCChecker gates funding on the sender's identity verification
+type KYCChecker interface {
+	RequireVerified(customerID uuid.UUID) error
+}
+
+// ScreeningGate holds transfers whose sender or recipient awaits a sanctions
+// review (screening.Service implements it)
+type ScreeningGate interface {
+	RequireClear(senderID, recipientID uuid.UUID) error
+}
+
+// Config holds quote limits and pricing
+type Config struct {
+	QuoteTTL       time.Duration
+	FeeBasisPoints int64
+	MinFeeCents    int64
+	MinAmountCents int64
+	MaxAmountCents int64
+}
+
+// DefaultConfig quotes for 15 minutes at 1% (KES 50 minimum) between KES 100
+// and KES 150,000, the M-Pesa B2C ceiling.
+func DefaultConfig() Config {
+	return Config{
+		QuoteTTL:       15 * time.Minute,
+		FeeBasisPoints: 100,
+		MinFeeCents:    5000,
+		MinAmountCents: 10000,
+		MaxAmountCents: 15000000,
+	}
+}
+
+// Fee returns the fee charged on amountCents
+func (c Config) Fee(amountCents int64) int64 {
+	fee := amountCents * c.FeeBasisPoints / 10000
+	if fee < c.MinFeeCents {
+		fee = c.MinFeeCents
+	}
+	return fee
+}
+
+// QuoteRequest asks what sending AmountCents to a recipient will cost
+type QuoteRequest struct {
+	RecipientID  uuid.UUID           `json:"recipient_id"`
+	AmountCents  int64               `json:"amount_cents"`
+	PayoutMethod models.PayoutMethod `json:"payout_method"`
+}
+
+// FundRequest says where the money for a quoted transfer comes from. Account
+// funding debits the real balance of AccountID; M-Pesa funding prompts Phone
+// (the customer's own number when empty) with an STK push.
+type FundRequest struct {
+	Source    models.FundingSource `json:"source"`
+	AccountID uuid.UUID            `json:"account_id"`
+	Phone     string               `json:"phone"`
+}
+
+// Service moves transfers through quote, funding, payout and cancellation
+type Service struct {
+	db        *gorm.DB
+	cfg       Config
+	kyc       KYCChecker
+	screen    ScreeningGate // optional
+	collector Collector
+	payouts   map[models.PayoutMethod]PayoutAdapter
+	now       func() time.Time
+}
+
+// NewService wires the remittance flow; payout methods without an adapter
+// cannot be quoted
+func NewService(db *gorm.DB, cfg Config, kyc KYCChecker, screen ScreeningGate, collector Collector, payouts map[models.PayoutMethod]PayoutAdapter) *Service {
+	return &Service{db: db, cfg: cfg, kyc: kyc, screen: screen, collector: collector, payouts: payouts, now: time.Now}
+}
+
+// requireClear stops a transfer while screening holds either party. It is
+// checked at quote, funding and payout, since a list refresh can flag a
+// party at any point.
+func (s *Service) requireClear(senderID, recipientID uuid.UUID) error {
+	if s.screen == nil {
+		return nil
+	}
+	return s.screen.RequireClear(senderID, recipientID)
+}
+
+// Quote prices a transfer to one of the customer's recipients
+func (s *Service) Quote(customerID uuid.UUID, req QuoteRequest) (*models.Transfer, error) {
+	if req.AmountCents < s.cfg.MinAmountCents || req.AmountCents > s.cfg.MaxAmountCents {
+		return nil, ErrInvalidAmount
+	}
+	if _, ok := s.payouts[req.PayoutMethod]; !ok {
+		return nil, ErrUnsupportedPayout
+	}
+	var sender models.Sender
+	err := s.db.Where("customer_id = ?", customerID).Take(&sender).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoSender
+	}
+	if err != nil {
+		return nil, err
+	}
+	recipient, err := s.recipient(s.db, customerID, req.RecipientID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := payoutFor(recipient, req.PayoutMethod); err != nil {
+		return nil, err
+	}
+	if err := s.requireClear(sender.ID, recipient.ID); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	fee := s.cfg.Fee(req.AmountCents)
+	t := &models.Transfer{
+		ID:             uuid.New(),
+		CustomerID:     customerID,
+		SenderID:       sender.ID,
+		RecipientID:    recipient.ID,
+		AmountCents:    req.AmountCents,
+		FeeCents:       fee,
+		TotalCents:     req.AmountCents + fee,
+		Currency:       "KES",
+		PayoutMethod:   req.PayoutMethod,
+		Status:         models.TransferQuoted,
+		QuoteExpiresAt: now.Add(s.cfg.QuoteTTL),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		return recordEvent(tx, t.ID, "", models.TransferQuoted, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Fund pays for a quoted transfer. Account funding settles immediately;
+// M-Pesa funding leaves the transfer awaiting_funds until ConfirmFunding.
+func (s *Service) Fund(ctx context.Context, customerID, transferID uuid.UUID, req FundRequest) (*models.Transfer, error) {
+	if err := s.kyc.RequireVerified(customerID); err != nil {
+		return nil, err
+	}
+	switch req.Source {
+	case models.FundingAccount:
+		return s.fundFromAccount(customerID, transferID, req.AccountID)
+	case models.FundingMpesa:
+		return s.fundFromMpesa(ctx, customerID, transferID, req.Phone)
+	}
+	return nil, ErrInvalidFunding
+}
+
+func (s *Service) fundFromAccount(customerID, transferID, accountID uuid.UUID) (*models.Transfer, error) {
+	var t *models.Transfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if t, err = s.lockQuoted(tx, customerID, transferID); err != nil {
+			return err
+		}
+		if err := s.requireClear(t.SenderID, t.RecipientID); err != nil {
+			return err
+		}
+		ref, err := models.ResolveAccount(tx, accountID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && ref.CustomerID != customerID) {
+			return ErrAccountNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := models.DebitBalance(tx, ref.Type, ref.ID, true, t.TotalCents); err != nil {
+			return err
+		}
+		ledger := &models.Transaction{
+			ID:             uuid.New(),
+			CustomerID:     customerID,
+			SenderID:       t.SenderID,
+			Type:           models.TransactionTypeTransfer,
+			AmountCents:    t.TotalCents,
+			IsReal:         true,
+			Currency:       t.Currency,
+			Status:         models.StatusSuccess,
+			Reference:      fmt.Sprintf("TRF-%s", uuid.New().String()[:8]),
+			IdempotencyKey: "transfer:" + t.ID.String(),
+			Metadata:       models.JSONMap{"transfer_id": t.ID.String(), "fee_cents": t.FeeCents},
+		}
+		if err := ledger.SetAccount(ref.Type, ref.ID); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Create(ledger).Error; err != nil {
+			return err
+		}
+		now := s.now()
+		return s.move(tx, t, models.TransferFunded, "funded from "+ref.Type+" account", map[string]interface{}{
+			"funding_source":     models.FundingAccount,
+			"funding_account_id": ref.ID,
+			"funding_type":       ref.Type,
+			"funding_ref":        ledger.Reference,
+			"funded_at":          now,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.reload(t.ID)
+}
+
+// fundFromMpesa claims the quote before prompting the phone so a second Fund
+// call cannot push twice. If the push cannot be started the transfer is
+// cancelled; nothing was collected.
+func (s *Service) fundFromMpesa(ctx context.Context, customerID, transferID uuid.UUID, phone string) (*models.Transfer, error) {
+	if s.collector == nil {
+		return nil, ErrInvalidFunding
+	}
+	var t *models.Transfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if t, err = s.lockQuoted(tx, customerID, transferID); err != nil {
+			return err
+		}
+		if err := s.requireClear(t.SenderID, t.RecipientID); err != nil {
+			return err
+		}
+		if phone == "" {
+			if err := tx.Table("customers").Select("phone").Where("id = ?", customerID).Scan(&phone).Error; err != nil {
+				return err
+			}
+		}
+		if phone, err = models.NormalizePhone(phone); err != nil {
+			return ErrInvalidFunding
+		}
+		return s.move(tx, t, models.TransferAwaitingFunds, "M-Pesa collection requested", map[string]interface{}{
+			"funding_source": models.FundingMpesa,
+			"funding_phone":  phone,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	checkoutID, err := s.collector.Collect(ctx, phone, t.TotalCents, t.ID.String())
+	if err != nil {
+		s.db.Transaction(func(tx *gorm.DB) error {
+			return s.move(tx, t, models.TransferCancelled, "collection failed: "+truncate(err.Error(), 200), map[string]interface{}{
+				"cancelled_at":   s.now(),
+				"failure_reason": "M-Pesa collection failed",
+			})
+		})
+		return nil, ErrCollectionFailed
+	}
+	if err := s.db.Model(&models.Transfer{}).Where("id = ?", t.ID).Update("funding_ref", checkoutID).Error; err != nil {
+		return nil, err
+	}
+	return s.reload(t.ID)
+}
+
+// ConfirmFunding applies the M-Pesa collection result for fundingRef
+func (s *Service) ConfirmFunding(fundingRef string, success bool, reason string) (*models.Transfer, error) {
+	var t models.Transfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("funding_ref = ? AND status = ?", fundingRef, models.TransferAwaitingFunds).
+			Take(&t).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownReference
+		}
+		if err != nil {
+			return err
+		}
+		if success {
+			return s.move(tx, &t, models.TransferFunded, "M-Pesa payment received", map[string]interface{}{"funded_at": s.now()})
+		}
+		return s.move(tx, &t, models.TransferCancelled, "M-Pesa payment failed", map[string]interface{}{
+			"cancelled_at":   s.now(),
+			"failure_reason": truncate(reason, 255),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.reload(t.ID)
+}
+
+// Send pays a funded transfer out to the recipient, or retries a failed payout.
+// Adapters that settle synchronously mark it paid at once; others leave it
+// payout_pending until SettlePayout.
+func (s *Service) Send(ctx context.Context, customerID, transferID uuid.UUID) (*models.Transfer, error) {
+	var (
+		t      *models.Transfer
+		payout Payout
+	)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if t, err = s.lock(tx, customerID, transferID); err != nil {
+			return err
+		}
+		if t.Status != models.TransferFunded && t.Status != models.TransferFailed {
+			return ErrInvalidState
+		}
+		if err := s.requireClear(t.SenderID, t.RecipientID); err != nil {
+			return err
+		}
+		recipient, err := s.recipient(tx, customerID, t.RecipientID)
+		if err != nil {
+			return err
+		}
+		if payout, err = payoutFor(recipient, t.PayoutMethod); err != nil {
+			return err
+		}
+		attempt := t.PayoutAttempts + 1
+		payout.TransferID = t.ID
+		payout.Reference = fmt.Sprintf("%s-%d", t.ID, attempt)
+		payout.AmountCents = t.AmountCents
+		payout.Currency = t.Currency
+		return s.move(tx, t, models.TransferPayoutPending, fmt.Sprintf("payout attempt %d", attempt), map[string]interface{}{
+			"payout_attempts": attempt,
+			"failure_reason":  "",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	adapter, ok := s.payouts[t.PayoutMethod]
+	if !ok {
+		return nil, s.failPayout(t.ID, ErrUnsupportedPayout.Error())
+	}
+	receipt, err := adapter.Pay(ctx, payout)
+	if err != nil {
+		if ferr := s.failPayout(t.ID, err.Error()); ferr != nil {
+			return nil, ferr
+		}
+		return s.reload(t.ID)
+	}
+	if err := s.db.Model(&models.Transfer{}).Where("id = ?", t.ID).Update("payout_ref", receipt.Reference).Error; err != nil {
+		return nil, err
+	}
+	if receipt.Settled {
+		return s.SettlePayout(receipt.Reference, true, "")
+	}
+	return s.reload(t.ID)
+}
+
+func (s *Service) failPayout(transferID uuid.UUID, reason string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var t models.Transfer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&t, "id = ?", transferID).Error; err != nil {
+			return err
+		}
+		return s.move(tx, &t, models.TransferFailed, "payout failed", map[string]interface{}{"failure_reason": truncate(reason, 255)})
+	})
+}
+
+// SettlePayout applies the payout result for payoutRef
+func (s *Service) SettlePayout(payoutRef string, success bool, reason string) (*models.Transfer, error) {
+	var t models.Transfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("payout_ref = ? AND status = ?", payoutRef, models.TransferPayoutPending).
+			Take(&t).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownReference
+		}
+		if err != nil {
+			return err
+		}
+		if success {
+			return s.move(tx, &t, models.TransferPaid, "paid out", map[string]interface{}{"paid_at": s.now()})
+		}
+		return s.move(tx, &t, models.TransferFailed, "payout failed", map[string]interface{}{"failure_reason": truncate(reason, 255)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.reload(t.ID)
+}
+
+// Cancel stops a transfer before payout. Funded transfers are refunded to
+// where the money came from: the account balance, or the paying phone via B2C.
+func (s *Service) Cancel(ctx context.Context, customerID, transferID uuid.UUID) (*models.Transfer, error) {
+	var t *models.Transfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if t, err = s.lock(tx, customerID, transferID); err != nil {
+			return err
+		}
+		fields := map[string]interface{}{"cancelled_at": s.now()}
+		switch t.Status {
+		case models.TransferQuoted:
+		case models.TransferFunded, models.TransferFailed:
+			ref, err := s.refund(ctx, tx, t)
+			if err != nil {
+				return err
+			}
+			fields["refund_ref"] = ref
+		default:
+			return ErrInvalidState
+		}
+		return s.move(tx, t, models.TransferCancelled, "cancelled by customer", fields)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.reload(t.ID)
+}
+
+// refund returns the total to the funding source inside tx. The B2C refund is
+// requested before commit, so a failed request leaves the transfer untouched.
+func (s *Service) refund(ctx context.Context, tx *gorm.DB, t *models.Transfer) (string, error) {
+	switch t.FundingSource {
+	case models.FundingAccount:
+		if t.FundingAccountID == nil {
+			return "", ErrInvalidState
+		}
+		if err := models.AdjustBalance(tx, t.FundingType, *t.FundingAccountID, true, t.TotalCents); err != nil {
+			return "", err
+		}
+		ledger := &models.Transaction{
+			ID:             uuid.New(),
+			CustomerID:     t.CustomerID,
+			SenderID:       t.SenderID,
+			Type:           models.TransactionTypeReversal,
+			AmountCents:    t.TotalCents,
+			IsReal:         true,
+			Currency:       t.Currency,
+			Status:         models.StatusSuccess,
+			Reference:      fmt.Sprintf("REV-%s", uuid.New().String()[:8]),
+			IdempotencyKey: "transfer-refund:" + t.ID.String(),
+			Metadata:       models.JSONMap{"transfer_id": t.ID.String(), "reverses": t.FundingRef},
+		}
+		if err := ledger.SetAccount(t.FundingType, *t.FundingAccountID); err != nil {
+			return "", err
+		}
+		if err := tx.Omit(clause.Associations).Create(ledger).Error; err != nil {
+			return "", err
+		}
+		return ledger.Reference, nil
+	case models.FundingMpesa:
+		adapter, ok := s.payouts[models.PayoutMpesaB2C]
+		if !ok {
+			return "", ErrRefundUnavailable
+		}
+		receipt, err := adapter.Pay(ctx, Payout{
+			TransferID:  t.ID,
+			Reference:   "refund-" + t.ID.String(),
+			AmountCents: t.TotalCents,
+			Currency:    t.Currency,
+			Phone:       t.FundingPhone,
+		})
+		if err != nil {
+			return "", err
+		}
+		return receipt.Reference, nil
+	}
+	return "", ErrInvalidState
+}
+
+// Get returns one of the customer's transfers with its history
+func (s *Service) Get(customerID, transferID uuid.UUID) (*models.Transfer, []models.TransferEvent, error) {
+	var t models.Transfer
+	err := s.db.Where("id = ? AND customer_id = ?", transferID, customerID).Take(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrTransferNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var events []models.TransferEvent
+	if err := s.db.Where("transfer_id = ?", t.ID).Order("created_at").Find(&events).Error; err != nil {
+		return nil, nil, err
+	}
+	return &t, events, nil
+}
+
+// List returns the customer's transfers, newest first
+func (s *Service) List(customerID uuid.UUID, limit, offset int) ([]models.Transfer, error) {
+	var out []models.Transfer
+	err := s.db.Where("customer_id = ?", customerID).
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&out).Error
+	return out, err
+}
+
+func (s *Service) lock(tx *gorm.DB, customerID, transferID uuid.UUID) (*models.Transfer, error) {
+	var t models.Transfer
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND customer_id = ?", transferID, customerID).
+		Take(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTransferNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *Service) lockQuoted(tx *gorm.DB, customerID, transferID uuid.UUID) (*models.Transfer, error) {
+	t, err := s.lock(tx, customerID, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != models.TransferQuoted {
+		return nil, ErrInvalidState
+	}
+	if !s.now().Before(t.QuoteExpiresAt) {
+		return nil, ErrQuoteExpired
+	}
+	return t, nil
+}
+
+// move applies a checked status change plus any extra columns and records it
+func (s *Service) move(tx *gorm.DB, t *models.Transfer, next models.TransferStatus, note string, fields map[string]interface{}) error {
+	if !t.Status.CanTransition(next) {
+		return ErrInvalidState
+	}
+	updates := map[string]interface{}{"status": next}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	res := tx.Model(&models.Transfer{}).Where("id = ? AND status = ?", t.ID, t.Status).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidState
+	}
+	if err := recordEvent(tx, t.ID, t.Status, next, note); err != nil {
+		return err
+	}
+	t.Status = next
+	return nil
+}
+
+func recordEvent(tx *gorm.DB, transferID uuid.UUID, from, to models.TransferStatus, note string) error {
+	return tx.Create(&models.TransferEvent{
+		ID:         uuid.New(),
+		TransferID: transferID,
+		From:       from,
+		To:         to,
+		Note:       truncate(note, 255),
+	}).Error
+}
+
+func (s *Service) recipient(tx *gorm.DB, customerID, recipientID uuid.UUID) (*models.Recipient, error) {
+	var r models.Recipient
+	err := tx.Where("id = ? AND customer_id = ?", recipientID, customerID).Take(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRecipientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *Service) reload(id uuid.UUID) (*models.Transfer, error) {
+	var t models.Transfer
+	if err := s.db.Take(&t, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,330 @@
+package remittance
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/keymgmt"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+type fakeKYC struct{ err error }
+
+func (f *fakeKYC) RequireVerified(uuid.UUID) error { return f.err }
+
+type fakeScreening struct{ err error }
+
+func (f *fakeScreening) RequireClear(uuid.UUID, uuid.UUID) error { return f.err }
+
+type fakeCollector struct {
+	phones []string
+	err    error
+}
+
+func (f *fakeCollector) Collect(_ context.Context, phone string, _ int64, key string) (string, error) {
+	f.phones = append(f.phones, phone)
+	return "ws_CO_" + key, f.err
+}
+
+type fakePayouts struct {
+	sent    []Payout
+	err     error
+	settled bool
+}
+
+func (f *fakePayouts) Pay(_ context.Context, p Payout) (PayoutReceipt, error) {
+	f.sent = append(f.sent, p)
+	if f.err != nil {
+		return PayoutReceipt{}, f.err
+	}
+	return PayoutReceipt{Reference: "AG_" + p.Reference, Settled: f.settled}, nil
+}
+
+type fixture struct {
+	s         *Service
+	db        *gorm.DB
+	kyc       *fakeKYC
+	screen    *fakeScreening
+	collector *fakeCollector
+	b2c       *fakePayouts
+	customer  uuid.UUID
+	recipient uuid.UUID
+	account   uuid.UUID
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	kms := keymgmt.NewLocalKMS()
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	models.SetFieldEncryption(keymgmt.NewEnvelope(kms))
+	models.SetBlindIndexKey([]byte(strings.Repeat("b", keymgmt.KeySize)))
+	t.Cleanup(func() {
+		models.SetFieldEncryption(nil)
+		models.SetBlindIndexKey(nil)
+	})
+
+	db := testdb.Open(t, &models.Transfer{}, &models.TransferEvent{}, &models.Sender{}, &models.Recipient{}, &models.Transaction{}, &models.Customer{},
+		&models.SharpAccount{}, &models.SportsAccount{}, &models.StockAccount{}, &models.ForexAccount{}, &models.CryptoAccount{})
+
+	f := &fixture{
+		db:        db,
+		kyc:       &fakeKYC{},
+		screen:    &fakeScreening{},
+		collector: &fakeCollector{},
+		b2c:       &fakePayouts{},
+		customer:  uuid.New(),
+		account:   uuid.New(),
+	}
+	f.s = NewService(db, DefaultConfig(), f.kyc, f.screen, f.collector, map[models.PayoutMethod]PayoutAdapter{models.PayoutMpesaB2C: f.b2c})
+	f.s.now = func() time.Time { return testNow }
+
+	db.Exec("INSERT INTO customers (id, name, email, phone) VALUES (?, 'Achieng', 'achieng@example.com', '+254700000001')", f.customer)
+	if err := db.Create(&models.Sender{ID: uuid.New(), CustomerID: f.customer, FirstName: "Achieng", LastName: "Otieno"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	r := models.Recipient{ID: uuid.New(), CustomerID: f.customer, FirstName: "Baraka", LastName: "Mwangi"}
+	if err := r.SetPhone("0722000111"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Omit("Customer").Create(&r).Error; err != nil {
+		t.Fatal(err)
+	}
+	f.recipient = r.ID
+	db.Exec("INSERT INTO sports_accounts (id, customer_id, bookie_id, manager_id, real_balance_cents) VALUES (?, ?, ?, ?, 500000)", f.account, f.customer, uuid.New(), uuid.New())
+	return f
+}
+
+func (f *fixture) quote(t *testing.T, amount int64) *models.Transfer {
+	t.Helper()
+	tr, err := f.s.Quote(f.customer, QuoteRequest{RecipientID: f.recipient, AmountCents: amount, PayoutMethod: models.PayoutMpesaB2C})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func (f *fixture) balance() int64 {
+	var b int64
+	f.db.Raw("SELECT real_balance_cents FROM sports_accounts WHERE id = ?", f.account).Row().Scan(&b)
+	return b
+}
+
+func TestQuote(t *testing.T) {
+	f := newFixture(t)
+	tr := f.quote(t, 100000)
+	if tr.FeeCents != 5000 || tr.TotalCents != 105000 || tr.Status != models.TransferQuoted {
+		t.Fatalf("quote = %+v", tr)
+	}
+	if !tr.QuoteExpiresAt.Equal(testNow.Add(15 * time.Minute)) {
+		t.Fatalf("expiry = %s", tr.QuoteExpiresAt)
+	}
+	if big := f.quote(t, 1000000); big.FeeCents != 10000 {
+		t.Fatalf("1%% fee = %d", big.FeeCents)
+	}
+
+	cases := map[string]struct {
+		req  QuoteRequest
+		want error
+	}{
+		"too small":         {QuoteRequest{RecipientID: f.recipient, AmountCents: 500, PayoutMethod: models.PayoutMpesaB2C}, ErrInvalidAmount},
+		"no bank adapter":   {QuoteRequest{RecipientID: f.recipient, AmountCents: 100000, PayoutMethod: models.PayoutBank}, ErrUnsupportedPayout},
+		"foreign recipient": {QuoteRequest{RecipientID: uuid.New(), AmountCents: 100000, PayoutMethod: models.PayoutMpesaB2C}, ErrRecipientNotFound},
+	}
+	for name, tc := range cases {
+		if _, err := f.s.Quote(f.customer, tc.req); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+	if _, err := f.s.Quote(uuid.New(), QuoteRequest{RecipientID: f.recipient, AmountCents: 100000, PayoutMethod: models.PayoutMpesaB2C}); !errors.Is(err, ErrNoSender) {
+		t.Errorf("no sender: %v", err)
+	}
+}
+
+func TestAccountFundedTransferPaysOut(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	tr := f.quote(t, 100000)
+
+	f.kyc.err = errors.New("not verified")
+	if _, err := f.s.Fund(ctx, f.customer, tr.ID, FundRequest{Source: models.FundingAccount, AccountID: f.account}); err == nil {
+		t.Fatal("funded without KYC")
+	}
+	f.kyc.err = nil
+
+	funded, err := f.s.Fund(ctx, f.customer, tr.ID, FundRequest{Source: models.FundingAccount, AccountID: f.account})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if funded.Status != models.TransferFunded || f.balance() != 500000-105000 {
+		t.Fatalf("after funding: %s, balance %d", funded.Status, f.balance())
+	}
+	if _, err := f.s.Fund(ctx, f.customer, tr.ID, FundRequest{Source: models.FundingAccount, AccountID: f.account}); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("double funding: %v", err)
+	}
+
+	// First attempt fails at the provider; the retry goes through.
+	f.b2c.err = errors.New("timeout")
+	failed, err := f.s.Send(ctx, f.customer, tr.ID)
+	if err != nil || failed.Status != models.TransferFailed {
+		t.Fatalf("failed send = %+v, %v", failed, err)
+	}
+	f.b2c.err = nil
+	pending, err := f.s.Send(ctx, f.customer, tr.ID)
+	if err != nil || pending.Status != models.TransferPayoutPending || pending.PayoutAttempts != 2 {
+		t.Fatalf("retry = %+v, %v", pending, err)
+	}
+	if last := f.b2c.sent[len(f.b2c.sent)-1]; last.Phone != "+254722000111" || last.AmountCents != 100000 {
+		t.Fatalf("payout = %+v", last)
+	}
+	if _, err := f.s.Cancel(ctx, f.customer, tr.ID); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("cancel during payout: %v", err)
+	}
+
+	paid, err := f.s.SettlePayout(pending.PayoutRef, true, "")
+	if err != nil || paid.Status != models.TransferPaid || paid.PaidAt == nil {
+		t.Fatalf("settle = %+v, %v", paid, err)
+	}
+	if _, err := f.s.SettlePayout(pending.PayoutRef, true, ""); !errors.Is(err, ErrUnknownReference) {
+		t.Fatalf("redelivered result: %v", err)
+	}
+
+	_, events, err := f.s.Get(f.customer, tr.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var path []models.TransferStatus
+	for _, e := range events {
+		path = append(path, e.To)
+	}
+	want := []models.TransferStatus{models.TransferQuoted, models.TransferFunded, models.TransferPayoutPending, models.TransferFailed, models.TransferPayoutPending, models.TransferPaid}
+	if !slices.Equal(path, want) {
+		t.Fatalf("history = %v", path)
+	}
+}
+
+func TestFundingRules(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	big := f.quote(t, 1000000)
+	if _, err := f.s.Fund(ctx, f.customer, big.ID, FundRequest{Source: models.FundingAccount, AccountID: f.account}); !errors.Is(err, models.ErrInsufficientFunds) {
+		t.Fatalf("overdraft: %v", err)
+	}
+
+	other := uuid.New()
+	f.db.Exec("INSERT INTO crypto_accounts (id, customer_id, bookie_id, manager_id, real_balance_cents) VALUES (?, ?, ?, ?, 900000)", other, uuid.New(), uuid.New(), uuid.New())
+	tr := f.quote(t, 100000)
+	if _, err := f.s.Fund(ctx, f.customer, tr.ID, FundRequest{Source: models.FundingAccount, AccountID: other}); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("someone else's account: %v", err)
+	}
+
+	f.s.now = func() time.Time { return testNow.Add(16 * time.Minute) }
+	if _, err := f.s.Fund(ctx, f.customer, tr.ID, FundRequest{Source: models.FundingAccount, AccountID: f.account}); !errors.Is(err, ErrQuoteExpired) {
+		t.Fatalf("expired quote: %v", err)
+	}
+	if f.balance() != 500000 {
+		t.Fatalf("balance moved: %d", f.balance())
+	}
+}
+
+func TestScreeningHoldsPayout(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	tr := f.quote(t, 100000)
+	if _, err := f.s.Fund(ctx, f.customer, tr.ID, FundRequest{Source: models.FundingAccount, AccountID: f.account}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A list refresh flags the recipient after funding.
+	hold := errors.New("held")
+	f.screen.err = hold
+	if _, err := f.s.Send(ctx, f.customer, tr.ID); !errors.Is(err, hold) {
+		t.Fatalf("send while held: %v", err)
+	}
+	if len(f.b2c.sent) != 0 {
+		t.Fatalf("payout sent while held: %+v", f.b2c.sent)
+	}
+	if _, err := f.s.Quote(f.customer, QuoteRequest{RecipientID: f.recipient, AmountCents: 100000, PayoutMethod: models.PayoutMpesaB2C}); !errors.Is(err, hold) {
+		t.Fatalf("quote while held: %v", err)
+	}
+
+	f.screen.err = nil
+	got, err := f.s.Send(ctx, f.customer, tr.ID)
+	if err != nil || got.Status != models.TransferPayoutPending {
+		t.Fatalf("send after review: %+v, %v", got, err)
+	}
+}
+
+func TestCancelRefundsAccount(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	tr := f.quote(t, 100000)
+	if _, err := f.s.Fund(ctx, f.customer, tr.ID, FundRequest{Source: models.FundingAccount, AccountID: f.account}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.s.Cancel(ctx, uuid.New(), tr.ID); !errors.Is(err, ErrTransferNotFound) {
+		t.Fatalf("foreign cancel: %v", err)
+	}
+	cancelled, err := f.s.Cancel(ctx, f.customer, tr.ID)
+	if err != nil || cancelled.Status != models.TransferCancelled || cancelled.RefundRef == "" {
+		t.Fatalf("cancel = %+v, %v", cancelled, err)
+	}
+	if f.balance() != 500000 {
+		t.Fatalf("balance after refund = %d", f.balance())
+	}
+	if _, err := f.s.Send(ctx, f.customer, tr.ID); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("send after cancel: %v", err)
+	}
+}
+
+func TestMpesaFunding(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	tr := f.quote(t, 100000)
+	waiting, err := f.s.Fund(ctx, f.customer, tr.ID, FundRequest{Source: models.FundingMpesa})
+	if err != nil || waiting.Status != models.TransferAwaitingFunds || waiting.FundingRef == "" {
+		t.Fatalf("fund = %+v, %v", waiting, err)
+	}
+	if f.collector.phones[0] != "+254700000001" {
+		t.Fatalf("pushed to %v", f.collector.phones)
+	}
+	if _, err := f.s.Cancel(ctx, f.customer, tr.ID); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("cancel while collecting: %v", err)
+	}
+	funded, err := f.s.ConfirmFunding(waiting.FundingRef, true, "")
+	if err != nil || funded.Status != models.TransferFunded {
+		t.Fatalf("confirm = %+v, %v", funded, err)
+	}
+
+	// Cancelling an M-Pesa funded transfer sends the money back to the paying phone.
+	if _, err := f.s.Cancel(ctx, f.customer, tr.ID); err != nil {
+		t.Fatal(err)
+	}
+	refund := f.b2c.sent[len(f.b2c.sent)-1]
+	if refund.Phone != "+254700000001" || refund.AmountCents != 105000 {
+		t.Fatalf("refund = %+v", refund)
+	}
+
+	// A declined STK push cancels the transfer.
+	declined := f.quote(t, 100000)
+	if _, err := f.s.Fund(ctx, f.customer, declined.ID, FundRequest{Source: models.FundingMpesa, Phone: "0733000222"}); err != nil {
+		t.Fatal(err)
+	}
+	got, _, _ := f.s.Get(f.customer, declined.ID)
+	if after, err := f.s.ConfirmFunding(got.FundingRef, false, "Request cancelled by user"); err != nil || after.Status != models.TransferCancelled {
+		t.Fatalf("declined = %+v, %v", after, err)
+	}
+}
@@ -0,0 +1,213 @@
+package screening
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Entry kinds
+const (
+	KindSanction = "sanction"
+	KindPEP      = "pep"
+)
+
+var ErrUnsupportedList = errors.New("unsupported list file; use .csv or .xml")
+
+// Entry is a listed person
+type Entry struct {
+	ID        string
+	Source    string
+	Kind      string
+	Names     []string // primary name first, then aliases
+	BirthYear int      // 0 when unknown
+	Country   string
+}
+
+// Watchlist is the set of entries loaded from the configured files.
+// Version changes whenever any file's contents do.
+type Watchlist struct {
+	Entries []Entry
+	Version string
+}
+
+// LoadFiles reads every list file. The source of an entry defaults to the
+// file name without its extension.
+func LoadFiles(paths ...string) (*Watchlist, error) {
+	w := &Watchlist{}
+	h := sha256.New()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(data)
+		source := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		var entries []Entry
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			entries, err = ParseCSV(bytes.NewReader(data), source)
+		case ".xml":
+			entries, err = ParseXML(bytes.NewReader(data), source)
+		default:
+			err = ErrUnsupportedList
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		w.Entries = append(w.Entries, entries...)
+	}
+	w.Version = hex.EncodeToString(h.Sum(nil))[:16]
+	return w, nil
+}
+
+// ParseCSV reads a list with a header row. Recognised columns are id, name,
+// aliases (separated by ";"), birth_date (YYYY or YYYY-MM-DD), kind
+// ("sanction" or "pep"), source and country; others are ignored.
+func ParseCSV(r io.Reader, source string) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := col["name"]; !ok {
+		return nil, errors.New("csv list has no name column")
+	}
+	get := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	var entries []Entry
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := Entry{
+			ID:        get(rec, "id"),
+			Source:    get(rec, "source"),
+			Kind:      get(rec, "kind"),
+			BirthYear: birthYear(get(rec, "birth_date")),
+			Country:   get(rec, "country"),
+		}
+		if name := get(rec, "name"); name != "" {
+			e.Names = append(e.Names, name)
+		}
+		for _, alias := range strings.Split(get(rec, "aliases"), ";") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				e.Names = append(e.Names, alias)
+			}
+		}
+		if err := finish(&e, source, line); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// xmlList is the XML list layout:
+//
+//	<list source="UN">
+//	  <entry id="QDi.001" kind="sanction">
+//	    <name>...</name>
+//	    <alias>...</alias>
+//	    <birth_date>1956-07-28</birth_date>
+//	    <country>AF</country>
+//	  </entry>
+//	</list>
+type xmlList struct {
+	Source  string `xml:"source,attr"`
+	Entries []struct {
+		ID        string   `xml:"id,attr"`
+		Kind      string   `xml:"kind,attr"`
+		Name      string   `xml:"name"`
+		Aliases   []string `xml:"alias"`
+		BirthDate string   `xml:"birth_date"`
+		Country   string   `xml:"country"`
+	} `xml:"entry"`
+}
+
+// ParseXML reads a list in the xmlList layout
+func ParseXML(r io.Reader, source string) ([]Entry, error) {
+	var doc xmlList
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.Source != "" {
+		source = doc.Source
+	}
+	entries := make([]Entry, 0, len(doc.Entries))
+	for i, x := range doc.Entries {
+		e := Entry{
+			ID:        strings.TrimSpace(x.ID),
+			Kind:      strings.TrimSpace(x.Kind),
+			BirthYear: birthYear(x.BirthDate),
+			Country:   strings.TrimSpace(x.Country),
+		}
+		for _, name := range append([]string{x.Name}, x.Aliases...) {
+			if name = strings.TrimSpace(name); name != "" {
+				e.Names = append(e.Names, name)
+			}
+		}
+		if err := finish(&e, source, i+1); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// finish fills defaults and rejects entries that cannot be matched
+func finish(e *Entry, source string, pos int) error {
+	if len(e.Names) == 0 {
+		return fmt.Errorf("entry %d has no name", pos)
+	}
+	if e.Source == "" {
+		e.Source = source
+	}
+	if e.ID == "" {
+		e.ID = strconv.Itoa(pos)
+	}
+	e.Kind = strings.ToLower(e.Kind)
+	switch e.Kind {
+	case "":
+		e.Kind = KindSanction
+	case KindSanction, KindPEP:
+	default:
+		return fmt.Errorf("entry %d has unknown kind %q", pos, e.Kind)
+	}
+	return nil
+}
+
+// birthYear takes the year from YYYY or YYYY-MM-DD; anything else is unknown
+func birthYear(s string) int {
+	s = strings.TrimSpace(s)
+	if len(s) < 4 {
+		return 0
+	}
+	y, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return 0
+	}
+	return y
+}
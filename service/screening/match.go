@@ -0,0 +1,151 @@
+package screening
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"weriKana/models"
+)
+
+// normalize lowercases a name, drops accents and punctuation, and returns its
+// words in sorted order so "Omar Ali" and "ALI, Omar" compare equal
+func normalize(name string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r): // combining accent
+		case unicode.IsLetter(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	words := strings.Fields(b.String())
+	slices.Sort(words)
+	return words
+}
+
+// similarity scores two normalized names from 0 to 1. Multi-word names also
+// score word by word, so a missing middle name or a misspelled surname still
+// comes close.
+func similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	best := jaroWinkler(strings.Join(a, " "), strings.Join(b, " "))
+	short, long := a, b
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	// A single word would match everyone sharing a first name.
+	if len(short) < 2 {
+		return best
+	}
+	var sum float64
+	for _, w := range short {
+		var top float64
+		for _, x := range long {
+			top = max(top, jaroWinkler(w, x))
+		}
+		sum += top
+	}
+	return max(best, sum/float64(len(short)))
+}
+
+// match returns the entries name comes within cfg.Threshold of, best first.
+// An entry whose birth year is known and further than
+// cfg.BirthYearTolerance from birthYear is ruled out.
+func match(name string, birthYear int, entries []Entry, cfg Config) models.ScreeningMatches {
+	words := normalize(name)
+	var out models.ScreeningMatches
+	for _, e := range entries {
+		if birthYear != 0 && e.BirthYear != 0 && abs(birthYear-e.BirthYear) > cfg.BirthYearTolerance {
+			continue
+		}
+		var top float64
+		var matched string
+		for _, n := range e.Names {
+			if s := similarity(words, normalize(n)); s > top {
+				top, matched = s, n
+			}
+		}
+		if top >= cfg.Threshold {
+			out = append(out, models.ScreeningMatch{
+				EntryID: e.ID,
+				Source:  e.Source,
+				Kind:    e.Kind,
+				Name:    matched,
+				Score:   float64(int(top*1000)) / 1000,
+			})
+		}
+	}
+	slices.SortStableFunc(out, func(x, y models.ScreeningMatch) int {
+		switch {
+		case x.Score > y.Score:
+			return -1
+		case x.Score < y.Score:
+			return 1
+		}
+		return 0
+	})
+	return out
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// jaroWinkler is the Jaro similarity boosted for a shared prefix of up to
+// four characters
+func jaroWinkler(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	window := max(len(s), len(t))/2 - 1
+	window = max(window, 0)
+	sm, tm := make([]bool, len(s)), make([]bool, len(t))
+	matches := 0
+	for i := range s {
+		lo, hi := max(0, i-window), min(len(t), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !tm[j] && s[i] == t[j] {
+				sm[i], tm[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range s {
+		if !sm[i] {
+			continue
+		}
+		for !tm[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3
+	prefix := 0
+	for prefix < min(4, len(s), len(t)) && s[prefix] == t[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
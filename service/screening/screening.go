@@ -0,0 +1,341 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var (
+	ErrScreeningHold  = errors.New("transfer held pending sanctions screening review")
+	ErrResultNotFound = errors.New("screening result not found")
+	ErrNotPending     = errors.New("screening result is not awaiting review")
+	ErrNoteRequired   = errors.New("a review note is required")
+	ErrNoLists        = errors.New("no screening lists configured")
+)
+
+// Config holds the list files and matching thresholds
+type Config struct {
+	Paths              []string // .csv or .xml list files
+	Threshold          float64  // minimum similarity (0-1) that counts as a possible match
+	BirthYearTolerance int      // years a known birth year may differ and still match
+}
+
+func DefaultConfig(paths ...string) Config {
+	return Config{Paths: paths, Threshold: 0.88, BirthYearTolerance: 1}
+}
+
+// subjectTables maps screened subjects to their tables
+var subjectTables = map[models.KYCSubject]string{
+	models.KYCSubjectSender:    "senders",
+	models.KYCSubjectRecipient: "recipients",
+}
+
+// subject is the part of a sender or recipient that is screened
+type subject struct {
+	ID         uuid.UUID
+	CustomerID uuid.UUID
+	FirstName  string
+	LastName   string
+	BirthDate  time.Time
+}
+
+// Service screens senders and recipients against the loaded lists. Until a
+// list is loaded nothing is screened and nothing is held.
+type Service struct {
+	db     *gorm.DB
+	cfg    Config
+	mu     sync.RWMutex
+	list   *Watchlist
+	loaded map[string]time.Time // list file modification times at last load
+	now    func() time.Time
+}
+
+func NewService(db *gorm.DB, cfg Config) *Service {
+	return &Service{db: db, cfg: cfg, now: time.Now}
+}
+
+// Refresh reloads the list files and rescreens everyone, returning how many
+// subjects now await review. The previous lists stay in force if a file
+// cannot be read.
+func (s *Service) Refresh() (int, error) {
+	if len(s.cfg.Paths) == 0 {
+		return 0, ErrNoLists
+	}
+	mtimes, err := modTimes(s.cfg.Paths)
+	if err != nil {
+		return 0, err
+	}
+	list, err := LoadFiles(s.cfg.Paths...)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	s.list, s.loaded = list, mtimes
+	s.mu.Unlock()
+	return s.Rescreen()
+}
+
+// Run refreshes the lists whenever one of the files changes on disk
+func (s *Service) Run(ctx context.Context, interval time.Duration, logf func(string, ...interface{})) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mtimes, err := modTimes(s.cfg.Paths)
+			if err != nil {
+				logf("screening: %v", err)
+				continue
+			}
+			s.mu.RLock()
+			changed := !mapsEqual(mtimes, s.loaded)
+			s.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if flagged, err := s.Refresh(); err != nil {
+				logf("screening: refresh failed: %v", err)
+			} else {
+				logf("screening: lists reloaded, %d subjects awaiting review", flagged)
+			}
+		}
+	}
+}
+
+func modTimes(paths []string) (map[string]time.Time, error) {
+	out := make(map[string]time.Time, len(paths))
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		out[p] = fi.ModTime()
+	}
+	return out, nil
+}
+
+func mapsEqual(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if !b[k].Equal(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Service) watchlist() *Watchlist {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list
+}
+
+// ScreenSubject screens a sender or recipient as saved in tx. KYC and the
+// address book call it after every create or update of a name or birth date.
+func (s *Service) ScreenSubject(tx *gorm.DB, subjectType models.KYCSubject, id uuid.UUID) error {
+	list := s.watchlist()
+	if list == nil {
+		return nil
+	}
+	table, ok := subjectTables[subjectType]
+	if !ok {
+		return ErrResultNotFound
+	}
+	var row subject
+	err := tx.Table(table).Select("id, customer_id, first_name, last_name, birth_date").
+		Where("id = ? AND deleted_at IS NULL", id).Take(&row).Error
+	if err != nil {
+		return err
+	}
+	_, err = s.screen(tx, subjectType, &row, list)
+	return err
+}
+
+// screen records the outcome for one subject. A confirmed match stays
+// confirmed; matches a reviewer already cleared do not reopen review.
+func (s *Service) screen(tx *gorm.DB, subjectType models.KYCSubject, row *subject, list *Watchlist) (*models.ScreeningResult, error) {
+	name := strings.TrimSpace(row.FirstName + " " + row.LastName)
+	year := 0
+	if !row.BirthDate.IsZero() {
+		year = row.BirthDate.Year()
+	}
+	matches := match(name, year, list.Entries, s.cfg)
+
+	var r models.ScreeningResult
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("subject_type = ? AND subject_id = ?", subjectType, row.ID).Take(&r).Error
+	isNew := errors.Is(err, gorm.ErrRecordNotFound)
+	if err != nil && !isNew {
+		return nil, err
+	}
+	if isNew {
+		r = models.ScreeningResult{ID: uuid.New(), SubjectType: subjectType, SubjectID: row.ID}
+	}
+	r.CustomerID = row.CustomerID
+	r.Name = name
+	r.Matches = matches
+	r.Score = 0
+	if len(matches) > 0 {
+		r.Score = matches[0].Score
+	}
+	r.ListVersion = list.Version
+	r.ScreenedAt = s.now()
+	switch {
+	case r.Status == models.ScreeningConfirmed:
+	case len(matches) == 0:
+		r.Status = models.ScreeningClear
+	case allCleared(matches, r.Cleared):
+		r.Status = models.ScreeningCleared
+	default:
+		r.Status = models.ScreeningPending
+	}
+	if isNew {
+		return &r, tx.Create(&r).Error
+	}
+	return &r, tx.Model(&r).Select("CustomerID", "Name", "Matches", "Score", "ListVersion", "ScreenedAt", "Status").Updates(&r).Error
+}
+
+func allCleared(matches, cleared models.ScreeningMatches) bool {
+	for _, m := range matches {
+		if !slices.ContainsFunc(cleared, func(c models.ScreeningMatch) bool { return c.Key() == m.Key() }) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rescreen screens every sender and recipient against the current lists and
+// returns how many await review afterwards
+func (s *Service) Rescreen() (int, error) {
+	list := s.watchlist()
+	if list == nil {
+		return 0, ErrNoLists
+	}
+	const batch = 500
+	flagged := 0
+	for subjectType, table := range subjectTables {
+		last := uuid.Nil
+		for {
+			var rows []subject
+			err := s.db.Table(table).Select("id, customer_id, first_name, last_name, birth_date").
+				Where("deleted_at IS NULL AND id > ?", last).Order("id").Limit(batch).Find(&rows).Error
+			if err != nil {
+				return flagged, err
+			}
+			for i := range rows {
+				var r *models.ScreeningResult
+				err := s.db.Transaction(func(tx *gorm.DB) (err error) {
+					r, err = s.screen(tx, subjectType, &rows[i], list)
+					return err
+				})
+				if err != nil {
+					return flagged, err
+				}
+				if r.Status == models.ScreeningPending {
+					flagged++
+				}
+			}
+			if len(rows) < batch {
+				break
+			}
+			last = rows[len(rows)-1].ID
+		}
+	}
+	return flagged, nil
+}
+
+// RequireClear refuses a transfer whose sender or recipient is awaiting
+// review or confirmed as listed
+func (s *Service) RequireClear(senderID, recipientID uuid.UUID) error {
+	var held int64
+	err := s.db.Model(&models.ScreeningResult{}).
+		Where("((subject_type = ? AND subject_id = ?) OR (subject_type = ? AND subject_id = ?)) AND status IN ?",
+			models.KYCSubjectSender, senderID, models.KYCSubjectRecipient, recipientID,
+			[]models.ScreeningStatus{models.ScreeningPending, models.ScreeningConfirmed}).
+		Count(&held).Error
+	if err != nil {
+		return err
+	}
+	if held > 0 {
+		return ErrScreeningHold
+	}
+	return nil
+}
+
+// Pending lists results awaiting review, strongest match first
+func (s *Service) Pending(limit, offset int) ([]models.ScreeningResult, error) {
+	var out []models.ScreeningResult
+	err := s.db.Where("status = ?", models.ScreeningPending).
+		Order("score DESC, screened_at").
+		Limit(limit).Offset(offset).
+		Find(&out).Error
+	return out, err
+}
+
+// Review settles a possible match. Confirming keeps the subject's transfers
+// held; clearing releases them and remembers the matches as false positives.
+func (s *Service) Review(actor backoffice.Actor, id uuid.UUID, confirm bool, note string) (*models.ScreeningResult, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, ErrNoteRequired
+	}
+	var r models.ScreeningResult
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&r, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrResultNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if r.Status != models.ScreeningPending {
+			return ErrNotPending
+		}
+		now := s.now()
+		r.ReviewerID, r.ReviewedAt, r.ReviewNote = &actor.ID, &now, truncate(note, 500)
+		r.Status = models.ScreeningConfirmed
+		if !confirm {
+			r.Status = models.ScreeningCleared
+			for _, m := range r.Matches {
+				if !allCleared(models.ScreeningMatches{m}, r.Cleared) {
+					r.Cleared = append(r.Cleared, m)
+				}
+			}
+		}
+		if err := tx.Model(&r).Select("Status", "Cleared", "ReviewerID", "ReviewedAt", "ReviewNote").Updates(&r).Error; err != nil {
+			return err
+		}
+		return backoffice.Audit(tx, actor, "screening.review", string(r.SubjectType), r.SubjectID, models.JSONMap{
+			"result_id": r.ID.String(),
+			"status":    string(r.Status),
+			"score":     r.Score,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
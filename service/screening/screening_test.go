@@ -0,0 +1,200 @@
+package screening
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/backoffice"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+const unCSV = `id,name,aliases,birth_date,kind,country
+QDi.001,Usama bin Laden,Osama bin Ladin;Abu Abdallah,1957-03-10,sanction,SA
+QDi.002,Joseph Kony,,1961,sanction,UG
+`
+
+const pepXML = `<list source="KE-PEP">
+  <entry id="P-7" kind="pep">
+    <name>Achieng Atieno Odhiambo</name>
+    <alias>Achieng Odhiambo</alias>
+    <birth_date>1975-01-02</birth_date>
+    <country>KE</country>
+  </entry>
+</list>`
+
+func writeList(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := writeList(t, dir, "un.csv", unCSV)
+	xmlPath := writeList(t, dir, "pep.xml", pepXML)
+
+	w, err := LoadFiles(csvPath, xmlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Entries) != 3 {
+		t.Fatalf("entries = %+v", w.Entries)
+	}
+	osama := w.Entries[0]
+	if osama.Source != "un" || osama.Kind != KindSanction || osama.BirthYear != 1957 || len(osama.Names) != 3 {
+		t.Fatalf("csv entry = %+v", osama)
+	}
+	if pep := w.Entries[2]; pep.Source != "KE-PEP" || pep.Kind != KindPEP || pep.ID != "P-7" || len(pep.Names) != 2 {
+		t.Fatalf("xml entry = %+v", pep)
+	}
+
+	writeList(t, dir, "un.csv", unCSV+"QDi.003,Someone Else,,,sanction,\n")
+	w2, err := LoadFiles(csvPath, xmlPath)
+	if err != nil || w2.Version == w.Version {
+		t.Fatalf("version did not change: %v", err)
+	}
+	if _, err := LoadFiles(writeList(t, dir, "list.txt", "x")); !errors.Is(err, ErrUnsupportedList) {
+		t.Fatalf("txt list: %v", err)
+	}
+	if _, err := ParseCSV(strings.NewReader("name,kind\nX,terrorist\n"), "bad"); err == nil {
+		t.Fatal("unknown kind accepted")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	entries, err := ParseCSV(strings.NewReader(unCSV), "un")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cases := []struct {
+		name  string
+		year  int
+		match bool
+	}{
+		{"Osama Bin Laden", 0, true},     // spelling variant of an alias
+		{"LADEN, Usama bin", 1957, true}, // reordered, with punctuation
+		{"Usama bin Laden", 1990, false}, // birth year rules it out
+		{"Josef Koni", 1962, true},       // misspelled, birth year within tolerance
+		{"Joseph Kamau", 0, false},       // shares only a first name
+		{"Wanjiru Kariuki", 0, false},
+	}
+	for _, c := range cases {
+		got := match(c.name, c.year, entries, cfg)
+		if (len(got) > 0) != c.match {
+			t.Errorf("%q (%d): matches = %+v", c.name, c.year, got)
+		}
+	}
+	if jaroWinkler("martha", "marhta") < 0.96 || jaroWinkler("abc", "xyz") != 0 {
+		t.Fatal("jaro-winkler out of range")
+	}
+}
+
+func newTestService(t *testing.T) (*Service, *gorm.DB, string) {
+	t.Helper()
+	db := testdb.Open(t, &models.ScreeningResult{}, &models.AuditLog{}, &models.Sender{}, &models.Recipient{})
+	dir := t.TempDir()
+	path := writeList(t, dir, "un.csv", unCSV)
+	s := NewService(db, DefaultConfig(path, writeList(t, dir, "pep.xml", pepXML)))
+	s.now = func() time.Time { return testNow }
+	return s, db, path
+}
+
+func addPerson(db *gorm.DB, table string, customer uuid.UUID, first, last string, born time.Time) uuid.UUID {
+	id := uuid.New()
+	db.Exec("INSERT INTO "+table+" (id, customer_id, first_name, last_name, birth_date) VALUES (?, ?, ?, ?, ?)", id, customer, first, last, born)
+	return id
+}
+
+func TestScreeningReview(t *testing.T) {
+	s, db, unPath := newTestService(t)
+	reviewer := backoffice.Actor{ID: uuid.New(), Role: models.RoleSupport}
+	customer := uuid.New()
+	sender := addPerson(db, "senders", customer, "Achieng", "Odhiambo", time.Date(1975, 5, 1, 0, 0, 0, 0, time.UTC))
+	recipient := addPerson(db, "recipients", customer, "Baraka", "Mwangi", time.Time{})
+
+	// Nothing is held before a list is loaded.
+	if err := s.ScreenSubject(db, models.KYCSubjectSender, sender); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RequireClear(sender, recipient); err != nil {
+		t.Fatalf("no list: %v", err)
+	}
+
+	flagged, err := s.Refresh()
+	if err != nil || flagged != 1 {
+		t.Fatalf("refresh flagged %d, %v", flagged, err)
+	}
+	if err := s.RequireClear(sender, recipient); !errors.Is(err, ErrScreeningHold) {
+		t.Fatalf("pending sender: %v", err)
+	}
+	pending, err := s.Pending(10, 0)
+	if err != nil || len(pending) != 1 || pending[0].SubjectID != sender || pending[0].Matches[0].Kind != KindPEP {
+		t.Fatalf("pending = %+v, %v", pending, err)
+	}
+
+	if _, err := s.Review(reviewer, pending[0].ID, false, ""); !errors.Is(err, ErrNoteRequired) {
+		t.Fatalf("review without note: %v", err)
+	}
+	cleared, err := s.Review(reviewer, pending[0].ID, false, "different person: ID and address do not match")
+	if err != nil || cleared.Status != models.ScreeningCleared {
+		t.Fatalf("clear: %+v, %v", cleared, err)
+	}
+	if err := s.RequireClear(sender, recipient); err != nil {
+		t.Fatalf("cleared sender: %v", err)
+	}
+
+	// Reloading the same lists keeps the false positive cleared ...
+	if flagged, err := s.Refresh(); err != nil || flagged != 0 {
+		t.Fatalf("refresh after review flagged %d, %v", flagged, err)
+	}
+	// ... but a new entry for the name reopens review.
+	writeList(t, filepath.Dir(unPath), "un.csv", unCSV+"QDi.009,Achieng Odiambo,,1975,sanction,KE\n")
+	if flagged, err := s.Refresh(); err != nil || flagged != 1 {
+		t.Fatalf("refresh with new entry flagged %d, %v", flagged, err)
+	}
+
+	// A recipient renamed onto a list is screened on save and, once
+	// confirmed, stays held.
+	db.Exec("UPDATE recipients SET first_name = 'Joseph', last_name = 'Kony' WHERE id = ?", recipient)
+	if err := s.ScreenSubject(db, models.KYCSubjectRecipient, recipient); err != nil {
+		t.Fatal(err)
+	}
+	var r models.ScreeningResult
+	db.Where("subject_type = ? AND subject_id = ?", models.KYCSubjectRecipient, recipient).Take(&r)
+	if r.Status != models.ScreeningPending {
+		t.Fatalf("renamed recipient = %+v", r)
+	}
+	if _, err := s.Review(reviewer, r.ID, true, "matches UN listing"); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("UPDATE recipients SET first_name = 'Baraka', last_name = 'Mwangi' WHERE id = ?", recipient)
+	s.ScreenSubject(db, models.KYCSubjectRecipient, recipient)
+	db.Where("id = ?", r.ID).Take(&r)
+	if r.Status != models.ScreeningConfirmed {
+		t.Fatalf("confirmed match was released by a rename: %s", r.Status)
+	}
+	if _, err := s.Review(reviewer, r.ID, false, "again"); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("second review: %v", err)
+	}
+
+	var audits []models.AuditLog
+	db.Where("action = ?", "screening.review").Find(&audits)
+	if len(audits) != 2 || !slices.ContainsFunc(audits, func(a models.AuditLog) bool { return a.TargetID == recipient }) {
+		t.Fatalf("audits = %+v", audits)
+	}
+}
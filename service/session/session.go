@@ -0,0 +1,325 @@
+package session
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/middleware"
+	"weriKana/models"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused; session revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+type Config struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	CacheTTL   time.Duration // how long IsActive trusts a positive lookup
+}
+
+// DefaultConfig returns the production lifetimes
+func DefaultConfig() Config {
+	return Config{
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 30 * 24 * time.Hour,
+		CacheTTL:   30 * time.Second,
+	}
+}
+
+// Tokens is the pair returned by login and refresh
+type Tokens struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	SessionID    uuid.UUID `json:"session_id"`
+}
+
+// Device is the client metadata recorded on a session
+type Device struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Signer supplies the current access-token signing key (jwtkeys.KeySet implements it)
+type Signer interface {
+	SigningKey() (kid string, method jwt.SigningMethod, key crypto.PrivateKey, err error)
+}
+
+// Service issues access/refresh token pairs backed by models.Session rows
+type Service struct {
+	db     *gorm.DB
+	signer Signer
+	cfg    Config
+	now    func() time.Time
+
+	mu     sync.Mutex
+	active map[string]time.Time // session id -> positive lookup expiry
+}
+
+func NewService(db *gorm.DB, signer Signer, cfg Config) *Service {
+	return &Service{
+		db:     db,
+		signer: signer,
+		cfg:    cfg,
+		now:    time.Now,
+		active: make(map[string]time.Time),
+	}
+}
+
+// Start creates a session for a freshly authenticated customer with the given role and scopes
+func (s *Service) Start(customerID uuid.UUID, role models.Role, scopes []string, device Device) (*Tokens, error) {
+	refresh, secretHash, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	sess := &models.Session{
+		ID:          uuid.New(),
+		CustomerID:  customerID,
+		Scope:       middleware.JoinScopes(scopes),
+		Role:        role,
+		RefreshHash: secretHash,
+		UserAgent:   truncate(device.UserAgent, 255),
+		IPAddress:   truncate(device.IPAddress, 64),
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(s.cfg.RefreshTTL),
+	}
+	if err := s.db.Create(sess).Error; err != nil {
+		return nil, err
+	}
+	return s.tokens(sess, refresh)
+}
+
+// Refresh rotates the refresh token and issues a new access token.
+// Presenting the token that was just rotated out revokes the whole session.
+func (s *Service) Refresh(refreshToken string, device Device) (*Tokens, error) {
+	sessionID, secret, ok := splitRefreshToken(refreshToken)
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	presented := hashSecret(secret)
+
+	var tokens *Tokens
+	var result error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var sess models.Session
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sess, "id = ?", sessionID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result = ErrInvalidRefreshToken
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		if !sess.Active(now) {
+			result = ErrInvalidRefreshToken
+			return nil
+		}
+		if sess.PreviousHash != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(sess.PreviousHash)) == 1 {
+			result = ErrRefreshTokenReused
+			s.forget(sess.ID)
+			return tx.Model(&sess).Update("revoked_at", now).Error
+		}
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(sess.RefreshHash)) != 1 {
+			result = ErrInvalidRefreshToken
+			return nil
+		}
+
+		refresh, nextHash, err := newRefreshSecret()
+		if err != nil {
+			return err
+		}
+		sess.PreviousHash = sess.RefreshHash
+		sess.RefreshHash = nextHash
+		sess.LastUsedAt = now
+		if device.UserAgent != "" {
+			sess.UserAgent = truncate(device.UserAgent, 255)
+		}
+		if device.IPAddress != "" {
+			sess.IPAddress = truncate(device.IPAddress, 64)
+		}
+		if err := tx.Save(&sess).Error; err != nil {
+			return err
+		}
+		tokens, err = s.tokens(&sess, refresh)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, result
+}
+
+// List returns the customer's unrevoked, unexpired sessions, newest first
+func (s *Service) List(customerID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.Where("customer_id = ? AND revoked_at IS NULL AND expires_at > ?", customerID, s.now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke ends one of the customer's sessions
+func (s *Service) Revoke(customerID, sessionID uuid.UUID) error {
+	res := s.db.Model(&models.Session{}).
+		Where("id = ? AND customer_id = ? AND revoked_at IS NULL", sessionID, customerID).
+		Update("revoked_at", s.now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	s.forget(sessionID)
+	return nil
+}
+
+// RevokeAll ends every session of the customer (e.g. after a password reset)
+func (s *Service) RevokeAll(customerID uuid.UUID) error {
+	return s.RevokeOthers(customerID, uuid.Nil)
+}
+
+// RevokeOthers ends every session of the customer except keep, the one the
+// request came in on (e.g. after a password change)
+func (s *Service) RevokeOthers(customerID, keep uuid.UUID) error {
+	others := s.db.Model(&models.Session{}).
+		Where("customer_id = ? AND revoked_at IS NULL AND id <> ?", customerID, keep)
+	var ids []uuid.UUID
+	if err := others.Session(&gorm.Session{}).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if err := others.Session(&gorm.Session{}).Update("revoked_at", s.now()).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.forget(id)
+	}
+	return nil
+}
+
+// IsActive implements middleware.SessionChecker. Positive results are cached
+// for CacheTTL; revocations through this Service take effect immediately.
+func (s *Service) IsActive(sessionID string) bool {
+	now := s.now()
+	s.mu.Lock()
+	until, ok := s.active[sessionID]
+	s.mu.Unlock()
+	if ok && now.Before(until) {
+		return true
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return false
+	}
+	var sess models.Session
+	if err := s.db.Select("id", "revoked_at", "expires_at").First(&sess, "id = ?", id).Error; err != nil {
+		return false
+	}
+	if !sess.Active(now) {
+		s.forget(id)
+		return false
+	}
+	s.mu.Lock()
+	s.active[sessionID] = now.Add(s.cfg.CacheTTL)
+	s.mu.Unlock()
+	return true
+}
+
+func (s *Service) forget(sessionID uuid.UUID) {
+	s.mu.Lock()
+	delete(s.active, sessionID.String())
+	s.mu.Unlock()
+}
+
+func (s *Service) tokens(sess *models.Session, refresh string) (*Tokens, error) {
+	access, expiresAt, err := s.accessToken(sess)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: sess.ID.String() + "." + refresh,
+		TokenType:    "bearer",
+		ExpiresAt:    expiresAt,
+		SessionID:    sess.ID,
+	}, nil
+}
+
+func (s *Service) accessToken(sess *models.Session) (string, time.Time, error) {
+	now := s.now()
+	expiresAt := now.Add(s.cfg.AccessTTL)
+	claims := &middleware.Claims{
+		CustomerID: sess.CustomerID.String(),
+		SessionID:  sess.ID.String(),
+		Scope:      sess.Scope,
+		Role:       string(sess.Role),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   sess.CustomerID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	kid, method, key, err := s.signer.SigningKey()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	return signed, expiresAt, err
+}
+
+// newRefreshSecret returns 32 random bytes (base64url) and their SHA-256 hex
+func newRefreshSecret() (secret, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(raw)
+	return secret, hashSecret(secret), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitRefreshToken parses "<session id>.<secret>"
+func splitRefreshToken(token string) (uuid.UUID, string, bool) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || secret == "" {
+		return uuid.Nil, "", false
+	}
+	sessionID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+	return sessionID, secret, true
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,193 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"weriKana/internal/testdb"
+	"weriKana/middleware"
+	"weriKana/models"
+	"weriKana/service/jwtkeys"
+)
+
+func newTestService(t *testing.T) (*Service, *time.Time) {
+	t.Helper()
+	keys, err := jwtkeys.Open(jwtkeys.DefaultConfig(jwtkeys.AlgEdDSA, t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	s := NewService(testdb.Open(t, &models.Session{}), keys, DefaultConfig())
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestRefreshTokenFormat(t *testing.T) {
+	secret, hash, err := newRefreshSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashSecret(secret) != hash || len(hash) != 64 {
+		t.Fatalf("hash mismatch: %s", hash)
+	}
+	other, _, _ := newRefreshSecret()
+	if other == secret {
+		t.Fatal("refresh secrets repeat")
+	}
+
+	id := uuid.New()
+	gotID, gotSecret, ok := splitRefreshToken(id.String() + "." + secret)
+	if !ok || gotID != id || gotSecret != secret {
+		t.Fatalf("split = %v %q %v", gotID, gotSecret, ok)
+	}
+	for _, bad := range []string{"", secret, "not-a-uuid." + secret, id.String() + "."} {
+		if _, _, ok := splitRefreshToken(bad); ok {
+			t.Errorf("%q parsed as a refresh token", bad)
+		}
+	}
+}
+
+func TestAccessTokenClaims(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	keys, err := jwtkeys.Open(jwtkeys.DefaultConfig(jwtkeys.AlgEdDSA, t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(nil, keys, DefaultConfig())
+	s.now = func() time.Time { return now }
+	sess := &models.Session{ID: uuid.New(), CustomerID: uuid.New(), Scope: "accounts:read accounts:trade", Role: models.RoleSupport}
+
+	signed, expiresAt, err := s.accessToken(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiresAt.Equal(now.Add(15 * time.Minute)) {
+		t.Fatalf("expiresAt = %v", expiresAt)
+	}
+	claims := &middleware.Claims{}
+	_, err = jwt.ParseWithClaims(signed, claims, func(token *jwt.Token) (interface{}, error) {
+		alg, key, ok := keys.VerificationKey(token.Header["kid"].(string))
+		if !ok || alg != token.Method.Alg() {
+			t.Fatalf("kid %v not resolvable", token.Header["kid"])
+		}
+		return key, nil
+	}, jwt.WithTimeFunc(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.SessionID != sess.ID.String() || claims.CustomerID != sess.CustomerID.String() || claims.ID == "" || claims.Scope != sess.Scope || claims.Role != "support" {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	again, _, _ := s.accessToken(sess)
+	if again == signed {
+		t.Fatal("jti not unique per token")
+	}
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	s, now := newTestService(t)
+	first, err := s.Start(uuid.New(), models.RoleCustomer, []string{middleware.ScopeAccountsRead}, Device{UserAgent: "app/1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(time.Hour)
+	second, err := s.Refresh(first.RefreshToken, Device{IPAddress: "10.0.0.7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.SessionID != first.SessionID || second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatalf("refresh did not rotate: %+v", second)
+	}
+	third, err := s.Refresh(second.RefreshToken, Device{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token rotated out by the last refresh kills the session, including
+	// the token that replaced it.
+	if _, err := s.Refresh(second.RefreshToken, Device{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused token: %v", err)
+	}
+	if _, err := s.Refresh(third.RefreshToken, Device{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("current token after reuse: %v", err)
+	}
+	if s.IsActive(first.SessionID.String()) {
+		t.Fatal("session still active after reuse")
+	}
+
+	for _, bad := range []string{"", "garbage", uuid.NewString() + ".secret", first.SessionID.String() + ".secret"} {
+		if _, err := s.Refresh(bad, Device{}); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("refresh with %q: %v", bad, err)
+		}
+	}
+}
+
+func TestRefreshAfterExpiry(t *testing.T) {
+	s, now := newTestService(t)
+	tokens, err := s.Start(uuid.New(), models.RoleCustomer, nil, Device{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(s.cfg.RefreshTTL)
+	if _, err := s.Refresh(tokens.RefreshToken, Device{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expired session: %v", err)
+	}
+	if s.IsActive(tokens.SessionID.String()) {
+		t.Fatal("expired session reported active")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s, _ := newTestService(t)
+	customer := uuid.New()
+	phone, _ := s.Start(customer, models.RoleCustomer, nil, Device{})
+	laptop, _ := s.Start(customer, models.RoleCustomer, nil, Device{})
+	tablet, _ := s.Start(customer, models.RoleCustomer, nil, Device{})
+	stranger, _ := s.Start(uuid.New(), models.RoleCustomer, nil, Device{})
+	for _, tk := range []*Tokens{phone, laptop, tablet, stranger} {
+		if !s.IsActive(tk.SessionID.String()) { // cached from here on
+			t.Fatalf("new session %s inactive", tk.SessionID)
+		}
+	}
+	if s.IsActive("not-a-uuid") || s.IsActive(uuid.NewString()) {
+		t.Fatal("unknown session reported active")
+	}
+
+	if err := s.Revoke(uuid.New(), phone.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking another customer's session: %v", err)
+	}
+	if err := s.Revoke(customer, phone.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsActive(phone.SessionID.String()) {
+		t.Fatal("revoked session still active")
+	}
+	if err := s.Revoke(customer, phone.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking twice: %v", err)
+	}
+	if _, err := s.Refresh(phone.RefreshToken, Device{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("refresh after revoke: %v", err)
+	}
+
+	if err := s.RevokeOthers(customer, laptop.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsActive(laptop.SessionID.String()) || s.IsActive(tablet.SessionID.String()) {
+		t.Fatal("RevokeOthers did not keep only the current session")
+	}
+	if err := s.RevokeAll(customer); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsActive(laptop.SessionID.String()) || !s.IsActive(stranger.SessionID.String()) {
+		t.Fatal("RevokeAll did not end exactly the customer's sessions")
+	}
+	if list, err := s.List(customer); err != nil || len(list) != 0 {
+		t.Fatalf("sessions after RevokeAll = %v, %v", list, err)
+	}
+}
@@ -0,0 +1,227 @@
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"weriKana/models"
+)
+
+const (
+	Issuer            = "BankRoll"
+	RecoveryCodeCount = 10
+	MaxAttempts       = 5 // failed verifications before lockout
+	LockoutPeriod     = 15 * time.Minute
+)
+
+var (
+	ErrAlreadyEnrolled = errors.New("authenticator already enrolled")
+	ErrNotEnrolled     = errors.New("authenticator not enrolled")
+	ErrInvalidCode     = errors.New("invalid authenticator code")
+	ErrLocked          = errors.New("too many authenticator attempts, try again later")
+)
+
+// Service manages per-customer TOTP enrollments
+type Service struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, now: time.Now}
+}
+
+// Enroll starts (or restarts) an unconfirmed enrollment and returns the secret
+// and provisioning URI. A confirmed enrollment must not be silently replaced.
+func (s *Service) Enroll(customerID uuid.UUID, account string) (secret, uri string, err error) {
+	secret, err = GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.CustomerTOTP
+		err := tx.Where("customer_id = ?", customerID).First(&existing).Error
+		switch {
+		case err == nil && existing.ConfirmedAt != nil:
+			return ErrAlreadyEnrolled
+		case err == nil:
+			existing.Secret = models.EncryptedString(secret)
+			existing.LastUsedStep = 0
+			return tx.Save(&existing).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return tx.Create(&models.CustomerTOTP{
+				ID:         uuid.New(),
+				CustomerID: customerID,
+				Secret:     models.EncryptedString(secret),
+			}).Error
+		default:
+			return err
+		}
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return secret, ProvisioningURI(Issuer, account, secret), nil
+}
+
+// Confirm activates an enrollment with a first valid code, switches the
+// customer's policy to TOTP and returns fresh recovery codes (shown once).
+func (s *Service) Confirm(customerID uuid.UUID, code string) ([]string, error) {
+	var recovery []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		enrollment, err := lockEnrollment(tx, customerID)
+		if err != nil {
+			return err
+		}
+		if enrollment.ConfirmedAt != nil {
+			return ErrAlreadyEnrolled
+		}
+		now := s.now()
+		step, ok, err := Validate(enrollment.Secret.String(), code, now)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidCode
+		}
+		enrollment.ConfirmedAt = &now
+		enrollment.LastUsedStep = step
+		if err := tx.Save(enrollment).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("customer_id = ?", customerID).Delete(&models.TOTPRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for i := 0; i < RecoveryCodeCount; i++ {
+			rc, err := generateRecoveryCode()
+			if err != nil {
+				return err
+			}
+			recovery = append(recovery, rc)
+			if err := tx.Create(&models.TOTPRecoveryCode{
+				ID:         uuid.New(),
+				CustomerID: customerID,
+				CodeHash:   hashRecoveryCode(rc),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Customer{}).Where("id = ?", customerID).
+			Update("second_factor", models.SecondFactorTOTP).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recovery, nil
+}
+
+// Enrolled reports whether the customer has a confirmed enrollment
+func (s *Service) Enrolled(customerID uuid.UUID) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.CustomerTOTP{}).
+		Where("customer_id = ? AND confirmed_at IS NOT NULL", customerID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Verify accepts a current authenticator code or an unused recovery code.
+// Each TOTP step and each recovery code can be used only once. Every failure
+// counts towards MaxAttempts, after which the customer is locked out of both.
+func (s *Service) Verify(customerID uuid.UUID, code string) error {
+	code = strings.TrimSpace(code)
+	var result error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		enrollment, err := lockEnrollment(tx, customerID)
+		if err != nil {
+			return err
+		}
+		if enrollment.ConfirmedAt == nil {
+			return ErrNotEnrolled
+		}
+		now := s.now()
+		if enrollment.LockedUntil != nil && now.Before(*enrollment.LockedUntil) {
+			result = ErrLocked
+			return nil
+		}
+		ok, err := useCode(tx, enrollment, code, now)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if enrollment.Attempts == 0 {
+				return nil
+			}
+			return tx.Model(enrollment).Updates(map[string]interface{}{"attempts": 0, "locked_until": nil}).Error
+		}
+
+		// The failure is committed, so result carries the error out instead
+		result = ErrInvalidCode
+		updates := map[string]interface{}{"attempts": enrollment.Attempts + 1}
+		if enrollment.Attempts+1 >= MaxAttempts {
+			updates["attempts"] = 0
+			updates["locked_until"] = now.Add(LockoutPeriod)
+			result = ErrLocked
+		}
+		return tx.Model(enrollment).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// useCode consumes code if it is a fresh TOTP step or an unused recovery code
+func useCode(tx *gorm.DB, enrollment *models.CustomerTOTP, code string, now time.Time) (bool, error) {
+	if len(code) == Digits {
+		step, ok, err := Validate(enrollment.Secret.String(), code, now)
+		if err != nil || !ok || step <= enrollment.LastUsedStep {
+			return false, err
+		}
+		return true, tx.Model(enrollment).Update("last_used_step", step).Error
+	}
+
+	res := tx.Model(&models.TOTPRecoveryCode{}).
+		Where("customer_id = ? AND code_hash = ? AND used_at IS NULL", enrollment.CustomerID, hashRecoveryCode(code)).
+		Update("used_at", now)
+	return res.RowsAffected > 0, res.Error
+}
+
+func lockEnrollment(tx *gorm.DB, customerID uuid.UUID) (*models.CustomerTOTP, error) {
+	var enrollment models.CustomerTOTP
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("customer_id = ?", customerID).
+		First(&enrollment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// generateRecoveryCode returns "xxxxx-xxxxx" (50 random bits, base32 lowercase)
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 7)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(b32.EncodeToString(raw))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+// hashRecoveryCode normalizes case and dashes before hashing
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,136 @@
+package totp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"weriKana/internal/testdb"
+	"weriKana/models"
+	"weriKana/service/keymgmt"
+)
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*Service, *time.Time) {
+	t.Helper()
+	kms := keymgmt.NewLocalKMS()
+	if _, err := kms.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	models.SetFieldEncryption(keymgmt.NewEnvelope(kms))
+	t.Cleanup(func() { models.SetFieldEncryption(nil) })
+
+	db := testdb.Open(t, &models.Customer{}, &models.CustomerTOTP{}, &models.TOTPRecoveryCode{})
+	now := testNow
+	s := NewService(db)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+// enroll takes a customer through Enroll and Confirm, returning the secret
+// and recovery codes
+func enroll(t *testing.T, s *Service, customer uuid.UUID) (string, []string) {
+	t.Helper()
+	secret, uri, err := s.Enroll(customer, "+254712345678")
+	if err != nil || uri == "" {
+		t.Fatalf("enroll = %q, %v", uri, err)
+	}
+	code, err := Code(secret, s.now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery, err := s.Confirm(customer, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovery) != RecoveryCodeCount {
+		t.Fatalf("recovery codes = %v", recovery)
+	}
+	return secret, recovery
+}
+
+func TestEnrollConfirmVerify(t *testing.T) {
+	s, now := newTestService(t)
+	customer := uuid.New()
+
+	if err := s.Verify(customer, "123456"); !errors.Is(err, ErrNotEnrolled) {
+		t.Fatalf("verify before enrolling: %v", err)
+	}
+	secret, _ := enroll(t, s, customer)
+	if ok, err := s.Enrolled(customer); !ok || err != nil {
+		t.Fatalf("enrolled = %v, %v", ok, err)
+	}
+	if _, _, err := s.Enroll(customer, "+254712345678"); !errors.Is(err, ErrAlreadyEnrolled) {
+		t.Fatalf("re-enrolling: %v", err)
+	}
+
+	// The step used to confirm cannot be replayed; the next one is accepted once.
+	code, _ := Code(secret, *now)
+	if err := s.Verify(customer, code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("confirmation code replayed: %v", err)
+	}
+	*now = now.Add(Period * time.Second)
+	code, _ = Code(secret, *now)
+	if err := s.Verify(customer, code); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(customer, code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("code replayed: %v", err)
+	}
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	s, _ := newTestService(t)
+	customer := uuid.New()
+	_, recovery := enroll(t, s, customer)
+
+	if err := s.Verify(customer, "  "+recovery[0]+" "); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(customer, recovery[0]); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("recovery code reused: %v", err)
+	}
+	if err := s.Verify(uuid.New(), recovery[1]); !errors.Is(err, ErrNotEnrolled) {
+		t.Fatalf("another customer's recovery code: %v", err)
+	}
+	if err := s.Verify(customer, recovery[1]); err != nil {
+		t.Fatalf("unused recovery code: %v", err)
+	}
+}
+
+func TestVerifyLockout(t *testing.T) {
+	s, now := newTestService(t)
+	customer := uuid.New()
+	secret, recovery := enroll(t, s, customer)
+
+	for i := 1; i < MaxAttempts; i++ {
+		if err := s.Verify(customer, "aaaaa-aaaaa"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+	if err := s.Verify(customer, "aaaaa-aaaaa"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("final attempt: %v", err)
+	}
+
+	// Neither factor works while locked, and the recovery code is not spent.
+	*now = now.Add(Period * time.Second)
+	code, _ := Code(secret, *now)
+	if err := s.Verify(customer, code); !errors.Is(err, ErrLocked) {
+		t.Fatalf("authenticator code while locked: %v", err)
+	}
+	if err := s.Verify(customer, recovery[0]); !errors.Is(err, ErrLocked) {
+		t.Fatalf("recovery code while locked: %v", err)
+	}
+
+	*now = now.Add(LockoutPeriod)
+	code, _ = Code(secret, *now)
+	if err := s.Verify(customer, code); err != nil {
+		t.Fatalf("after the lockout: %v", err)
+	}
+	if err := s.Verify(customer, recovery[0]); err != nil {
+		t.Fatalf("recovery code after the lockout: %v", err)
+	}
+}
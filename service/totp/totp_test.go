@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B, SHA-1 column (8 digits)
+func TestHOTPRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, want := range vectors {
+		if got := hotp(key, uint64(unix/Period), 8); got != want {
+			t.Errorf("T=%d: got %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestCodeAndValidate(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	at := time.Unix(1111111109, 0)
+
+	code, err := Code(secret, at)
+	if err != nil || code != "081804" {
+		t.Fatalf("Code = %q, %v", code, err)
+	}
+
+	for _, drift := range []time.Duration{-Period * time.Second, 0, Period * time.Second} {
+		step, ok, err := Validate(secret, code, at.Add(drift))
+		if err != nil || !ok || step != Step(at) {
+			t.Errorf("drift %v: step=%d ok=%v err=%v", drift, step, ok, err)
+		}
+	}
+	if _, ok, _ := Validate(secret, code, at.Add(2*Period*time.Second)); ok {
+		t.Error("code accepted two steps late")
+	}
+	if _, ok, _ := Validate(secret, "12345", at); ok {
+		t.Error("short code accepted")
+	}
+	if _, _, err := Validate("not base32!", code, at); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := decodeSecret(secret); err != nil || len(key) != SecretSize {
+		t.Fatalf("secret %q decodes to %d bytes, %v", secret, len(key), err)
+	}
+
+	uri := ProvisioningURI("BankRoll", "+254712345678", secret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || !strings.HasPrefix(u.Path, "/BankRoll:+254712345678") {
+		t.Fatalf("unexpected URI %s", uri)
+	}
+	if q := u.Query(); q.Get("secret") != secret || q.Get("issuer") != "BankRoll" || q.Get("digits") != "6" {
+		t.Fatalf("unexpected query %v", q)
+	}
+}